@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+)
+
+// accountEmailTimeout bounds sending a verification or password reset email.
+const accountEmailTimeout = 30 * time.Second
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ConfirmPasswordResetRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=6"`
+}
+
+func (s *server) VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var req VerifyEmailRequest
+	if err := readJSON(w, r, &req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := Validate.Struct(req); err != nil {
+		errorResponse(w, http.StatusBadRequest, formatValidationErrors(err))
+		return
+	}
+
+	_, err := s.store.VerifyEmailTx(r.Context(), hashToken(req.Token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorResponse(w, http.StatusBadRequest, "Invalid or expired token")
+			return
+		}
+		s.logger.Error("Error verifying email", err)
+		errorResponse(w, http.StatusInternalServerError, "Error verifying email")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, nil, "Email verified successfully")
+}
+
+func (s *server) ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if user.EmailVerifiedAt.Valid {
+		errorResponse(w, http.StatusConflict, "Email is already verified")
+		return
+	}
+
+	if err := s.sendVerificationEmail(r.Context(), user); err != nil {
+		s.logger.Error("Error creating verification token", err)
+		errorResponse(w, http.StatusInternalServerError, "Error creating verification token")
+		return
+	}
+
+	jsonResponse(w, http.StatusAccepted, nil, "Verification email sent")
+}
+
+func (s *server) RequestPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	var req PasswordResetRequest
+	if err := readJSON(w, r, &req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := Validate.Struct(req); err != nil {
+		errorResponse(w, http.StatusBadRequest, formatValidationErrors(err))
+		return
+	}
+
+	// the response is the same whether or not the account exists, so it can't be used to find accounts
+	const message = "If the account exists, a password reset email has been sent"
+	user, err := s.store.FindUserByEmail(r.Context(), req.Email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			jsonResponse(w, http.StatusAccepted, nil, message)
+			return
+		}
+		s.logger.Error("Error finding user", err)
+		errorResponse(w, http.StatusInternalServerError, "Error requesting password reset")
+		return
+	}
+
+	token, expiresAt, err := s.issueAccountToken(r.Context(), user, db.AccountTokenPasswordReset, s.config.PASSWORD_RESET_TTL)
+	if err != nil {
+		s.logger.Error("Error creating password reset token", err)
+		errorResponse(w, http.StatusInternalServerError, "Error requesting password reset")
+		return
+	}
+	s.sendAccountEmail(func(ctx context.Context) error {
+		return s.accountMailer.SendPasswordReset(ctx, user.Email, token, expiresAt)
+	})
+
+	jsonResponse(w, http.StatusAccepted, nil, message)
+}
+
+func (s *server) ConfirmPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	var req ConfirmPasswordResetRequest
+	if err := readJSON(w, r, &req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := Validate.Struct(req); err != nil {
+		errorResponse(w, http.StatusBadRequest, formatValidationErrors(err))
+		return
+	}
+
+	hashedPassword, err := helpers.HashPasswordBase64(req.Password)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Error hashing password")
+		return
+	}
+
+	// every session is revoked, so whoever knew the old password is signed out
+	result, err := s.store.ResetPasswordTx(r.Context(), db.ResetPasswordTxParams{
+		HashedToken:    hashToken(req.Token),
+		HashedPassword: hashedPassword,
+		IpAddress:      clientIP(r),
+		UserAgent:      truncate(r.UserAgent(), maxUserAgentLength),
+		DenyUntil:      time.Now().Add(helpers.AccessTokenTTL),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorResponse(w, http.StatusBadRequest, "Invalid or expired token")
+			return
+		}
+		s.logger.Error("Error resetting password", err)
+		errorResponse(w, http.StatusInternalServerError, "Error resetting password")
+		return
+	}
+	for _, sessionId := range result.SessionIDs {
+		s.denylist.add(db.DeniedTokenKindSession, sessionId.String(), time.Now().Add(helpers.AccessTokenTTL))
+	}
+
+	s.logger.Infow("Password reset", "user_id", result.User.ID, "sessions_revoked", len(result.SessionIDs))
+
+	jsonResponse(w, http.StatusOK, nil, "Password reset successfully")
+}
+
+// requireVerifiedEmail stops users who haven't verified their email when REQUIRE_VERIFIED_EMAIL is set.
+func (s *server) requireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.config.REQUIRE_VERIFIED_EMAIL {
+			next.ServeHTTP(w, r)
+			return
+		}
+		user, ok := UserFromContext(r)
+		if !ok {
+			errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		if !user.EmailVerifiedAt.Valid {
+			errorResponse(w, http.StatusForbidden, "Verify your email address first")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sendVerificationEmail issues a verification token for the user and emails it.
+func (s *server) sendVerificationEmail(ctx context.Context, user db.User) error {
+	token, expiresAt, err := s.issueAccountToken(ctx, user, db.AccountTokenEmailVerification, s.config.EMAIL_VERIFICATION_TTL)
+	if err != nil {
+		return err
+	}
+	s.sendAccountEmail(func(ctx context.Context) error {
+		return s.accountMailer.SendVerification(ctx, user.Email, token, expiresAt)
+	})
+	return nil
+}
+
+// issueAccountToken creates a single-use token for the user, replacing earlier ones with the same purpose.
+// Only its hash is stored.
+func (s *server) issueAccountToken(ctx context.Context, user db.User, purpose string, ttl time.Duration) (string, time.Time, error) {
+	token, err := helpers.GenerateSecureToken(32)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	issued, err := s.store.IssueAccountTokenTx(ctx, db.CreateAccountTokenParams{
+		HashedToken: hashToken(token),
+		UserID:      user.ID,
+		Purpose:     purpose,
+		ExpiresAt:   time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, issued.ExpiresAt, nil
+}
+
+// sendAccountEmail sends an account email in the background, so how long a response takes
+// doesn't depend on the mail server.
+func (s *server) sendAccountEmail(send func(ctx context.Context) error) {
+	if s.accountMailer == nil {
+		s.logger.Warn("SMTP_HOST is not set, account email not sent")
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), accountEmailTimeout)
+		defer cancel()
+		if err := send(ctx); err != nil {
+			s.logger.Errorw("Error sending account email", "error", err)
+		}
+	}()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/trenchesdeveloper/csv-reporter/config"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+)
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	verified := db.User{EmailVerifiedAt: sql.NullTime{Time: time.Now(), Valid: true}}
+
+	testCases := []struct {
+		name    string
+		require bool
+		user    db.User
+		status  int
+	}{
+		{"verified", true, verified, http.StatusOK},
+		{"unverified", true, db.User{}, http.StatusForbidden},
+		{"not required", false, db.User{}, http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &server{config: &config.AppConfig{REQUIRE_VERIFIED_EMAIL: tc.require}}
+			handler := s.requireVerifiedEmail(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req = req.WithContext(context.WithValue(req.Context(), "user", tc.user))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			require.Equal(t, tc.status, rec.Code)
+		})
+	}
+}
+
+func TestDeliverToRequiresVerifiedEmail(t *testing.T) {
+	s := &server{config: &config.AppConfig{}}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"report_type":"monsters","deliver_to":["someone@example.com"]}`))
+	req = req.WithContext(context.WithValue(req.Context(), "user", db.User{}))
+	rec := httptest.NewRecorder()
+	s.CreateReportHandler(rec, req)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+	"github.com/trenchesdeveloper/csv-reporter/reports"
+)
+
+// reportStatuses are the statuses GetStatus returns, which the admin report list filters by
+var reportStatuses = []string{"requested", "processing", "completed", "failed", "cancelled"}
+
+type UpdateUserRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=user admin auditor"`
+}
+
+type AdminUserResponse struct {
+	ID            uuid.UUID `json:"id"`
+	Email         string    `json:"email"`
+	Role          string    `json:"role"`
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type AdminReportResponse struct {
+	UserID uuid.UUID `json:"user_id"`
+	ReportResponse
+}
+
+type QueueStatusResponse struct {
+	Queue   reports.QueueDepth     `json:"queue"`
+	Reports []ActiveReportsSummary `json:"reports"`
+}
+
+// ActiveReportsSummary counts the reports waiting for or being built by a worker.
+// OldestAt is when the oldest of them was requested or started, so stuck reports stand out.
+type ActiveReportsSummary struct {
+	Status   string    `json:"status"`
+	Count    int64     `json:"count"`
+	OldestAt time.Time `json:"oldest_at"`
+}
+
+// requireRole only lets users with one of the roles through. It reads the role from the user
+// the auth middleware loaded rather than the token claim, so a role change applies at once.
+func requireRole(roles ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := UserFromContext(r)
+			if !ok {
+				errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+			if !slices.Contains(roles, user.Role) {
+				errorResponse(w, http.StatusForbidden, "Forbidden")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (s *server) AdminListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := readPagination(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	users, err := s.store.ListUsers(r.Context(), db.ListUsersParams{
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		s.logger.Error("Error listing users", err)
+		errorResponse(w, http.StatusInternalServerError, "Error listing users")
+		return
+	}
+
+	response := make([]AdminUserResponse, 0, len(users))
+	for _, user := range users {
+		response = append(response, newAdminUserResponse(user))
+	}
+
+	jsonResponse(w, http.StatusOK, response, "Users retrieved successfully")
+}
+
+func (s *server) AdminUpdateUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	var req UpdateUserRoleRequest
+	if err := readJSON(w, r, &req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := Validate.Struct(req); err != nil {
+		errorResponse(w, http.StatusBadRequest, formatValidationErrors(err))
+		return
+	}
+
+	admin, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	userId, err := uuid.Parse(chi.URLParam(r, "userId"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	// another admin has to do it, so the last admin can't lock everyone out
+	if userId == admin.ID {
+		errorResponse(w, http.StatusBadRequest, "You can't change your own role")
+		return
+	}
+
+	// a lowered role revokes the user's sessions, so no token keeps the old one
+	result, err := s.store.ChangeUserRoleTx(r.Context(), db.ChangeUserRoleTxParams{
+		UserID:    userId,
+		Role:      req.Role,
+		ChangedBy: admin.ID,
+		IpAddress: clientIP(r),
+		UserAgent: truncate(r.UserAgent(), maxUserAgentLength),
+		DenyUntil: time.Now().Add(helpers.AccessTokenTTL),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorResponse(w, http.StatusNotFound, "User not found")
+			return
+		}
+		s.logger.Error("Error changing user role", err)
+		errorResponse(w, http.StatusInternalServerError, "Error changing user role")
+		return
+	}
+	for _, sessionId := range result.SessionIDs {
+		s.denylist.add(db.DeniedTokenKindSession, sessionId.String(), time.Now().Add(helpers.AccessTokenTTL))
+	}
+
+	s.logger.Infow("User role changed", "user_id", userId, "role", req.Role, "changed_by", admin.ID)
+
+	jsonResponse(w, http.StatusOK, newAdminUserResponse(result.User), "User role updated successfully")
+}
+
+// AdminUnlockUserHandler lifts the sign-in lockout and delay of an account. Lockouts of IPs
+// expire on their own.
+func (s *server) AdminUnlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	admin, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	userId, err := uuid.Parse(chi.URLParam(r, "userId"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	user, err := s.store.FindUserById(r.Context(), userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorResponse(w, http.StatusNotFound, "User not found")
+			return
+		}
+		s.logger.Error("Error finding user", err)
+		errorResponse(w, http.StatusInternalServerError, "Error unlocking user")
+		return
+	}
+
+	unlocked, err := s.store.DeleteLoginThrottle(r.Context(), db.DeleteLoginThrottleParams{
+		Scope: db.LoginThrottleAccount,
+		Key:   loginAccountKey(user.Email),
+	})
+	if err != nil {
+		s.logger.Error("Error unlocking user", err)
+		errorResponse(w, http.StatusInternalServerError, "Error unlocking user")
+		return
+	}
+
+	// unlocking an account that wasn't throttled changes nothing and isn't recorded
+	if unlocked > 0 {
+		details, err := json.Marshal(map[string]any{"unlocked_by": admin.ID})
+		if err != nil {
+			s.logger.Error("Error encoding unlock details", err)
+			errorResponse(w, http.StatusInternalServerError, "Error unlocking user")
+			return
+		}
+		if _, err := s.store.CreateSecurityEvent(r.Context(), db.CreateSecurityEventParams{
+			UserID:    uuid.NullUUID{UUID: user.ID, Valid: true},
+			EventType: db.SecurityEventLoginUnlocked,
+			IpAddress: clientIP(r),
+			UserAgent: truncate(r.UserAgent(), maxUserAgentLength),
+			Details:   details,
+		}); err != nil {
+			s.logger.Error("Error recording unlock", err)
+			errorResponse(w, http.StatusInternalServerError, "Error unlocking user")
+			return
+		}
+		s.logger.Infow("User unlocked", "user_id", user.ID, "unlocked_by", admin.ID)
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]bool{"was_locked": unlocked > 0}, "User unlocked successfully")
+}
+
+func (s *server) AdminListReportsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := readPagination(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	arg := db.ListAllReportsParams{Limit: limit, Offset: offset}
+	if value := r.URL.Query().Get("user_id"); value != "" {
+		userId, err := uuid.Parse(value)
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Invalid user ID")
+			return
+		}
+		arg.UserID = uuid.NullUUID{UUID: userId, Valid: true}
+	}
+	if value := r.URL.Query().Get("status"); value != "" {
+		if !slices.Contains(reportStatuses, value) {
+			errorResponse(w, http.StatusBadRequest, "Invalid status")
+			return
+		}
+		arg.Status = sql.NullString{String: value, Valid: true}
+	}
+
+	reportList, err := s.store.ListAllReports(r.Context(), arg)
+	if err != nil {
+		s.logger.Error("Error listing reports", err)
+		errorResponse(w, http.StatusInternalServerError, "Error listing reports")
+		return
+	}
+
+	response := make([]AdminReportResponse, 0, len(reportList))
+	for _, report := range reportList {
+		response = append(response, AdminReportResponse{
+			UserID:         report.UserID,
+			ReportResponse: newReportResponse(report),
+		})
+	}
+
+	jsonResponse(w, http.StatusOK, response, "Reports retrieved successfully")
+}
+
+func (s *server) AdminQueueStatusHandler(w http.ResponseWriter, r *http.Request) {
+	depth, err := reports.GetQueueDepth(r.Context(), s.sqsClient, s.config.SQS_QUEUE)
+	if err != nil {
+		s.logger.Error("Error getting queue depth", err)
+		errorResponse(w, http.StatusInternalServerError, "Error getting queue depth")
+		return
+	}
+
+	active, err := s.store.CountActiveReports(r.Context())
+	if err != nil {
+		s.logger.Error("Error counting active reports", err)
+		errorResponse(w, http.StatusInternalServerError, "Error counting active reports")
+		return
+	}
+
+	response := QueueStatusResponse{Queue: depth, Reports: make([]ActiveReportsSummary, 0, len(active))}
+	for _, row := range active {
+		response.Reports = append(response.Reports, ActiveReportsSummary{
+			Status:   row.Status,
+			Count:    row.Count,
+			OldestAt: row.OldestAt,
+		})
+	}
+
+	jsonResponse(w, http.StatusOK, response, "Queue status retrieved successfully")
+}
+
+func newAdminUserResponse(user db.User) AdminUserResponse {
+	return AdminUserResponse{
+		ID:            user.ID,
+		Email:         user.Email,
+		Role:          user.Role,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		CreatedAt:     user.CreatedAt,
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+)
+
+func TestRequireRole(t *testing.T) {
+	testCases := []struct {
+		name   string
+		user   *db.User
+		status int
+	}{
+		{"admin", &db.User{Role: db.UserRoleAdmin}, http.StatusOK},
+		{"auditor", &db.User{Role: db.UserRoleAuditor}, http.StatusOK},
+		{"user", &db.User{Role: db.UserRoleUser}, http.StatusForbidden},
+		{"signed out", nil, http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := requireRole(db.UserRoleAdmin, db.UserRoleAuditor)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.user != nil {
+				req = req.WithContext(context.WithValue(req.Context(), "user", *tc.user))
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			require.Equal(t, tc.status, rec.Code)
+		})
+	}
+}
@@ -12,13 +12,16 @@ import (
 	_ "github.com/lib/pq"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"github.com/trenchesdeveloper/csv-reporter/helpers"
+	"github.com/trenchesdeveloper/csv-reporter/mailer"
 	"go.uber.org/zap"
 
 	"net/http"
+	"net/netip"
 	"time"
 
 	"github.com/trenchesdeveloper/csv-reporter/config"
 	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"github.com/trenchesdeveloper/csv-reporter/reports"
 )
 
 type server struct {
@@ -28,40 +31,180 @@ type server struct {
 	tokenManager    *helpers.JwtManager
 	sqsClient       *sqs.Client
 	presignedClient *s3.PresignClient
+	s3Client        *s3.Client
+	events          *reportEventHub
+	lozClient       *reports.LozClient
+	denylist        *tokenDenylist
+	accountMailer   *mailer.AccountMailer
+	trustedProxies  []netip.Prefix
 }
 
+// requestTimeout bounds regular requests; event streams and downloads are exempt.
+const requestTimeout = 60 * time.Second
+
 func (s *server) mount() http.Handler {
 	r := chi.NewRouter()
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Logger)
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(realIP(s.trustedProxies))
+
+	// public keys for services that verify our tokens
+	r.Get("/.well-known/jwks.json", s.JWKSHandler)
 
-	r.Use(middleware.Timeout(60 * time.Second))
 	r.Route("/api/v1", func(r chi.Router) {
-		//r.Get("/health", s.healthCheckHandler)
-		docsURL := fmt.Sprintf("%s/swagger/doc.json", s.config.SERVER_PORT)
-		r.Get("/swagger/*", httpSwagger.Handler(
-			httpSwagger.URL(docsURL), //The url pointing to API definition
-		))
-
-		// ping route
-		r.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
-			w.Write([]byte("pong"))
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Timeout(requestTimeout))
+
+			//r.Get("/health", s.healthCheckHandler)
+			docsURL := fmt.Sprintf("%s/swagger/doc.json", s.config.SERVER_PORT)
+			r.Get("/swagger/*", httpSwagger.Handler(
+				httpSwagger.URL(docsURL), //The url pointing to API definition
+			))
+
+			// ping route
+			r.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("pong"))
+			})
+
+			// Public routes
+			r.Route("/auth", func(r chi.Router) {
+				r.Post("/signup", s.SignupHandler)
+				r.Post("/login", s.SigninHandler)
+				r.Post("/refresh", s.RefreshTokenHandler)
+				r.Post("/verify-email", s.VerifyEmailHandler)
+				r.Post("/password-reset", s.RequestPasswordResetHandler)
+				r.Post("/password-reset/confirm", s.ConfirmPasswordResetHandler)
+				r.Post("/login/mfa", s.MfaLoginHandler)
+
+				r.Group(func(r chi.Router) {
+					r.Use(NewAuthMiddleware(s.tokenManager, s.store, s.denylist, s.logger))
+					r.Post("/logout", s.LogoutHandler)
+					r.Post("/logout-all", s.LogoutAllHandler)
+					r.Post("/verify-email/resend", s.ResendVerificationHandler)
+					r.Post("/mfa/enroll", s.EnrollMfaHandler)
+					r.Post("/mfa/confirm", s.ConfirmMfaHandler)
+					r.Post("/mfa/disable", s.DisableMfaHandler)
+					r.Post("/mfa/recovery-codes", s.RegenerateRecoveryCodesHandler)
+				})
+			})
+
+			// API keys can't manage API keys, so a leaked key can't mint more
+			r.Route("/api-keys", func(r chi.Router) {
+				r.Use(NewAuthMiddleware(s.tokenManager, s.store, s.denylist, s.logger))
+				r.Post("/", s.CreateAPIKeyHandler)
+				r.Get("/", s.ListAPIKeysHandler)
+				r.Delete("/{apiKeyId}", s.RevokeAPIKeyHandler)
+			})
+
+			// organizations route
+			r.Route("/organizations", func(r chi.Router) {
+				r.Use(NewAuthMiddleware(s.tokenManager, s.store, s.denylist, s.logger))
+				r.Post("/", s.CreateOrganizationHandler)
+				r.Get("/", s.ListOrganizationsHandler)
+				r.Get("/invitations", s.ListUserInvitationsHandler)
+				r.Post("/invitations/{invitationId}/accept", s.AcceptInvitationHandler)
+				r.Delete("/invitations/{invitationId}", s.DeclineInvitationHandler)
+				r.Get("/{organizationId}/members", s.ListOrganizationMembersHandler)
+				r.Post("/{organizationId}/invitations", s.InviteOrganizationMemberHandler)
+				r.Get("/{organizationId}/invitations", s.ListOrganizationInvitationsHandler)
+				r.Delete("/{organizationId}/invitations/{invitationId}", s.RevokeOrganizationInvitationHandler)
+				r.Patch("/{organizationId}/members/{userId}", s.UpdateOrganizationMemberHandler)
+				r.Delete("/{organizationId}/members/{userId}", s.RemoveOrganizationMemberHandler)
+			})
+
+			// admin route, auditors can read everything but only admins change roles
+			r.Route("/admin", func(r chi.Router) {
+				r.Use(NewAuthMiddleware(s.tokenManager, s.store, s.denylist, s.logger))
+				r.Use(requireRole(db.UserRoleAdmin, db.UserRoleAuditor))
+				r.Get("/users", s.AdminListUsersHandler)
+				r.With(requireRole(db.UserRoleAdmin)).Patch("/users/{userId}/role", s.AdminUpdateUserRoleHandler)
+				r.With(requireRole(db.UserRoleAdmin)).Post("/users/{userId}/unlock", s.AdminUnlockUserHandler)
+				r.Get("/reports", s.AdminListReportsHandler)
+				r.Get("/queue", s.AdminQueueStatusHandler)
+			})
+
+			// sessions route
+			r.Route("/sessions", func(r chi.Router) {
+				r.Use(NewAuthMiddleware(s.tokenManager, s.store, s.denylist, s.logger))
+				r.Get("/", s.ListSessionsHandler)
+				r.Delete("/{sessionId}", s.RevokeSessionHandler)
+			})
+
+			// webhooks route
+			r.Route("/webhooks", func(r chi.Router) {
+				r.Use(allowAPIKeys("webhooks"))
+				r.Use(NewAuthMiddleware(s.tokenManager, s.store, s.denylist, s.logger))
+				r.Post("/", s.CreateWebhookHandler)
+				r.Get("/", s.ListWebhooksHandler)
+				r.Delete("/{webhookId}", s.DeleteWebhookHandler)
+				r.Get("/deliveries", s.ListWebhookDeliveriesHandler)
+				r.Get("/deliveries/{deliveryId}", s.GetWebhookDeliveryHandler)
+				r.Post("/deliveries/{deliveryId}/redeliver", s.RedeliverWebhookHandler)
+			})
+
+			// templates route
+			r.Route("/templates", func(r chi.Router) {
+				r.Use(allowAPIKeys("templates"))
+				r.Use(NewAuthMiddleware(s.tokenManager, s.store, s.denylist, s.logger))
+				r.Post("/", s.CreateTemplateHandler)
+				r.Get("/", s.ListTemplatesHandler)
+				r.Get("/{templateId}", s.GetTemplateHandler)
+				r.Put("/{templateId}", s.UpdateTemplateHandler)
+				r.Delete("/{templateId}", s.DeleteTemplateHandler)
+			})
+
+			// destinations route
+			r.Route("/destinations", func(r chi.Router) {
+				r.Use(allowAPIKeys("destinations"))
+				r.Use(NewAuthMiddleware(s.tokenManager, s.store, s.denylist, s.logger))
+				r.Post("/", s.CreateDestinationHandler)
+				r.Get("/", s.ListDestinationsHandler)
+				r.Get("/{destinationId}", s.GetDestinationHandler)
+				r.Patch("/{destinationId}", s.UpdateDestinationHandler)
+				r.Delete("/{destinationId}", s.DeleteDestinationHandler)
+			})
+
+			// schedules route
+			r.Route("/schedules", func(r chi.Router) {
+				r.Use(allowAPIKeys("schedules"))
+				r.Use(NewAuthMiddleware(s.tokenManager, s.store, s.denylist, s.logger))
+				r.Post("/", s.CreateScheduleHandler)
+				r.Get("/", s.ListSchedulesHandler)
+				r.Get("/{scheduleId}", s.GetScheduleHandler)
+				r.Put("/{scheduleId}", s.UpdateScheduleHandler)
+				r.Delete("/{scheduleId}", s.DeleteScheduleHandler)
+			})
 		})
 
-		// Public routes
-		r.Route("/auth", func(r chi.Router) {
-			r.Post("/signup", s.SignupHandler)
-			r.Post("/login", s.SigninHandler)
-			r.Post("/refresh", s.RefreshTokenHandler)
+		// public share links stream the report, like downloads
+		r.Route("/shares", func(r chi.Router) {
+			r.Get("/{token}", s.GetShareLinkHandler)
+			r.Post("/{token}", s.RedeemShareHandler)
 		})
 
 		//reports route
 		r.Route("/reports", func(r chi.Router) {
-			r.Use(NewAuthMiddleware(s.tokenManager, s.store))
-			r.Post("/", s.CreateReportHandler)
-			r.Get("/{reportId}", s.GetReportHandler)
+			r.Use(allowAPIKeys("reports"))
+			r.Use(NewAuthMiddleware(s.tokenManager, s.store, s.denylist, s.logger))
+			// event streams stay open for the lifetime of the build
+			r.Get("/{reportId}/events", s.ReportEventsHandler)
+			// downloads stream large files
+			r.Get("/{reportId}/download", s.DownloadReportHandler)
+
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.Timeout(requestTimeout))
+				r.With(s.requireVerifiedEmail, s.idempotent).Post("/", s.CreateReportHandler)
+				r.Post("/preview", s.PreviewReportHandler)
+				r.Get("/{reportId}", s.GetReportHandler)
+				r.Delete("/{reportId}", s.DeleteReportHandler)
+				r.Post("/{reportId}/cancel", s.CancelReportHandler)
+				r.Post("/{reportId}/retry", s.RetryReportHandler)
+				r.Post("/{reportId}/shares", s.CreateShareHandler)
+				r.Get("/{reportId}/shares", s.ListSharesHandler)
+				r.Delete("/{reportId}/shares/{shareId}", s.RevokeShareHandler)
+				r.Get("/{reportId}/shares/{shareId}/redemptions", s.ListShareRedemptionsHandler)
+			})
 		})
 	})
 
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+	"go.uber.org/zap"
+)
+
+// apiKeyPrefix starts every API key so the auth middleware can tell keys from JWTs
+const apiKeyPrefix = "csvr_"
+
+// maxAPIKeyTTL bounds the optional expiry of a key
+const maxAPIKeyTTL = 365 * 24 * time.Hour
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=reports:read reports:write templates:read templates:write schedules:read schedules:write webhooks:read webhooks:write destinations:read destinations:write"`
+	// ExpiresIn is the lifetime of the key in seconds; keys without it never expire.
+	ExpiresIn int `json:"expires_in,omitempty" validate:"omitempty,min=3600,max=31536000"`
+}
+
+type APIKeyResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Key        string     `json:"key,omitempty"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// allowAPIKeys lets API keys use the routes of a resource. Reads need the <resource>:read
+// scope and everything else <resource>:write. Routes without it only accept signed-in users.
+func allowAPIKeys(resource string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), "api_key_resource", resource)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// apiKeyScope returns the scope a request on resource needs
+func apiKeyScope(resource string, method string) string {
+	if method == http.MethodGet || method == http.MethodHead {
+		return resource + ":read"
+	}
+	return resource + ":write"
+}
+
+// authenticateAPIKey checks an API key and returns the request context of its user.
+func authenticateAPIKey(w http.ResponseWriter, r *http.Request, store db.Store, logger *zap.SugaredLogger, key string) (context.Context, bool) {
+	resource, _ := r.Context().Value("api_key_resource").(string)
+	if resource == "" {
+		errorResponse(w, http.StatusForbidden, "API keys can't be used for this endpoint")
+		return nil, false
+	}
+
+	apiKey, err := store.GetApiKeyByHash(r.Context(), hashToken(key))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorResponse(w, http.StatusUnauthorized, "Invalid API key")
+			return nil, false
+		}
+		logger.Errorw("Error getting API key", "error", err)
+		errorResponse(w, http.StatusInternalServerError, "Error checking API key")
+		return nil, false
+	}
+	if apiKey.ExpiresAt.Valid && !apiKey.ExpiresAt.Time.After(time.Now()) {
+		errorResponse(w, http.StatusUnauthorized, "API key expired")
+		return nil, false
+	}
+
+	scope := apiKeyScope(resource, r.Method)
+	if !slices.Contains(apiKey.Scopes, scope) {
+		errorResponse(w, http.StatusForbidden, "API key is missing the "+scope+" scope")
+		return nil, false
+	}
+
+	user, err := store.FindUserById(r.Context(), apiKey.UserID)
+	if err != nil {
+		errorResponse(w, http.StatusUnauthorized, "User not found")
+		return nil, false
+	}
+
+	// last use is tracked to the minute, so most requests don't write
+	if err := store.TouchApiKey(r.Context(), apiKey.ID); err != nil {
+		logger.Errorw("Error tracking API key use", "api_key_id", apiKey.ID, "error", err)
+	}
+
+	ctx := context.WithValue(r.Context(), "user", user)
+	ctx = context.WithValue(ctx, "api_key", apiKey)
+	return ctx, true
+}
+
+// APIKeyFromContext returns the API key the request was made with, if any
+func APIKeyFromContext(r *http.Request) (db.ApiKey, bool) {
+	apiKey, ok := r.Context().Value("api_key").(db.ApiKey)
+	return apiKey, ok
+}
+
+func (s *server) CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateAPIKeyRequest
+	if err := readJSON(w, r, &req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := Validate.Struct(req); err != nil {
+		errorResponse(w, http.StatusBadRequest, formatValidationErrors(err))
+		return
+	}
+
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// the prefix identifies the key in lists and logs, the secret is only known to the caller
+	id, err := helpers.GenerateSecureToken(6)
+	if err != nil {
+		s.logger.Error("Error generating API key", err)
+		errorResponse(w, http.StatusInternalServerError, "Error creating API key")
+		return
+	}
+	secret, err := helpers.GenerateSecureToken(32)
+	if err != nil {
+		s.logger.Error("Error generating API key", err)
+		errorResponse(w, http.StatusInternalServerError, "Error creating API key")
+		return
+	}
+	prefix := apiKeyPrefix + id
+	key := prefix + "." + secret
+
+	var expiresAt sql.NullTime
+	if req.ExpiresIn > 0 {
+		ttl := min(time.Duration(req.ExpiresIn)*time.Second, maxAPIKeyTTL)
+		expiresAt = sql.NullTime{Time: time.Now().Add(ttl), Valid: true}
+	}
+
+	slices.Sort(req.Scopes)
+	apiKey, err := s.store.CreateApiKey(r.Context(), db.CreateApiKeyParams{
+		UserID:    user.ID,
+		Name:      req.Name,
+		Prefix:    prefix,
+		KeyHash:   hashToken(key),
+		Scopes:    slices.Compact(req.Scopes),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		s.logger.Error("Error creating API key", err)
+		errorResponse(w, http.StatusInternalServerError, "Error creating API key")
+		return
+	}
+
+	// the key is only returned once, when it is created
+	response := newAPIKeyResponse(apiKey)
+	response.Key = key
+	jsonResponse(w, http.StatusCreated, response, "API key created successfully")
+}
+
+func (s *server) ListAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	apiKeys, err := s.store.ListApiKeys(r.Context(), user.ID)
+	if err != nil {
+		s.logger.Error("Error listing API keys", err)
+		errorResponse(w, http.StatusInternalServerError, "Error listing API keys")
+		return
+	}
+
+	response := make([]APIKeyResponse, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		response = append(response, newAPIKeyResponse(apiKey))
+	}
+
+	jsonResponse(w, http.StatusOK, response, "API keys retrieved successfully")
+}
+
+func (s *server) RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	apiKeyId, err := uuid.Parse(chi.URLParam(r, "apiKeyId"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	revoked, err := s.store.RevokeApiKey(r.Context(), db.RevokeApiKeyParams{
+		UserID: user.ID,
+		ID:     apiKeyId,
+	})
+	if err != nil {
+		s.logger.Error("Error revoking API key", err)
+		errorResponse(w, http.StatusInternalServerError, "Error revoking API key")
+		return
+	}
+	if revoked == 0 {
+		errorResponse(w, http.StatusNotFound, "API key not found")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, nil, "API key revoked successfully")
+}
+
+func newAPIKeyResponse(apiKey db.ApiKey) APIKeyResponse {
+	response := APIKeyResponse{
+		ID:        apiKey.ID,
+		Name:      apiKey.Name,
+		Prefix:    apiKey.Prefix,
+		Scopes:    apiKey.Scopes,
+		CreatedAt: apiKey.CreatedAt,
+	}
+	if apiKey.ExpiresAt.Valid {
+		response.ExpiresAt = &apiKey.ExpiresAt.Time
+	}
+	if apiKey.LastUsedAt.Valid {
+		response.LastUsedAt = &apiKey.LastUsedAt.Time
+	}
+	return response
+}
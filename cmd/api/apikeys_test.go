@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/trenchesdeveloper/csv-reporter/config"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+	"go.uber.org/zap"
+)
+
+// apiKeyStore serves the queries API key authentication uses.
+type apiKeyStore struct {
+	db.Store
+	apiKeys map[string]db.ApiKey
+	user    db.User
+	touched int
+}
+
+func (s *apiKeyStore) GetApiKeyByHash(ctx context.Context, keyHash string) (db.ApiKey, error) {
+	apiKey, ok := s.apiKeys[keyHash]
+	if !ok {
+		return db.ApiKey{}, sql.ErrNoRows
+	}
+	return apiKey, nil
+}
+
+func (s *apiKeyStore) FindUserById(ctx context.Context, id uuid.UUID) (db.User, error) {
+	return s.user, nil
+}
+
+func (s *apiKeyStore) TouchApiKey(ctx context.Context, id uuid.UUID) error {
+	s.touched++
+	return nil
+}
+
+func TestAuthMiddlewareAPIKeys(t *testing.T) {
+	jwtManager := helpers.NewJwtManager(&config.AppConfig{AppName: "csv_reporter", JWT_SECRET: "secretToSecretMySecret"})
+	user := db.User{ID: uuid.New(), Email: "user@example.com"}
+
+	readKey := apiKeyPrefix + "read.secret"
+	expiredKey := apiKeyPrefix + "expired.secret"
+	store := &apiKeyStore{user: user, apiKeys: map[string]db.ApiKey{
+		hashToken(readKey): {ID: uuid.New(), UserID: user.ID, Scopes: []string{"reports:read"}},
+		hashToken(expiredKey): {
+			ID: uuid.New(), UserID: user.ID, Scopes: []string{"reports:read"},
+			ExpiresAt: sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true},
+		},
+	}}
+	denylist := newTokenDenylist(store, time.Second, zap.NewNop().Sugar())
+
+	testCases := []struct {
+		name     string
+		resource string
+		method   string
+		key      string
+		status   int
+	}{
+		{"scope allowed", "reports", http.MethodGet, readKey, http.StatusOK},
+		{"missing write scope", "reports", http.MethodPost, readKey, http.StatusForbidden},
+		{"other resource", "templates", http.MethodGet, readKey, http.StatusForbidden},
+		{"endpoint without api keys", "", http.MethodGet, readKey, http.StatusForbidden},
+		{"expired", "reports", http.MethodGet, expiredKey, http.StatusUnauthorized},
+		{"unknown", "reports", http.MethodGet, apiKeyPrefix + "unknown.secret", http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			if tc.resource != "" {
+				r.Use(allowAPIKeys(tc.resource))
+			}
+			r.Use(NewAuthMiddleware(jwtManager, store, denylist, zap.NewNop().Sugar()))
+			r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+				got, ok := UserFromContext(r)
+				require.True(t, ok)
+				require.Equal(t, user.ID, got.ID)
+				_, ok = APIKeyFromContext(r)
+				require.True(t, ok)
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(tc.method, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+tc.key)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			require.Equal(t, tc.status, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"go.uber.org/zap"
+)
+
+// denylistCleanupInterval is how often expired entries are deleted from Postgres
+const denylistCleanupInterval = time.Minute
+
+// tokenDenylist keeps the denied access tokens and sessions in memory. Denials made by this
+// instance apply at once; the ones made by other instances arrive with the next sync.
+type tokenDenylist struct {
+	store    db.Store
+	interval time.Duration
+	logger   *zap.SugaredLogger
+
+	mu      sync.RWMutex
+	entries map[string]time.Time
+}
+
+func newTokenDenylist(store db.Store, interval time.Duration, logger *zap.SugaredLogger) *tokenDenylist {
+	return &tokenDenylist{
+		store:    store,
+		interval: interval,
+		logger:   logger,
+		entries:  make(map[string]time.Time),
+	}
+}
+
+func denylistKey(kind, id string) string {
+	return kind + ":" + id
+}
+
+// run syncs the denylist with Postgres until the context is done.
+func (d *tokenDenylist) run(ctx context.Context) {
+	interval := d.interval
+	if interval <= 0 {
+		interval = time.Second * 5
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastCleanup := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.sync(ctx); err != nil {
+				d.logger.Errorw("Error syncing token denylist", "error", err)
+			}
+			if time.Since(lastCleanup) >= denylistCleanupInterval {
+				lastCleanup = time.Now()
+				if _, err := d.store.DeleteExpiredDeniedTokens(ctx); err != nil {
+					d.logger.Errorw("Error deleting expired denied tokens", "error", err)
+				}
+			}
+		}
+	}
+}
+
+// sync adds the denials stored in Postgres and forgets the ones that expired.
+// Entries are never removed early since a denial is never lifted.
+func (d *tokenDenylist) sync(ctx context.Context) error {
+	denied, err := d.store.ListActiveDeniedTokens(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, token := range denied {
+		d.addLocked(denylistKey(token.Kind, token.TokenID), token.ExpiresAt)
+	}
+	for key, expiresAt := range d.entries {
+		if !expiresAt.After(now) {
+			delete(d.entries, key)
+		}
+	}
+	return nil
+}
+
+// add applies a denial that was just stored, before the next sync picks it up.
+func (d *tokenDenylist) add(kind, id string, until time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.addLocked(denylistKey(kind, id), until)
+}
+
+func (d *tokenDenylist) addLocked(key string, until time.Time) {
+	if until.After(d.entries[key]) {
+		d.entries[key] = until
+	}
+}
+
+// denied reports whether the token or session id is denied.
+func (d *tokenDenylist) denied(kind, id string) bool {
+	if id == "" {
+		return false
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	expiresAt, ok := d.entries[denylistKey(kind, id)]
+	return ok && expiresAt.After(time.Now())
+}
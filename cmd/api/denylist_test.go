@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/trenchesdeveloper/csv-reporter/config"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+	"go.uber.org/zap"
+)
+
+// denylistStore serves the queries the denylist and the auth middleware use.
+type denylistStore struct {
+	db.Store
+	denied []db.DeniedToken
+	user   db.User
+}
+
+func (s *denylistStore) ListActiveDeniedTokens(ctx context.Context) ([]db.DeniedToken, error) {
+	return s.denied, nil
+}
+
+func (s *denylistStore) FindUserById(ctx context.Context, id uuid.UUID) (db.User, error) {
+	return s.user, nil
+}
+
+func TestTokenDenylistSync(t *testing.T) {
+	store := &denylistStore{denied: []db.DeniedToken{
+		{Kind: db.DeniedTokenKindToken, TokenID: "from-other-instance", ExpiresAt: time.Now().Add(time.Minute)},
+	}}
+	denylist := newTokenDenylist(store, time.Second, zap.NewNop().Sugar())
+
+	denylist.add(db.DeniedTokenKindSession, "local", time.Now().Add(time.Minute))
+	denylist.add(db.DeniedTokenKindToken, "expired", time.Now().Add(-time.Second))
+	require.NoError(t, denylist.sync(context.Background()))
+
+	// local denials survive a sync that ran before they were stored
+	require.True(t, denylist.denied(db.DeniedTokenKindSession, "local"))
+	require.True(t, denylist.denied(db.DeniedTokenKindToken, "from-other-instance"))
+	require.False(t, denylist.denied(db.DeniedTokenKindSession, "from-other-instance"))
+	require.False(t, denylist.denied(db.DeniedTokenKindToken, "expired"))
+	require.False(t, denylist.denied(db.DeniedTokenKindToken, ""))
+	require.NotContains(t, denylist.entries, denylistKey(db.DeniedTokenKindToken, "expired"))
+}
+
+func TestAuthMiddlewareDenylist(t *testing.T) {
+	jwtManager := helpers.NewJwtManager(&config.AppConfig{AppName: "csv_reporter", JWT_SECRET: "secretToSecretMySecret"})
+	user := db.User{ID: uuid.New(), Email: "user@example.com"}
+	store := &denylistStore{user: user}
+
+	issue := func(t *testing.T) (string, *helpers.CustomClaims) {
+		tokens, err := jwtManager.GenerateSessionTokenPairs(user.ID, uuid.New(), user.Role)
+		require.NoError(t, err)
+		token, err := jwtManager.ValidateToken(tokens.AccessToken)
+		require.NoError(t, err)
+		claims, _ := jwtManager.Claims(token)
+		return tokens.AccessToken, claims
+	}
+
+	testCases := []struct {
+		name   string
+		deny   func(d *tokenDenylist, claims *helpers.CustomClaims)
+		status int
+	}{
+		{"allowed", func(d *tokenDenylist, claims *helpers.CustomClaims) {}, http.StatusOK},
+		{"denied token", func(d *tokenDenylist, claims *helpers.CustomClaims) {
+			d.add(db.DeniedTokenKindToken, claims.ID, claims.ExpiresAt.Time)
+		}, http.StatusUnauthorized},
+		{"revoked session", func(d *tokenDenylist, claims *helpers.CustomClaims) {
+			d.add(db.DeniedTokenKindSession, claims.SessionID, time.Now().Add(helpers.AccessTokenTTL))
+		}, http.StatusUnauthorized},
+		{"other token denied", func(d *tokenDenylist, claims *helpers.CustomClaims) {
+			d.add(db.DeniedTokenKindToken, uuid.NewString(), claims.ExpiresAt.Time)
+		}, http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			denylist := newTokenDenylist(store, time.Second, zap.NewNop().Sugar())
+			accessToken, claims := issue(t)
+			tc.deny(denylist, claims)
+
+			handler := NewAuthMiddleware(jwtManager, store, denylist, zap.NewNop().Sugar())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, ok := ClaimsFromContext(r)
+				require.True(t, ok)
+				require.Equal(t, claims.ID, got.ID)
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+accessToken)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			require.Equal(t, tc.status, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"github.com/trenchesdeveloper/csv-reporter/destinations"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+)
+
+type CreateDestinationRequest struct {
+	Name    string                    `json:"name" validate:"required,max=255"`
+	Kind    string                    `json:"kind" validate:"required,oneof=sftp webdav"`
+	Enabled *bool                     `json:"enabled,omitempty"`
+	SFTP    *SFTPDestinationRequest   `json:"sftp,omitempty" validate:"required_if=Kind sftp,excluded_unless=Kind sftp"`
+	WebDAV  *WebDAVDestinationRequest `json:"webdav,omitempty" validate:"required_if=Kind webdav,excluded_unless=Kind webdav"`
+}
+
+type SFTPDestinationRequest struct {
+	Host     string `json:"host" validate:"required,hostname_rfc1123|ip"`
+	Port     int    `json:"port,omitempty" validate:"omitempty,min=1,max=65535"`
+	Username string `json:"username" validate:"required,max=255"`
+	// HostKey is the server's public key in authorized_keys format, e.g. from ssh-keyscan.
+	HostKey      string `json:"host_key" validate:"required,max=8192"`
+	PathTemplate string `json:"path_template,omitempty" validate:"max=1024"`
+}
+
+type WebDAVDestinationRequest struct {
+	URL          string `json:"url" validate:"required,http_url,max=2048"`
+	Username     string `json:"username,omitempty" validate:"max=255"`
+	Password     string `json:"password,omitempty" validate:"max=1024"`
+	PathTemplate string `json:"path_template,omitempty" validate:"max=1024"`
+}
+
+type UpdateDestinationRequest struct {
+	Enabled *bool `json:"enabled" validate:"required"`
+}
+
+type DestinationResponse struct {
+	ID        uuid.UUID                  `json:"id"`
+	Name      string                     `json:"name"`
+	Kind      string                     `json:"kind"`
+	Enabled   bool                       `json:"enabled"`
+	SFTP      *destinations.SFTPConfig   `json:"sftp,omitempty"`
+	WebDAV    *destinations.WebDAVConfig `json:"webdav,omitempty"`
+	PublicKey string                     `json:"public_key,omitempty"`
+	CreatedAt time.Time                  `json:"created_at"`
+}
+
+type DestinationDeliveryResponse struct {
+	DestinationID   *uuid.UUID `json:"destination_id,omitempty"`
+	DestinationName string     `json:"destination_name"`
+	Status          string     `json:"status"`
+	RemotePath      string     `json:"remote_path,omitempty"`
+	AttemptCount    int32      `json:"attempt_count"`
+	LastError       string     `json:"last_error,omitempty"`
+	DeliveredAt     time.Time  `json:"delivered_at,omitempty"`
+}
+
+// CreateDestinationHandler registers an SFTP or WebDAV destination. SFTP destinations get a key
+// pair of their own; the public key is returned for the partner to authorize.
+func (s *server) CreateDestinationHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateDestinationRequest
+	if err := readJSON(w, r, &req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := Validate.Struct(req); err != nil {
+		errorResponse(w, http.StatusBadRequest, formatValidationErrors(err))
+		return
+	}
+
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var config any
+	var pathTemplate string
+	var secret, publicKey sql.NullString
+	switch req.Kind {
+	case destinations.KindSFTP:
+		if err := helpers.ValidateOutboundHost(req.SFTP.Host); err != nil {
+			errorResponse(w, http.StatusBadRequest, "Invalid SFTP host: "+err.Error())
+			return
+		}
+		if _, err := destinations.ParseHostKey(req.SFTP.HostKey); err != nil {
+			errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		privateKey, authorizedKey, err := destinations.GenerateKeyPair("csv-reporter-" + req.Name)
+		if err != nil {
+			s.logger.Error("Error generating destination key", err)
+			errorResponse(w, http.StatusInternalServerError, "Error creating destination")
+			return
+		}
+		config = destinations.SFTPConfig{
+			Host:         req.SFTP.Host,
+			Port:         req.SFTP.Port,
+			Username:     req.SFTP.Username,
+			HostKey:      req.SFTP.HostKey,
+			PathTemplate: req.SFTP.PathTemplate,
+		}
+		pathTemplate = req.SFTP.PathTemplate
+		secret = sql.NullString{String: privateKey, Valid: true}
+		publicKey = sql.NullString{String: authorizedKey, Valid: true}
+	case destinations.KindWebDAV:
+		if err := s.validateOutboundURL(req.WebDAV.URL); err != nil {
+			errorResponse(w, http.StatusBadRequest, "Invalid WebDAV URL: "+err.Error())
+			return
+		}
+		config = destinations.WebDAVConfig{
+			URL:          req.WebDAV.URL,
+			Username:     req.WebDAV.Username,
+			PathTemplate: req.WebDAV.PathTemplate,
+		}
+		pathTemplate = req.WebDAV.PathTemplate
+		secret = sql.NullString{String: req.WebDAV.Password, Valid: req.WebDAV.Password != ""}
+	}
+
+	// render the template once so mistakes surface now rather than on the first delivery
+	sample := destinations.NewPathData(uuid.New(), "monsters", "totk", "csv", "monsters.csv.gz", time.Now())
+	if _, err := destinations.RenderPath(pathTemplate, sample); err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		s.logger.Error("Error encoding destination config", err)
+		errorResponse(w, http.StatusInternalServerError, "Error creating destination")
+		return
+	}
+
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	destination, err := s.store.CreateReportDestination(r.Context(), db.CreateReportDestinationParams{
+		UserID:    user.ID,
+		Name:      req.Name,
+		Kind:      req.Kind,
+		Config:    configJSON,
+		Secret:    secret,
+		PublicKey: publicKey,
+		Enabled:   enabled,
+	})
+	if err != nil {
+		if db.ErrorCode(err) == db.UniqueViolation {
+			errorResponse(w, http.StatusConflict, "A destination with this name already exists")
+			return
+		}
+		s.logger.Error("Error creating destination", err)
+		errorResponse(w, http.StatusInternalServerError, "Error creating destination")
+		return
+	}
+
+	jsonResponse(w, http.StatusCreated, newDestinationResponse(destination), "Destination created successfully")
+}
+
+func (s *server) ListDestinationsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	destinationList, err := s.store.ListReportDestinations(r.Context(), user.ID)
+	if err != nil {
+		s.logger.Error("Error listing destinations", err)
+		errorResponse(w, http.StatusInternalServerError, "Error listing destinations")
+		return
+	}
+
+	response := make([]DestinationResponse, 0, len(destinationList))
+	for _, destination := range destinationList {
+		response = append(response, newDestinationResponse(destination))
+	}
+
+	jsonResponse(w, http.StatusOK, response, "Destinations retrieved successfully")
+}
+
+func (s *server) GetDestinationHandler(w http.ResponseWriter, r *http.Request) {
+	destination, ok := s.loadDestination(w, r)
+	if !ok {
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, newDestinationResponse(destination), "Destination retrieved successfully")
+}
+
+// UpdateDestinationHandler enables or disables a destination.
+func (s *server) UpdateDestinationHandler(w http.ResponseWriter, r *http.Request) {
+	destination, ok := s.loadDestination(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateDestinationRequest
+	if err := readJSON(w, r, &req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := Validate.Struct(req); err != nil {
+		errorResponse(w, http.StatusBadRequest, formatValidationErrors(err))
+		return
+	}
+
+	// destinations registered before their targets were validated are checked when enabled again
+	if *req.Enabled {
+		if err := s.validateDestinationTarget(destination); err != nil {
+			errorResponse(w, http.StatusBadRequest, "Destination can't be enabled: "+err.Error())
+			return
+		}
+	}
+
+	destination, err := s.store.SetReportDestinationEnabled(r.Context(), db.SetReportDestinationEnabledParams{
+		UserID:  destination.UserID,
+		ID:      destination.ID,
+		Enabled: *req.Enabled,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorResponse(w, http.StatusNotFound, "Destination not found")
+			return
+		}
+		s.logger.Error("Error updating destination", err)
+		errorResponse(w, http.StatusInternalServerError, "Error updating destination")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, newDestinationResponse(destination), "Destination updated successfully")
+}
+
+func (s *server) DeleteDestinationHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	destinationId, err := uuid.Parse(chi.URLParam(r, "destinationId"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid destination ID")
+		return
+	}
+
+	deleted, err := s.store.DeleteReportDestination(r.Context(), db.DeleteReportDestinationParams{
+		UserID: user.ID,
+		ID:     destinationId,
+	})
+	if err != nil {
+		s.logger.Error("Error deleting destination", err)
+		errorResponse(w, http.StatusInternalServerError, "Error deleting destination")
+		return
+	}
+	if deleted == 0 {
+		errorResponse(w, http.StatusNotFound, "Destination not found")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, nil, "Destination deleted successfully")
+}
+
+// validateDestinationTarget rejects a stored destination whose host or URL the worker must not
+// connect to.
+func (s *server) validateDestinationTarget(destination db.ReportDestination) error {
+	switch destination.Kind {
+	case destinations.KindSFTP:
+		var config destinations.SFTPConfig
+		if err := json.Unmarshal(destination.Config, &config); err != nil {
+			return err
+		}
+		if err := helpers.ValidateOutboundHost(config.Host); err != nil {
+			return fmt.Errorf("invalid SFTP host: %w", err)
+		}
+	case destinations.KindWebDAV:
+		var config destinations.WebDAVConfig
+		if err := json.Unmarshal(destination.Config, &config); err != nil {
+			return err
+		}
+		if err := s.validateOutboundURL(config.URL); err != nil {
+			return fmt.Errorf("invalid WebDAV URL: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadDestination fetches the destination named in the URL for the signed-in user.
+func (s *server) loadDestination(w http.ResponseWriter, r *http.Request) (db.ReportDestination, bool) {
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return db.ReportDestination{}, false
+	}
+
+	destinationId, err := uuid.Parse(chi.URLParam(r, "destinationId"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid destination ID")
+		return db.ReportDestination{}, false
+	}
+
+	destination, err := s.store.GetReportDestination(r.Context(), db.GetReportDestinationParams{
+		UserID: user.ID,
+		ID:     destinationId,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorResponse(w, http.StatusNotFound, "Destination not found")
+			return db.ReportDestination{}, false
+		}
+		s.logger.Error("Error getting destination", err)
+		errorResponse(w, http.StatusInternalServerError, "Error getting destination")
+		return db.ReportDestination{}, false
+	}
+
+	return destination, true
+}
+
+// newDestinationResponse describes a destination without its secret.
+func newDestinationResponse(destination db.ReportDestination) DestinationResponse {
+	response := DestinationResponse{
+		ID:        destination.ID,
+		Name:      destination.Name,
+		Kind:      destination.Kind,
+		Enabled:   destination.Enabled,
+		PublicKey: destination.PublicKey.String,
+		CreatedAt: destination.CreatedAt,
+	}
+	switch destination.Kind {
+	case destinations.KindSFTP:
+		var config destinations.SFTPConfig
+		if err := json.Unmarshal(destination.Config, &config); err == nil {
+			response.SFTP = &config
+		}
+	case destinations.KindWebDAV:
+		var config destinations.WebDAVConfig
+		if err := json.Unmarshal(destination.Config, &config); err == nil {
+			response.WebDAV = &config
+		}
+	}
+	return response
+}
+
+func newDestinationDeliveryResponses(deliveries []db.ReportDestinationDelivery) []DestinationDeliveryResponse {
+	response := make([]DestinationDeliveryResponse, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		item := DestinationDeliveryResponse{
+			DestinationName: delivery.DestinationName,
+			Status:          delivery.Status,
+			RemotePath:      delivery.RemotePath.String,
+			AttemptCount:    delivery.AttemptCount,
+			LastError:       delivery.LastError.String,
+			DeliveredAt:     delivery.DeliveredAt.Time,
+		}
+		if delivery.DestinationID.Valid {
+			item.DestinationID = &delivery.DestinationID.UUID
+		}
+		response = append(response, item)
+	}
+	return response
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"github.com/trenchesdeveloper/csv-reporter/reports"
+)
+
+// DownloadReportHandler streams the report file through the API, for clients that can't reach S3.
+func (s *server) DownloadReportHandler(w http.ResponseWriter, r *http.Request) {
+	report, ok := s.loadReport(w, r)
+	if !ok {
+		return
+	}
+
+	if !isDownloadable(report) {
+		errorResponse(w, http.StatusConflict, "Report is not ready for download")
+		return
+	}
+
+	s.streamReport(w, r, report, nil)
+}
+
+// streamReport writes the file of a completed report. Clients that accept gzip get the stored file
+// as is, with Range support. Other clients get the file decompressed on the fly, which can't be ranged.
+// When begin is set, it is called once the file is open, right before the response starts; if it
+// returns false, it has written its own response and nothing is streamed. Every such response counts
+// as a whole download, so Range is ignored and the full file is always sent.
+func (s *server) streamReport(w http.ResponseWriter, r *http.Request, report db.Report, begin func() bool) {
+	params, err := reports.ParamsFromReport(report)
+	if err != nil {
+		s.logger.Error("Error decoding report params", err)
+		errorResponse(w, http.StatusInternalServerError, "Error downloading report")
+		return
+	}
+
+	compressed := acceptsGzip(r.Header.Get("Accept-Encoding"))
+	ranged := compressed && begin == nil
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.config.S3_BUCKET),
+		Key:    aws.String(report.OutputFilePath.String),
+	}
+	if rangeHeader := r.Header.Get("Range"); ranged && rangeHeader != "" {
+		input.Range = aws.String(rangeHeader)
+	}
+
+	object, err := s.s3Client.GetObject(r.Context(), input)
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		var apiErr smithy.APIError
+		switch {
+		case errors.As(err, &noSuchKey):
+			errorResponse(w, http.StatusNotFound, "Report file not found")
+		case errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidRange":
+			errorResponse(w, http.StatusRequestedRangeNotSatisfiable, "Invalid range")
+		default:
+			s.logger.Error("Error getting report file", err)
+			errorResponse(w, http.StatusBadGateway, "Error downloading report")
+		}
+		return
+	}
+	defer object.Body.Close()
+
+	// large files outlive the server write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		s.logger.Warnw("failed to clear write deadline", "error", err)
+	}
+
+	filename := reports.DownloadFilename(report, params)
+	header := w.Header()
+	header.Set("Cache-Control", "private, no-store")
+	header.Set("Vary", "Accept-Encoding")
+	if object.LastModified != nil {
+		header.Set("Last-Modified", object.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	var body io.Reader = object.Body
+	status := http.StatusOK
+	if compressed {
+		header.Set("Content-Type", "application/gzip")
+		if ranged {
+			header.Set("Accept-Ranges", "bytes")
+		} else {
+			header.Set("Accept-Ranges", "none")
+		}
+		if object.ETag != nil {
+			header.Set("ETag", *object.ETag)
+		}
+		if object.ContentLength != nil {
+			header.Set("Content-Length", strconv.FormatInt(*object.ContentLength, 10))
+		}
+		if object.ContentRange != nil {
+			header.Set("Content-Range", *object.ContentRange)
+			status = http.StatusPartialContent
+		}
+	} else {
+		gz, err := gzip.NewReader(object.Body)
+		if err != nil {
+			s.logger.Error("Error decompressing report file", err)
+			errorResponse(w, http.StatusInternalServerError, "Error downloading report")
+			return
+		}
+		defer gz.Close()
+		body = gz
+		filename = strings.TrimSuffix(filename, ".gz")
+		header.Set("Content-Type", params.ContentType())
+		header.Set("Accept-Ranges", "none")
+	}
+	header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+
+	if begin != nil && !begin() {
+		return
+	}
+	w.WriteHeader(status)
+	if _, err := io.Copy(w, body); err != nil {
+		// the status is already sent, the client sees a truncated body
+		s.logger.Warnw("report download interrupted", "report_id", report.ID, "error", err)
+	}
+}
+
+func isDownloadable(report db.Report) bool {
+	return report.CompletedAt.Valid && report.OutputFilePath.Valid
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, q, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "x-gzip" && coding != "*" {
+			continue
+		}
+		q = strings.TrimSpace(q)
+		if value, ok := strings.CutPrefix(q, "q="); ok {
+			if weight, err := strconv.ParseFloat(value, 64); err == nil && weight == 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// presignDownload signs a download URL for the object and returns it with its expiry. The stored
+// expiry and the signed X-Amz-Expires come from the same TTL, so they can't drift apart.
+func (s *server) presignDownload(ctx context.Context, key string, now time.Time) (string, time.Time, error) {
+	ttl := min(s.config.DOWNLOAD_URL_TTL, reports.MaxPresignTTL)
+	signed, err := s.presignedClient.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.config.S3_BUCKET),
+		Key:    aws.String(key),
+	}, func(options *s3.PresignOptions) {
+		options.Expires = ttl
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed.URL, now.Add(ttl), nil
+}
+
+// downloadURLStale reports whether the stored download URL is missing or expires within the
+// refresh window, so clients never get a link that dies before they use it.
+func (s *server) downloadURLStale(report db.Report, now time.Time) bool {
+	if !report.DownloadUrl.Valid || !report.DownloadExpiresAt.Valid {
+		return true
+	}
+	return !report.DownloadExpiresAt.Time.After(now.Add(s.config.DOWNLOAD_URL_REFRESH_WINDOW))
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/require"
+	"github.com/trenchesdeveloper/csv-reporter/config"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"github.com/trenchesdeveloper/csv-reporter/reports"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	testCases := []struct {
+		header string
+		want   bool
+	}{
+		{header: "", want: false},
+		{header: "gzip", want: true},
+		{header: "deflate, gzip;q=1.0, *;q=0.5", want: true},
+		{header: "GZIP", want: true},
+		{header: "identity", want: false},
+		{header: "gzip;q=0", want: false},
+		{header: "br, *", want: true},
+		{header: "*;q=0", want: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.header, func(t *testing.T) {
+			require.Equal(t, tc.want, acceptsGzip(tc.header))
+		})
+	}
+}
+
+func newPresignTestServer(ttl, refreshWindow time.Duration) *server {
+	client := s3.New(s3.Options{
+		Region:      "us-west-2",
+		Credentials: credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", ""),
+	})
+	return &server{
+		config: &config.AppConfig{
+			S3_BUCKET:                   "api-reports",
+			DOWNLOAD_URL_TTL:            ttl,
+			DOWNLOAD_URL_REFRESH_WINDOW: refreshWindow,
+		},
+		presignedClient: s3.NewPresignClient(client),
+	}
+}
+
+func TestPresignDownloadExpiry(t *testing.T) {
+	testCases := []struct {
+		name string
+		ttl  time.Duration
+		want time.Duration
+	}{
+		{name: "configured", ttl: 15 * time.Minute, want: 15 * time.Minute},
+		{name: "capped", ttl: 30 * 24 * time.Hour, want: reports.MaxPresignTTL},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newPresignTestServer(tc.ttl, time.Minute)
+			now := time.Now()
+
+			signedURL, expiresAt, err := s.presignDownload(context.Background(), "artifacts/report.csv.gz", now)
+			require.NoError(t, err)
+			require.Equal(t, now.Add(tc.want), expiresAt)
+
+			parsed, err := url.Parse(signedURL)
+			require.NoError(t, err)
+			require.Equal(t, strconv.Itoa(int(tc.want.Seconds())), parsed.Query().Get("X-Amz-Expires"))
+		})
+	}
+}
+
+func TestDownloadURLStale(t *testing.T) {
+	s := newPresignTestServer(10*time.Minute, 2*time.Minute)
+	now := time.Now()
+	downloadURL := sql.NullString{String: "https://example.com/report.csv.gz", Valid: true}
+
+	testCases := []struct {
+		name   string
+		report db.Report
+		want   bool
+	}{
+		{name: "missing", report: db.Report{}, want: true},
+		{name: "expired", report: db.Report{
+			DownloadUrl:       downloadURL,
+			DownloadExpiresAt: sql.NullTime{Time: now.Add(-time.Second), Valid: true},
+		}, want: true},
+		{name: "expiring soon", report: db.Report{
+			DownloadUrl:       downloadURL,
+			DownloadExpiresAt: sql.NullTime{Time: now.Add(time.Minute), Valid: true},
+		}, want: true},
+		{name: "fresh", report: db.Report{
+			DownloadUrl:       downloadURL,
+			DownloadExpiresAt: sql.NullTime{Time: now.Add(5 * time.Minute), Valid: true},
+		}, want: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, s.downloadURLStale(tc.report, now))
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"github.com/trenchesdeveloper/csv-reporter/reports"
+	"go.uber.org/zap"
+)
+
+const (
+	eventBufferSize   = 16
+	heartbeatInterval = 15 * time.Second
+)
+
+// reportEventHub listens for report events published by the workers and fans them out to the streams of this instance.
+type reportEventHub struct {
+	listener *pq.Listener
+	logger   *zap.SugaredLogger
+
+	mu          sync.Mutex
+	subscribers map[uuid.UUID]map[chan reports.ReportEvent]struct{}
+}
+
+func newReportEventHub(dataSource string, logger *zap.SugaredLogger) *reportEventHub {
+	listener := pq.NewListener(dataSource, 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Warnw("report event listener", "event", ev, "error", err)
+		}
+	})
+	return &reportEventHub{
+		listener:    listener,
+		logger:      logger,
+		subscribers: make(map[uuid.UUID]map[chan reports.ReportEvent]struct{}),
+	}
+}
+
+// run receives notifications until the context is done.
+func (h *reportEventHub) run(ctx context.Context) error {
+	if err := h.listener.Listen(reports.ReportEventsChannel); err != nil {
+		return fmt.Errorf("failed to listen for report events: %w", err)
+	}
+	defer h.listener.Close()
+
+	// pinging detects connections that were dropped without an error
+	ping := time.NewTicker(time.Minute)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-h.listener.Notify:
+			// a nil notification means the connection was re-established
+			if n == nil {
+				continue
+			}
+			var event reports.ReportEvent
+			if err := json.Unmarshal([]byte(n.Extra), &event); err != nil {
+				h.logger.Warnw("invalid report event", "error", err)
+				continue
+			}
+			h.broadcast(event)
+		case <-ping.C:
+			go h.listener.Ping()
+		}
+	}
+}
+
+func (h *reportEventHub) broadcast(event reports.ReportEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers[event.ReportID] {
+		// slow subscribers miss intermediate events rather than blocking the hub
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// subscribe returns a channel with the events of a report and a function that releases it.
+func (h *reportEventHub) subscribe(reportID uuid.UUID) (<-chan reports.ReportEvent, func()) {
+	ch := make(chan reports.ReportEvent, eventBufferSize)
+
+	h.mu.Lock()
+	if h.subscribers[reportID] == nil {
+		h.subscribers[reportID] = make(map[chan reports.ReportEvent]struct{})
+	}
+	h.subscribers[reportID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subscribers[reportID], ch)
+		if len(h.subscribers[reportID]) == 0 {
+			delete(h.subscribers, reportID)
+		}
+	}
+}
+
+func (s *server) ReportEventsHandler(w http.ResponseWriter, r *http.Request) {
+	report, ok := s.loadReport(w, r)
+	if !ok {
+		return
+	}
+
+	// subscribe before reading the current state so no transition is missed in between
+	events, unsubscribe := s.events.subscribe(report.ID)
+	defer unsubscribe()
+
+	report, err := s.store.GetReport(r.Context(), db.GetReportParams{
+		ID:     report.ID,
+		UserID: report.UserID,
+	})
+	if err != nil {
+		s.logger.Error("Error getting report", err)
+		errorResponse(w, http.StatusInternalServerError, "Error getting report")
+		return
+	}
+
+	// the stream outlives the server write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		s.logger.Warnw("failed to clear write deadline", "error", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	status := GetStatus(report)
+	if err := writeEvent(w, rc, reports.ReportEvent{
+		Type:         reports.EventStatus,
+		ReportID:     report.ID,
+		UserID:       report.UserID,
+		Status:       status,
+		Phase:        report.Phase.String,
+		RowsTotal:    int64(report.RowsTotal.Int32),
+		RowsWritten:  int64(report.RowsWritten),
+		BytesWritten: report.BytesWritten,
+		ErrorMessage: report.ErrorMessage.String,
+		CreatedAt:    time.Now(),
+	}); err != nil || isFinalStatus(status) {
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case event := <-events:
+			if err := writeEvent(w, rc, event); err != nil {
+				return
+			}
+			if event.Type == reports.EventStatus && isFinalStatus(event.Status) {
+				return
+			}
+		}
+	}
+}
+
+// publishStatus notifies streams about a status change made through the API.
+func (s *server) publishStatus(ctx context.Context, report db.Report) {
+	err := reports.PublishEvent(ctx, s.store, reports.ReportEvent{
+		Type:         reports.EventStatus,
+		ReportID:     report.ID,
+		UserID:       report.UserID,
+		Status:       GetStatus(report),
+		ErrorMessage: report.ErrorMessage.String,
+	})
+	if err != nil {
+		s.logger.Warnw("failed to publish report event", "report_id", report.ID, "error", err)
+	}
+}
+
+// writeEvent writes a single server-sent event and flushes it to the client.
+func writeEvent(w http.ResponseWriter, rc *http.ResponseController, event reports.ReportEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
+
+func isFinalStatus(status string) bool {
+	return status == "completed" || status == "failed" || status == "cancelled"
+}
@@ -3,23 +3,27 @@ package main
 import (
 	"crypto/sha256"
 	"database/sql"
-	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/go-chi/chi/v5"
+	"net"
 	"net/http"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/google/uuid"
 	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
 	"github.com/trenchesdeveloper/csv-reporter/helpers"
 	"github.com/trenchesdeveloper/csv-reporter/reports"
-	"golang.org/x/crypto/bcrypt"
 )
 
+// maxUserAgentLength matches security_events.user_agent
+const maxUserAgentLength = 512
+
 type SignupRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,min=6"`
@@ -28,28 +32,110 @@ type SignupRequest struct {
 type SigninRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,min=6"`
+	// DeviceName labels the session in the sessions list, e.g. "CI bot" or "Work laptop".
+	DeviceName string `json:"device_name" validate:"omitempty,max=100"`
 }
 
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" validate:"required"`
 }
 
+// ReportParamsRequest holds the parameters of a report, shared by reports, schedules and templates.
+type ReportParamsRequest struct {
+	ReportType string          `json:"report_type" validate:"omitempty,oneof=monsters weapons armor"`
+	Game       string          `json:"game,omitempty" validate:"omitempty,oneof=totk botw"`
+	Columns    []string        `json:"columns,omitempty" validate:"omitempty,dive,required"`
+	Filters    reports.Filters `json:"filters"`
+	Format     string          `json:"format,omitempty" validate:"omitempty,oneof=csv ndjson"`
+}
+
+// params returns the normalized report params of the request.
+func (req ReportParamsRequest) params() (reports.Params, error) {
+	return req.apply(reports.Params{}).Normalize()
+}
+
+// apply overrides the given params with every parameter set in the request.
+func (req ReportParamsRequest) apply(params reports.Params) reports.Params {
+	if req.ReportType != "" {
+		params.Type = req.ReportType
+	}
+	if req.Game != "" {
+		params.Game = req.Game
+	}
+	if len(req.Columns) > 0 {
+		params.Columns = req.Columns
+	}
+	if req.Format != "" {
+		params.Format = req.Format
+	}
+	if req.Filters.Name != "" {
+		params.Filters.Name = req.Filters.Name
+	}
+	if req.Filters.Location != "" {
+		params.Filters.Location = req.Filters.Location
+	}
+	if req.Filters.Drop != "" {
+		params.Filters.Drop = req.Filters.Drop
+	}
+	if req.Filters.Dlc != nil {
+		params.Filters.Dlc = req.Filters.Dlc
+	}
+	return params
+}
+
 type CreateReportRequest struct {
-	ReportType string `json:"report_type" validate:"required,oneof=monsters weapons armor"`
+	// TemplateID starts the report from a saved template; the other parameters override it.
+	TemplateID *uuid.UUID `json:"template_id,omitempty"`
+	// OrganizationID shares the report with the members of an organization.
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty"`
+	ReportParamsRequest
+	CallbackURL string `json:"callback_url,omitempty" validate:"omitempty,http_url,max=2048"`
+	// DeliverTo lists email addresses the completed report is sent to.
+	DeliverTo []string `json:"deliver_to,omitempty" validate:"omitempty,max=20,dive,email,max=255"`
 }
 
 type ReportResponse struct {
-	ID                   uuid.UUID `json:"id"`
-	ReportType           string    `json:"report_type,omitempty"`
-	OutputFilePath       string    `json:"output_file_path,omitempty"`
-	DownloadURL          string    `json:"download_url,omitempty"`
-	DownloadUrlExpiresAt time.Time `json:"download_url_expires_at,omitempty"`
-	StartedAt            time.Time `json:"started_at,omitempty"`
-	CompletedAt          time.Time `json:"completed_at,omitempty"`
-	FailedAt             time.Time `json:"failed_at,omitempty"`
-	CreatedAt            time.Time `json:"created_at,omitempty"`
-	ErrorMessage         string    `json:"error_message,omitempty"`
-	Status               string    `json:"status"`
+	ID                    uuid.UUID                     `json:"id"`
+	OrganizationID        *uuid.UUID                    `json:"organization_id,omitempty"`
+	ReportType            string                        `json:"report_type,omitempty"`
+	OutputFilePath        string                        `json:"output_file_path,omitempty"`
+	DownloadURL           string                        `json:"download_url,omitempty"`
+	DownloadUrlExpiresAt  time.Time                     `json:"download_url_expires_at,omitempty"`
+	StartedAt             time.Time                     `json:"started_at,omitempty"`
+	CompletedAt           time.Time                     `json:"completed_at,omitempty"`
+	FailedAt              time.Time                     `json:"failed_at,omitempty"`
+	CancelledAt           time.Time                     `json:"cancelled_at,omitempty"`
+	CreatedAt             time.Time                     `json:"created_at,omitempty"`
+	ErrorMessage          string                        `json:"error_message,omitempty"`
+	Status                string                        `json:"status"`
+	CallbackURL           string                        `json:"callback_url,omitempty"`
+	CallbackSecret        string                        `json:"callback_secret,omitempty"`
+	TemplateID            *uuid.UUID                    `json:"template_id,omitempty"`
+	TemplateVersion       int32                         `json:"template_version,omitempty"`
+	Params                *reports.Params               `json:"params,omitempty"`
+	Phase                 string                        `json:"phase,omitempty"`
+	RowsTotal             int32                         `json:"rows_total,omitempty"`
+	RowsWritten           int32                         `json:"rows_written"`
+	BytesWritten          int64                         `json:"bytes_written"`
+	Attempts              []ReportAttemptResponse       `json:"attempts,omitempty"`
+	EmailDeliveries       []EmailDeliveryResponse       `json:"email_deliveries,omitempty"`
+	DestinationDeliveries []DestinationDeliveryResponse `json:"destination_deliveries,omitempty"`
+}
+
+type EmailDeliveryResponse struct {
+	Recipient    string    `json:"recipient"`
+	Status       string    `json:"status"`
+	AttemptCount int32     `json:"attempt_count"`
+	LastError    string    `json:"last_error,omitempty"`
+	SentAt       time.Time `json:"sent_at,omitempty"`
+}
+
+type ReportAttemptResponse struct {
+	AttemptNumber int32     `json:"attempt_number"`
+	StartedAt     time.Time `json:"started_at,omitempty"`
+	FailedAt      time.Time `json:"failed_at,omitempty"`
+	ErrorMessage  string    `json:"error_message,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
 }
 
 func (s *server) SignupHandler(w http.ResponseWriter, r *http.Request) {
@@ -78,7 +164,7 @@ func (s *server) SignupHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = s.store.CreateUser(r.Context(), db.CreateUserParams{
+	user, err := s.store.CreateUser(r.Context(), db.CreateUserParams{
 		Email:          req.Email,
 		HashedPassword: hashedPassword,
 	})
@@ -89,6 +175,11 @@ func (s *server) SignupHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the account works without it; the user can ask for another email later
+	if err := s.sendVerificationEmail(r.Context(), user); err != nil {
+		s.logger.Error("Error creating verification token", err)
+	}
+
 	jsonResponse(w, http.StatusCreated, nil, "User created successfully")
 }
 
@@ -105,49 +196,48 @@ func (s *server) SigninHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the attempt is counted before the password is checked, and locked accounts and IPs are refused
+	reserved, ok := s.reserveLogin(w, r, req.Email)
+	if !ok {
+		return
+	}
+
+	// unknown emails count as failures too, so lockouts don't reveal which accounts exist
 	user, err := s.store.FindUserByEmail(r.Context(), req.Email)
 	if err != nil {
+		s.recordLoginFailure(r, reserved, uuid.NullUUID{})
 		errorResponse(w, http.StatusUnauthorized, "Invalid email or password")
 		return
 	}
 	if err := helpers.ComparePasswordBase64(user.HashedPassword, req.Password); err != nil {
+		s.recordLoginFailure(r, reserved, uuid.NullUUID{UUID: user.ID, Valid: true})
 		errorResponse(w, http.StatusUnauthorized, "Invalid email or password")
 		return
 	}
 
-	// Generate JWT token
-	token, err := s.tokenManager.GenerateTokenPairs(user.ID)
-
-	if err != nil {
-		s.logger.Error("Error generating token", err)
-		errorResponse(w, http.StatusInternalServerError, "Error generating token")
+	// with MFA the password only earns a challenge; MfaLoginHandler issues the tokens
+	// and clears the failures once the code is right
+	if user.MfaEnabledAt.Valid {
+		s.releaseAttempt(r, reserved)
+		mfaToken, expiresAt, err := s.tokenManager.GenerateMFAToken(user.ID)
+		if err != nil {
+			s.logger.Error("Error generating MFA token", err)
+			errorResponse(w, http.StatusInternalServerError, "Error generating token")
+			return
+		}
+		jsonResponse(w, http.StatusOK, MfaChallengeResponse{
+			MfaRequired: true,
+			MfaToken:    mfaToken,
+			ExpiresAt:   expiresAt,
+		}, "MFA code required")
 		return
 	}
 
-	// delete the old refresh token
-	err = s.store.DeleteAllUserRefreshTokens(r.Context(), user.ID)
-	if err != nil {
-		s.logger.Error("Error deleting old refresh tokens", err)
-		errorResponse(w, http.StatusInternalServerError, "Error deleting old refresh tokens")
-		return
-	}
-	// convert hashed token to base64
-	hashedToken, err := hashToken(token.RefreshToken)
-	if err != nil {
-		s.logger.Error("Error hashing token", err)
-		errorResponse(w, http.StatusInternalServerError, "Error hashing token")
-		return
-	}
-	// create refresh_token
-	_, err = s.store.CreateRefreshToken(r.Context(), db.CreateRefreshTokenParams{
-		HashedToken: hashedToken,
-		UserID:      user.ID,
-		ExpiresAt:   time.Now().Add(24 * time.Hour * 7),
-	})
+	s.attemptSucceeded(r, reserved)
 
-	if err != nil {
-		s.logger.Error("Error creating refresh token", err)
-		errorResponse(w, http.StatusInternalServerError, "Error creating refresh token")
+	// each sign-in gets its own session, so other devices stay signed in
+	token, ok := s.startSession(w, r, user, req.DeviceName)
+	if !ok {
 		return
 	}
 
@@ -189,69 +279,55 @@ func (s *server) RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// hash the refresh token
-	hashedToken, err := hashToken(req.RefreshToken)
-	if err != nil {
-		s.logger.Error("Error hashing token", err)
-		errorResponse(w, http.StatusInternalServerError, "Error hashing token")
+	// tokens issued before sessions existed carry no session and need a new sign-in
+	sessionId, ok := s.tokenManager.SessionID(claims)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
-	// query the database for the refresh token
-	currentRefreshToken, err := s.store.GetTokenByPrimaryKey(r.Context(), userId)
+	// the role comes from the user, never from the presented token
+	user, err := s.store.FindUserById(r.Context(), userId)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			s.logger.Error("Refresh token not found", err)
-			errorResponse(w, http.StatusUnauthorized, "Unauthorized")
-			return
-		}
-		s.logger.Error("Error getting refresh token", err)
-		errorResponse(w, http.StatusInternalServerError, "Error getting refresh token")
-	}
-
-	// check if the refresh token is expired
-	if currentRefreshToken.ExpiresAt.Before(time.Now()) {
-		s.logger.Error("Refresh token expired", err)
-		errorResponse(w, http.StatusUnauthorized, "Refresh token expired")
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	// create a new token
-	token, err := s.tokenManager.GenerateTokenPairs(userId)
+	token, err := s.tokenManager.GenerateSessionTokenPairs(user.ID, sessionId, user.Role)
 	if err != nil {
 		s.logger.Error("Error generating token", err)
 		errorResponse(w, http.StatusInternalServerError, "Error generating token")
 		return
 	}
 
-	// delete the old refresh token
-	err = s.store.DeleteAllUserRefreshTokens(r.Context(), userId)
-
-	if err != nil {
-		s.logger.Error("Error deleting old refresh tokens", err)
-		errorResponse(w, http.StatusInternalServerError, "Error deleting old refresh tokens")
-		return
-	}
-
-	// convert hashed token to base64
-	hashedToken, err = hashToken(token.RefreshToken)
-
+	// exchange the presented refresh token for the next one of its session
+	rotated, err := s.store.RotateRefreshTokenTx(r.Context(), db.RotateRefreshTokenTxParams{
+		UserID:         userId,
+		SessionID:      sessionId,
+		HashedToken:    hashToken(req.RefreshToken),
+		NewHashedToken: hashToken(token.RefreshToken),
+		ExpiresAt:      time.Now().Add(helpers.RefreshTokenTTL),
+		IpAddress:      clientIP(r),
+		UserAgent:      truncate(r.UserAgent(), maxUserAgentLength),
+		DenyUntil:      time.Now().Add(helpers.AccessTokenTTL),
+	})
 	if err != nil {
-		s.logger.Error("Error hashing token", err)
-		errorResponse(w, http.StatusInternalServerError, "Error hashing token")
+		if errors.Is(err, sql.ErrNoRows) {
+			errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		s.logger.Error("Error rotating refresh token", err)
+		errorResponse(w, http.StatusInternalServerError, "Error rotating refresh token")
 		return
 	}
-	// create refresh_token
-	_, err = s.store.CreateRefreshToken(r.Context(), db.CreateRefreshTokenParams{
-		HashedToken: hashedToken,
-		UserID:      userId,
-		ExpiresAt:   time.Now().Add(24 * time.Hour * 7),
-	})
-	if err != nil {
-		s.logger.Error("Error creating refresh token", err)
-		errorResponse(w, http.StatusInternalServerError, "Error creating refresh token")
+	if rotated.Reused {
+		s.logger.Warnw("Refresh token reuse detected, session revoked", "user_id", userId, "session_id", sessionId)
+		s.denylist.add(db.DeniedTokenKindSession, sessionId.String(), time.Now().Add(helpers.AccessTokenTTL))
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
+
 	// check if the user exists in the database
 	jsonResponse(w, http.StatusOK, token, "Refresh token successful")
 }
@@ -273,10 +349,64 @@ func (s *server) CreateReportHandler(w http.ResponseWriter, r *http.Request) {
 		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
+	// otherwise anyone could sign up and mail attachments to strangers
+	if len(req.DeliverTo) > 0 && !user.EmailVerifiedAt.Valid {
+		errorResponse(w, http.StatusForbidden, "Verify your email address before delivering reports by email")
+		return
+	}
+
+	organizationId, ok := s.organizationFor(w, r, req.OrganizationID)
+	if !ok {
+		return
+	}
+
+	params, templateID, templateVersion, ok := s.resolveReportParams(w, r, user, req.TemplateID, req.ReportParamsRequest)
+	if !ok {
+		return
+	}
+	paramsHash, err := params.Hash()
+	if err != nil {
+		s.logger.Error("Error hashing report params", err)
+		errorResponse(w, http.StatusInternalServerError, "Error creating report")
+		return
+	}
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		s.logger.Error("Error encoding report params", err)
+		errorResponse(w, http.StatusInternalServerError, "Error creating report")
+		return
+	}
 
-	report, err := s.store.CreateReport(r.Context(), db.CreateReportParams{
-		UserID:     user.ID,
-		ReportType: req.ReportType,
+	// callback deliveries are signed with a secret that belongs to the report
+	var callbackURL, callbackSecret sql.NullString
+	if req.CallbackURL != "" {
+		if err := s.validateOutboundURL(req.CallbackURL); err != nil {
+			errorResponse(w, http.StatusBadRequest, "Invalid callback URL: "+err.Error())
+			return
+		}
+		secret, err := newWebhookSecret()
+		if err != nil {
+			s.logger.Error("Error generating callback secret", err)
+			errorResponse(w, http.StatusInternalServerError, "Error generating callback secret")
+			return
+		}
+		callbackURL = sql.NullString{String: req.CallbackURL, Valid: true}
+		callbackSecret = sql.NullString{String: secret, Valid: true}
+	}
+
+	result, err := s.store.CreateReportTx(r.Context(), db.CreateReportTxParams{
+		Report: db.CreateReportParams{
+			UserID:          user.ID,
+			ReportType:      params.Type,
+			CallbackUrl:     callbackURL,
+			CallbackSecret:  callbackSecret,
+			Params:          paramsJSON,
+			ParamsHash:      sql.NullString{String: paramsHash, Valid: true},
+			TemplateID:      templateID,
+			TemplateVersion: templateVersion,
+			OrganizationID:  organizationId,
+		},
+		DeliverTo: recipients(req.DeliverTo),
 	})
 
 	if err != nil {
@@ -284,33 +414,160 @@ func (s *server) CreateReportHandler(w http.ResponseWriter, r *http.Request) {
 		errorResponse(w, http.StatusInternalServerError, "Error creating report")
 		return
 	}
+	report := result.Report
 
 	//  send sqs message to build the report
-	sqsMessage := reports.SQSMessage{
+	err = reports.EnqueueReport(r.Context(), s.sqsClient, s.config.SQS_QUEUE, reports.SQSMessage{
 		UserID:   report.UserID,
 		ReportID: report.ID,
+	})
+	if err != nil {
+		// the report is saved, so answer as created rather than invite a retry that would create it
+		// again; the scheduler enqueues it once it has been requested for REPORT_REQUEUE_AFTER
+		s.logger.Errorw("Error sending message to SQS", "report_id", report.ID, "error", err)
 	}
-	queueUrl, err := s.sqsClient.GetQueueUrl(r.Context(), &sqs.GetQueueUrlInput{
-		QueueName: aws.String(s.config.SQS_QUEUE),
+
+	// the callback secret is only returned once, when the report is created
+	reportResponse := newReportResponse(report)
+	reportResponse.CallbackSecret = report.CallbackSecret.String
+	reportResponse.EmailDeliveries = newEmailDeliveryResponses(result.EmailDeliveries)
+
+	jsonResponse(w, http.StatusCreated, reportResponse, "Report created successfully")
+}
+
+func (s *server) GetReportHandler(w http.ResponseWriter, r *http.Request) {
+	report, ok := s.loadReport(w, r)
+	if !ok {
+		return
+	}
+
+	if report.CompletedAt.Valid && s.downloadURLStale(report, time.Now()) {
+		signedUrl, expiresAt, err := s.presignDownload(r.Context(), report.OutputFilePath.String, time.Now())
+		if err != nil {
+			s.logger.Error("Error generating presigned URL", err)
+			errorResponse(w, http.StatusInternalServerError, "Error generating presigned URL")
+			return
+		}
+
+		// update the report
+		report, err = s.store.UpdateReport(r.Context(), db.UpdateReportParams{
+			ID:                report.ID,
+			UserID:            report.UserID,
+			DownloadUrl:       sql.NullString{String: signedUrl, Valid: true},
+			DownloadExpiresAt: sql.NullTime{Time: expiresAt, Valid: true},
+		})
+
+		if err != nil {
+			s.logger.Error("Error updating report with download URL", err)
+			errorResponse(w, http.StatusInternalServerError, "Error updating report with download URL")
+			return
+		}
+	}
+
+	attempts, err := s.store.ListReportAttempts(r.Context(), db.ListReportAttemptsParams{
+		UserID:   report.UserID,
+		ReportID: report.ID,
 	})
+	if err != nil {
+		s.logger.Error("Error getting report attempts", err)
+		errorResponse(w, http.StatusInternalServerError, "Error getting report attempts")
+		return
+	}
+
+	deliveries, err := s.store.ListReportEmailDeliveries(r.Context(), db.ListReportEmailDeliveriesParams{
+		UserID:   report.UserID,
+		ReportID: report.ID,
+	})
+	if err != nil {
+		s.logger.Error("Error getting report email deliveries", err)
+		errorResponse(w, http.StatusInternalServerError, "Error getting report email deliveries")
+		return
+	}
 
+	destinationDeliveries, err := s.store.ListReportDestinationDeliveries(r.Context(), db.ListReportDestinationDeliveriesParams{
+		UserID:   report.UserID,
+		ReportID: report.ID,
+	})
 	if err != nil {
-		s.logger.Error("Error getting SQS queue URL", err)
-		errorResponse(w, http.StatusInternalServerError, "Error getting SQS queue URL")
+		s.logger.Error("Error getting report destination deliveries", err)
+		errorResponse(w, http.StatusInternalServerError, "Error getting report destination deliveries")
+		return
+	}
+
+	reportResponse := newReportResponse(report)
+	reportResponse.EmailDeliveries = newEmailDeliveryResponses(deliveries)
+	reportResponse.DestinationDeliveries = newDestinationDeliveryResponses(destinationDeliveries)
+	for _, attempt := range attempts {
+		reportResponse.Attempts = append(reportResponse.Attempts, ReportAttemptResponse{
+			AttemptNumber: attempt.AttemptNumber,
+			StartedAt:     attempt.StartedAt.Time,
+			FailedAt:      attempt.FailedAt.Time,
+			ErrorMessage:  attempt.ErrorMessage.String,
+			CreatedAt:     attempt.CreatedAt,
+		})
+	}
+
+	jsonResponse(w, http.StatusOK, reportResponse, "Report retrieved successfully")
+}
+
+func (s *server) CancelReportHandler(w http.ResponseWriter, r *http.Request) {
+	report, ok := s.loadReport(w, r)
+	if !ok {
 		return
 	}
 
-	bytes, err := json.Marshal(sqsMessage)
+	if !s.authorizeChange(w, r, report.UserID, report.OrganizationID) {
+		return
+	}
 
+	// a requested report is skipped by the worker, a running one is stopped by the worker that owns it
+	report, err := s.store.CancelReport(r.Context(), db.CancelReportParams{
+		ID:     report.ID,
+		UserID: report.UserID,
+	})
 	if err != nil {
-		s.logger.Error("Error getting SQS queue URL", err)
-		errorResponse(w, http.StatusInternalServerError, "Error getting SQS queue URL")
+		if errors.Is(err, sql.ErrNoRows) {
+			errorResponse(w, http.StatusConflict, "Report can no longer be cancelled")
+			return
+		}
+		s.logger.Error("Error cancelling report", err)
+		errorResponse(w, http.StatusInternalServerError, "Error cancelling report")
 		return
 	}
 
-	_, err = s.sqsClient.SendMessage(r.Context(), &sqs.SendMessageInput{
-		QueueUrl:    queueUrl.QueueUrl,
-		MessageBody: aws.String(string(bytes)),
+	s.publishStatus(r.Context(), report)
+
+	jsonResponse(w, http.StatusOK, newReportResponse(report), "Report cancelled successfully")
+}
+
+func (s *server) RetryReportHandler(w http.ResponseWriter, r *http.Request) {
+	report, ok := s.loadReport(w, r)
+	if !ok {
+		return
+	}
+
+	if !s.authorizeChange(w, r, report.UserID, report.OrganizationID) {
+		return
+	}
+
+	// move the failed run into the attempt history and reset the report
+	result, err := s.store.RetryReportTx(r.Context(), db.RetryReportTxParams{
+		UserID: report.UserID,
+		ID:     report.ID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorResponse(w, http.StatusConflict, "Only failed reports can be retried")
+			return
+		}
+		s.logger.Error("Error resetting report", err)
+		errorResponse(w, http.StatusInternalServerError, "Error resetting report")
+		return
+	}
+
+	err = reports.EnqueueReport(r.Context(), s.sqsClient, s.config.SQS_QUEUE, reports.SQSMessage{
+		UserID:   result.Report.UserID,
+		ReportID: result.Report.ID,
 	})
 	if err != nil {
 		s.logger.Error("Error sending message to SQS", err)
@@ -318,28 +575,95 @@ func (s *server) CreateReportHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	reportResponse := ReportResponse{
-		ID:                   report.ID,
-		ReportType:           req.ReportType,
-		StartedAt:            report.StartedAt.Time,
-		Status:               GetStatus(report),
-		OutputFilePath:       report.OutputFilePath.String,
-		DownloadURL:          report.DownloadUrl.String,
-		DownloadUrlExpiresAt: report.DownloadExpiresAt.Time,
-		CompletedAt:          report.CompletedAt.Time,
-		FailedAt:             report.FailedAt.Time,
-		CreatedAt:            report.CreatedAt,
-		ErrorMessage:         report.ErrorMessage.String,
+	s.publishStatus(r.Context(), result.Report)
+
+	jsonResponse(w, http.StatusOK, newReportResponse(result.Report), "Report retry requested successfully")
+}
+
+func (s *server) DeleteReportHandler(w http.ResponseWriter, r *http.Request) {
+	report, ok := s.loadReport(w, r)
+	if !ok {
+		return
 	}
 
-	jsonResponse(w, http.StatusCreated, reportResponse, "Report created successfully")
+	if !s.authorizeChange(w, r, report.UserID, report.OrganizationID) {
+		return
+	}
+
+	result, err := s.store.DeleteReportTx(r.Context(), db.DeleteReportTxParams{
+		UserID: report.UserID,
+		ID:     report.ID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorResponse(w, http.StatusNotFound, "Report not found")
+			return
+		}
+		s.logger.Error("Error deleting report", err)
+		errorResponse(w, http.StatusInternalServerError, "Error deleting report")
+		return
+	}
+
+	// the object is only removed once no other report shares it
+	if result.OrphanedObjectKey != "" {
+		_, err := s.s3Client.DeleteObject(r.Context(), &s3.DeleteObjectInput{
+			Bucket: aws.String(s.config.S3_BUCKET),
+			Key:    aws.String(result.OrphanedObjectKey),
+		})
+		if err != nil {
+			s.logger.Errorw("Error removing report object", "key", result.OrphanedObjectKey, "error", err)
+		}
+	}
+
+	jsonResponse(w, http.StatusOK, nil, "Report deleted successfully")
 }
 
-func (s *server) GetReportHandler(w http.ResponseWriter, r *http.Request) {
+// resolveReportParams combines the template, if any, with the parameters of the request.
+func (s *server) resolveReportParams(w http.ResponseWriter, r *http.Request, user db.User, templateId *uuid.UUID, req ReportParamsRequest) (reports.Params, uuid.NullUUID, sql.NullInt32, bool) {
+	// a template provides the defaults, the request overrides them
+	var base reports.Params
+	var templateID uuid.NullUUID
+	var templateVersion sql.NullInt32
+	if templateId != nil {
+		template, err := s.store.GetReportTemplate(r.Context(), db.GetReportTemplateParams{
+			UserID: user.ID,
+			ID:     *templateId,
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				errorResponse(w, http.StatusBadRequest, "Template not found")
+				return reports.Params{}, templateID, templateVersion, false
+			}
+			s.logger.Error("Error getting template", err)
+			errorResponse(w, http.StatusInternalServerError, "Error getting template")
+			return reports.Params{}, templateID, templateVersion, false
+		}
+		base, err = reports.ParseParams(template.Params)
+		if err != nil {
+			s.logger.Error("Error decoding template params", err)
+			errorResponse(w, http.StatusInternalServerError, "Error getting template")
+			return reports.Params{}, templateID, templateVersion, false
+		}
+		templateID = uuid.NullUUID{UUID: template.ID, Valid: true}
+		templateVersion = sql.NullInt32{Int32: template.Version, Valid: true}
+	}
+
+	params, err := req.apply(base).Normalize()
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return reports.Params{}, templateID, templateVersion, false
+	}
+	return params, templateID, templateVersion, true
+}
+
+// loadReport fetches the report named in the URL if the signed-in user created it or is a member of its organization.
+// Like the other helpers that return ok, it writes the error response itself and reports whether the
+// caller can continue.
+func (s *server) loadReport(w http.ResponseWriter, r *http.Request) (db.Report, bool) {
 	user, ok := UserFromContext(r)
 	if !ok {
 		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
-		return
+		return db.Report{}, false
 	}
 
 	reportIdStr := chi.URLParam(r, "reportId")
@@ -347,7 +671,7 @@ func (s *server) GetReportHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		s.logger.Error("Error parsing report ID", err)
 		errorResponse(w, http.StatusBadRequest, "Invalid report ID")
-		return
+		return db.Report{}, false
 	}
 
 	report, err := s.store.GetReport(r.Context(), db.GetReportParams{
@@ -358,51 +682,29 @@ func (s *server) GetReportHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			errorResponse(w, http.StatusNotFound, "Report not found")
-			return
+			return db.Report{}, false
 		}
 		s.logger.Error("Error getting report", err)
 		errorResponse(w, http.StatusInternalServerError, "Error getting report")
-		return
+		return db.Report{}, false
 	}
 
-	if report.CompletedAt.Valid {
-		refreshNeeded := report.DownloadExpiresAt.Valid && report.DownloadExpiresAt.Time.Before(time.Now())
-		if !report.DownloadUrl.Valid || refreshNeeded {
-			expiredAt := time.Now().Add(time.Minute * 10)
-			signedUrl, err := s.presignedClient.PresignGetObject(r.Context(), &s3.GetObjectInput{
-				Bucket: aws.String(s.config.S3_BUCKET),
-				Key:    aws.String(report.OutputFilePath.String),
-			}, func(options *s3.PresignOptions) {
-				options.Expires = time.Second * 10
-			})
-
-			s.logger.Debug("Generated presigned URL:", signedUrl.URL)
-
-			if err != nil {
-				s.logger.Error("Error generating presigned URL", err)
-				errorResponse(w, http.StatusInternalServerError, "Error generating presigned URL")
-				return
-			}
-
-			// update the report
-			report, err = s.store.UpdateReport(r.Context(), db.UpdateReportParams{
-				ID:                report.ID,
-				UserID:            report.UserID,
-				DownloadUrl:       sql.NullString{String: signedUrl.URL, Valid: true},
-				DownloadExpiresAt: sql.NullTime{Time: expiredAt, Valid: true},
-			})
-
-			if err != nil {
-				s.logger.Error("Error updating report with download URL", err)
-				errorResponse(w, http.StatusInternalServerError, "Error updating report with download URL")
-				return
-			}
-		}
+	return report, true
+}
 
+func newReportResponse(report db.Report) ReportResponse {
+	var params *reports.Params
+	if p, err := reports.ParamsFromReport(report); err == nil {
+		params = &p
+	}
+	var templateID *uuid.UUID
+	if report.TemplateID.Valid {
+		templateID = &report.TemplateID.UUID
 	}
 
-	reportResponse := ReportResponse{
+	return ReportResponse{
 		ID:                   report.ID,
+		OrganizationID:       organizationID(report.OrganizationID),
 		ReportType:           report.ReportType,
 		OutputFilePath:       report.OutputFilePath.String,
 		DownloadURL:          report.DownloadUrl.String,
@@ -411,10 +713,47 @@ func (s *server) GetReportHandler(w http.ResponseWriter, r *http.Request) {
 		Status:               GetStatus(report),
 		CompletedAt:          report.CompletedAt.Time,
 		FailedAt:             report.FailedAt.Time,
+		CancelledAt:          report.CancelledAt.Time,
+		CreatedAt:            report.CreatedAt,
 		ErrorMessage:         report.ErrorMessage.String,
+		CallbackURL:          report.CallbackUrl.String,
+		Phase:                report.Phase.String,
+		RowsTotal:            report.RowsTotal.Int32,
+		RowsWritten:          report.RowsWritten,
+		BytesWritten:         report.BytesWritten,
+		Params:               params,
+		TemplateID:           templateID,
+		TemplateVersion:      report.TemplateVersion.Int32,
 	}
+}
 
-	jsonResponse(w, http.StatusOK, reportResponse, "Report retrieved successfully")
+// recipients lowercases the addresses and drops duplicates, keeping their order.
+func recipients(addresses []string) []string {
+	seen := make(map[string]bool, len(addresses))
+	unique := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		address = strings.ToLower(strings.TrimSpace(address))
+		if seen[address] {
+			continue
+		}
+		seen[address] = true
+		unique = append(unique, address)
+	}
+	return unique
+}
+
+func newEmailDeliveryResponses(deliveries []db.ReportEmailDelivery) []EmailDeliveryResponse {
+	response := make([]EmailDeliveryResponse, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		response = append(response, EmailDeliveryResponse{
+			Recipient:    delivery.Recipient,
+			Status:       delivery.Status,
+			AttemptCount: delivery.AttemptCount,
+			LastError:    delivery.LastError.String,
+			SentAt:       delivery.SentAt.Time,
+		})
+	}
+	return response
 }
 
 func isDone(r db.Report) bool {
@@ -424,6 +763,8 @@ func isDone(r db.Report) bool {
 
 func GetStatus(r db.Report) string {
 	switch {
+	case r.CancelledAt.Valid:
+		return "cancelled"
 	case !r.StartedAt.Valid:
 		return "requested"
 	case r.StartedAt.Valid && !isDone(r):
@@ -437,16 +778,28 @@ func GetStatus(r db.Report) string {
 	}
 }
 
-func hashToken(plain string) (string, error) {
-	// 1) Pre-hash:
+func hashToken(plain string) string {
+	// refresh tokens are looked up by hash, so it has to be deterministic;
+	// they are long random JWTs, which makes a plain SHA-256 enough
 	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
 
-	// 2) Bcrypt the 32-byte digest
-	bts, err := bcrypt.GenerateFromPassword(sum[:], bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
+// clientIP returns the address of the client, which realIP resolves from the headers of trusted proxies
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
 	}
+	return r.RemoteAddr
+}
 
-	// 3) Base64 for safe storage
-	return base64.StdEncoding.EncodeToString(bts), nil
+// truncate shortens s to at most n bytes without splitting a UTF-8 sequence
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
 }
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+)
+
+const (
+	idempotencyKeyHeader    = "Idempotency-Key"
+	idempotencyReplayHeader = "Idempotent-Replayed"
+	idempotencyKeyTTL       = 24 * time.Hour
+	maxIdempotencyKeyLength = 255
+)
+
+// idempotent makes a handler safe to retry with an Idempotency-Key header. The first request under a
+// key is executed and its response stored; retries with the same body get that response replayed and
+// retries with a different body are rejected. Server errors release the key so the request can be retried,
+// so handlers must not answer with one once they have saved anything.
+func (s *server) idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			errorResponse(w, http.StatusBadRequest, "Idempotency-Key is too long")
+			return
+		}
+
+		user, ok := UserFromContext(r)
+		if !ok {
+			errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		requestHash := fingerprint(r, body)
+		_, err = s.store.CreateIdempotencyKey(r.Context(), db.CreateIdempotencyKeyParams{
+			UserID:      user.ID,
+			Key:         key,
+			RequestHash: requestHash,
+			ExpiresAt:   time.Now().Add(idempotencyKeyTTL),
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			s.replay(w, r, user, key, requestHash)
+			return
+		}
+		if err != nil {
+			s.logger.Error("Error saving idempotency key", err)
+			errorResponse(w, http.StatusInternalServerError, "Error saving idempotency key")
+			return
+		}
+
+		// a panicking handler releases the key before the recoverer answers with a server error
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				s.releaseIdempotencyKey(context.WithoutCancel(r.Context()), user, key)
+				panic(recovered)
+			}
+		}()
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		// the response has been sent, so store it even if the client went away
+		ctx := context.WithoutCancel(r.Context())
+		if rec.status >= http.StatusInternalServerError {
+			s.releaseIdempotencyKey(ctx, user, key)
+			return
+		}
+		err = s.store.SaveIdempotencyResponse(ctx, db.SaveIdempotencyResponseParams{
+			UserID:       user.ID,
+			Key:          key,
+			StatusCode:   sql.NullInt32{Int32: int32(rec.status), Valid: true},
+			ResponseBody: rec.body.Bytes(),
+		})
+		if err != nil {
+			s.logger.Errorw("Error storing idempotent response", "key", key, "error", err)
+		}
+	})
+}
+
+// releaseIdempotencyKey deletes a key whose request failed, so it can be retried.
+func (s *server) releaseIdempotencyKey(ctx context.Context, user db.User, key string) {
+	err := s.store.DeleteIdempotencyKey(ctx, db.DeleteIdempotencyKeyParams{
+		UserID: user.ID,
+		Key:    key,
+	})
+	if err != nil {
+		s.logger.Errorw("Error releasing idempotency key", "key", key, "error", err)
+	}
+}
+
+// replay answers a request whose key has been used before.
+func (s *server) replay(w http.ResponseWriter, r *http.Request, user db.User, key string, requestHash string) {
+	stored, err := s.store.GetIdempotencyKey(r.Context(), db.GetIdempotencyKeyParams{
+		UserID: user.ID,
+		Key:    key,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// the original request failed and released the key in the meantime
+			errorResponse(w, http.StatusConflict, "Idempotency-Key was released, please retry")
+			return
+		}
+		s.logger.Error("Error getting idempotency key", err)
+		errorResponse(w, http.StatusInternalServerError, "Error getting idempotency key")
+		return
+	}
+
+	if stored.RequestHash != requestHash {
+		errorResponse(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request")
+		return
+	}
+	if !stored.StatusCode.Valid {
+		errorResponse(w, http.StatusConflict, "A request with this Idempotency-Key is still being processed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(idempotencyReplayHeader, "true")
+	w.WriteHeader(int(stored.StatusCode.Int32))
+	w.Write(stored.ResponseBody)
+}
+
+// fingerprint identifies a request by its method, path and body.
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.Path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder passes a response through while keeping a copy of its status and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// pruneIdempotencyKeys removes expired keys until the context is done.
+func (s *server) pruneIdempotencyKeys(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			removed, err := s.store.DeleteExpiredIdempotencyKeys(ctx)
+			if err != nil {
+				s.logger.Errorw("Error removing expired idempotency keys", "error", err)
+				continue
+			}
+			s.logger.Infow("removed expired idempotency keys", "count", removed)
+		}
+	}
+}
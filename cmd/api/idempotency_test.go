@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"go.uber.org/zap"
+)
+
+// idempotencyStore keeps the pending idempotency keys in memory.
+type idempotencyStore struct {
+	db.Store
+	pending map[string]bool
+}
+
+func (s *idempotencyStore) CreateIdempotencyKey(ctx context.Context, arg db.CreateIdempotencyKeyParams) (db.IdempotencyKey, error) {
+	s.pending[arg.Key] = true
+	return db.IdempotencyKey{UserID: arg.UserID, Key: arg.Key, RequestHash: arg.RequestHash}, nil
+}
+
+func (s *idempotencyStore) DeleteIdempotencyKey(ctx context.Context, arg db.DeleteIdempotencyKeyParams) error {
+	delete(s.pending, arg.Key)
+	return nil
+}
+
+func TestIdempotentReleasesKeyOnPanic(t *testing.T) {
+	store := &idempotencyStore{pending: map[string]bool{}}
+	s := &server{logger: zap.NewNop().Sugar(), store: store}
+
+	handler := s.idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/reports", strings.NewReader(`{}`))
+	req.Header.Set(idempotencyKeyHeader, "key")
+	req = req.WithContext(context.WithValue(req.Context(), "user", db.User{ID: uuid.New()}))
+
+	// the panic still reaches the recoverer
+	require.PanicsWithValue(t, "boom", func() {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	})
+	require.NotContains(t, store.pending, "key")
+}
@@ -2,8 +2,15 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/go-playground/validator/v10"
 	"net/http"
+	"strconv"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
 )
 
 var Validate *validator.Validate
@@ -30,6 +37,28 @@ func readJSON(w http.ResponseWriter, r *http.Request, data any) error {
 	return nil
 }
 
+// readPagination reads the limit and offset query parameters
+func readPagination(r *http.Request) (limit int32, offset int32, err error) {
+	limit = defaultPageSize
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxPageSize {
+			return 0, 0, errors.New("limit must be between 1 and " + strconv.Itoa(maxPageSize))
+		}
+		limit = int32(parsed)
+	}
+
+	if value := r.URL.Query().Get("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return 0, 0, errors.New("offset must be a positive number")
+		}
+		offset = int32(parsed)
+	}
+
+	return limit, offset, nil
+}
+
 func writeJSONError(w http.ResponseWriter, status int, message string) error {
 	data := map[string]string{"error": message}
 	return writeJSON(w, status, data)
@@ -0,0 +1,12 @@
+package main
+
+import "net/http"
+
+// jwksMaxAge lets verifiers cache the key set; new keys are published before they start signing
+const jwksMaxAge = "public, max-age=300"
+
+// JWKSHandler publishes the public signing keys as a JSON Web Key Set
+func (s *server) JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", jwksMaxAge)
+	writeJSON(w, http.StatusOK, s.tokenManager.JWKS())
+}
@@ -7,14 +7,17 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/trenchesdeveloper/csv-reporter/helpers"
+	"github.com/trenchesdeveloper/csv-reporter/mailer"
 
 	"go.uber.org/zap"
 	"log"
+	"net/http"
 	"time"
 
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/trenchesdeveloper/csv-reporter/config"
 	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"github.com/trenchesdeveloper/csv-reporter/reports"
 )
 
 const version = "0.0.1"
@@ -45,18 +48,34 @@ func main() {
 	defer cancel()
 
 	// Load the AWS SDK config
-	sqsClient, presignedClient := mustNewAWSClient(ctx, cfg)
+	sqsClient, presignedClient, s3Client := mustNewAWSClient(ctx, cfg)
 
 	// logger
 	logger := zap.Must(zap.NewProduction()).Sugar()
 	defer logger.Sync()
 
+	tokenManager, err := helpers.LoadJwtManager(cfg)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if cfg.JWT_KEYSET_PATH == "" && cfg.ENVIRONMENT == "production" {
+		logger.Warn("JWT_KEYSET_PATH is not set, signing tokens with the shared HS256 secret")
+	}
+
+	trustedProxies, err := parseTrustedProxies(cfg.TRUSTED_PROXIES)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
 	app := &server{
 		config:          cfg,
 		logger:          logger,
-		tokenManager:    helpers.NewJwtManager(cfg),
+		tokenManager:    tokenManager,
 		sqsClient:       sqsClient,
 		presignedClient: presignedClient,
+		s3Client:        s3Client,
+		lozClient:       reports.NewClient(&http.Client{Timeout: time.Second * 10}),
+		trustedProxies:  trustedProxies,
 	}
 
 	// connect to the database
@@ -76,6 +95,38 @@ func main() {
 
 	app.store = storage
 
+	// verification and password reset emails
+	if cfg.SMTP_HOST == "" {
+		logger.Warn("SMTP_HOST is not set, verification and password reset emails are not sent")
+	} else {
+		sender, err := mailer.NewSMTPMailer(cfg)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		app.accountMailer, err = mailer.NewAccountMailer(sender, cfg.APP_URL)
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	// load revoked tokens before serving so a restart doesn't accept them
+	app.denylist = newTokenDenylist(storage, cfg.DENYLIST_SYNC_INTERVAL, logger)
+	if err := app.denylist.sync(ctx); err != nil {
+		logger.Fatal(err)
+	}
+	go app.denylist.run(context.Background())
+
+	// fan out report events published by the workers
+	app.events = newReportEventHub(cfg.DBSOURCE, logger)
+	go func() {
+		if err := app.events.run(context.Background()); err != nil {
+			logger.Fatal(err)
+		}
+	}()
+
+	go app.pruneIdempotencyKeys(context.Background())
+	go app.pruneLoginThrottles(context.Background())
+
 	mux := app.mount()
 	if err := app.start(mux); err != nil {
 		logger.Fatal(err)
@@ -83,7 +134,7 @@ func main() {
 
 }
 
-func mustNewAWSClient(ctx context.Context, cfg *config.AppConfig) (*sqs.Client, *s3.PresignClient) {
+func mustNewAWSClient(ctx context.Context, cfg *config.AppConfig) (*sqs.Client, *s3.PresignClient, *s3.Client) {
 	// 1) Load the SDK config, explicitly setting your Localstack region for signing
 	sdkCfg, err := awsconfig.LoadDefaultConfig(ctx,
 		awsconfig.WithRegion(cfg.AWS_DEFAULT_REGION), // e.g. "us-west-2"
@@ -105,5 +156,5 @@ func mustNewAWSClient(ctx context.Context, cfg *config.AppConfig) (*sqs.Client,
 
 	presignerClient := s3.NewPresignClient(s3Client)
 
-	return sqsClient, presignerClient
+	return sqsClient, presignerClient, s3Client
 }
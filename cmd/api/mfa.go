@@ -0,0 +1,392 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+)
+
+const (
+	// recoveryCodeCount codes are issued at a time, each usable once instead of an authenticator code
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+	// recoveryCodeAlphabet has 32 letters, so each random byte picks one without bias
+	recoveryCodeAlphabet = "abcdefghijklmnopqrstuvwxyz234567"
+)
+
+type MfaCodeRequest struct {
+	// Code is a code from the authenticator app, or a recovery code where one is accepted.
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+type MfaLoginRequest struct {
+	MfaToken   string `json:"mfa_token" validate:"required"`
+	Code       string `json:"code" validate:"required,max=32"`
+	DeviceName string `json:"device_name" validate:"omitempty,max=100"`
+}
+
+type MfaEnrollmentResponse struct {
+	Secret string `json:"secret"`
+	// OtpauthURI is what authenticator apps import; render it as a QR code to scan.
+	OtpauthURI string `json:"otpauth_uri"`
+}
+
+type MfaRecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MfaChallengeResponse is returned by sign-in instead of tokens when the user has MFA enabled.
+type MfaChallengeResponse struct {
+	MfaRequired bool      `json:"mfa_required"`
+	MfaToken    string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+func (s *server) EnrollMfaHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if user.MfaEnabledAt.Valid {
+		errorResponse(w, http.StatusConflict, "MFA is already enabled")
+		return
+	}
+
+	// enrolling again replaces a secret that was never confirmed
+	secret, err := helpers.GenerateTOTPSecret()
+	if err != nil {
+		s.logger.Error("Error generating MFA secret", err)
+		errorResponse(w, http.StatusInternalServerError, "Error generating MFA secret")
+		return
+	}
+	_, err = s.store.SetUserMfaSecret(r.Context(), db.SetUserMfaSecretParams{
+		ID:        user.ID,
+		MfaSecret: sql.NullString{String: secret, Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorResponse(w, http.StatusConflict, "MFA is already enabled")
+			return
+		}
+		s.logger.Error("Error saving MFA secret", err)
+		errorResponse(w, http.StatusInternalServerError, "Error saving MFA secret")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, MfaEnrollmentResponse{
+		Secret:     secret,
+		OtpauthURI: helpers.TOTPURI(s.config.AppName, user.Email, secret),
+	}, "MFA enrollment started, confirm it with a code")
+}
+
+func (s *server) ConfirmMfaHandler(w http.ResponseWriter, r *http.Request) {
+	req, user, ok := s.readMfaCodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if user.MfaEnabledAt.Valid {
+		errorResponse(w, http.StatusConflict, "MFA is already enabled")
+		return
+	}
+	if !user.MfaSecret.Valid {
+		errorResponse(w, http.StatusBadRequest, "Start MFA enrollment first")
+		return
+	}
+
+	// only the authenticator proves the enrollment worked
+	step, ok := helpers.ValidateTOTP(user.MfaSecret.String, req.Code, time.Now())
+	if !ok {
+		errorResponse(w, http.StatusBadRequest, "Invalid MFA code")
+		return
+	}
+
+	codes, hashedCodes, err := newRecoveryCodes()
+	if err != nil {
+		s.logger.Error("Error generating recovery codes", err)
+		errorResponse(w, http.StatusInternalServerError, "Error generating recovery codes")
+		return
+	}
+
+	_, err = s.store.EnableMfaTx(r.Context(), db.EnableMfaTxParams{
+		UserID:              user.ID,
+		Step:                step,
+		HashedRecoveryCodes: hashedCodes,
+		IpAddress:           clientIP(r),
+		UserAgent:           truncate(r.UserAgent(), maxUserAgentLength),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorResponse(w, http.StatusConflict, "MFA is already enabled")
+			return
+		}
+		s.logger.Error("Error enabling MFA", err)
+		errorResponse(w, http.StatusInternalServerError, "Error enabling MFA")
+		return
+	}
+
+	// recovery codes are only shown once
+	jsonResponse(w, http.StatusOK, MfaRecoveryCodesResponse{RecoveryCodes: codes}, "MFA enabled successfully")
+}
+
+func (s *server) DisableMfaHandler(w http.ResponseWriter, r *http.Request) {
+	req, user, ok := s.readMfaCodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if !user.MfaEnabledAt.Valid {
+		errorResponse(w, http.StatusBadRequest, "MFA is not enabled")
+		return
+	}
+	if !s.checkMfaCode(w, r, user, req.Code, http.StatusBadRequest) {
+		return
+	}
+
+	_, err := s.store.DisableMfaTx(r.Context(), db.DisableMfaTxParams{
+		UserID:    user.ID,
+		IpAddress: clientIP(r),
+		UserAgent: truncate(r.UserAgent(), maxUserAgentLength),
+	})
+	if err != nil {
+		s.logger.Error("Error disabling MFA", err)
+		errorResponse(w, http.StatusInternalServerError, "Error disabling MFA")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, nil, "MFA disabled successfully")
+}
+
+func (s *server) RegenerateRecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	req, user, ok := s.readMfaCodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if !user.MfaEnabledAt.Valid {
+		errorResponse(w, http.StatusBadRequest, "MFA is not enabled")
+		return
+	}
+	if !s.checkMfaCode(w, r, user, req.Code, http.StatusBadRequest) {
+		return
+	}
+
+	codes, hashedCodes, err := newRecoveryCodes()
+	if err != nil {
+		s.logger.Error("Error generating recovery codes", err)
+		errorResponse(w, http.StatusInternalServerError, "Error generating recovery codes")
+		return
+	}
+	err = s.store.ReplaceMfaRecoveryCodesTx(r.Context(), db.ReplaceMfaRecoveryCodesTxParams{
+		UserID:              user.ID,
+		HashedRecoveryCodes: hashedCodes,
+	})
+	if err != nil {
+		s.logger.Error("Error replacing recovery codes", err)
+		errorResponse(w, http.StatusInternalServerError, "Error replacing recovery codes")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, MfaRecoveryCodesResponse{RecoveryCodes: codes}, "Recovery codes regenerated successfully")
+}
+
+// MfaLoginHandler finishes a sign-in that SigninHandler answered with an MFA challenge.
+func (s *server) MfaLoginHandler(w http.ResponseWriter, r *http.Request) {
+	var req MfaLoginRequest
+	if err := readJSON(w, r, &req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := Validate.Struct(req); err != nil {
+		errorResponse(w, http.StatusBadRequest, formatValidationErrors(err))
+		return
+	}
+
+	token, err := s.tokenManager.ValidateToken(req.MfaToken)
+	if err != nil || !s.tokenManager.IsMFAToken(token) {
+		errorResponse(w, http.StatusUnauthorized, "Invalid or expired MFA token")
+		return
+	}
+	claims, ok := s.tokenManager.Claims(token)
+	if !ok || s.denylist.denied(db.DeniedTokenKindToken, claims.ID) {
+		errorResponse(w, http.StatusUnauthorized, "Invalid or expired MFA token")
+		return
+	}
+	userId, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		errorResponse(w, http.StatusUnauthorized, "Invalid or expired MFA token")
+		return
+	}
+
+	user, err := s.store.FindUserById(r.Context(), userId)
+	if err != nil || !user.MfaEnabledAt.Valid {
+		errorResponse(w, http.StatusUnauthorized, "Invalid or expired MFA token")
+		return
+	}
+
+	// wrong codes count like wrong passwords, so a challenge can't be used to guess codes
+	reserved, ok := s.reserveLogin(w, r, user.Email)
+	if !ok {
+		return
+	}
+	used, err := s.useMfaCode(r, user, req.Code)
+	if err != nil {
+		s.releaseAttempt(r, reserved)
+		s.logger.Error("Error checking MFA code", err)
+		errorResponse(w, http.StatusInternalServerError, "Error checking MFA code")
+		return
+	}
+	if !used {
+		s.recordLoginFailure(r, reserved, uuid.NullUUID{UUID: user.ID, Valid: true})
+		errorResponse(w, http.StatusUnauthorized, "Invalid MFA code")
+		return
+	}
+	s.attemptSucceeded(r, reserved)
+
+	// a challenge completes one sign-in
+	expiresAt := claims.ExpiresAt.Time
+	if err := s.store.DenyToken(r.Context(), db.DenyTokenParams{
+		Kind:      db.DeniedTokenKindToken,
+		TokenID:   claims.ID,
+		UserID:    user.ID,
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		s.logger.Error("Error revoking MFA token", err)
+		errorResponse(w, http.StatusInternalServerError, "Error revoking MFA token")
+		return
+	}
+	s.denylist.add(db.DeniedTokenKindToken, claims.ID, expiresAt)
+
+	tokens, ok := s.startSession(w, r, user, req.DeviceName)
+	if !ok {
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, tokens, "Signin successful")
+}
+
+// readMfaCodeRequest reads the code of a request made by a signed-in user.
+func (s *server) readMfaCodeRequest(w http.ResponseWriter, r *http.Request) (MfaCodeRequest, db.User, bool) {
+	var req MfaCodeRequest
+	if err := readJSON(w, r, &req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return req, db.User{}, false
+	}
+
+	if err := Validate.Struct(req); err != nil {
+		errorResponse(w, http.StatusBadRequest, formatValidationErrors(err))
+		return req, db.User{}, false
+	}
+
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return req, db.User{}, false
+	}
+
+	return req, user, true
+}
+
+// checkMfaCode accepts an authenticator code that wasn't used before or an unused recovery code,
+// and uses it up. Wrong codes are answered with status and count like failed sign-ins of the user,
+// so a stolen session can't be used to guess codes either.
+func (s *server) checkMfaCode(w http.ResponseWriter, r *http.Request, user db.User, code string, status int) bool {
+	reserved, ok := s.reserveLogin(w, r, user.Email)
+	if !ok {
+		return false
+	}
+	ok, err := s.useMfaCode(r, user, code)
+	if err != nil {
+		s.releaseAttempt(r, reserved)
+		s.logger.Error("Error checking MFA code", err)
+		errorResponse(w, http.StatusInternalServerError, "Error checking MFA code")
+		return false
+	}
+	if !ok {
+		s.recordLoginFailure(r, reserved, uuid.NullUUID{UUID: user.ID, Valid: true})
+		errorResponse(w, status, "Invalid MFA code")
+		return false
+	}
+	s.attemptSucceeded(r, reserved)
+	return true
+}
+
+// useMfaCode reports whether code is a valid authenticator or recovery code of the user and uses it up.
+func (s *server) useMfaCode(r *http.Request, user db.User, code string) (bool, error) {
+	ctx := r.Context()
+	if step, ok := helpers.ValidateTOTP(user.MfaSecret.String, code, time.Now()); ok {
+		// each code works once, even while it is still current
+		used, err := s.store.UseMfaStep(ctx, db.UseMfaStepParams{
+			ID:              user.ID,
+			MfaLastUsedStep: step,
+		})
+		return used > 0, err
+	}
+
+	used, err := s.store.UseMfaRecoveryCode(ctx, db.UseMfaRecoveryCodeParams{
+		UserID:     user.ID,
+		HashedCode: hashToken(normalizeRecoveryCode(code)),
+	})
+	if err != nil || used == 0 {
+		return false, err
+	}
+
+	remaining, err := s.store.CountUnusedMfaRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		return false, err
+	}
+	details, err := json.Marshal(map[string]int64{"remaining": remaining})
+	if err != nil {
+		return false, err
+	}
+	if _, err := s.store.CreateSecurityEvent(ctx, db.CreateSecurityEventParams{
+		UserID:    uuid.NullUUID{UUID: user.ID, Valid: true},
+		EventType: db.SecurityEventMfaRecoveryCodeUsed,
+		IpAddress: clientIP(r),
+		UserAgent: truncate(r.UserAgent(), maxUserAgentLength),
+		Details:   details,
+	}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// newRecoveryCodes returns recovery codes formatted like "abcde-fghij" and the hashes to store.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashedCodes := make([]string, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		b := make([]byte, recoveryCodeLength)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		for i := range b {
+			b[i] = recoveryCodeAlphabet[int(b[i])%len(recoveryCodeAlphabet)]
+		}
+		code := string(b[:recoveryCodeLength/2]) + "-" + string(b[recoveryCodeLength/2:])
+		codes = append(codes, code)
+		hashedCodes = append(hashedCodes, hashToken(normalizeRecoveryCode(code)))
+	}
+	return codes, hashedCodes, nil
+}
+
+// normalizeRecoveryCode ignores case, dashes and spaces, which people add or drop when typing codes
+func normalizeRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(code)))
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/trenchesdeveloper/csv-reporter/config"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+	"go.uber.org/zap"
+)
+
+// mfaStore serves the queries of the second sign-in step.
+type mfaStore struct {
+	db.Store
+	user          db.User
+	recoveryCodes map[string]bool
+	sessions      int
+}
+
+func (s *mfaStore) FindUserById(ctx context.Context, id uuid.UUID) (db.User, error) {
+	return s.user, nil
+}
+
+func (s *mfaStore) UseMfaStep(ctx context.Context, arg db.UseMfaStepParams) (int64, error) {
+	if arg.MfaLastUsedStep <= s.user.MfaLastUsedStep {
+		return 0, nil
+	}
+	s.user.MfaLastUsedStep = arg.MfaLastUsedStep
+	return 1, nil
+}
+
+func (s *mfaStore) UseMfaRecoveryCode(ctx context.Context, arg db.UseMfaRecoveryCodeParams) (int64, error) {
+	if used, ok := s.recoveryCodes[arg.HashedCode]; !ok || used {
+		return 0, nil
+	}
+	s.recoveryCodes[arg.HashedCode] = true
+	return 1, nil
+}
+
+func (s *mfaStore) CountUnusedMfaRecoveryCodes(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return 0, nil
+}
+
+func (s *mfaStore) CreateSecurityEvent(ctx context.Context, arg db.CreateSecurityEventParams) (db.SecurityEvent, error) {
+	return db.SecurityEvent{}, nil
+}
+
+func (s *mfaStore) DenyToken(ctx context.Context, arg db.DenyTokenParams) error {
+	return nil
+}
+
+func (s *mfaStore) CreateSessionTx(ctx context.Context, arg db.CreateSessionTxParams) (db.CreateSessionTxResult, error) {
+	s.sessions++
+	return db.CreateSessionTxResult{}, nil
+}
+
+func TestMfaLoginHandler(t *testing.T) {
+	secret, err := helpers.GenerateTOTPSecret()
+	require.NoError(t, err)
+	codes, hashedCodes, err := newRecoveryCodes()
+	require.NoError(t, err)
+
+	store := &mfaStore{
+		Store: newThrottleStore(),
+		user: db.User{
+			ID:           uuid.New(),
+			MfaSecret:    sql.NullString{String: secret, Valid: true},
+			MfaEnabledAt: sql.NullTime{Time: time.Now(), Valid: true},
+		},
+		recoveryCodes: map[string]bool{hashedCodes[0]: false},
+	}
+	s := &server{
+		config:       &config.AppConfig{},
+		logger:       zap.NewNop().Sugar(),
+		store:        store,
+		tokenManager: helpers.NewJwtManager(&config.AppConfig{AppName: "csv_reporter", JWT_SECRET: "secretToSecretMySecret"}),
+	}
+	s.denylist = newTokenDenylist(store, time.Second, s.logger)
+
+	login := func(mfaToken, code string) int {
+		body, err := json.Marshal(MfaLoginRequest{MfaToken: mfaToken, Code: code})
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		s.MfaLoginHandler(rec, httptest.NewRequest(http.MethodPost, "/auth/login/mfa", bytes.NewReader(body)))
+		return rec.Code
+	}
+	challenge := func() string {
+		mfaToken, _, err := s.tokenManager.GenerateMFAToken(store.user.ID)
+		require.NoError(t, err)
+		return mfaToken
+	}
+
+	code, err := helpers.TOTPCode(secret, helpers.TOTPStep(time.Now()))
+	require.NoError(t, err)
+
+	mfaToken := challenge()
+	require.Equal(t, http.StatusUnauthorized, login(mfaToken, "000000x"))
+	require.Equal(t, http.StatusOK, login(mfaToken, code))
+	require.Equal(t, 1, store.sessions)
+
+	// the challenge completes one sign-in, and the code can't be used again
+	require.Equal(t, http.StatusUnauthorized, login(mfaToken, code))
+	require.Equal(t, http.StatusUnauthorized, login(challenge(), code))
+
+	// recovery codes work once, however they are typed
+	require.Equal(t, http.StatusOK, login(challenge(), " "+strings.ToUpper(codes[0])))
+	require.Equal(t, http.StatusUnauthorized, login(challenge(), codes[0]))
+	require.Equal(t, 2, store.sessions)
+
+	// access tokens can't stand in for a challenge
+	tokens, err := s.tokenManager.GenerateSessionTokenPairs(store.user.ID, uuid.New(), "")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, login(tokens.AccessToken, code))
+}
+
+func (s *mfaStore) DisableMfaTx(ctx context.Context, arg db.DisableMfaTxParams) (db.User, error) {
+	s.user.MfaEnabledAt = sql.NullTime{}
+	return s.user, nil
+}
+
+func TestDisableMfaThrottle(t *testing.T) {
+	secret, err := helpers.GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	store := &mfaStore{
+		Store: newThrottleStore(),
+		user: db.User{
+			ID:           uuid.New(),
+			Email:        "user@example.com",
+			MfaSecret:    sql.NullString{String: secret, Valid: true},
+			MfaEnabledAt: sql.NullTime{Time: time.Now(), Valid: true},
+		},
+	}
+	s := &server{
+		config: &config.AppConfig{
+			LOGIN_MAX_FAILURES:     3,
+			LOGIN_IP_MAX_FAILURES:  50,
+			LOGIN_FAILURE_WINDOW:   time.Minute,
+			LOGIN_LOCKOUT_DURATION: time.Hour,
+		},
+		logger: zap.NewNop().Sugar(),
+		store:  store,
+	}
+
+	disable := func(code string) int {
+		body, err := json.Marshal(MfaCodeRequest{Code: code})
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/auth/mfa/disable", bytes.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), "user", store.user))
+		rec := httptest.NewRecorder()
+		s.DisableMfaHandler(rec, req)
+		return rec.Code
+	}
+
+	for range 3 {
+		require.Equal(t, http.StatusBadRequest, disable("000000"))
+	}
+
+	// wrong codes lock the account like wrong passwords, so the right code is refused too
+	code, err := helpers.TOTPCode(secret, helpers.TOTPStep(time.Now()))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusTooManyRequests, disable(code))
+	require.True(t, store.user.MfaEnabledAt.Valid)
+}
+
+func TestNewRecoveryCodes(t *testing.T) {
+	codes, hashedCodes, err := newRecoveryCodes()
+	require.NoError(t, err)
+	require.Len(t, codes, recoveryCodeCount)
+	require.Len(t, hashedCodes, recoveryCodeCount)
+
+	format := regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`)
+	for i, code := range codes {
+		require.Regexp(t, format, code)
+		require.Equal(t, hashToken(normalizeRecoveryCode(code)), hashedCodes[i])
+	}
+	require.Equal(t, "abcdefghij", normalizeRecoveryCode(" ABCDE-fghij "))
+}
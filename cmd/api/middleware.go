@@ -5,12 +5,13 @@ import (
 	"github.com/google/uuid"
 	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
 	"github.com/trenchesdeveloper/csv-reporter/helpers"
+	"go.uber.org/zap"
 	"log"
 	"net/http"
 	"strings"
 )
 
-func NewAuthMiddleware(jwtManager *helpers.JwtManager, store db.Store) func(next http.Handler) http.Handler {
+func NewAuthMiddleware(jwtManager *helpers.JwtManager, store db.Store, denylist *tokenDenylist, logger *zap.SugaredLogger) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Extract the token from the Authorization header
@@ -27,6 +28,15 @@ func NewAuthMiddleware(jwtManager *helpers.JwtManager, store db.Store) func(next
 				authHeader = strings.TrimSpace(parts[1])
 			}
 
+			if strings.HasPrefix(authHeader, apiKeyPrefix) {
+				ctx, ok := authenticateAPIKey(w, r, store, logger, authHeader)
+				if !ok {
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
 			// Verify the token
 			claims, err := jwtManager.ValidateToken(authHeader)
 			if err != nil {
@@ -40,6 +50,18 @@ func NewAuthMiddleware(jwtManager *helpers.JwtManager, store db.Store) func(next
 				return
 			}
 
+			// logged out tokens and revoked sessions are refused until the token expires
+			tokenClaims, ok := jwtManager.Claims(claims)
+			if !ok {
+				errorResponse(w, http.StatusUnauthorized, "UnAuthorized")
+				return
+			}
+			if denylist.denied(db.DeniedTokenKindToken, tokenClaims.ID) ||
+				denylist.denied(db.DeniedTokenKindSession, tokenClaims.SessionID) {
+				errorResponse(w, http.StatusUnauthorized, "Token revoked")
+				return
+			}
+
 			// extract the user ID from the claims
 			userIdStr, err := claims.Claims.GetSubject()
 			if err != nil {
@@ -61,8 +83,12 @@ func NewAuthMiddleware(jwtManager *helpers.JwtManager, store db.Store) func(next
 				return
 			}
 
-			// Set the user in the request context
+			// Set the user, the token claims and the session in the request context
 			ctx := context.WithValue(r.Context(), "user", user)
+			ctx = context.WithValue(ctx, "claims", tokenClaims)
+			if sessionId, ok := jwtManager.SessionID(claims); ok {
+				ctx = context.WithValue(ctx, "session_id", sessionId)
+			}
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -74,4 +100,16 @@ func UserFromContext(r *http.Request) (db.User, bool) {
 		return db.User{}, false
 	}
 	return user, true
-}
\ No newline at end of file
+}
+
+// SessionIDFromContext returns the session of the access token, if it has one
+func SessionIDFromContext(r *http.Request) (uuid.UUID, bool) {
+	sessionId, ok := r.Context().Value("session_id").(uuid.UUID)
+	return sessionId, ok
+}
+
+// ClaimsFromContext returns the claims of the access token
+func ClaimsFromContext(r *http.Request) (*helpers.CustomClaims, bool) {
+	claims, ok := r.Context().Value("claims").(*helpers.CustomClaims)
+	return claims, ok
+}
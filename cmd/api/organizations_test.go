@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"go.uber.org/zap"
+)
+
+// membershipStore serves the membership lookups of authorizeChange.
+type membershipStore struct {
+	db.Store
+	members []db.OrganizationMember
+}
+
+func (s *membershipStore) GetOrganizationMember(ctx context.Context, arg db.GetOrganizationMemberParams) (db.OrganizationMember, error) {
+	for _, member := range s.members {
+		if member.OrganizationID == arg.OrganizationID && member.UserID == arg.UserID {
+			return member, nil
+		}
+	}
+	return db.OrganizationMember{}, sql.ErrNoRows
+}
+
+// invitationStore keeps invitations in memory on top of membershipStore.
+type invitationStore struct {
+	membershipStore
+	invitations []db.OrganizationInvitation
+}
+
+func (s *invitationStore) CreateOrganizationInvitation(ctx context.Context, arg db.CreateOrganizationInvitationParams) (db.OrganizationInvitation, error) {
+	invitation := db.OrganizationInvitation{
+		ID:             uuid.New(),
+		OrganizationID: arg.OrganizationID,
+		Email:          arg.Email,
+		Role:           arg.Role,
+		ExpiresAt:      arg.ExpiresAt,
+	}
+	s.invitations = append(s.invitations, invitation)
+	return invitation, nil
+}
+
+func (s *invitationStore) AcceptOrganizationInvitationTx(ctx context.Context, arg db.AcceptOrganizationInvitationTxParams) (db.OrganizationMember, error) {
+	for i, invitation := range s.invitations {
+		if invitation.ID == arg.ID && invitation.Email == arg.Email {
+			s.invitations = append(s.invitations[:i], s.invitations[i+1:]...)
+			member := db.OrganizationMember{OrganizationID: invitation.OrganizationID, UserID: arg.UserID, Role: invitation.Role}
+			s.members = append(s.members, member)
+			return member, nil
+		}
+	}
+	return db.OrganizationMember{}, sql.ErrNoRows
+}
+
+func TestOrganizationInvitations(t *testing.T) {
+	organizationId := uuid.New()
+	admin := uuid.New()
+	store := &invitationStore{membershipStore: membershipStore{members: []db.OrganizationMember{
+		{OrganizationID: organizationId, UserID: admin, Role: db.OrganizationRoleAdmin},
+	}}}
+	s := &server{logger: zap.NewNop().Sugar(), store: store}
+
+	invite := func(email string) *httptest.ResponseRecorder {
+		routeContext := chi.NewRouteContext()
+		routeContext.URLParams.Add("organizationId", organizationId.String())
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"email":"`+email+`","role":"member"}`))
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, routeContext)
+		req = req.WithContext(context.WithValue(ctx, "user", db.User{ID: admin}))
+		rec := httptest.NewRecorder()
+		s.InviteOrganizationMemberHandler(rec, req)
+		return rec
+	}
+	accept := func(user db.User, invitationId uuid.UUID) int {
+		routeContext := chi.NewRouteContext()
+		routeContext.URLParams.Add("invitationId", invitationId.String())
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, routeContext)
+		req = req.WithContext(context.WithValue(ctx, "user", user))
+		rec := httptest.NewRecorder()
+		s.AcceptInvitationHandler(rec, req)
+		return rec.Code
+	}
+
+	// inviting doesn't look the email up, so it can't tell whether it has an account
+	rec := invite("Bob@Example.com")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	require.Len(t, store.members, 1)
+	invitation := store.invitations[0]
+	require.Equal(t, "bob@example.com", invitation.Email)
+
+	bob := db.User{ID: uuid.New(), Email: "bob@example.com"}
+	require.Equal(t, http.StatusForbidden, accept(bob, invitation.ID))
+
+	eve := db.User{ID: uuid.New(), Email: "eve@example.com", EmailVerifiedAt: sql.NullTime{Time: time.Now(), Valid: true}}
+	require.Equal(t, http.StatusNotFound, accept(eve, invitation.ID))
+
+	bob.EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	require.Equal(t, http.StatusCreated, accept(bob, invitation.ID))
+	require.Equal(t, db.OrganizationMember{OrganizationID: organizationId, UserID: bob.ID, Role: db.OrganizationRoleMember}, store.members[1])
+	require.Equal(t, http.StatusNotFound, accept(bob, invitation.ID))
+}
+
+func TestAuthorizeChange(t *testing.T) {
+	organizationId := uuid.New()
+	creator, admin, member, outsider := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	s := &server{
+		logger: zap.NewNop().Sugar(),
+		store: &membershipStore{members: []db.OrganizationMember{
+			{OrganizationID: organizationId, UserID: creator, Role: db.OrganizationRoleMember},
+			{OrganizationID: organizationId, UserID: admin, Role: db.OrganizationRoleAdmin},
+			{OrganizationID: organizationId, UserID: member, Role: db.OrganizationRoleMember},
+		}},
+	}
+	organization := uuid.NullUUID{UUID: organizationId, Valid: true}
+
+	testCases := []struct {
+		name         string
+		userId       uuid.UUID
+		organization uuid.NullUUID
+		status       int
+	}{
+		{"creator", creator, organization, http.StatusOK},
+		{"organization admin", admin, organization, http.StatusOK},
+		{"organization member", member, organization, http.StatusForbidden},
+		{"outsider", outsider, organization, http.StatusNotFound},
+		{"personal resource", admin, uuid.NullUUID{}, http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/", nil)
+			req = req.WithContext(context.WithValue(req.Context(), "user", db.User{ID: tc.userId}))
+			rec := httptest.NewRecorder()
+
+			if s.authorizeChange(rec, req, creator, tc.organization) {
+				rec.WriteHeader(http.StatusOK)
+			}
+			require.Equal(t, tc.status, rec.Code)
+		})
+	}
+}
+
+func TestCanManageMembers(t *testing.T) {
+	owner := db.OrganizationMember{Role: db.OrganizationRoleOwner}
+	admin := db.OrganizationMember{Role: db.OrganizationRoleAdmin}
+	member := db.OrganizationMember{Role: db.OrganizationRoleMember}
+
+	require.True(t, canManageMembers(httptest.NewRecorder(), owner, db.OrganizationRoleOwner))
+	require.True(t, canManageMembers(httptest.NewRecorder(), admin, db.OrganizationRoleAdmin))
+	require.False(t, canManageMembers(httptest.NewRecorder(), admin, db.OrganizationRoleOwner))
+	require.False(t, canManageMembers(httptest.NewRecorder(), member, db.OrganizationRoleMember))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trenchesdeveloper/csv-reporter/reports"
+)
+
+const (
+	// previewTimeout bounds the synchronous fetch of a preview.
+	previewTimeout = 10 * time.Second
+
+	defaultPreviewLimit = 20
+	maxPreviewLimit     = 100
+)
+
+// PreviewReportRequest previews the first rows of a report without building it.
+type PreviewReportRequest struct {
+	TemplateID *uuid.UUID `json:"template_id,omitempty"`
+	Limit      int        `json:"limit,omitempty" validate:"omitempty,min=1,max=100"`
+	ReportParamsRequest
+}
+
+type PreviewReportResponse struct {
+	Params  reports.Params      `json:"params"`
+	Columns []string            `json:"columns"`
+	Rows    []map[string]string `json:"rows"`
+}
+
+// PreviewReportHandler runs the report pipeline synchronously for the first rows only.
+// Nothing is stored, uploaded or queued.
+func (s *server) PreviewReportHandler(w http.ResponseWriter, r *http.Request) {
+	var req PreviewReportRequest
+	if err := readJSON(w, r, &req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := Validate.Struct(req); err != nil {
+		errorResponse(w, http.StatusBadRequest, formatValidationErrors(err))
+		return
+	}
+
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	params, _, _, ok := s.resolveReportParams(w, r, user, req.TemplateID, req.ReportParamsRequest)
+	if !ok {
+		return
+	}
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultPreviewLimit
+	}
+	limit = min(limit, maxPreviewLimit)
+
+	ctx, cancel := context.WithTimeout(r.Context(), previewTimeout)
+	defer cancel()
+
+	entries, err := s.lozClient.FetchEntries(ctx, params)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			errorResponse(w, http.StatusGatewayTimeout, "Preview timed out")
+			return
+		}
+		s.logger.Error("Error fetching preview entries", err)
+		errorResponse(w, http.StatusBadGateway, "Error fetching preview entries")
+		return
+	}
+
+	rows := reports.Rows(entries, params, limit)
+	response := PreviewReportResponse{
+		Params:  params,
+		Columns: params.Columns,
+		Rows:    make([]map[string]string, 0, len(rows)),
+	}
+	for _, row := range rows {
+		response.Rows = append(response.Rows, reports.RowObject(params.Columns, row))
+	}
+
+	jsonResponse(w, http.StatusOK, response, "Report preview generated successfully")
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// parseTrustedProxies parses a comma separated list of addresses and CIDR ranges.
+func parseTrustedProxies(list string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+			}
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		proxies = append(proxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return proxies, nil
+}
+
+// isTrustedProxy reports whether addr is one of the proxies.
+func isTrustedProxy(proxies []netip.Prefix, addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range proxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// realIP sets RemoteAddr to the address of the client. The forwarding headers are only believed
+// when the request comes from one of the trusted proxies; otherwise anyone could pick the address
+// that brute force throttling keys on. X-Forwarded-For is read from the right, skipping the
+// trusted proxies, because the client can put anything on its left.
+func realIP(proxies []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ip, ok := forwardedIP(r, proxies); ok {
+				r.RemoteAddr = ip.String()
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedIP returns the client address the trusted proxies forwarded r for.
+func forwardedIP(r *http.Request, proxies []netip.Prefix) (netip.Addr, bool) {
+	if len(proxies) == 0 {
+		return netip.Addr{}, false
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil || !isTrustedProxy(proxies, peer) {
+		return netip.Addr{}, false
+	}
+
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		var client netip.Addr
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+			client = hop.Unmap()
+			if !isTrustedProxy(proxies, client) {
+				break
+			}
+		}
+		return client, client.IsValid()
+	}
+	if xrip, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return xrip.Unmap(), true
+	}
+	return netip.Addr{}, false
+}
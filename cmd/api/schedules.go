@@ -0,0 +1,279 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"github.com/trenchesdeveloper/csv-reporter/reports"
+)
+
+// ScheduleRequest creates or replaces a schedule.
+type ScheduleRequest struct {
+	Name     string `json:"name" validate:"required,max=255"`
+	Cron     string `json:"cron" validate:"required,max=255"`
+	Timezone string `json:"timezone,omitempty" validate:"omitempty,max=64"`
+	Enabled  *bool  `json:"enabled,omitempty"`
+	// OrganizationID shares a new schedule and its reports with the members of an organization;
+	// updates keep the organization.
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty"`
+	ReportParamsRequest
+}
+
+type ScheduleResponse struct {
+	ID             uuid.UUID      `json:"id"`
+	OrganizationID *uuid.UUID     `json:"organization_id,omitempty"`
+	Name           string         `json:"name"`
+	Cron           string         `json:"cron"`
+	Timezone       string         `json:"timezone"`
+	Enabled        bool           `json:"enabled"`
+	Params         reports.Params `json:"params"`
+	LastRunAt      time.Time      `json:"last_run_at,omitempty"`
+	LastReportID   *uuid.UUID     `json:"last_report_id,omitempty"`
+	LastError      string         `json:"last_error,omitempty"`
+	NextRunAt      time.Time      `json:"next_run_at"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// scheduleFields is a validated schedule request, ready to be stored.
+type scheduleFields struct {
+	timezone  string
+	enabled   bool
+	params    json.RawMessage
+	nextRunAt time.Time
+}
+
+// readScheduleRequest reads and validates a schedule request.
+func (s *server) readScheduleRequest(w http.ResponseWriter, r *http.Request) (ScheduleRequest, scheduleFields, bool) {
+	var req ScheduleRequest
+	if err := readJSON(w, r, &req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return req, scheduleFields{}, false
+	}
+
+	if err := Validate.Struct(req); err != nil {
+		errorResponse(w, http.StatusBadRequest, formatValidationErrors(err))
+		return req, scheduleFields{}, false
+	}
+
+	fields := scheduleFields{timezone: req.Timezone, enabled: true}
+	if fields.timezone == "" {
+		fields.timezone = "UTC"
+	}
+	if req.Enabled != nil {
+		fields.enabled = *req.Enabled
+	}
+
+	var err error
+	fields.nextRunAt, err = reports.NextRun(req.Cron, fields.timezone, time.Now())
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return req, scheduleFields{}, false
+	}
+
+	params, err := req.params()
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return req, scheduleFields{}, false
+	}
+	fields.params, err = json.Marshal(params)
+	if err != nil {
+		s.logger.Error("Error encoding report params", err)
+		errorResponse(w, http.StatusInternalServerError, "Error encoding report params")
+		return req, scheduleFields{}, false
+	}
+
+	return req, fields, true
+}
+
+func (s *server) CreateScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	req, fields, ok := s.readScheduleRequest(w, r)
+	if !ok {
+		return
+	}
+
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	organizationId, ok := s.organizationFor(w, r, req.OrganizationID)
+	if !ok {
+		return
+	}
+
+	schedule, err := s.store.CreateReportSchedule(r.Context(), db.CreateReportScheduleParams{
+		UserID:         user.ID,
+		Name:           req.Name,
+		CronExpression: req.Cron,
+		Timezone:       fields.timezone,
+		Params:         fields.params,
+		Enabled:        fields.enabled,
+		NextRunAt:      fields.nextRunAt,
+		OrganizationID: organizationId,
+	})
+	if err != nil {
+		s.logger.Error("Error creating schedule", err)
+		errorResponse(w, http.StatusInternalServerError, "Error creating schedule")
+		return
+	}
+
+	jsonResponse(w, http.StatusCreated, newScheduleResponse(schedule), "Schedule created successfully")
+}
+
+func (s *server) ListSchedulesHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	schedules, err := s.store.ListReportSchedules(r.Context(), user.ID)
+	if err != nil {
+		s.logger.Error("Error listing schedules", err)
+		errorResponse(w, http.StatusInternalServerError, "Error listing schedules")
+		return
+	}
+
+	response := make([]ScheduleResponse, 0, len(schedules))
+	for _, schedule := range schedules {
+		response = append(response, newScheduleResponse(schedule))
+	}
+
+	jsonResponse(w, http.StatusOK, response, "Schedules retrieved successfully")
+}
+
+func (s *server) GetScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	schedule, ok := s.loadSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, newScheduleResponse(schedule), "Schedule retrieved successfully")
+}
+
+func (s *server) UpdateScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	schedule, ok := s.loadSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	if !s.authorizeChange(w, r, schedule.UserID, schedule.OrganizationID) {
+		return
+	}
+
+	req, fields, ok := s.readScheduleRequest(w, r)
+	if !ok {
+		return
+	}
+
+	schedule, err := s.store.UpdateReportSchedule(r.Context(), db.UpdateReportScheduleParams{
+		UserID:         schedule.UserID,
+		ID:             schedule.ID,
+		Name:           req.Name,
+		CronExpression: req.Cron,
+		Timezone:       fields.timezone,
+		Params:         fields.params,
+		Enabled:        fields.enabled,
+		NextRunAt:      fields.nextRunAt,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorResponse(w, http.StatusNotFound, "Schedule not found")
+			return
+		}
+		s.logger.Error("Error updating schedule", err)
+		errorResponse(w, http.StatusInternalServerError, "Error updating schedule")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, newScheduleResponse(schedule), "Schedule updated successfully")
+}
+
+func (s *server) DeleteScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	schedule, ok := s.loadSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	if !s.authorizeChange(w, r, schedule.UserID, schedule.OrganizationID) {
+		return
+	}
+
+	deleted, err := s.store.DeleteReportSchedule(r.Context(), db.DeleteReportScheduleParams{
+		UserID: schedule.UserID,
+		ID:     schedule.ID,
+	})
+	if err != nil {
+		s.logger.Error("Error deleting schedule", err)
+		errorResponse(w, http.StatusInternalServerError, "Error deleting schedule")
+		return
+	}
+	if deleted == 0 {
+		errorResponse(w, http.StatusNotFound, "Schedule not found")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, nil, "Schedule deleted successfully")
+}
+
+// loadSchedule fetches the schedule named in the URL if the signed-in user created it or is a member of its organization.
+func (s *server) loadSchedule(w http.ResponseWriter, r *http.Request) (db.ReportSchedule, bool) {
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return db.ReportSchedule{}, false
+	}
+
+	scheduleId, err := uuid.Parse(chi.URLParam(r, "scheduleId"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid schedule ID")
+		return db.ReportSchedule{}, false
+	}
+
+	schedule, err := s.store.GetReportSchedule(r.Context(), db.GetReportScheduleParams{
+		UserID: user.ID,
+		ID:     scheduleId,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorResponse(w, http.StatusNotFound, "Schedule not found")
+			return db.ReportSchedule{}, false
+		}
+		s.logger.Error("Error getting schedule", err)
+		errorResponse(w, http.StatusInternalServerError, "Error getting schedule")
+		return db.ReportSchedule{}, false
+	}
+
+	return schedule, true
+}
+
+func newScheduleResponse(schedule db.ReportSchedule) ScheduleResponse {
+	response := ScheduleResponse{
+		ID:             schedule.ID,
+		OrganizationID: organizationID(schedule.OrganizationID),
+		Name:           schedule.Name,
+		Cron:           schedule.CronExpression,
+		Timezone:       schedule.Timezone,
+		Enabled:        schedule.Enabled,
+		LastRunAt:      schedule.LastRunAt.Time,
+		LastError:      schedule.LastError.String,
+		NextRunAt:      schedule.NextRunAt,
+		CreatedAt:      schedule.CreatedAt,
+		UpdatedAt:      schedule.UpdatedAt,
+	}
+	if params, err := reports.ParseParams(schedule.Params); err == nil {
+		response.Params = params
+	}
+	if schedule.LastReportID.Valid {
+		response.LastReportID = &schedule.LastReportID.UUID
+	}
+	return response
+}
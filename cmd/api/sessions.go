@@ -0,0 +1,185 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+)
+
+type SessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	DeviceName string    `json:"device_name,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	IpAddress  string    `json:"ip_address,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Current marks the session the request was made from.
+	Current bool `json:"current"`
+}
+
+// startSession creates a session for a signed-in user and issues its first token pair.
+func (s *server) startSession(w http.ResponseWriter, r *http.Request, user db.User, deviceName string) (*helpers.TokenPairs, bool) {
+	sessionId := uuid.New()
+	token, err := s.tokenManager.GenerateSessionTokenPairs(user.ID, sessionId, user.Role)
+	if err != nil {
+		s.logger.Error("Error generating token", err)
+		errorResponse(w, http.StatusInternalServerError, "Error generating token")
+		return nil, false
+	}
+
+	_, err = s.store.CreateSessionTx(r.Context(), db.CreateSessionTxParams{
+		Session: db.CreateSessionParams{
+			ID:         sessionId,
+			UserID:     user.ID,
+			DeviceName: deviceName,
+			UserAgent:  truncate(r.UserAgent(), maxUserAgentLength),
+			IpAddress:  clientIP(r),
+			ExpiresAt:  time.Now().Add(helpers.RefreshTokenTTL),
+		},
+		HashedToken: hashToken(token.RefreshToken),
+	})
+	if err != nil {
+		s.logger.Error("Error creating session", err)
+		errorResponse(w, http.StatusInternalServerError, "Error creating session")
+		return nil, false
+	}
+
+	return token, true
+}
+
+func (s *server) ListSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	sessions, err := s.store.ListActiveSessions(r.Context(), user.ID)
+	if err != nil {
+		s.logger.Error("Error listing sessions", err)
+		errorResponse(w, http.StatusInternalServerError, "Error listing sessions")
+		return
+	}
+
+	currentId, _ := SessionIDFromContext(r)
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, newSessionResponse(session, currentId))
+	}
+
+	jsonResponse(w, http.StatusOK, response, "Sessions retrieved successfully")
+}
+
+func (s *server) RevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	sessionId, err := uuid.Parse(chi.URLParam(r, "sessionId"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	revoked, ok := s.revokeSessions(w, r, db.RevokeSessionsTxParams{
+		UserID:    user.ID,
+		SessionID: uuid.NullUUID{UUID: sessionId, Valid: true},
+		Reason:    db.SessionRevokedByUser,
+	})
+	if !ok {
+		return
+	}
+	if len(revoked) == 0 {
+		errorResponse(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, nil, "Session revoked successfully")
+}
+
+// LogoutHandler ends the session of the access token and revokes the token itself.
+func (s *server) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	s.logout(w, r, false)
+}
+
+// LogoutAllHandler ends every session of the user.
+func (s *server) LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	s.logout(w, r, true)
+}
+
+func (s *server) logout(w http.ResponseWriter, r *http.Request, allSessions bool) {
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	claims, ok := ClaimsFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	arg := db.RevokeSessionsTxParams{
+		UserID:      user.ID,
+		AllSessions: allSessions,
+		Reason:      db.SessionLoggedOut,
+		TokenID:     claims.ID,
+	}
+	if claims.ExpiresAt != nil {
+		arg.TokenExpiresAt = claims.ExpiresAt.Time
+	}
+	if sessionId, ok := SessionIDFromContext(r); ok && !allSessions {
+		arg.SessionID = uuid.NullUUID{UUID: sessionId, Valid: true}
+	}
+
+	// logging out twice is not an error, the session is already gone
+	revoked, ok := s.revokeSessions(w, r, arg)
+	if !ok {
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]int{"revoked_sessions": len(revoked)}, "Logout successful")
+}
+
+// revokeSessions revokes sessions together with their access tokens and applies the denial to
+// this instance right away.
+func (s *server) revokeSessions(w http.ResponseWriter, r *http.Request, arg db.RevokeSessionsTxParams) ([]uuid.UUID, bool) {
+	// every access token of a session has expired one token lifetime after it is revoked
+	arg.DenyUntil = time.Now().Add(helpers.AccessTokenTTL)
+
+	result, err := s.store.RevokeSessionsTx(r.Context(), arg)
+	if err != nil {
+		s.logger.Error("Error revoking sessions", err)
+		errorResponse(w, http.StatusInternalServerError, "Error revoking sessions")
+		return nil, false
+	}
+
+	for _, sessionId := range result.SessionIDs {
+		s.denylist.add(db.DeniedTokenKindSession, sessionId.String(), arg.DenyUntil)
+	}
+	if arg.TokenID != "" {
+		s.denylist.add(db.DeniedTokenKindToken, arg.TokenID, arg.TokenExpiresAt)
+	}
+
+	return result.SessionIDs, true
+}
+
+func newSessionResponse(session db.Session, currentId uuid.UUID) SessionResponse {
+	return SessionResponse{
+		ID:         session.ID,
+		DeviceName: session.DeviceName,
+		UserAgent:  session.UserAgent,
+		IpAddress:  session.IpAddress,
+		CreatedAt:  session.CreatedAt,
+		LastUsedAt: session.LastUsedAt,
+		ExpiresAt:  session.ExpiresAt,
+		Current:    session.ID == currentId,
+	}
+}
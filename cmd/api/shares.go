@@ -0,0 +1,427 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultShareTTL = 7 * 24 * time.Hour
+	maxShareTTL     = 30 * 24 * time.Hour
+)
+
+// reasons a share redemption is refused, recorded in the audit log
+const (
+	shareRevoked          = "revoked"
+	shareExpired          = "expired"
+	shareLimitReached     = "download_limit_reached"
+	sharePasswordRequired = "password_required"
+	shareInvalidPassword  = "invalid_password"
+	shareReportNotReady   = "report_not_ready"
+)
+
+type CreateShareRequest struct {
+	// ExpiresIn is the lifetime of the link in seconds, 7 days by default.
+	ExpiresIn    int    `json:"expires_in,omitempty" validate:"omitempty,min=60,max=2592000"`
+	MaxDownloads *int32 `json:"max_downloads,omitempty" validate:"omitempty,min=1"`
+	Password     string `json:"password,omitempty" validate:"omitempty,min=6,max=72"`
+}
+
+type RedeemShareRequest struct {
+	Password string `json:"password"`
+}
+
+type ShareResponse struct {
+	ID                uuid.UUID  `json:"id"`
+	ReportID          uuid.UUID  `json:"report_id"`
+	Token             string     `json:"token,omitempty"`
+	URL               string     `json:"url,omitempty"`
+	ExpiresAt         time.Time  `json:"expires_at"`
+	MaxDownloads      *int32     `json:"max_downloads,omitempty"`
+	DownloadCount     int32      `json:"download_count"`
+	PasswordProtected bool       `json:"password_protected"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// ShareLinkResponse describes a share link to anyone holding its token.
+type ShareLinkResponse struct {
+	ExpiresAt         time.Time `json:"expires_at"`
+	DownloadsLeft     *int32    `json:"downloads_left,omitempty"`
+	PasswordProtected bool      `json:"password_protected"`
+}
+
+type ShareRedemptionResponse struct {
+	Succeeded     bool      `json:"succeeded"`
+	FailureReason string    `json:"failure_reason,omitempty"`
+	IPAddress     string    `json:"ip_address"`
+	UserAgent     string    `json:"user_agent"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (s *server) CreateShareHandler(w http.ResponseWriter, r *http.Request) {
+	report, ok := s.loadReport(w, r)
+	if !ok {
+		return
+	}
+
+	// a share link exposes the report outside the organization
+	if !s.authorizeChange(w, r, report.UserID, report.OrganizationID) {
+		return
+	}
+
+	var req CreateShareRequest
+	if err := readJSON(w, r, &req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := Validate.Struct(req); err != nil {
+		errorResponse(w, http.StatusBadRequest, formatValidationErrors(err))
+		return
+	}
+
+	if !isDownloadable(report) {
+		errorResponse(w, http.StatusConflict, "Only completed reports can be shared")
+		return
+	}
+
+	ttl := defaultShareTTL
+	if req.ExpiresIn > 0 {
+		ttl = min(time.Duration(req.ExpiresIn)*time.Second, maxShareTTL)
+	}
+
+	var passwordHash sql.NullString
+	if req.Password != "" {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			s.logger.Error("Error hashing share password", err)
+			errorResponse(w, http.StatusInternalServerError, "Error creating share link")
+			return
+		}
+		passwordHash = sql.NullString{String: string(hashed), Valid: true}
+	}
+
+	var maxDownloads sql.NullInt32
+	if req.MaxDownloads != nil {
+		maxDownloads = sql.NullInt32{Int32: *req.MaxDownloads, Valid: true}
+	}
+
+	token, err := helpers.GenerateSecureToken(32)
+	if err != nil {
+		s.logger.Error("Error generating share token", err)
+		errorResponse(w, http.StatusInternalServerError, "Error creating share link")
+		return
+	}
+
+	share, err := s.store.CreateReportShare(r.Context(), db.CreateReportShareParams{
+		UserID:       report.UserID,
+		ReportID:     report.ID,
+		TokenHash:    hashShareToken(token),
+		PasswordHash: passwordHash,
+		ExpiresAt:    time.Now().Add(ttl),
+		MaxDownloads: maxDownloads,
+	})
+	if err != nil {
+		s.logger.Error("Error creating share link", err)
+		errorResponse(w, http.StatusInternalServerError, "Error creating share link")
+		return
+	}
+
+	// the token is only returned once, when the link is created
+	response := newShareResponse(share)
+	response.Token = token
+	response.URL = "/api/v1/shares/" + token
+	jsonResponse(w, http.StatusCreated, response, "Share link created successfully")
+}
+
+func (s *server) ListSharesHandler(w http.ResponseWriter, r *http.Request) {
+	report, ok := s.loadReport(w, r)
+	if !ok {
+		return
+	}
+
+	// share links are managed by those who can create them
+	if !s.authorizeChange(w, r, report.UserID, report.OrganizationID) {
+		return
+	}
+
+	shares, err := s.store.ListReportShares(r.Context(), db.ListReportSharesParams{
+		UserID:   report.UserID,
+		ReportID: report.ID,
+	})
+	if err != nil {
+		s.logger.Error("Error listing share links", err)
+		errorResponse(w, http.StatusInternalServerError, "Error listing share links")
+		return
+	}
+
+	response := make([]ShareResponse, 0, len(shares))
+	for _, share := range shares {
+		response = append(response, newShareResponse(share))
+	}
+
+	jsonResponse(w, http.StatusOK, response, "Share links retrieved successfully")
+}
+
+func (s *server) RevokeShareHandler(w http.ResponseWriter, r *http.Request) {
+	report, share, ok := s.loadShare(w, r)
+	if !ok {
+		return
+	}
+
+	if !s.authorizeChange(w, r, report.UserID, report.OrganizationID) {
+		return
+	}
+
+	share, err := s.store.RevokeReportShare(r.Context(), db.RevokeReportShareParams{
+		UserID:   share.UserID,
+		ReportID: share.ReportID,
+		ID:       share.ID,
+	})
+	if err != nil {
+		s.logger.Error("Error revoking share link", err)
+		errorResponse(w, http.StatusInternalServerError, "Error revoking share link")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, newShareResponse(share), "Share link revoked successfully")
+}
+
+func (s *server) ListShareRedemptionsHandler(w http.ResponseWriter, r *http.Request) {
+	report, share, ok := s.loadShare(w, r)
+	if !ok {
+		return
+	}
+
+	// the audit log has the IP addresses and user agents of the recipients
+	if !s.authorizeChange(w, r, report.UserID, report.OrganizationID) {
+		return
+	}
+
+	redemptions, err := s.store.ListReportShareRedemptions(r.Context(), share.ID)
+	if err != nil {
+		s.logger.Error("Error listing share redemptions", err)
+		errorResponse(w, http.StatusInternalServerError, "Error listing share redemptions")
+		return
+	}
+
+	response := make([]ShareRedemptionResponse, 0, len(redemptions))
+	for _, redemption := range redemptions {
+		response = append(response, ShareRedemptionResponse{
+			Succeeded:     redemption.Succeeded,
+			FailureReason: redemption.FailureReason.String,
+			IPAddress:     redemption.IpAddress,
+			UserAgent:     redemption.UserAgent,
+			CreatedAt:     redemption.CreatedAt,
+		})
+	}
+
+	jsonResponse(w, http.StatusOK, response, "Share redemptions retrieved successfully")
+}
+
+// GetShareLinkHandler tells anyone holding a share token whether the link still works and if it
+// needs a password. It doesn't use up a download, so link previews and crawlers can follow it.
+func (s *server) GetShareLinkHandler(w http.ResponseWriter, r *http.Request) {
+	share, ok := s.loadShareByToken(w, r)
+	if !ok {
+		return
+	}
+
+	switch {
+	case share.RevokedAt.Valid:
+		errorResponse(w, http.StatusGone, "Share link has been revoked")
+		return
+	case !share.ExpiresAt.After(time.Now()):
+		errorResponse(w, http.StatusGone, "Share link has expired")
+		return
+	case share.MaxDownloads.Valid && share.DownloadCount >= share.MaxDownloads.Int32:
+		errorResponse(w, http.StatusGone, "Share link download limit reached")
+		return
+	}
+
+	response := ShareLinkResponse{
+		ExpiresAt:         share.ExpiresAt,
+		PasswordProtected: share.PasswordHash.Valid,
+	}
+	if share.MaxDownloads.Valid {
+		left := share.MaxDownloads.Int32 - share.DownloadCount
+		response.DownloadsLeft = &left
+	}
+	jsonResponse(w, http.StatusOK, response, "Share link retrieved successfully")
+}
+
+// RedeemShareHandler is the public side of a share link: it streams the report to anyone holding
+// the token. Password protected links take the password in the body.
+func (s *server) RedeemShareHandler(w http.ResponseWriter, r *http.Request) {
+	share, ok := s.loadShareByToken(w, r)
+	if !ok {
+		return
+	}
+
+	// links without a password can be redeemed with an empty body
+	var req RedeemShareRequest
+	if err := readJSON(w, r, &req); err != nil && !errors.Is(err, io.EOF) {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	switch {
+	case share.RevokedAt.Valid:
+		s.refuseRedemption(w, r, share, shareRevoked, http.StatusGone, "Share link has been revoked")
+		return
+	case !share.ExpiresAt.After(time.Now()):
+		s.refuseRedemption(w, r, share, shareExpired, http.StatusGone, "Share link has expired")
+		return
+	case share.MaxDownloads.Valid && share.DownloadCount >= share.MaxDownloads.Int32:
+		s.refuseRedemption(w, r, share, shareLimitReached, http.StatusGone, "Share link download limit reached")
+		return
+	case share.PasswordHash.Valid && req.Password == "":
+		s.refuseRedemption(w, r, share, sharePasswordRequired, http.StatusUnauthorized, "Password required")
+		return
+	}
+
+	if share.PasswordHash.Valid {
+		reserved, ok := s.reserveAttempt(w, r, shareThrottleKeys(r, share.ID), "Too many wrong passwords, try again later")
+		if !ok {
+			return
+		}
+		if bcrypt.CompareHashAndPassword([]byte(share.PasswordHash.String), []byte(req.Password)) != nil {
+			s.recordFailure(r, reserved, db.SecurityEventShareLocked, uuid.NullUUID{UUID: share.UserID, Valid: true})
+			s.refuseRedemption(w, r, share, shareInvalidPassword, http.StatusUnauthorized, "Invalid password")
+			return
+		}
+		s.attemptSucceeded(r, reserved)
+	}
+
+	report, err := s.store.GetReport(r.Context(), db.GetReportParams{
+		UserID: share.UserID,
+		ID:     share.ReportID,
+	})
+	if err != nil {
+		s.logger.Error("Error getting shared report", err)
+		errorResponse(w, http.StatusInternalServerError, "Error getting shared report")
+		return
+	}
+	if !isDownloadable(report) {
+		s.refuseRedemption(w, r, share, shareReportNotReady, http.StatusConflict, "Report is not ready for download")
+		return
+	}
+
+	// the download is only counted once the file could be opened, so a failed read doesn't use it up
+	s.streamReport(w, r, report, func() bool {
+		// counting the download also rechecks the limits, in case another redemption won the race
+		if _, err := s.store.ConsumeReportShare(r.Context(), share.ID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				s.refuseRedemption(w, r, share, shareLimitReached, http.StatusGone, "Share link download limit reached")
+				return false
+			}
+			s.logger.Error("Error redeeming share link", err)
+			errorResponse(w, http.StatusInternalServerError, "Error redeeming share link")
+			return false
+		}
+		s.auditRedemption(r, share, "")
+		return true
+	})
+}
+
+// refuseRedemption records a refused redemption and writes the error response.
+func (s *server) refuseRedemption(w http.ResponseWriter, r *http.Request, share db.ReportShare, reason string, status int, message string) {
+	s.auditRedemption(r, share, reason)
+	errorResponse(w, status, message)
+}
+
+// auditRedemption records a redemption attempt; an empty reason means it succeeded.
+func (s *server) auditRedemption(r *http.Request, share db.ReportShare, reason string) {
+	_, err := s.store.CreateReportShareRedemption(r.Context(), db.CreateReportShareRedemptionParams{
+		ShareID:       share.ID,
+		Succeeded:     reason == "",
+		FailureReason: sql.NullString{String: reason, Valid: reason != ""},
+		IpAddress:     clientIP(r),
+		UserAgent:     r.UserAgent(),
+	})
+	if err != nil {
+		s.logger.Errorw("Error recording share redemption", "share_id", share.ID, "error", err)
+	}
+}
+
+// loadShareByToken fetches the share link whose token is in the URL.
+func (s *server) loadShareByToken(w http.ResponseWriter, r *http.Request) (db.ReportShare, bool) {
+	share, err := s.store.GetReportShareByTokenHash(r.Context(), hashShareToken(chi.URLParam(r, "token")))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorResponse(w, http.StatusNotFound, "Share link not found")
+			return db.ReportShare{}, false
+		}
+		s.logger.Error("Error getting share link", err)
+		errorResponse(w, http.StatusInternalServerError, "Error getting share link")
+		return db.ReportShare{}, false
+	}
+	return share, true
+}
+
+// loadShare fetches the report and the share link named in the URL for the signed-in user.
+func (s *server) loadShare(w http.ResponseWriter, r *http.Request) (db.Report, db.ReportShare, bool) {
+	report, ok := s.loadReport(w, r)
+	if !ok {
+		return db.Report{}, db.ReportShare{}, false
+	}
+
+	shareId, err := uuid.Parse(chi.URLParam(r, "shareId"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid share ID")
+		return db.Report{}, db.ReportShare{}, false
+	}
+
+	share, err := s.store.GetReportShare(r.Context(), db.GetReportShareParams{
+		UserID:   report.UserID,
+		ReportID: report.ID,
+		ID:       shareId,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorResponse(w, http.StatusNotFound, "Share link not found")
+			return db.Report{}, db.ReportShare{}, false
+		}
+		s.logger.Error("Error getting share link", err)
+		errorResponse(w, http.StatusInternalServerError, "Error getting share link")
+		return db.Report{}, db.ReportShare{}, false
+	}
+
+	return report, share, true
+}
+
+// hashShareToken hashes a share token for lookup. Tokens are random, so a fast hash is enough.
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newShareResponse(share db.ReportShare) ShareResponse {
+	response := ShareResponse{
+		ID:                share.ID,
+		ReportID:          share.ReportID,
+		ExpiresAt:         share.ExpiresAt,
+		DownloadCount:     share.DownloadCount,
+		PasswordProtected: share.PasswordHash.Valid,
+		CreatedAt:         share.CreatedAt,
+	}
+	if share.MaxDownloads.Valid {
+		response.MaxDownloads = &share.MaxDownloads.Int32
+	}
+	if share.RevokedAt.Valid {
+		response.RevokedAt = &share.RevokedAt.Time
+	}
+	return response
+}
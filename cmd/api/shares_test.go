@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/trenchesdeveloper/csv-reporter/config"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"go.uber.org/zap"
+)
+
+// shareStore serves one share link of one report and counts its downloads.
+type shareStore struct {
+	db.Store
+	share  db.ReportShare
+	report db.Report
+}
+
+func (s *shareStore) GetReportShareByTokenHash(ctx context.Context, tokenHash string) (db.ReportShare, error) {
+	if tokenHash != s.share.TokenHash {
+		return db.ReportShare{}, sql.ErrNoRows
+	}
+	return s.share, nil
+}
+
+func (s *shareStore) GetReport(ctx context.Context, arg db.GetReportParams) (db.Report, error) {
+	return s.report, nil
+}
+
+func (s *shareStore) ConsumeReportShare(ctx context.Context, id uuid.UUID) (db.ReportShare, error) {
+	if s.share.MaxDownloads.Valid && s.share.DownloadCount >= s.share.MaxDownloads.Int32 {
+		return db.ReportShare{}, sql.ErrNoRows
+	}
+	s.share.DownloadCount++
+	return s.share, nil
+}
+
+func (s *shareStore) CreateReportShareRedemption(ctx context.Context, arg db.CreateReportShareRedemptionParams) (db.ReportShareRedemption, error) {
+	return db.ReportShareRedemption{}, nil
+}
+
+func TestRedeemShareCountsStartedDownloads(t *testing.T) {
+	// the bucket has the file until it is taken away
+	available := false
+	ranged := false
+	bucket := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			ranged = true
+		}
+		if !available {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("file"))
+	}))
+	defer bucket.Close()
+
+	store := &shareStore{
+		share: db.ReportShare{
+			ID:           uuid.New(),
+			TokenHash:    hashShareToken("token"),
+			ExpiresAt:    time.Now().Add(time.Hour),
+			MaxDownloads: sql.NullInt32{Int32: 1, Valid: true},
+		},
+		report: db.Report{
+			ID:             uuid.New(),
+			ReportType:     "monsters",
+			CompletedAt:    sql.NullTime{Time: time.Now(), Valid: true},
+			OutputFilePath: sql.NullString{String: "reports/monsters.csv.gz", Valid: true},
+		},
+	}
+	s := &server{
+		config: &config.AppConfig{S3_BUCKET: "api-reports"},
+		logger: zap.NewNop().Sugar(),
+		store:  store,
+		s3Client: s3.New(s3.Options{
+			Region:           "us-west-2",
+			Credentials:      credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", ""),
+			BaseEndpoint:     aws.String(bucket.URL),
+			UsePathStyle:     true,
+			RetryMaxAttempts: 1,
+		}),
+	}
+
+	request := func(method string, handler http.HandlerFunc) int {
+		routeContext := chi.NewRouteContext()
+		routeContext.URLParams.Add("token", "token")
+		req := httptest.NewRequest(method, "/", nil)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeContext))
+		req.Header.Set("Accept-Encoding", "gzip")
+		// resuming a download would count it again
+		req.Header.Set("Range", "bytes=2-")
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Code
+	}
+
+	// looking at the link doesn't use up a download
+	require.Equal(t, http.StatusOK, request(http.MethodGet, s.GetShareLinkHandler))
+	require.Equal(t, int32(0), store.share.DownloadCount)
+
+	// neither does a download that couldn't be read
+	require.Equal(t, http.StatusBadGateway, request(http.MethodPost, s.RedeemShareHandler))
+	require.Equal(t, int32(0), store.share.DownloadCount)
+
+	available = true
+	require.Equal(t, http.StatusOK, request(http.MethodPost, s.RedeemShareHandler))
+	require.Equal(t, int32(1), store.share.DownloadCount)
+	// a counted download always sends the whole file
+	require.False(t, ranged)
+
+	require.Equal(t, http.StatusGone, request(http.MethodPost, s.RedeemShareHandler))
+	require.Equal(t, http.StatusGone, request(http.MethodGet, s.GetShareLinkHandler))
+}
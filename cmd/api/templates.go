@@ -0,0 +1,240 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"github.com/trenchesdeveloper/csv-reporter/reports"
+)
+
+// TemplateRequest creates or replaces a template.
+type TemplateRequest struct {
+	Name string `json:"name" validate:"required,max=255"`
+	// OrganizationID shares a new template with the members of an organization; updates keep the organization.
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty"`
+	ReportParamsRequest
+}
+
+type TemplateResponse struct {
+	ID             uuid.UUID      `json:"id"`
+	OrganizationID *uuid.UUID     `json:"organization_id,omitempty"`
+	Name           string         `json:"name"`
+	Params         reports.Params `json:"params"`
+	Version        int32          `json:"version"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// readTemplateRequest reads and validates a template request and returns its encoded params.
+func (s *server) readTemplateRequest(w http.ResponseWriter, r *http.Request) (TemplateRequest, json.RawMessage, bool) {
+	var req TemplateRequest
+	if err := readJSON(w, r, &req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return req, nil, false
+	}
+
+	if err := Validate.Struct(req); err != nil {
+		errorResponse(w, http.StatusBadRequest, formatValidationErrors(err))
+		return req, nil, false
+	}
+
+	params, err := req.params()
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return req, nil, false
+	}
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		s.logger.Error("Error encoding report params", err)
+		errorResponse(w, http.StatusInternalServerError, "Error encoding report params")
+		return req, nil, false
+	}
+
+	return req, paramsJSON, true
+}
+
+func (s *server) CreateTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	req, params, ok := s.readTemplateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	organizationId, ok := s.organizationFor(w, r, req.OrganizationID)
+	if !ok {
+		return
+	}
+
+	template, err := s.store.CreateReportTemplate(r.Context(), db.CreateReportTemplateParams{
+		UserID:         user.ID,
+		Name:           req.Name,
+		Params:         params,
+		OrganizationID: organizationId,
+	})
+	if err != nil {
+		if db.ErrorCode(err) == db.UniqueViolation {
+			errorResponse(w, http.StatusConflict, "Template name already exists")
+			return
+		}
+		s.logger.Error("Error creating template", err)
+		errorResponse(w, http.StatusInternalServerError, "Error creating template")
+		return
+	}
+
+	jsonResponse(w, http.StatusCreated, newTemplateResponse(template), "Template created successfully")
+}
+
+func (s *server) ListTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	templates, err := s.store.ListReportTemplates(r.Context(), user.ID)
+	if err != nil {
+		s.logger.Error("Error listing templates", err)
+		errorResponse(w, http.StatusInternalServerError, "Error listing templates")
+		return
+	}
+
+	response := make([]TemplateResponse, 0, len(templates))
+	for _, template := range templates {
+		response = append(response, newTemplateResponse(template))
+	}
+
+	jsonResponse(w, http.StatusOK, response, "Templates retrieved successfully")
+}
+
+func (s *server) GetTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	template, ok := s.loadTemplate(w, r)
+	if !ok {
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, newTemplateResponse(template), "Template retrieved successfully")
+}
+
+func (s *server) UpdateTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	template, ok := s.loadTemplate(w, r)
+	if !ok {
+		return
+	}
+
+	if !s.authorizeChange(w, r, template.UserID, template.OrganizationID) {
+		return
+	}
+
+	req, params, ok := s.readTemplateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// the version only changes when the params do
+	template, err := s.store.UpdateReportTemplate(r.Context(), db.UpdateReportTemplateParams{
+		UserID: template.UserID,
+		ID:     template.ID,
+		Name:   req.Name,
+		Params: params,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorResponse(w, http.StatusNotFound, "Template not found")
+			return
+		}
+		if db.ErrorCode(err) == db.UniqueViolation {
+			errorResponse(w, http.StatusConflict, "Template name already exists")
+			return
+		}
+		s.logger.Error("Error updating template", err)
+		errorResponse(w, http.StatusInternalServerError, "Error updating template")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, newTemplateResponse(template), "Template updated successfully")
+}
+
+func (s *server) DeleteTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	template, ok := s.loadTemplate(w, r)
+	if !ok {
+		return
+	}
+
+	if !s.authorizeChange(w, r, template.UserID, template.OrganizationID) {
+		return
+	}
+
+	// reports keep their params and version, only the link to the template is cleared
+	deleted, err := s.store.DeleteReportTemplate(r.Context(), db.DeleteReportTemplateParams{
+		UserID: template.UserID,
+		ID:     template.ID,
+	})
+	if err != nil {
+		s.logger.Error("Error deleting template", err)
+		errorResponse(w, http.StatusInternalServerError, "Error deleting template")
+		return
+	}
+	if deleted == 0 {
+		errorResponse(w, http.StatusNotFound, "Template not found")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, nil, "Template deleted successfully")
+}
+
+// loadTemplate fetches the template named in the URL if the signed-in user created it or is a member of its organization.
+func (s *server) loadTemplate(w http.ResponseWriter, r *http.Request) (db.ReportTemplate, bool) {
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return db.ReportTemplate{}, false
+	}
+
+	templateId, err := uuid.Parse(chi.URLParam(r, "templateId"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid template ID")
+		return db.ReportTemplate{}, false
+	}
+
+	template, err := s.store.GetReportTemplate(r.Context(), db.GetReportTemplateParams{
+		UserID: user.ID,
+		ID:     templateId,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorResponse(w, http.StatusNotFound, "Template not found")
+			return db.ReportTemplate{}, false
+		}
+		s.logger.Error("Error getting template", err)
+		errorResponse(w, http.StatusInternalServerError, "Error getting template")
+		return db.ReportTemplate{}, false
+	}
+
+	return template, true
+}
+
+func newTemplateResponse(template db.ReportTemplate) TemplateResponse {
+	response := TemplateResponse{
+		ID:             template.ID,
+		OrganizationID: organizationID(template.OrganizationID),
+		Name:           template.Name,
+		Version:        template.Version,
+		CreatedAt:      template.CreatedAt,
+		UpdatedAt:      template.UpdatedAt,
+	}
+	if params, err := reports.ParseParams(template.Params); err == nil {
+		response.Params = params
+	}
+	return response
+}
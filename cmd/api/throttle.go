@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+)
+
+// loginThrottleKey names the counter of failed attempts in one scope.
+type loginThrottleKey struct {
+	scope string
+	key   string
+}
+
+// isIPScope reports whether the counters of a scope are kept per client IP.
+func isIPScope(scope string) bool {
+	return scope == db.LoginThrottleIP || scope == db.LoginThrottleShareIP
+}
+
+// loginThrottleKeys returns the counters a sign-in for email from r is held against:
+// the account and the client IP.
+func loginThrottleKeys(r *http.Request, email string) []loginThrottleKey {
+	return []loginThrottleKey{
+		{db.LoginThrottleAccount, loginAccountKey(email)},
+		{db.LoginThrottleIP, clientIP(r)},
+	}
+}
+
+// shareThrottleKeys returns the counters a password attempt for a share link from r is held
+// against: the share link and the client IP. They are separate from the sign-in counters, so
+// guessing at a link doesn't lock the IP out of signing in.
+func shareThrottleKeys(r *http.Request, shareId uuid.UUID) []loginThrottleKey {
+	return []loginThrottleKey{
+		{db.LoginThrottleShare, shareId.String()},
+		{db.LoginThrottleShareIP, clientIP(r)},
+	}
+}
+
+// loginAccountKey is the key of an account's counter, so differently cased emails share it.
+func loginAccountKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// reserveLogin counts a sign-in for email against the account and the IP before the password is
+// checked. Sign-ins for a locked account or from a locked IP, and ones made before the delay of the
+// last failure has passed, are refused with 429 and a Retry-After header.
+func (s *server) reserveLogin(w http.ResponseWriter, r *http.Request, email string) ([]db.LoginThrottle, bool) {
+	return s.reserveAttempt(w, r, loginThrottleKeys(r, email), "Too many failed sign-in attempts, try again later")
+}
+
+// reserveAttempt counts an attempt as failed against every counter before it is made, so parallel
+// attempts can't all get past a check made before any of them failed. While a counter is locked or
+// delayed the attempt is refused with message, a 429 and a Retry-After header.
+// It returns the counters as reserved, for recordFailure, releaseAttempt or attemptSucceeded.
+func (s *server) reserveAttempt(w http.ResponseWriter, r *http.Request, keys []loginThrottleKey, message string) ([]db.LoginThrottle, bool) {
+	now := time.Now()
+	reserved := make([]db.LoginThrottle, 0, len(keys))
+	for _, k := range keys {
+		// failures are counted in Postgres, so every replica sees the same count
+		result, err := s.store.ReserveLoginAttemptTx(r.Context(), db.ReserveLoginAttemptTxParams{
+			Scope: k.scope,
+			Key:   k.key,
+			Reserve: func(throttle db.LoginThrottle) (db.LoginThrottle, bool) {
+				return s.countAttempt(throttle, now)
+			},
+		})
+		if err != nil {
+			s.releaseAttempt(r, reserved)
+			s.logger.Error("Error reserving login attempt", err)
+			errorResponse(w, http.StatusInternalServerError, "Error checking attempt limits")
+			return nil, false
+		}
+		if !result.Reserved {
+			s.releaseAttempt(r, reserved)
+			retryAt := result.Throttle.NextAttemptAt.Time
+			if result.Throttle.LockedUntil.Valid && result.Throttle.LockedUntil.Time.After(retryAt) {
+				retryAt = result.Throttle.LockedUntil.Time
+			}
+			w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(retryAt.Sub(now).Seconds()))))
+			errorResponse(w, http.StatusTooManyRequests, message)
+			return nil, false
+		}
+		reserved = append(reserved, result.Throttle)
+	}
+	return reserved, true
+}
+
+// countAttempt counts an attempt made at now against throttle, delays the next one and locks the
+// counter once it reaches its limit. It refuses while the counter is locked or delayed.
+func (s *server) countAttempt(throttle db.LoginThrottle, now time.Time) (db.LoginThrottle, bool) {
+	for _, until := range []sql.NullTime{throttle.LockedUntil, throttle.NextAttemptAt} {
+		if until.Valid && until.Time.After(now) {
+			return throttle, false
+		}
+	}
+
+	// failures before the window and the ones that led to an expired lockout are forgotten
+	if throttle.LastFailedAt.Before(now.Add(-s.config.LOGIN_FAILURE_WINDOW)) || throttle.LockedUntil.Valid {
+		throttle.Failures = 0
+		throttle.LockedUntil = sql.NullTime{}
+	}
+	throttle.Failures++
+	throttle.LastFailedAt = now
+	throttle.NextAttemptAt = sql.NullTime{Time: now.Add(loginDelay(throttle.Failures, s.config.LOGIN_DELAY_BASE, s.config.LOGIN_DELAY_MAX)), Valid: true}
+	if maxFailures := s.maxFailures(throttle.Scope); maxFailures > 0 && throttle.Failures >= maxFailures {
+		throttle.LockedUntil = sql.NullTime{Time: now.Add(s.config.LOGIN_LOCKOUT_DURATION), Valid: true}
+	}
+	return throttle, true
+}
+
+// maxFailures is the number of failures that locks a counter of scope.
+func (s *server) maxFailures(scope string) int32 {
+	if isIPScope(scope) {
+		return s.config.LOGIN_IP_MAX_FAILURES
+	}
+	return s.config.LOGIN_MAX_FAILURES
+}
+
+// recordLoginFailure keeps a failed sign-in reserved by reserveLogin counted. userId is the
+// account's user when the email belongs to one.
+func (s *server) recordLoginFailure(r *http.Request, reserved []db.LoginThrottle, userId uuid.NullUUID) {
+	s.recordFailure(r, reserved, db.SecurityEventLoginLocked, userId)
+}
+
+// recordFailure keeps a failed attempt counted and records the lockouts it caused as lockedEvent
+// security events of userId.
+func (s *server) recordFailure(r *http.Request, reserved []db.LoginThrottle, lockedEvent string, userId uuid.NullUUID) {
+	for _, throttle := range reserved {
+		// the failure that reaches the limit records the lockout
+		if throttle.LockedUntil.Valid && throttle.Failures == s.maxFailures(throttle.Scope) {
+			k := loginThrottleKey{throttle.Scope, throttle.Key}
+			s.recordLockout(r.Context(), r, k, throttle.Failures, throttle.LockedUntil.Time, lockedEvent, userId)
+		}
+	}
+}
+
+// releaseAttempt uncounts an attempt that didn't fail, such as a right password that still needs
+// an MFA code, or one that couldn't be checked. Errors are only logged.
+func (s *server) releaseAttempt(r *http.Request, reserved []db.LoginThrottle) {
+	// the attempt is over, so release it even if the client went away
+	ctx := context.WithoutCancel(r.Context())
+	for _, throttle := range reserved {
+		if err := s.store.ReleaseLoginAttempt(ctx, db.ReleaseLoginAttemptParams{
+			MaxFailures: s.maxFailures(throttle.Scope),
+			Scope:       throttle.Scope,
+			Key:         throttle.Key,
+		}); err != nil {
+			s.logger.Errorw("Error releasing login attempt", "scope", throttle.Scope, "error", err)
+		}
+	}
+}
+
+// attemptSucceeded forgets the failures of the account or share link after a successful attempt.
+// The IP only gets the attempt back, so succeeding on one account doesn't reset guessing at others.
+func (s *server) attemptSucceeded(r *http.Request, reserved []db.LoginThrottle) {
+	var ipThrottles []db.LoginThrottle
+	for _, throttle := range reserved {
+		if isIPScope(throttle.Scope) {
+			ipThrottles = append(ipThrottles, throttle)
+			continue
+		}
+		if _, err := s.store.DeleteLoginThrottle(context.WithoutCancel(r.Context()), db.DeleteLoginThrottleParams{
+			Scope: throttle.Scope,
+			Key:   throttle.Key,
+		}); err != nil {
+			s.logger.Errorw("Error clearing login throttle", "scope", throttle.Scope, "error", err)
+		}
+	}
+	s.releaseAttempt(r, ipThrottles)
+}
+
+func (s *server) recordLockout(ctx context.Context, r *http.Request, k loginThrottleKey, failures int32, lockedUntil time.Time, eventType string, userId uuid.NullUUID) {
+	s.logger.Warnw("Locked out after failed attempts", "scope", k.scope, "key", k.key, "failures", failures, "locked_until", lockedUntil)
+
+	details, err := json.Marshal(map[string]any{
+		"scope":        k.scope,
+		"key":          k.key,
+		"failures":     failures,
+		"locked_until": lockedUntil,
+	})
+	if err != nil {
+		s.logger.Errorw("Error encoding lockout details", "error", err)
+		return
+	}
+	// IP lockouts aren't tied to a user
+	if isIPScope(k.scope) {
+		userId = uuid.NullUUID{}
+	}
+	if _, err := s.store.CreateSecurityEvent(ctx, db.CreateSecurityEventParams{
+		UserID:    userId,
+		EventType: eventType,
+		IpAddress: clientIP(r),
+		UserAgent: truncate(r.UserAgent(), maxUserAgentLength),
+		Details:   details,
+	}); err != nil {
+		s.logger.Errorw("Error recording lockout", "error", err)
+	}
+}
+
+// loginDelay is how long to wait after the given number of consecutive failures:
+// base, doubling with each failure, up to max.
+func loginDelay(failures int32, base, max time.Duration) time.Duration {
+	if failures <= 0 || base <= 0 {
+		return 0
+	}
+	delay := base
+	for i := int32(1); i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	return min(delay, max)
+}
+
+// pruneLoginThrottles removes the counters of failures that are past the window and not locked
+// until the context is done.
+func (s *server) pruneLoginThrottles(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			removed, err := s.store.DeleteStaleLoginThrottles(ctx, time.Now().Add(-s.config.LOGIN_FAILURE_WINDOW))
+			if err != nil {
+				s.logger.Errorw("Error removing stale login throttles", "error", err)
+				continue
+			}
+			s.logger.Infow("removed stale login throttles", "count", removed)
+		}
+	}
+}
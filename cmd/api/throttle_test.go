@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/trenchesdeveloper/csv-reporter/config"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// throttleStore keeps login throttles and security events in memory.
+type throttleStore struct {
+	db.Store
+	mu        sync.Mutex
+	throttles map[loginThrottleKey]db.LoginThrottle
+	events    []db.CreateSecurityEventParams
+	users     map[string]db.User
+	shares    []db.ReportShare
+}
+
+func newThrottleStore() *throttleStore {
+	return &throttleStore{
+		throttles: make(map[loginThrottleKey]db.LoginThrottle),
+		users:     make(map[string]db.User),
+	}
+}
+
+func (s *throttleStore) GetLoginThrottle(ctx context.Context, arg db.GetLoginThrottleParams) (db.LoginThrottle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	throttle, ok := s.throttles[loginThrottleKey{arg.Scope, arg.Key}]
+	if !ok {
+		return db.LoginThrottle{}, sql.ErrNoRows
+	}
+	return throttle, nil
+}
+
+// ReserveLoginAttemptTx holds the store's lock while Reserve decides, like the row lock in Postgres.
+func (s *throttleStore) ReserveLoginAttemptTx(ctx context.Context, arg db.ReserveLoginAttemptTxParams) (db.ReserveLoginAttemptTxResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := loginThrottleKey{arg.Scope, arg.Key}
+	throttle, ok := s.throttles[k]
+	if !ok {
+		throttle = db.LoginThrottle{Scope: arg.Scope, Key: arg.Key, LastFailedAt: time.Now()}
+	}
+	reserved, ok := arg.Reserve(throttle)
+	if !ok {
+		return db.ReserveLoginAttemptTxResult{Throttle: throttle}, nil
+	}
+	s.throttles[k] = reserved
+	return db.ReserveLoginAttemptTxResult{Reserved: true, Throttle: reserved}, nil
+}
+
+func (s *throttleStore) ReleaseLoginAttempt(ctx context.Context, arg db.ReleaseLoginAttemptParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := loginThrottleKey{arg.Scope, arg.Key}
+	throttle, ok := s.throttles[k]
+	if !ok {
+		return nil
+	}
+	if throttle.Failures <= 1 {
+		throttle.NextAttemptAt = sql.NullTime{}
+	}
+	if throttle.Failures <= arg.MaxFailures {
+		throttle.LockedUntil = sql.NullTime{}
+	}
+	throttle.Failures = max(throttle.Failures-1, 0)
+	s.throttles[k] = throttle
+	return nil
+}
+
+func (s *throttleStore) DeleteLoginThrottle(ctx context.Context, arg db.DeleteLoginThrottleParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := loginThrottleKey{arg.Scope, arg.Key}
+	if _, ok := s.throttles[k]; !ok {
+		return 0, nil
+	}
+	delete(s.throttles, k)
+	return 1, nil
+}
+
+func (s *throttleStore) CreateSecurityEvent(ctx context.Context, arg db.CreateSecurityEventParams) (db.SecurityEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, arg)
+	return db.SecurityEvent{}, nil
+}
+
+func (s *throttleStore) FindUserByEmail(ctx context.Context, email string) (db.User, error) {
+	user, ok := s.users[email]
+	if !ok {
+		return db.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (s *throttleStore) CreateSessionTx(ctx context.Context, arg db.CreateSessionTxParams) (db.CreateSessionTxResult, error) {
+	return db.CreateSessionTxResult{}, nil
+}
+
+func TestSigninThrottle(t *testing.T) {
+	hashedPassword, err := helpers.HashPasswordBase64("correct-password")
+	require.NoError(t, err)
+	user := db.User{ID: uuid.New(), Email: "user@example.com", HashedPassword: hashedPassword}
+
+	store := newThrottleStore()
+	store.users[user.Email] = user
+	s := &server{
+		logger:       zap.NewNop().Sugar(),
+		store:        store,
+		tokenManager: helpers.NewJwtManager(&config.AppConfig{AppName: "csv_reporter", JWT_SECRET: "secretToSecretMySecret"}),
+		config: &config.AppConfig{
+			LOGIN_MAX_FAILURES:     3,
+			LOGIN_IP_MAX_FAILURES:  10,
+			LOGIN_FAILURE_WINDOW:   time.Minute,
+			LOGIN_LOCKOUT_DURATION: time.Hour,
+		},
+	}
+
+	signin := func(password string) *httptest.ResponseRecorder {
+		body, err := json.Marshal(SigninRequest{Email: user.Email, Password: password})
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		s.SigninHandler(rec, httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewReader(body)))
+		return rec
+	}
+
+	require.Equal(t, http.StatusUnauthorized, signin("wrong-password").Code)
+	require.Equal(t, http.StatusUnauthorized, signin("wrong-password").Code)
+	// a success forgets the account's failures
+	require.Equal(t, http.StatusOK, signin("correct-password").Code)
+	require.NotContains(t, store.throttles, loginThrottleKey{db.LoginThrottleAccount, user.Email})
+
+	for range 3 {
+		require.Equal(t, http.StatusUnauthorized, signin("wrong-password").Code)
+	}
+	require.Len(t, store.events, 1)
+	require.Equal(t, db.SecurityEventLoginLocked, store.events[0].EventType)
+	require.Equal(t, uuid.NullUUID{UUID: user.ID, Valid: true}, store.events[0].UserID)
+
+	// locked out even with the right password
+	rec := signin("correct-password")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	// the lockout starts when the attempt is counted, before its password was checked
+	retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	require.InDelta(t, 3600, retryAfter, 5)
+
+	// the IP kept counting, but is below its limit
+	require.Equal(t, int32(5), store.throttles[loginThrottleKey{db.LoginThrottleIP, "192.0.2.1"}].Failures)
+}
+
+func TestSigninThrottleParallel(t *testing.T) {
+	hashedPassword, err := helpers.HashPasswordBase64("correct-password")
+	require.NoError(t, err)
+	user := db.User{ID: uuid.New(), Email: "user@example.com", HashedPassword: hashedPassword}
+
+	store := newThrottleStore()
+	store.users[user.Email] = user
+	s := &server{
+		logger: zap.NewNop().Sugar(),
+		store:  store,
+		config: &config.AppConfig{
+			LOGIN_MAX_FAILURES:     3,
+			LOGIN_IP_MAX_FAILURES:  50,
+			LOGIN_FAILURE_WINDOW:   time.Minute,
+			LOGIN_LOCKOUT_DURATION: time.Hour,
+		},
+	}
+
+	body, err := json.Marshal(SigninRequest{Email: user.Email, Password: "wrong-password"})
+	require.NoError(t, err)
+
+	// a burst can't get more guesses than the limit, even though none of them has failed yet
+	// when the others start
+	codes := make(chan int, 20)
+	var wg sync.WaitGroup
+	for range cap(codes) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			s.SigninHandler(rec, httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewReader(body)))
+			codes <- rec.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	counts := make(map[int]int)
+	for code := range codes {
+		counts[code]++
+	}
+	require.Equal(t, map[int]int{http.StatusUnauthorized: 3, http.StatusTooManyRequests: 17}, counts)
+	require.Len(t, store.events, 1)
+}
+
+func TestSigninThrottleDelay(t *testing.T) {
+	store := newThrottleStore()
+	s := &server{
+		logger: zap.NewNop().Sugar(),
+		store:  store,
+		config: &config.AppConfig{
+			LOGIN_MAX_FAILURES:    5,
+			LOGIN_IP_MAX_FAILURES: 50,
+			LOGIN_FAILURE_WINDOW:  time.Minute,
+			LOGIN_DELAY_BASE:      time.Minute,
+			LOGIN_DELAY_MAX:       time.Hour,
+		},
+	}
+
+	signin := func(email string) int {
+		body, err := json.Marshal(SigninRequest{Email: email, Password: "wrong-password"})
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		s.SigninHandler(rec, httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewReader(body)))
+		return rec.Code
+	}
+
+	// unknown accounts are throttled like existing ones
+	require.Equal(t, http.StatusUnauthorized, signin("nobody@example.com"))
+	require.Equal(t, http.StatusTooManyRequests, signin("NOBODY@example.com"))
+	// the IP has to wait too, whatever account it tries next
+	require.Equal(t, http.StatusTooManyRequests, signin("other@example.com"))
+}
+
+func TestLoginDelay(t *testing.T) {
+	testCases := []struct {
+		failures int32
+		delay    time.Duration
+	}{
+		{0, 0},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{6, 30 * time.Second},
+		{40, 30 * time.Second},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.delay, loginDelay(tc.failures, time.Second, 30*time.Second))
+	}
+}
+
+func TestRealIP(t *testing.T) {
+	proxies, err := parseTrustedProxies("10.0.0.0/8, 192.168.1.1")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		ip         string
+	}{
+		{"no proxy", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"spoofed from untrusted peer", "203.0.113.7:5000", map[string]string{"X-Forwarded-For": "1.2.3.4", "X-Real-IP": "1.2.3.5"}, "203.0.113.7"},
+		{"trusted proxy", "10.1.2.3:5000", map[string]string{"X-Forwarded-For": "198.51.100.9"}, "198.51.100.9"},
+		{"client prepended hop", "10.1.2.3:5000", map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.9, 192.168.1.1"}, "198.51.100.9"},
+		{"real ip header", "192.168.1.1:5000", map[string]string{"X-Real-IP": "198.51.100.9"}, "198.51.100.9"},
+		{"garbage header", "10.1.2.3:5000", map[string]string{"X-Forwarded-For": "nope"}, "10.1.2.3"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var ip string
+			handler := realIP(proxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ip = clientIP(r)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			require.Equal(t, tc.ip, ip)
+		})
+	}
+
+	_, err = parseTrustedProxies("10.0.0.0/33")
+	require.Error(t, err)
+}
+
+func (s *throttleStore) FindUserById(ctx context.Context, id uuid.UUID) (db.User, error) {
+	for _, user := range s.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return db.User{}, sql.ErrNoRows
+}
+
+func TestAdminUnlockUser(t *testing.T) {
+	user := db.User{ID: uuid.New(), Email: "User@example.com"}
+	store := newThrottleStore()
+	store.users[user.Email] = user
+	store.throttles[loginThrottleKey{db.LoginThrottleAccount, "user@example.com"}] = db.LoginThrottle{
+		Failures:    5,
+		LockedUntil: sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true},
+	}
+	s := &server{logger: zap.NewNop().Sugar(), store: store}
+
+	unlock := func(userId uuid.UUID) int {
+		routeContext := chi.NewRouteContext()
+		routeContext.URLParams.Add("userId", userId.String())
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, routeContext)
+		ctx = context.WithValue(ctx, "user", db.User{ID: uuid.New(), Role: db.UserRoleAdmin})
+		rec := httptest.NewRecorder()
+		s.AdminUnlockUserHandler(rec, req.WithContext(ctx))
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusOK, unlock(user.ID))
+	require.Empty(t, store.throttles)
+	require.Len(t, store.events, 1)
+	require.Equal(t, db.SecurityEventLoginUnlocked, store.events[0].EventType)
+
+	// unlocking again is harmless and not recorded
+	require.Equal(t, http.StatusOK, unlock(user.ID))
+	require.Len(t, store.events, 1)
+
+	require.Equal(t, http.StatusNotFound, unlock(uuid.New()))
+}
+
+func (s *throttleStore) GetReportShareByTokenHash(ctx context.Context, tokenHash string) (db.ReportShare, error) {
+	for _, share := range s.shares {
+		if share.TokenHash == tokenHash {
+			return share, nil
+		}
+	}
+	return db.ReportShare{}, sql.ErrNoRows
+}
+
+func (s *throttleStore) CreateReportShareRedemption(ctx context.Context, arg db.CreateReportShareRedemptionParams) (db.ReportShareRedemption, error) {
+	return db.ReportShareRedemption{}, nil
+}
+
+func TestShareThrottle(t *testing.T) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("correct-password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	share := db.ReportShare{
+		ID:           uuid.New(),
+		UserID:       uuid.New(),
+		TokenHash:    hashShareToken("token"),
+		PasswordHash: sql.NullString{String: string(hashedPassword), Valid: true},
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}
+
+	store := newThrottleStore()
+	store.shares = append(store.shares, share)
+	s := &server{
+		logger: zap.NewNop().Sugar(),
+		store:  store,
+		config: &config.AppConfig{
+			LOGIN_MAX_FAILURES:     3,
+			LOGIN_IP_MAX_FAILURES:  10,
+			LOGIN_FAILURE_WINDOW:   time.Minute,
+			LOGIN_LOCKOUT_DURATION: time.Hour,
+		},
+	}
+
+	redeem := func(password string) *httptest.ResponseRecorder {
+		body, err := json.Marshal(RedeemShareRequest{Password: password})
+		require.NoError(t, err)
+		routeContext := chi.NewRouteContext()
+		routeContext.URLParams.Add("token", "token")
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeContext))
+		rec := httptest.NewRecorder()
+		s.RedeemShareHandler(rec, req)
+		return rec
+	}
+
+	for range 3 {
+		require.Equal(t, http.StatusUnauthorized, redeem("wrong-password").Code)
+	}
+	require.Len(t, store.events, 1)
+	require.Equal(t, db.SecurityEventShareLocked, store.events[0].EventType)
+	require.Equal(t, uuid.NullUUID{UUID: share.UserID, Valid: true}, store.events[0].UserID)
+
+	// locked out even with the right password
+	rec := redeem("correct-password")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	require.InDelta(t, 3600, retryAfter, 5)
+
+	// guessing at the link doesn't count against signing in from the IP
+	require.Equal(t, int32(3), store.throttles[loginThrottleKey{db.LoginThrottleShareIP, "192.0.2.1"}].Failures)
+	require.NotContains(t, store.throttles, loginThrottleKey{db.LoginThrottleIP, "192.0.2.1"})
+}
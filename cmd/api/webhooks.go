@@ -0,0 +1,303 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+	"github.com/trenchesdeveloper/csv-reporter/webhooks"
+)
+
+type CreateWebhookRequest struct {
+	URL string `json:"url" validate:"required,http_url,max=2048"`
+}
+
+type WebhookResponse struct {
+	ID        uuid.UUID `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID             uuid.UUID                        `json:"id"`
+	WebhookID      *uuid.UUID                       `json:"webhook_id,omitempty"`
+	ReportID       uuid.UUID                        `json:"report_id"`
+	Event          string                           `json:"event"`
+	URL            string                           `json:"url"`
+	Status         string                           `json:"status"`
+	AttemptCount   int32                            `json:"attempt_count"`
+	LastStatusCode int32                            `json:"last_status_code,omitempty"`
+	LastError      string                           `json:"last_error,omitempty"`
+	NextAttemptAt  time.Time                        `json:"next_attempt_at,omitempty"`
+	DeliveredAt    time.Time                        `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time                        `json:"created_at"`
+	Attempts       []WebhookDeliveryAttemptResponse `json:"attempts,omitempty"`
+}
+
+type WebhookDeliveryAttemptResponse struct {
+	AttemptNumber int32     `json:"attempt_number"`
+	StatusCode    int32     `json:"status_code,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	DurationMs    int32     `json:"duration_ms"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (s *server) CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateWebhookRequest
+	if err := readJSON(w, r, &req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := Validate.Struct(req); err != nil {
+		errorResponse(w, http.StatusBadRequest, formatValidationErrors(err))
+		return
+	}
+	if err := s.validateOutboundURL(req.URL); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid webhook URL: "+err.Error())
+		return
+	}
+
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		s.logger.Error("Error generating webhook secret", err)
+		errorResponse(w, http.StatusInternalServerError, "Error generating webhook secret")
+		return
+	}
+
+	endpoint, err := s.store.CreateWebhookEndpoint(r.Context(), db.CreateWebhookEndpointParams{
+		UserID: user.ID,
+		Url:    req.URL,
+		Secret: secret,
+	})
+	if err != nil {
+		s.logger.Error("Error creating webhook", err)
+		errorResponse(w, http.StatusInternalServerError, "Error creating webhook")
+		return
+	}
+
+	// the secret is only returned once, when the webhook is registered
+	jsonResponse(w, http.StatusCreated, WebhookResponse{
+		ID:        endpoint.ID,
+		URL:       endpoint.Url,
+		Secret:    endpoint.Secret,
+		CreatedAt: endpoint.CreatedAt,
+	}, "Webhook created successfully")
+}
+
+func (s *server) ListWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	endpoints, err := s.store.ListWebhookEndpoints(r.Context(), user.ID)
+	if err != nil {
+		s.logger.Error("Error listing webhooks", err)
+		errorResponse(w, http.StatusInternalServerError, "Error listing webhooks")
+		return
+	}
+
+	response := make([]WebhookResponse, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		response = append(response, WebhookResponse{
+			ID:        endpoint.ID,
+			URL:       endpoint.Url,
+			CreatedAt: endpoint.CreatedAt,
+		})
+	}
+
+	jsonResponse(w, http.StatusOK, response, "Webhooks retrieved successfully")
+}
+
+func (s *server) DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	webhookId, err := uuid.Parse(chi.URLParam(r, "webhookId"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	deleted, err := s.store.DeleteWebhookEndpoint(r.Context(), db.DeleteWebhookEndpointParams{
+		UserID: user.ID,
+		ID:     webhookId,
+	})
+	if err != nil {
+		s.logger.Error("Error deleting webhook", err)
+		errorResponse(w, http.StatusInternalServerError, "Error deleting webhook")
+		return
+	}
+	if deleted == 0 {
+		errorResponse(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, nil, "Webhook deleted successfully")
+}
+
+func (s *server) ListWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	limit, offset, err := readPagination(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	deliveries, err := s.store.ListWebhookDeliveries(r.Context(), db.ListWebhookDeliveriesParams{
+		UserID: user.ID,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		s.logger.Error("Error listing webhook deliveries", err)
+		errorResponse(w, http.StatusInternalServerError, "Error listing webhook deliveries")
+		return
+	}
+
+	response := make([]WebhookDeliveryResponse, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		response = append(response, newWebhookDeliveryResponse(delivery))
+	}
+
+	jsonResponse(w, http.StatusOK, response, "Webhook deliveries retrieved successfully")
+}
+
+func (s *server) GetWebhookDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	delivery, ok := s.loadWebhookDelivery(w, r)
+	if !ok {
+		return
+	}
+
+	attempts, err := s.store.ListWebhookDeliveryAttempts(r.Context(), delivery.ID)
+	if err != nil {
+		s.logger.Error("Error listing webhook delivery attempts", err)
+		errorResponse(w, http.StatusInternalServerError, "Error listing webhook delivery attempts")
+		return
+	}
+
+	response := newWebhookDeliveryResponse(delivery)
+	for _, attempt := range attempts {
+		response.Attempts = append(response.Attempts, WebhookDeliveryAttemptResponse{
+			AttemptNumber: attempt.AttemptNumber,
+			StatusCode:    attempt.StatusCode.Int32,
+			Error:         attempt.Error.String,
+			DurationMs:    attempt.DurationMs,
+			CreatedAt:     attempt.CreatedAt,
+		})
+	}
+
+	jsonResponse(w, http.StatusOK, response, "Webhook delivery retrieved successfully")
+}
+
+func (s *server) RedeliverWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	delivery, ok := s.loadWebhookDelivery(w, r)
+	if !ok {
+		return
+	}
+
+	// the worker picks the delivery up again on its next poll
+	delivery, err := s.store.RedeliverWebhookDelivery(r.Context(), db.RedeliverWebhookDeliveryParams{
+		UserID: delivery.UserID,
+		ID:     delivery.ID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorResponse(w, http.StatusConflict, "Webhook delivery is already pending")
+			return
+		}
+		s.logger.Error("Error redelivering webhook", err)
+		errorResponse(w, http.StatusInternalServerError, "Error redelivering webhook")
+		return
+	}
+
+	jsonResponse(w, http.StatusAccepted, newWebhookDeliveryResponse(delivery), "Webhook redelivery scheduled")
+}
+
+func (s *server) loadWebhookDelivery(w http.ResponseWriter, r *http.Request) (db.WebhookDelivery, bool) {
+	user, ok := UserFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return db.WebhookDelivery{}, false
+	}
+
+	deliveryId, err := uuid.Parse(chi.URLParam(r, "deliveryId"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid delivery ID")
+		return db.WebhookDelivery{}, false
+	}
+
+	delivery, err := s.store.GetWebhookDelivery(r.Context(), db.GetWebhookDeliveryParams{
+		UserID: user.ID,
+		ID:     deliveryId,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorResponse(w, http.StatusNotFound, "Webhook delivery not found")
+			return db.WebhookDelivery{}, false
+		}
+		s.logger.Error("Error getting webhook delivery", err)
+		errorResponse(w, http.StatusInternalServerError, "Error getting webhook delivery")
+		return db.WebhookDelivery{}, false
+	}
+
+	return delivery, true
+}
+
+func newWebhookDeliveryResponse(delivery db.WebhookDelivery) WebhookDeliveryResponse {
+	response := WebhookDeliveryResponse{
+		ID:             delivery.ID,
+		ReportID:       delivery.ReportID,
+		Event:          delivery.Event,
+		URL:            delivery.Url,
+		Status:         delivery.Status,
+		AttemptCount:   delivery.AttemptCount,
+		LastStatusCode: delivery.LastStatusCode.Int32,
+		LastError:      delivery.LastError.String,
+		DeliveredAt:    delivery.DeliveredAt.Time,
+		CreatedAt:      delivery.CreatedAt,
+	}
+	if delivery.EndpointID.Valid {
+		response.WebhookID = &delivery.EndpointID.UUID
+	}
+	if delivery.Status == webhooks.StatusPending {
+		response.NextAttemptAt = delivery.NextAttemptAt
+	}
+	return response
+}
+
+func newWebhookSecret() (string, error) {
+	token, err := helpers.GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + token, nil
+}
+
+// validateOutboundURL rejects URLs the worker must not make requests to. Plain http is only
+// accepted in development.
+func (s *server) validateOutboundURL(rawURL string) error {
+	return helpers.ValidateOutboundURL(rawURL, s.config.ENVIRONMENT != "development")
+}
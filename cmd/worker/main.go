@@ -11,7 +11,11 @@ import (
 	_ "github.com/lib/pq"
 	"github.com/trenchesdeveloper/csv-reporter/config"
 	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"github.com/trenchesdeveloper/csv-reporter/destinations"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+	"github.com/trenchesdeveloper/csv-reporter/mailer"
 	"github.com/trenchesdeveloper/csv-reporter/reports"
+	"github.com/trenchesdeveloper/csv-reporter/webhooks"
 	"go.uber.org/zap"
 	"log"
 	"net/http"
@@ -62,6 +66,37 @@ func run() error {
 
 	builder := reports.NewReportBuilder(storage, lozclient, s3Client, cfg, logger)
 
+	// webhook deliveries for finished reports, to user supplied URLs that must not reach internal hosts
+	dispatcher := webhooks.NewDispatcher(storage, helpers.NewGuardedHTTPClient(time.Second*10), cfg, logger)
+	builder.AddListener(dispatcher)
+	go dispatcher.Start(ctx)
+
+	// email deliveries for completed reports
+	if cfg.SMTP_HOST == "" {
+		logger.Warn("SMTP_HOST is not set, report emails are not sent")
+	} else {
+		sender, err := mailer.NewSMTPMailer(cfg)
+		if err != nil {
+			return fmt.Errorf("creating mailer: %w", err)
+		}
+		templates, err := mailer.LoadTemplates(cfg.EMAIL_TEMPLATE_PATH)
+		if err != nil {
+			return fmt.Errorf("loading email templates: %w", err)
+		}
+		notifier := mailer.NewNotifier(storage, sender, templates, s3Client, cfg, logger)
+		builder.AddListener(notifier)
+		go notifier.Start(ctx)
+	}
+
+	// copies of completed reports for SFTP and WebDAV destinations
+	deliverer := destinations.NewDeliverer(storage, s3Client, cfg, logger)
+	builder.AddListener(deliverer)
+	go deliverer.Start(ctx)
+
+	// create and enqueue the reports of due schedules
+	scheduler := reports.NewScheduler(storage, sqsClient, cfg, logger)
+	go scheduler.Start(ctx)
+
 	// create the worker
 	worker := reports.NewWorker(cfg, builder, logger, sqsClient, 5) // nil for sqsClient as we are not using SQS in this example
 
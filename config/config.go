@@ -1,6 +1,9 @@
 package config
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/spf13/viper"
 )
 
@@ -11,6 +14,7 @@ type AppConfig struct {
 	SERVER_PORT             string `mapstructure:"SERVER_PORT"`
 	ENVIRONMENT             string `mapstructure:"ENVIRONMENT"`
 	JWT_SECRET              string `mapstructure:"JWT_SECRET"`
+	JWT_KEYSET_PATH         string `mapstructure:"JWT_KEYSET_PATH"`
 	AppName                 string `mapstructure:"APP_NAME"`
 	AWS_ACCESS_KEY_ID       string `mapstructure:"AWS_ACCESS_KEY_ID"`
 	AWS_SECRET_ACCESS_KEY   string `mapstructure:"AWS_SECRET_ACCESS_KEY"`
@@ -20,6 +24,40 @@ type AppConfig struct {
 	S3_LOCALSTACK_ENDPOINT  string `mapstructure:"S3_LOCALSTACK_ENDPOINT"`
 	SQS_LOCALSTACK_ENDPOINT string `mapstructure:"SQS_LOCALSTACK_ENDPOINT"`
 
+	DOWNLOAD_URL_TTL            time.Duration `mapstructure:"DOWNLOAD_URL_TTL"`
+	DOWNLOAD_URL_REFRESH_WINDOW time.Duration `mapstructure:"DOWNLOAD_URL_REFRESH_WINDOW"`
+	REPORT_CANCEL_POLL_INTERVAL time.Duration `mapstructure:"REPORT_CANCEL_POLL_INTERVAL"`
+	REPORT_PROGRESS_INTERVAL    time.Duration `mapstructure:"REPORT_PROGRESS_INTERVAL"`
+	REPORT_CACHE_TTL            time.Duration `mapstructure:"REPORT_CACHE_TTL"`
+	SCHEDULER_POLL_INTERVAL     time.Duration `mapstructure:"SCHEDULER_POLL_INTERVAL"`
+	REPORT_REQUEUE_AFTER        time.Duration `mapstructure:"REPORT_REQUEUE_AFTER"`
+	WEBHOOK_POLL_INTERVAL       time.Duration `mapstructure:"WEBHOOK_POLL_INTERVAL"`
+	WEBHOOK_RETRY_BASE_DELAY    time.Duration `mapstructure:"WEBHOOK_RETRY_BASE_DELAY"`
+	WEBHOOK_MAX_ATTEMPTS        int           `mapstructure:"WEBHOOK_MAX_ATTEMPTS"`
+
+	SMTP_HOST                  string        `mapstructure:"SMTP_HOST"`
+	SMTP_PORT                  int           `mapstructure:"SMTP_PORT"`
+	SMTP_USERNAME              string        `mapstructure:"SMTP_USERNAME"`
+	SMTP_PASSWORD              string        `mapstructure:"SMTP_PASSWORD"`
+	SMTP_FROM                  string        `mapstructure:"SMTP_FROM"`
+	EMAIL_TEMPLATE_PATH        string        `mapstructure:"EMAIL_TEMPLATE_PATH"`
+	EMAIL_ATTACHMENT_MAX_BYTES int64         `mapstructure:"EMAIL_ATTACHMENT_MAX_BYTES"`
+	EMAIL_LINK_TTL             time.Duration `mapstructure:"EMAIL_LINK_TTL"`
+	EMAIL_POLL_INTERVAL        time.Duration `mapstructure:"EMAIL_POLL_INTERVAL"`
+	DESTINATION_POLL_INTERVAL  time.Duration `mapstructure:"DESTINATION_POLL_INTERVAL"`
+	DENYLIST_SYNC_INTERVAL     time.Duration `mapstructure:"DENYLIST_SYNC_INTERVAL"`
+	APP_URL                    string        `mapstructure:"APP_URL"`
+	EMAIL_VERIFICATION_TTL     time.Duration `mapstructure:"EMAIL_VERIFICATION_TTL"`
+	PASSWORD_RESET_TTL         time.Duration `mapstructure:"PASSWORD_RESET_TTL"`
+	REQUIRE_VERIFIED_EMAIL     bool          `mapstructure:"REQUIRE_VERIFIED_EMAIL"`
+	LOGIN_MAX_FAILURES         int32         `mapstructure:"LOGIN_MAX_FAILURES"`
+	LOGIN_IP_MAX_FAILURES      int32         `mapstructure:"LOGIN_IP_MAX_FAILURES"`
+	LOGIN_FAILURE_WINDOW       time.Duration `mapstructure:"LOGIN_FAILURE_WINDOW"`
+	LOGIN_LOCKOUT_DURATION     time.Duration `mapstructure:"LOGIN_LOCKOUT_DURATION"`
+	LOGIN_DELAY_BASE           time.Duration `mapstructure:"LOGIN_DELAY_BASE"`
+	LOGIN_DELAY_MAX            time.Duration `mapstructure:"LOGIN_DELAY_MAX"`
+	TRUSTED_PROXIES            string        `mapstructure:"TRUSTED_PROXIES"`
+
 	TF_VAR_aws_access_key_id       string `mapstructure:"TF_VAR_aws_access_key_id"`
 	TF_VAR_aws_secret_access_key   string `mapstructure:"TF_VAR_aws_secret_access_key"`
 	TF_VAR_aws_default_region      string `mapstructure:"TF_VAR_aws_default_region"`
@@ -41,6 +79,7 @@ func LoadConfig(path string) (*AppConfig, error) {
 	viper.BindEnv("SERVER_PORT", "SERVER_PORT")
 	viper.BindEnv("ENVIRONMENT", "ENVIRONMENT")
 	viper.BindEnv("JWT_SECRET", "JWT_SECRET")
+	viper.BindEnv("JWT_KEYSET_PATH", "JWT_KEYSET_PATH")
 	viper.BindEnv("APP_NAME", "APP_NAME")
 	viper.BindEnv("AWS_ACCESS_KEY_ID", "AWS_ACCESS_KEY_ID")
 	viper.BindEnv("AWS_SECRET_ACCESS_KEY", "AWS_SECRET_ACCESS_KEY")
@@ -54,6 +93,68 @@ func LoadConfig(path string) (*AppConfig, error) {
 	viper.BindEnv("TF_VAR_s3_bucket", "TF_VAR_s3_bucket")
 	viper.BindEnv("S3_LOCALSTACK_ENDPOINT", "S3_LOCALSTACK_ENDPOINT")
 	viper.BindEnv("SQS_LOCALSTACK_ENDPOINT", "SQS_LOCALSTACK_ENDPOINT")
+	viper.BindEnv("DOWNLOAD_URL_TTL", "DOWNLOAD_URL_TTL")
+	viper.BindEnv("DOWNLOAD_URL_REFRESH_WINDOW", "DOWNLOAD_URL_REFRESH_WINDOW")
+	viper.BindEnv("REPORT_CANCEL_POLL_INTERVAL", "REPORT_CANCEL_POLL_INTERVAL")
+	viper.BindEnv("REPORT_PROGRESS_INTERVAL", "REPORT_PROGRESS_INTERVAL")
+	viper.BindEnv("REPORT_CACHE_TTL", "REPORT_CACHE_TTL")
+	viper.BindEnv("SCHEDULER_POLL_INTERVAL", "SCHEDULER_POLL_INTERVAL")
+	viper.BindEnv("REPORT_REQUEUE_AFTER", "REPORT_REQUEUE_AFTER")
+	viper.BindEnv("WEBHOOK_POLL_INTERVAL", "WEBHOOK_POLL_INTERVAL")
+	viper.BindEnv("WEBHOOK_RETRY_BASE_DELAY", "WEBHOOK_RETRY_BASE_DELAY")
+	viper.BindEnv("WEBHOOK_MAX_ATTEMPTS", "WEBHOOK_MAX_ATTEMPTS")
+	viper.BindEnv("SMTP_HOST", "SMTP_HOST")
+	viper.BindEnv("SMTP_PORT", "SMTP_PORT")
+	viper.BindEnv("SMTP_USERNAME", "SMTP_USERNAME")
+	viper.BindEnv("SMTP_PASSWORD", "SMTP_PASSWORD")
+	viper.BindEnv("SMTP_FROM", "SMTP_FROM")
+	viper.BindEnv("EMAIL_TEMPLATE_PATH", "EMAIL_TEMPLATE_PATH")
+	viper.BindEnv("EMAIL_ATTACHMENT_MAX_BYTES", "EMAIL_ATTACHMENT_MAX_BYTES")
+	viper.BindEnv("EMAIL_LINK_TTL", "EMAIL_LINK_TTL")
+	viper.BindEnv("EMAIL_POLL_INTERVAL", "EMAIL_POLL_INTERVAL")
+	viper.BindEnv("DESTINATION_POLL_INTERVAL", "DESTINATION_POLL_INTERVAL")
+	viper.BindEnv("DENYLIST_SYNC_INTERVAL", "DENYLIST_SYNC_INTERVAL")
+	viper.BindEnv("APP_URL", "APP_URL")
+	viper.BindEnv("EMAIL_VERIFICATION_TTL", "EMAIL_VERIFICATION_TTL")
+	viper.BindEnv("PASSWORD_RESET_TTL", "PASSWORD_RESET_TTL")
+	viper.BindEnv("REQUIRE_VERIFIED_EMAIL", "REQUIRE_VERIFIED_EMAIL")
+	viper.BindEnv("LOGIN_MAX_FAILURES", "LOGIN_MAX_FAILURES")
+	viper.BindEnv("LOGIN_IP_MAX_FAILURES", "LOGIN_IP_MAX_FAILURES")
+	viper.BindEnv("LOGIN_FAILURE_WINDOW", "LOGIN_FAILURE_WINDOW")
+	viper.BindEnv("LOGIN_LOCKOUT_DURATION", "LOGIN_LOCKOUT_DURATION")
+	viper.BindEnv("LOGIN_DELAY_BASE", "LOGIN_DELAY_BASE")
+	viper.BindEnv("LOGIN_DELAY_MAX", "LOGIN_DELAY_MAX")
+	viper.BindEnv("TRUSTED_PROXIES", "TRUSTED_PROXIES")
+
+	// defaults for optional settings
+	viper.SetDefault("DOWNLOAD_URL_TTL", "10m")
+	viper.SetDefault("DOWNLOAD_URL_REFRESH_WINDOW", "2m")
+	viper.SetDefault("REPORT_CANCEL_POLL_INTERVAL", "2s")
+	viper.SetDefault("REPORT_PROGRESS_INTERVAL", "2s")
+	viper.SetDefault("REPORT_CACHE_TTL", "1h")
+	viper.SetDefault("SCHEDULER_POLL_INTERVAL", "30s")
+	viper.SetDefault("REPORT_REQUEUE_AFTER", "15m")
+	viper.SetDefault("WEBHOOK_POLL_INTERVAL", "5s")
+	viper.SetDefault("WEBHOOK_RETRY_BASE_DELAY", "30s")
+	viper.SetDefault("WEBHOOK_MAX_ATTEMPTS", 8)
+	viper.SetDefault("SMTP_PORT", 587)
+	viper.SetDefault("SMTP_FROM", "CSV Reporter <reports@localhost>")
+	viper.SetDefault("EMAIL_ATTACHMENT_MAX_BYTES", 5<<20)
+	viper.SetDefault("EMAIL_LINK_TTL", "72h")
+	viper.SetDefault("EMAIL_POLL_INTERVAL", "10s")
+	viper.SetDefault("DESTINATION_POLL_INTERVAL", "10s")
+	viper.SetDefault("DENYLIST_SYNC_INTERVAL", "5s")
+	viper.SetDefault("APP_URL", "http://localhost:3000")
+	viper.SetDefault("EMAIL_VERIFICATION_TTL", "48h")
+	viper.SetDefault("PASSWORD_RESET_TTL", "1h")
+	viper.SetDefault("REQUIRE_VERIFIED_EMAIL", false)
+	viper.SetDefault("LOGIN_MAX_FAILURES", 5)
+	viper.SetDefault("LOGIN_IP_MAX_FAILURES", 50)
+	viper.SetDefault("LOGIN_FAILURE_WINDOW", "15m")
+	viper.SetDefault("LOGIN_LOCKOUT_DURATION", "15m")
+	viper.SetDefault("LOGIN_DELAY_BASE", "1s")
+	viper.SetDefault("LOGIN_DELAY_MAX", "30s")
+	viper.SetDefault("TRUSTED_PROXIES", "")
 
 	// Check if the environment is set to production
 	if viper.GetString("ENVIRONMENT") != "production" {
@@ -74,5 +175,11 @@ func LoadConfig(path string) (*AppConfig, error) {
 		return nil, err
 	}
 
+	// a window as long as the URL lifetime would sign a new URL on every request
+	if config.DOWNLOAD_URL_REFRESH_WINDOW >= config.DOWNLOAD_URL_TTL {
+		return nil, fmt.Errorf("DOWNLOAD_URL_REFRESH_WINDOW (%s) must be shorter than DOWNLOAD_URL_TTL (%s)",
+			config.DOWNLOAD_URL_REFRESH_WINDOW, config.DOWNLOAD_URL_TTL)
+	}
+
 	return &config, nil
 }
@@ -0,0 +1,12 @@
+CREATE TABLE report_attempts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    report_id UUID NOT NULL,
+    attempt_number INT NOT NULL,
+    started_at TIMESTAMPTZ,
+    failed_at TIMESTAMPTZ,
+    error_message VARCHAR,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id, report_id) REFERENCES reports(user_id, id) ON DELETE CASCADE,
+    UNIQUE (report_id, attempt_number)
+);
@@ -0,0 +1,42 @@
+ALTER TABLE reports ADD COLUMN callback_url VARCHAR(2048);
+ALTER TABLE reports ADD COLUMN callback_secret VARCHAR(128);
+
+CREATE TABLE webhook_endpoints (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(128) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE webhook_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    endpoint_id UUID REFERENCES webhook_endpoints(id) ON DELETE SET NULL,
+    report_id UUID NOT NULL,
+    event VARCHAR(50) NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(128) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempt_count INT NOT NULL DEFAULT 0,
+    last_status_code INT,
+    last_error VARCHAR,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id, report_id) REFERENCES reports(user_id, id) ON DELETE CASCADE
+);
+
+CREATE INDEX webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX webhook_deliveries_user_idx ON webhook_deliveries (user_id, created_at);
+
+CREATE TABLE webhook_delivery_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    delivery_id UUID NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+    attempt_number INT NOT NULL,
+    status_code INT,
+    error VARCHAR,
+    duration_ms INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
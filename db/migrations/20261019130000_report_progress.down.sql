@@ -0,0 +1,5 @@
+ALTER TABLE reports
+    DROP COLUMN IF EXISTS phase,
+    DROP COLUMN IF EXISTS bytes_written,
+    DROP COLUMN IF EXISTS rows_written,
+    DROP COLUMN IF EXISTS rows_total;
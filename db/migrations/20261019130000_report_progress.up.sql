@@ -0,0 +1,5 @@
+ALTER TABLE reports
+    ADD COLUMN rows_total INTEGER,
+    ADD COLUMN rows_written INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN bytes_written BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN phase TEXT;
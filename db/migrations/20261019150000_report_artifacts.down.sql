@@ -0,0 +1,6 @@
+ALTER TABLE reports
+    DROP COLUMN IF EXISTS artifact_id,
+    DROP COLUMN IF EXISTS params_hash,
+    DROP COLUMN IF EXISTS params;
+
+DROP TABLE IF EXISTS report_artifacts;
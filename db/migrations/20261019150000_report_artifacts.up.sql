@@ -0,0 +1,20 @@
+CREATE TABLE report_artifacts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    params_hash VARCHAR(64) NOT NULL,
+    object_key VARCHAR(1024) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    row_count INT NOT NULL,
+    ref_count INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_report_artifacts_params_hash ON report_artifacts(params_hash, created_at DESC);
+
+ALTER TABLE reports
+    ADD COLUMN params JSONB NOT NULL DEFAULT '{}',
+    ADD COLUMN params_hash VARCHAR(64),
+    ADD COLUMN artifact_id UUID REFERENCES report_artifacts(id);
+
+UPDATE reports SET params = jsonb_build_object('type', report_type);
+
+CREATE INDEX idx_reports_artifact_id ON reports(artifact_id);
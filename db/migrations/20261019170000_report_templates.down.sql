@@ -0,0 +1,5 @@
+ALTER TABLE reports
+    DROP COLUMN IF EXISTS template_version,
+    DROP COLUMN IF EXISTS template_id;
+
+DROP TABLE IF EXISTS report_templates;
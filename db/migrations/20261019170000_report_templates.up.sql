@@ -0,0 +1,14 @@
+CREATE TABLE report_templates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    params JSONB NOT NULL,
+    version INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, name)
+);
+
+ALTER TABLE reports
+    ADD COLUMN template_id UUID REFERENCES report_templates(id) ON DELETE SET NULL,
+    ADD COLUMN template_version INT;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS report_share_redemptions;
+DROP TABLE IF EXISTS report_shares;
@@ -0,0 +1,27 @@
+CREATE TABLE report_shares (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    report_id UUID NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    password_hash TEXT,
+    expires_at TIMESTAMPTZ NOT NULL,
+    max_downloads INT,
+    download_count INT NOT NULL DEFAULT 0,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id, report_id) REFERENCES reports(user_id, id) ON DELETE CASCADE
+);
+
+CREATE INDEX report_shares_report_id_idx ON report_shares (report_id);
+
+CREATE TABLE report_share_redemptions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    share_id UUID NOT NULL REFERENCES report_shares(id) ON DELETE CASCADE,
+    succeeded BOOLEAN NOT NULL,
+    failure_reason TEXT,
+    ip_address TEXT NOT NULL,
+    user_agent TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX report_share_redemptions_share_id_idx ON report_share_redemptions (share_id, created_at);
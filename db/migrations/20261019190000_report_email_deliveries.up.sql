@@ -0,0 +1,17 @@
+CREATE TABLE report_email_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    report_id UUID NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    -- waiting until the report completes, then pending until sent or failed
+    status TEXT NOT NULL DEFAULT 'waiting',
+    attempt_count INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (report_id, recipient),
+    FOREIGN KEY (user_id, report_id) REFERENCES reports(user_id, id) ON DELETE CASCADE
+);
+
+CREATE INDEX report_email_deliveries_due_idx ON report_email_deliveries (next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS report_destination_deliveries;
+DROP TABLE IF EXISTS report_destinations;
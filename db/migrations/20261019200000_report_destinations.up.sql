@@ -0,0 +1,32 @@
+CREATE TABLE report_destinations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    kind TEXT NOT NULL,
+    config JSONB NOT NULL,
+    -- the SFTP private key or the WebDAV password; never returned by the API
+    secret TEXT,
+    public_key TEXT,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE report_destination_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    report_id UUID NOT NULL,
+    destination_id UUID REFERENCES report_destinations(id) ON DELETE SET NULL,
+    destination_name VARCHAR(255) NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    remote_path TEXT,
+    attempt_count INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id, report_id) REFERENCES reports(user_id, id) ON DELETE CASCADE
+);
+
+CREATE INDEX report_destination_deliveries_report_id_idx ON report_destination_deliveries (report_id);
+CREATE INDEX report_destination_deliveries_due_idx ON report_destination_deliveries (next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS security_events;
+
+DROP INDEX IF EXISTS refresh_tokens_family_id_idx;
+
+ALTER TABLE refresh_tokens
+    DROP COLUMN IF EXISTS rotated_at,
+    DROP COLUMN IF EXISTS family_id;
+
+DROP TABLE IF EXISTS refresh_token_families;
@@ -0,0 +1,30 @@
+-- refresh tokens were stored as bcrypt hashes, which can't be looked up, so everyone signs in again
+DELETE FROM refresh_tokens;
+
+CREATE TABLE refresh_token_families (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    revoked_at TIMESTAMPTZ,
+    revoke_reason VARCHAR(50),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX refresh_token_families_user_id_idx ON refresh_token_families (user_id);
+
+ALTER TABLE refresh_tokens
+    ADD COLUMN family_id UUID NOT NULL REFERENCES refresh_token_families(id) ON DELETE CASCADE,
+    ADD COLUMN rotated_at TIMESTAMPTZ;
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+
+CREATE TABLE security_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    event_type VARCHAR(50) NOT NULL,
+    ip_address VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    details JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX security_events_user_id_created_at_idx ON security_events (user_id, created_at DESC);
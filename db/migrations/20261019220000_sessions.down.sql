@@ -0,0 +1,15 @@
+ALTER INDEX refresh_tokens_session_id_idx RENAME TO refresh_tokens_family_id_idx;
+ALTER TABLE refresh_tokens RENAME CONSTRAINT refresh_tokens_session_id_fkey TO refresh_tokens_family_id_fkey;
+ALTER TABLE refresh_tokens RENAME COLUMN session_id TO family_id;
+
+ALTER TABLE sessions
+    DROP COLUMN IF EXISTS expires_at,
+    DROP COLUMN IF EXISTS last_used_at,
+    DROP COLUMN IF EXISTS ip_address,
+    DROP COLUMN IF EXISTS user_agent,
+    DROP COLUMN IF EXISTS device_name;
+
+ALTER INDEX sessions_user_id_idx RENAME TO refresh_token_families_user_id_idx;
+ALTER TABLE sessions RENAME CONSTRAINT sessions_user_id_fkey TO refresh_token_families_user_id_fkey;
+ALTER TABLE sessions RENAME CONSTRAINT sessions_pkey TO refresh_token_families_pkey;
+ALTER TABLE sessions RENAME TO refresh_token_families;
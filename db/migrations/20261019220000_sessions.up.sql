@@ -0,0 +1,16 @@
+-- every refresh token family becomes a session that users can see and revoke
+ALTER TABLE refresh_token_families RENAME TO sessions;
+ALTER TABLE sessions RENAME CONSTRAINT refresh_token_families_pkey TO sessions_pkey;
+ALTER TABLE sessions RENAME CONSTRAINT refresh_token_families_user_id_fkey TO sessions_user_id_fkey;
+ALTER INDEX refresh_token_families_user_id_idx RENAME TO sessions_user_id_idx;
+
+ALTER TABLE sessions
+    ADD COLUMN device_name VARCHAR(100) NOT NULL DEFAULT '',
+    ADD COLUMN user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    ADD COLUMN ip_address VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN last_used_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN expires_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP + INTERVAL '7 days';
+
+ALTER TABLE refresh_tokens RENAME COLUMN family_id TO session_id;
+ALTER TABLE refresh_tokens RENAME CONSTRAINT refresh_tokens_family_id_fkey TO refresh_tokens_session_id_fkey;
+ALTER INDEX refresh_tokens_family_id_idx RENAME TO refresh_tokens_session_id_idx;
@@ -0,0 +1,11 @@
+-- access tokens that stop working before they expire, either one token (jti) or every token of a session (sid)
+CREATE TABLE denied_tokens (
+    kind VARCHAR(10) NOT NULL,
+    token_id VARCHAR(64) NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (kind, token_id)
+);
+
+CREATE INDEX denied_tokens_expires_at_idx ON denied_tokens (expires_at);
@@ -0,0 +1,4 @@
+ALTER TABLE users
+    ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'user';
+
+CREATE INDEX users_role_idx ON users (role) WHERE role <> 'user';
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_report_schedules_organization_id;
+DROP INDEX IF EXISTS idx_report_templates_organization_id;
+DROP INDEX IF EXISTS idx_reports_organization_id;
+
+ALTER TABLE report_artifacts DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE report_schedules DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE report_templates DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE reports DROP COLUMN IF EXISTS organization_id;
+
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
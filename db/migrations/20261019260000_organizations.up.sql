@@ -0,0 +1,31 @@
+CREATE TABLE organizations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE organization_members (
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(16) NOT NULL DEFAULT 'member',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX organization_members_user_id_idx ON organization_members (user_id);
+
+ALTER TABLE reports
+    ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+
+ALTER TABLE report_templates
+    ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+
+ALTER TABLE report_schedules
+    ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+
+ALTER TABLE report_artifacts
+    ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_reports_organization_id ON reports (organization_id);
+CREATE INDEX idx_report_templates_organization_id ON report_templates (organization_id);
+CREATE INDEX idx_report_schedules_organization_id ON report_schedules (organization_id);
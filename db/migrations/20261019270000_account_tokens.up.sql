@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMPTZ;
+
+-- existing accounts predate verification and keep working
+UPDATE users SET email_verified_at = created_at;
+
+CREATE TABLE account_tokens (
+    hashed_token VARCHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(32) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX account_tokens_user_id_purpose_idx ON account_tokens (user_id, purpose);
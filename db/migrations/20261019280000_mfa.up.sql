@@ -0,0 +1,13 @@
+ALTER TABLE users
+    ADD COLUMN mfa_secret VARCHAR(64),
+    ADD COLUMN mfa_enabled_at TIMESTAMPTZ,
+    ADD COLUMN mfa_last_used_step BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE mfa_recovery_codes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    hashed_code VARCHAR(64) NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, hashed_code)
+);
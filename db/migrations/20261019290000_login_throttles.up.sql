@@ -0,0 +1,12 @@
+-- failed sign-ins per account (the lowercased email) and per client IP
+CREATE TABLE login_throttles (
+    scope VARCHAR(16) NOT NULL,
+    key VARCHAR(320) NOT NULL,
+    failures INT NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    next_attempt_at TIMESTAMPTZ,
+    locked_until TIMESTAMPTZ,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX login_throttles_last_failed_at_idx ON login_throttles (last_failed_at);
@@ -0,0 +1,5 @@
+ALTER TABLE report_schedules DROP COLUMN IF EXISTS last_error;
+
+DROP INDEX IF EXISTS reports_requested_enqueued_at_idx;
+
+ALTER TABLE reports DROP COLUMN IF EXISTS enqueued_at;
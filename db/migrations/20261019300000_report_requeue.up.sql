@@ -0,0 +1,9 @@
+-- when a requested report was last sent to the queue; reports that stay requested long after that
+-- are enqueued again
+ALTER TABLE reports ADD COLUMN enqueued_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX reports_requested_enqueued_at_idx ON reports (enqueued_at)
+    WHERE started_at IS NULL AND cancelled_at IS NULL;
+
+-- why a schedule was disabled by the scheduler
+ALTER TABLE report_schedules ADD COLUMN last_error TEXT;
@@ -0,0 +1,27 @@
+-- name: CreateAccountToken :one
+INSERT INTO account_tokens (hashed_token,
+                            user_id,
+                            purpose,
+                            expires_at)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetAccountTokenForUpdate :one
+SELECT *
+FROM account_tokens
+WHERE hashed_token = $1
+  AND purpose = $2
+FOR UPDATE;
+
+-- name: MarkAccountTokenUsed :execrows
+UPDATE account_tokens
+SET used_at = NOW()
+WHERE hashed_token = $1
+  AND used_at IS NULL;
+
+-- name: InvalidateAccountTokens :exec
+UPDATE account_tokens
+SET used_at = NOW()
+WHERE user_id = $1
+  AND purpose = $2
+  AND used_at IS NULL;
@@ -0,0 +1,35 @@
+-- name: CreateApiKey :one
+INSERT INTO api_keys (user_id,
+                      name,
+                      prefix,
+                      key_hash,
+                      scopes,
+                      expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetApiKeyByHash :one
+SELECT *
+FROM api_keys
+WHERE key_hash = $1
+  AND revoked_at IS NULL;
+
+-- name: ListApiKeys :many
+SELECT *
+FROM api_keys
+WHERE user_id = $1
+  AND revoked_at IS NULL
+ORDER BY created_at DESC;
+
+-- name: TouchApiKey :exec
+UPDATE api_keys
+SET last_used_at = NOW()
+WHERE id = $1
+  AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute');
+
+-- name: RevokeApiKey :execrows
+UPDATE api_keys
+SET revoked_at = NOW()
+WHERE user_id = $1
+  AND id = $2
+  AND revoked_at IS NULL;
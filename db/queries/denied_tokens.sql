@@ -0,0 +1,14 @@
+-- name: DenyToken :exec
+INSERT INTO denied_tokens (kind, token_id, user_id, expires_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (kind, token_id) DO UPDATE
+    SET expires_at = GREATEST(denied_tokens.expires_at, EXCLUDED.expires_at);
+
+-- name: ListActiveDeniedTokens :many
+SELECT *
+FROM denied_tokens
+WHERE expires_at > NOW();
+
+-- name: DeleteExpiredDeniedTokens :execrows
+DELETE FROM denied_tokens
+WHERE expires_at <= NOW();
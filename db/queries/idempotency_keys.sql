@@ -0,0 +1,36 @@
+-- name: CreateIdempotencyKey :one
+INSERT INTO idempotency_keys (user_id, key, request_hash, expires_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, key) DO UPDATE
+SET
+    request_hash = EXCLUDED.request_hash,
+    status_code = NULL,
+    response_body = NULL,
+    created_at = NOW(),
+    expires_at = EXCLUDED.expires_at
+WHERE idempotency_keys.expires_at <= NOW()
+RETURNING *;
+
+-- name: GetIdempotencyKey :one
+SELECT *
+FROM idempotency_keys
+WHERE user_id = $1
+  AND key = $2
+  AND expires_at > NOW();
+
+-- name: SaveIdempotencyResponse :exec
+UPDATE idempotency_keys
+SET
+    status_code = $3,
+    response_body = $4
+WHERE user_id = $1
+  AND key = $2;
+
+-- name: DeleteIdempotencyKey :exec
+DELETE FROM idempotency_keys
+WHERE user_id = $1
+  AND key = $2;
+
+-- name: DeleteExpiredIdempotencyKeys :execrows
+DELETE FROM idempotency_keys
+WHERE expires_at <= NOW();
@@ -0,0 +1,47 @@
+-- name: GetLoginThrottle :one
+SELECT *
+FROM login_throttles
+WHERE scope = $1
+  AND key = $2;
+
+-- name: CreateLoginThrottle :exec
+INSERT INTO login_throttles (scope, key)
+VALUES ($1, $2)
+ON CONFLICT (scope, key) DO NOTHING;
+
+-- name: GetLoginThrottleForUpdate :one
+SELECT *
+FROM login_throttles
+WHERE scope = $1
+  AND key = $2
+FOR UPDATE;
+
+-- name: UpdateLoginThrottle :one
+UPDATE login_throttles
+SET failures        = $3,
+    last_failed_at  = $4,
+    next_attempt_at = $5,
+    locked_until    = $6
+WHERE scope = $1
+  AND key = $2
+RETURNING *;
+
+-- name: ReleaseLoginAttempt :exec
+UPDATE login_throttles
+SET failures        = GREATEST(failures - 1, 0),
+    next_attempt_at = CASE WHEN failures <= 1 THEN NULL ELSE next_attempt_at END,
+    locked_until    = CASE WHEN failures <= sqlc.arg('max_failures') THEN NULL ELSE locked_until END
+WHERE scope = sqlc.arg('scope')
+  AND key = sqlc.arg('key');
+
+-- name: DeleteLoginThrottle :execrows
+DELETE
+FROM login_throttles
+WHERE scope = $1
+  AND key = $2;
+
+-- name: DeleteStaleLoginThrottles :execrows
+DELETE
+FROM login_throttles
+WHERE last_failed_at < $1
+  AND (locked_until IS NULL OR locked_until <= NOW());
@@ -0,0 +1,51 @@
+-- name: SetUserMfaSecret :one
+UPDATE users
+SET mfa_secret = $2
+WHERE id = $1
+  AND mfa_enabled_at IS NULL
+RETURNING *;
+
+-- name: EnableUserMfa :one
+UPDATE users
+SET mfa_enabled_at     = NOW(),
+    mfa_last_used_step = $2
+WHERE id = $1
+  AND mfa_secret IS NOT NULL
+  AND mfa_enabled_at IS NULL
+RETURNING *;
+
+-- name: DisableUserMfa :one
+UPDATE users
+SET mfa_secret         = NULL,
+    mfa_enabled_at     = NULL,
+    mfa_last_used_step = 0
+WHERE id = $1
+RETURNING *;
+
+-- name: UseMfaStep :execrows
+UPDATE users
+SET mfa_last_used_step = $2
+WHERE id = $1
+  AND mfa_last_used_step < $2;
+
+-- name: CreateMfaRecoveryCode :exec
+INSERT INTO mfa_recovery_codes (user_id, hashed_code)
+VALUES ($1, $2);
+
+-- name: DeleteMfaRecoveryCodes :exec
+DELETE
+FROM mfa_recovery_codes
+WHERE user_id = $1;
+
+-- name: UseMfaRecoveryCode :execrows
+UPDATE mfa_recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1
+  AND hashed_code = $2
+  AND used_at IS NULL;
+
+-- name: CountUnusedMfaRecoveryCodes :one
+SELECT COUNT(*)
+FROM mfa_recovery_codes
+WHERE user_id = $1
+  AND used_at IS NULL;
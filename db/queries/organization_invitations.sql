@@ -0,0 +1,47 @@
+-- name: CreateOrganizationInvitation :one
+INSERT INTO organization_invitations (organization_id, email, role, invited_by, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (organization_id, email) DO UPDATE
+SET role       = EXCLUDED.role,
+    invited_by = EXCLUDED.invited_by,
+    created_at = NOW(),
+    expires_at = EXCLUDED.expires_at
+RETURNING *;
+
+-- name: GetOrganizationInvitation :one
+SELECT *
+FROM organization_invitations
+WHERE organization_id = $1
+  AND id = $2;
+
+-- name: ListOrganizationInvitations :many
+SELECT *
+FROM organization_invitations
+WHERE organization_id = $1
+  AND expires_at > NOW()
+ORDER BY created_at;
+
+-- name: ListUserOrganizationInvitations :many
+SELECT i.id, i.organization_id, o.name AS organization_name, i.role, i.created_at, i.expires_at
+FROM organization_invitations i
+JOIN organizations o ON o.id = i.organization_id
+WHERE i.email = $1
+  AND i.expires_at > NOW()
+ORDER BY i.created_at;
+
+-- name: DeleteOrganizationInvitation :execrows
+DELETE FROM organization_invitations
+WHERE organization_id = $1
+  AND id = $2;
+
+-- name: TakeOrganizationInvitation :one
+DELETE FROM organization_invitations
+WHERE id = $1
+  AND email = $2
+  AND expires_at > NOW()
+RETURNING *;
+
+-- name: DeclineOrganizationInvitation :execrows
+DELETE FROM organization_invitations
+WHERE id = $1
+  AND email = $2;
@@ -0,0 +1,54 @@
+-- name: CreateOrganization :one
+INSERT INTO organizations (name)
+VALUES ($1)
+RETURNING *;
+
+-- name: ListUserOrganizations :many
+SELECT o.id, o.name, o.created_at, m.role
+FROM organizations o
+JOIN organization_members m ON m.organization_id = o.id
+WHERE m.user_id = $1
+ORDER BY o.name;
+
+-- name: AddOrganizationMember :one
+INSERT INTO organization_members (organization_id, user_id, role)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetOrganizationMember :one
+SELECT *
+FROM organization_members
+WHERE organization_id = $1
+  AND user_id = $2;
+
+-- name: ListOrganizationMembers :many
+SELECT m.user_id, u.email, m.role, m.created_at
+FROM organization_members m
+JOIN users u ON u.id = m.user_id
+WHERE m.organization_id = $1
+ORDER BY m.created_at;
+
+-- name: UpdateOrganizationMemberRole :one
+UPDATE organization_members
+SET role = $3
+WHERE organization_id = $1
+  AND user_id = $2
+RETURNING *;
+
+-- name: RemoveOrganizationMember :execrows
+DELETE FROM organization_members
+WHERE organization_id = $1
+  AND user_id = $2;
+
+-- name: CountOrganizationOwners :one
+SELECT COUNT(*)
+FROM organization_members
+WHERE organization_id = $1
+  AND role = 'owner';
+
+-- name: LockOrganizationOwners :many
+SELECT user_id
+FROM organization_members
+WHERE organization_id = $1
+  AND role = 'owner'
+FOR UPDATE;
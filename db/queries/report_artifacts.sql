@@ -0,0 +1,40 @@
+-- name: CreateReportArtifact :one
+INSERT INTO report_artifacts (
+    params_hash,
+    object_key,
+    size_bytes,
+    row_count,
+    organization_id
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: GetReusableReportArtifact :one
+SELECT *
+FROM report_artifacts
+WHERE params_hash = sqlc.arg('params_hash')
+  AND organization_id IS NOT DISTINCT FROM sqlc.narg('organization_id')
+  AND created_at > sqlc.arg('created_after')
+  AND ref_count > 0
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: AcquireReportArtifact :one
+UPDATE report_artifacts
+SET ref_count = ref_count + 1
+WHERE id = $1
+  AND ref_count > 0
+RETURNING *;
+
+-- name: ReleaseReportArtifact :one
+UPDATE report_artifacts
+SET ref_count = ref_count - 1
+WHERE id = $1
+  AND ref_count > 0
+RETURNING *;
+
+-- name: DeleteReportArtifact :exec
+DELETE FROM report_artifacts
+WHERE id = $1
+  AND ref_count = 0;
@@ -0,0 +1,30 @@
+-- name: CreateReportAttempt :one
+INSERT INTO report_attempts (
+    user_id,
+    report_id,
+    attempt_number,
+    started_at,
+    failed_at,
+    error_message
+)
+SELECT
+    r.user_id,
+    r.id,
+    (SELECT COUNT(*) + 1 FROM report_attempts a WHERE a.report_id = r.id),
+    r.started_at,
+    r.failed_at,
+    r.error_message
+FROM reports r
+WHERE
+    r.user_id = $1
+  AND r.id = $2
+  AND r.failed_at IS NOT NULL
+FOR UPDATE OF r
+RETURNING *;
+
+-- name: ListReportAttempts :many
+SELECT *
+FROM report_attempts
+WHERE user_id = $1
+  AND report_id = $2
+ORDER BY attempt_number;
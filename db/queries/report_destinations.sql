@@ -0,0 +1,88 @@
+-- name: CreateReportDestination :one
+INSERT INTO report_destinations (
+    user_id,
+    name,
+    kind,
+    config,
+    secret,
+    public_key,
+    enabled
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+)
+RETURNING *;
+
+-- name: GetReportDestination :one
+SELECT *
+FROM report_destinations
+WHERE user_id = $1
+  AND id = $2;
+
+-- name: ListReportDestinations :many
+SELECT *
+FROM report_destinations
+WHERE user_id = $1
+ORDER BY name;
+
+-- name: ListEnabledReportDestinations :many
+SELECT *
+FROM report_destinations
+WHERE user_id = $1
+  AND enabled
+ORDER BY name;
+
+-- name: SetReportDestinationEnabled :one
+UPDATE report_destinations
+SET enabled = $3
+WHERE user_id = $1
+  AND id = $2
+RETURNING *;
+
+-- name: DeleteReportDestination :execrows
+DELETE FROM report_destinations
+WHERE user_id = $1
+  AND id = $2;
+
+-- name: CreateReportDestinationDelivery :one
+INSERT INTO report_destination_deliveries (
+    user_id,
+    report_id,
+    destination_id,
+    destination_name
+) VALUES (
+    $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: ListReportDestinationDeliveries :many
+SELECT *
+FROM report_destination_deliveries
+WHERE user_id = $1
+  AND report_id = $2
+ORDER BY created_at;
+
+-- name: ClaimDueReportDestinationDeliveries :many
+UPDATE report_destination_deliveries
+SET next_attempt_at = sqlc.arg('lease_until')
+WHERE id IN (
+    SELECT id
+    FROM report_destination_deliveries
+    WHERE status = 'pending'
+      AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg('limit')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: UpdateReportDestinationDeliveryResult :one
+UPDATE report_destination_deliveries
+SET
+    status = sqlc.arg('status'),
+    attempt_count = attempt_count + 1,
+    remote_path = sqlc.narg('remote_path'),
+    last_error = sqlc.narg('last_error'),
+    next_attempt_at = sqlc.arg('next_attempt_at'),
+    delivered_at = sqlc.narg('delivered_at')
+WHERE id = sqlc.arg('id')
+RETURNING *;
@@ -0,0 +1,49 @@
+-- name: CreateReportEmailDelivery :one
+INSERT INTO report_email_deliveries (
+    user_id,
+    report_id,
+    recipient
+) VALUES (
+    $1, $2, $3
+)
+RETURNING *;
+
+-- name: ListReportEmailDeliveries :many
+SELECT *
+FROM report_email_deliveries
+WHERE user_id = $1
+  AND report_id = $2
+ORDER BY recipient;
+
+-- name: ActivateReportEmailDeliveries :execrows
+UPDATE report_email_deliveries
+SET
+    status = 'pending',
+    next_attempt_at = NOW()
+WHERE report_id = $1
+  AND status = 'waiting';
+
+-- name: ClaimDueReportEmailDeliveries :many
+UPDATE report_email_deliveries
+SET next_attempt_at = sqlc.arg('lease_until')
+WHERE id IN (
+    SELECT id
+    FROM report_email_deliveries
+    WHERE status = 'pending'
+      AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg('limit')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: UpdateReportEmailDeliveryResult :one
+UPDATE report_email_deliveries
+SET
+    status = sqlc.arg('status'),
+    attempt_count = attempt_count + 1,
+    last_error = sqlc.narg('last_error'),
+    next_attempt_at = sqlc.arg('next_attempt_at'),
+    sent_at = sqlc.narg('sent_at')
+WHERE id = sqlc.arg('id')
+RETURNING *;
@@ -0,0 +1,2 @@
+-- name: PublishReportEvent :exec
+SELECT pg_notify('report_events', sqlc.arg('payload')::text);
@@ -0,0 +1,79 @@
+-- name: CreateReportSchedule :one
+INSERT INTO report_schedules (
+    user_id,
+    name,
+    cron_expression,
+    timezone,
+    params,
+    enabled,
+    next_run_at,
+    organization_id
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8
+)
+RETURNING *;
+
+-- name: GetReportSchedule :one
+SELECT *
+FROM report_schedules
+WHERE id = $2
+  AND (user_id = $1 OR
+       organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = $1));
+
+-- name: ListReportSchedules :many
+SELECT *
+FROM report_schedules
+WHERE user_id = $1
+   OR organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = $1)
+ORDER BY created_at;
+
+-- name: UpdateReportSchedule :one
+UPDATE report_schedules
+SET
+    name = sqlc.arg('name'),
+    cron_expression = sqlc.arg('cron_expression'),
+    timezone = sqlc.arg('timezone'),
+    params = sqlc.arg('params'),
+    enabled = sqlc.arg('enabled'),
+    next_run_at = sqlc.arg('next_run_at'),
+    last_error = NULL,
+    updated_at = NOW()
+WHERE user_id = sqlc.arg('user_id')
+  AND id = sqlc.arg('id')
+RETURNING *;
+
+-- name: DeleteReportSchedule :execrows
+DELETE FROM report_schedules
+WHERE id = $2
+  AND (user_id = $1 OR
+       organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = $1));
+
+-- name: ListDueReportSchedules :many
+SELECT *
+FROM report_schedules
+WHERE enabled
+  AND next_run_at <= sqlc.arg('now')
+ORDER BY next_run_at
+LIMIT sqlc.arg('limit')
+FOR UPDATE SKIP LOCKED;
+
+-- name: MarkReportScheduleRun :one
+UPDATE report_schedules
+SET
+    last_run_at = sqlc.arg('last_run_at'),
+    last_report_id = sqlc.arg('last_report_id'),
+    next_run_at = sqlc.arg('next_run_at')
+WHERE id = sqlc.arg('id')
+RETURNING *;
+
+-- name: DisableReportSchedule :one
+UPDATE report_schedules
+SET
+    enabled = FALSE,
+    last_error = sqlc.arg('last_error'),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id')
+RETURNING *;
+
+-- name: TryAdvisoryXactLock :one
+SELECT pg_try_advisory_xact_lock(sqlc.arg('lock_id')::bigint) AS acquired;
@@ -0,0 +1,66 @@
+-- name: CreateReportShare :one
+INSERT INTO report_shares (
+    user_id,
+    report_id,
+    token_hash,
+    password_hash,
+    expires_at,
+    max_downloads
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+RETURNING *;
+
+-- name: GetReportShare :one
+SELECT *
+FROM report_shares
+WHERE user_id = $1
+  AND report_id = $2
+  AND id = $3;
+
+-- name: GetReportShareByTokenHash :one
+SELECT *
+FROM report_shares
+WHERE token_hash = $1;
+
+-- name: ListReportShares :many
+SELECT *
+FROM report_shares
+WHERE user_id = $1
+  AND report_id = $2
+ORDER BY created_at DESC;
+
+-- name: RevokeReportShare :one
+UPDATE report_shares
+SET revoked_at = COALESCE(revoked_at, NOW())
+WHERE user_id = $1
+  AND report_id = $2
+  AND id = $3
+RETURNING *;
+
+-- name: ConsumeReportShare :one
+UPDATE report_shares
+SET download_count = download_count + 1
+WHERE id = $1
+  AND revoked_at IS NULL
+  AND expires_at > NOW()
+  AND (max_downloads IS NULL OR download_count < max_downloads)
+RETURNING *;
+
+-- name: CreateReportShareRedemption :one
+INSERT INTO report_share_redemptions (
+    share_id,
+    succeeded,
+    failure_reason,
+    ip_address,
+    user_agent
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: ListReportShareRedemptions :many
+SELECT *
+FROM report_share_redemptions
+WHERE share_id = $1
+ORDER BY created_at DESC;
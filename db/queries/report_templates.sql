@@ -0,0 +1,41 @@
+-- name: CreateReportTemplate :one
+INSERT INTO report_templates (
+    user_id,
+    name,
+    params,
+    organization_id
+) VALUES (
+    $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: GetReportTemplate :one
+SELECT *
+FROM report_templates
+WHERE id = $2
+  AND (user_id = $1 OR
+       organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = $1));
+
+-- name: ListReportTemplates :many
+SELECT *
+FROM report_templates
+WHERE user_id = $1
+   OR organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = $1)
+ORDER BY name;
+
+-- name: UpdateReportTemplate :one
+UPDATE report_templates
+SET
+    name = sqlc.arg('name'),
+    params = sqlc.arg('params'),
+    version = CASE WHEN params = sqlc.arg('params') THEN version ELSE version + 1 END,
+    updated_at = NOW()
+WHERE user_id = sqlc.arg('user_id')
+  AND id = sqlc.arg('id')
+RETURNING *;
+
+-- name: DeleteReportTemplate :execrows
+DELETE FROM report_templates
+WHERE id = $2
+  AND (user_id = $1 OR
+       organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = $1));
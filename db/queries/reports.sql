@@ -8,17 +8,31 @@ INSERT INTO reports (
     error_message,
     started_at,
     failed_at,
-    completed_at
+    completed_at,
+    callback_url,
+    callback_secret,
+    params,
+    params_hash,
+    template_id,
+    template_version,
+    organization_id
 ) VALUES (
-             $1,  -- user_id
-             $2,  -- report_type
-             $3,  -- output_file_path
-             $4,  -- download_url
-             $5,  -- download_expires_at
-             $6,  -- error_message
-             $7,  -- started_at
-             $8,  -- failed_at
-             $9   -- completed_at
+             sqlc.arg('user_id'),
+             sqlc.arg('report_type'),
+             sqlc.narg('output_file_path'),
+             sqlc.narg('download_url'),
+             sqlc.narg('download_expires_at'),
+             sqlc.narg('error_message'),
+             sqlc.narg('started_at'),
+             sqlc.narg('failed_at'),
+             sqlc.narg('completed_at'),
+             sqlc.narg('callback_url'),
+             sqlc.narg('callback_secret'),
+             COALESCE(sqlc.arg('params')::jsonb, '{}'),
+             sqlc.narg('params_hash'),
+             sqlc.narg('template_id'),
+             sqlc.narg('template_version'),
+             sqlc.narg('organization_id')
          )
 RETURNING *;
 
@@ -34,11 +48,26 @@ SELECT
     created_at,
     started_at,
     failed_at,
-    completed_at
+    completed_at,
+    cancelled_at,
+    callback_url,
+    callback_secret,
+    rows_total,
+    rows_written,
+    bytes_written,
+    phase,
+    params,
+    params_hash,
+    artifact_id,
+    template_id,
+    template_version,
+    organization_id,
+    enqueued_at
 FROM reports
 WHERE
-    user_id = $1  -- UUID
-  AND id      = $2; -- UUID
+    id = $2 -- UUID
+  AND (user_id = $1 OR
+       organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = $1));
 
 -- name: DeleteReport :exec
 DELETE FROM reports
@@ -46,6 +75,13 @@ WHERE
     user_id = $1  -- UUID
   AND id      = $2; -- UUID
 
+-- name: DeleteReportReturning :one
+DELETE FROM reports
+WHERE
+    user_id = $1
+  AND id = $2
+RETURNING *;
+
 -- name: UpdateReport :one
 UPDATE reports
 SET
@@ -70,4 +106,130 @@ RETURNING
     created_at,
     started_at,
     failed_at,
-    completed_at;
\ No newline at end of file
+    completed_at,
+    cancelled_at,
+    callback_url,
+    callback_secret,
+    rows_total,
+    rows_written,
+    bytes_written,
+    phase,
+    params,
+    params_hash,
+    artifact_id,
+    template_id,
+    template_version,
+    organization_id,
+    enqueued_at;
+
+-- name: CancelReport :one
+UPDATE reports
+SET cancelled_at = NOW()
+WHERE
+    user_id = sqlc.arg('user_id')
+  AND id = sqlc.arg('id')
+  AND completed_at IS NULL
+  AND failed_at IS NULL
+  AND cancelled_at IS NULL
+RETURNING *;
+
+-- name: StartReport :one
+UPDATE reports
+SET started_at = NOW()
+WHERE
+    user_id = sqlc.arg('user_id')
+  AND id = sqlc.arg('id')
+  AND started_at IS NULL
+  AND cancelled_at IS NULL
+RETURNING *;
+
+-- name: CompleteReport :one
+UPDATE reports
+SET
+    output_file_path = sqlc.arg('output_file_path'),
+    artifact_id = sqlc.narg('artifact_id'),
+    completed_at = NOW()
+WHERE
+    user_id = sqlc.arg('user_id')
+  AND id = sqlc.arg('id')
+  AND completed_at IS NULL
+  AND cancelled_at IS NULL
+RETURNING *;
+
+-- name: ResetReport :one
+UPDATE reports
+SET
+    output_file_path = NULL,
+    download_url = NULL,
+    download_expires_at = NULL,
+    error_message = NULL,
+    started_at = NULL,
+    failed_at = NULL,
+    completed_at = NULL,
+    cancelled_at = NULL,
+    rows_total = NULL,
+    rows_written = 0,
+    bytes_written = 0,
+    phase = NULL,
+    artifact_id = NULL,
+    enqueued_at = NOW()
+WHERE
+    user_id = sqlc.arg('user_id')
+  AND id = sqlc.arg('id')
+RETURNING *;
+
+-- name: UpdateReportProgress :exec
+UPDATE reports
+SET
+    phase = sqlc.narg('phase'),
+    rows_total = sqlc.narg('rows_total'),
+    rows_written = sqlc.arg('rows_written'),
+    bytes_written = sqlc.arg('bytes_written')
+WHERE
+    user_id = sqlc.arg('user_id')
+  AND id = sqlc.arg('id')
+  AND completed_at IS NULL
+  AND failed_at IS NULL
+  AND cancelled_at IS NULL;
+
+-- name: ListAllReports :many
+SELECT *
+FROM reports
+WHERE (sqlc.narg('user_id')::uuid IS NULL OR user_id = sqlc.narg('user_id'))
+  AND (sqlc.narg('status')::text IS NULL OR
+       CASE
+           WHEN cancelled_at IS NOT NULL THEN 'cancelled'
+           WHEN started_at IS NULL THEN 'requested'
+           WHEN completed_at IS NOT NULL THEN 'completed'
+           WHEN failed_at IS NOT NULL THEN 'failed'
+           ELSE 'processing'
+           END = sqlc.narg('status'))
+ORDER BY created_at DESC
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- name: CountActiveReports :many
+SELECT CASE WHEN started_at IS NULL THEN 'requested' ELSE 'processing' END::text AS status,
+       COUNT(*)                                                                AS count,
+       MIN(COALESCE(started_at, created_at))::timestamptz                      AS oldest_at
+FROM reports
+WHERE completed_at IS NULL
+  AND failed_at IS NULL
+  AND cancelled_at IS NULL
+GROUP BY 1
+ORDER BY 1 DESC;
+
+-- name: ClaimStaleRequestedReports :many
+UPDATE reports
+SET enqueued_at = NOW()
+WHERE id IN (
+    SELECT id
+    FROM reports
+    WHERE started_at IS NULL
+      AND cancelled_at IS NULL
+      AND enqueued_at < sqlc.arg('enqueued_before')
+    ORDER BY enqueued_at
+    LIMIT sqlc.arg('limit')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
@@ -0,0 +1,47 @@
+-- name: CreateSession :one
+INSERT INTO sessions (id,
+                      user_id,
+                      device_name,
+                      user_agent,
+                      ip_address,
+                      expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetSession :one
+SELECT *
+FROM sessions
+WHERE id = $1;
+
+-- name: ListActiveSessions :many
+SELECT *
+FROM sessions
+WHERE user_id = $1
+  AND revoked_at IS NULL
+  AND expires_at > NOW()
+ORDER BY last_used_at DESC;
+
+-- name: TouchSession :one
+UPDATE sessions
+SET last_used_at = NOW(),
+    ip_address   = $2,
+    user_agent   = $3,
+    expires_at   = $4
+WHERE id = $1
+RETURNING *;
+
+-- name: RevokeSession :execrows
+UPDATE sessions
+SET revoked_at    = NOW(),
+    revoke_reason = $3
+WHERE user_id = $1
+  AND id = $2
+  AND revoked_at IS NULL;
+
+-- name: RevokeUserSessions :many
+UPDATE sessions
+SET revoked_at    = NOW(),
+    revoke_reason = $2
+WHERE user_id = $1
+  AND revoked_at IS NULL
+RETURNING *;
@@ -1,8 +1,9 @@
 -- name: CreateRefreshToken :one
 INSERT INTO refresh_tokens (hashed_token,
                             user_id,
-                            expires_at)
-VALUES ($1, $2, $3)
+                            expires_at,
+                            session_id)
+VALUES ($1, $2, $3, $4)
 RETURNING *;
 
 -- name: GetRefreshToken :one
@@ -42,3 +43,15 @@ WHERE user_id = $1;
 -- name: DeleteAllUserRefreshTokens :exec
 DELETE FROM refresh_tokens
 WHERE user_id = $1;
+
+-- name: GetRefreshTokenForUpdate :one
+SELECT *
+FROM refresh_tokens
+WHERE hashed_token = $1
+FOR UPDATE;
+
+-- name: MarkRefreshTokenRotated :execrows
+UPDATE refresh_tokens
+SET rotated_at = NOW()
+WHERE hashed_token = $1
+  AND rotated_at IS NULL;
@@ -7,3 +7,27 @@ SELECT * FROM users WHERE email = $1;
 -- name: FindUserById :one
 SELECT * FROM users WHERE id = $1;
 
+-- name: ListUsers :many
+SELECT *
+FROM users
+ORDER BY created_at DESC
+LIMIT $1
+OFFSET $2;
+
+-- name: UpdateUserRole :one
+UPDATE users
+SET role = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: MarkUserEmailVerified :one
+UPDATE users
+SET email_verified_at = COALESCE(email_verified_at, NOW())
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password = $2
+WHERE id = $1
+RETURNING *;
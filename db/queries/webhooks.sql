@@ -0,0 +1,102 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (user_id, url, secret)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: ListWebhookEndpoints :many
+SELECT *
+FROM webhook_endpoints
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE user_id = $1
+  AND id = $2;
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (
+    user_id,
+    endpoint_id,
+    report_id,
+    event,
+    url,
+    secret,
+    payload
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+)
+RETURNING *;
+
+-- name: GetWebhookDelivery :one
+SELECT *
+FROM webhook_deliveries
+WHERE user_id = $1
+  AND id = $2;
+
+-- name: ListWebhookDeliveries :many
+SELECT *
+FROM webhook_deliveries
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT $2
+OFFSET $3;
+
+-- name: ClaimDueWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET next_attempt_at = sqlc.arg('lease_until')
+WHERE id IN (
+    SELECT id
+    FROM webhook_deliveries
+    WHERE status = 'pending'
+      AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg('limit')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: UpdateWebhookDeliveryResult :one
+UPDATE webhook_deliveries
+SET
+    status = sqlc.arg('status'),
+    attempt_count = attempt_count + 1,
+    last_status_code = sqlc.narg('last_status_code'),
+    last_error = sqlc.narg('last_error'),
+    next_attempt_at = sqlc.arg('next_attempt_at'),
+    delivered_at = sqlc.narg('delivered_at')
+WHERE id = sqlc.arg('id')
+RETURNING *;
+
+-- name: RedeliverWebhookDelivery :one
+UPDATE webhook_deliveries
+SET
+    status = 'pending',
+    attempt_count = 0,
+    next_attempt_at = NOW()
+WHERE user_id = $1
+  AND id = $2
+  AND status <> 'pending'
+RETURNING *;
+
+-- name: CreateWebhookDeliveryAttempt :one
+INSERT INTO webhook_delivery_attempts (
+    delivery_id,
+    attempt_number,
+    status_code,
+    error,
+    duration_ms
+) VALUES (
+    $1,
+    (SELECT COUNT(*) + 1 FROM webhook_delivery_attempts WHERE delivery_id = $1),
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: ListWebhookDeliveryAttempts :many
+SELECT *
+FROM webhook_delivery_attempts
+WHERE delivery_id = $1
+ORDER BY attempt_number;
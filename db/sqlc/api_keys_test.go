@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+)
+
+// createRandomApiKey is a helper function to create an API key for a user
+func createRandomApiKey(t *testing.T, user User) ApiKey {
+	arg := CreateApiKeyParams{
+		UserID:    user.ID,
+		Name:      helpers.RandomString(8),
+		Prefix:    "csvr_" + helpers.RandomString(8),
+		KeyHash:   helpers.RandomString(64),
+		Scopes:    []string{"reports:read", "reports:write"},
+		ExpiresAt: sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true},
+	}
+
+	apiKey, err := testStore.CreateApiKey(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Prefix, apiKey.Prefix)
+	require.Equal(t, arg.Scopes, apiKey.Scopes)
+	require.False(t, apiKey.LastUsedAt.Valid)
+	require.False(t, apiKey.RevokedAt.Valid)
+	return apiKey
+}
+
+func TestGetApiKeyByHash(t *testing.T) {
+	user := createRandomUser(t)
+	apiKey := createRandomApiKey(t, user)
+
+	got, err := testStore.GetApiKeyByHash(context.Background(), apiKey.KeyHash)
+	require.NoError(t, err)
+	require.Equal(t, apiKey.ID, got.ID)
+	require.Equal(t, user.ID, got.UserID)
+
+	_, err = testStore.GetApiKeyByHash(context.Background(), helpers.RandomString(64))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestTouchApiKey(t *testing.T) {
+	user := createRandomUser(t)
+	apiKey := createRandomApiKey(t, user)
+
+	require.NoError(t, testStore.TouchApiKey(context.Background(), apiKey.ID))
+
+	got, err := testStore.GetApiKeyByHash(context.Background(), apiKey.KeyHash)
+	require.NoError(t, err)
+	require.True(t, got.LastUsedAt.Valid)
+	require.WithinDuration(t, time.Now(), got.LastUsedAt.Time, time.Minute)
+}
+
+func TestRevokeApiKey(t *testing.T) {
+	user := createRandomUser(t)
+	other := createRandomUser(t)
+	apiKey := createRandomApiKey(t, user)
+	createRandomApiKey(t, user)
+
+	// other users can't revoke the key
+	revoked, err := testStore.RevokeApiKey(context.Background(), RevokeApiKeyParams{UserID: other.ID, ID: apiKey.ID})
+	require.NoError(t, err)
+	require.Zero(t, revoked)
+
+	revoked, err = testStore.RevokeApiKey(context.Background(), RevokeApiKeyParams{UserID: user.ID, ID: apiKey.ID})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, revoked)
+
+	_, err = testStore.GetApiKeyByHash(context.Background(), apiKey.KeyHash)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	apiKeys, err := testStore.ListApiKeys(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Len(t, apiKeys, 1)
+	require.NotEqual(t, apiKey.ID, apiKeys[0].ID)
+}
@@ -0,0 +1,21 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+const (
+	ForeignKeyViolation = "23503"
+	UniqueViolation     = "23505"
+)
+
+// ErrorCode returns the Postgres error code of err, or an empty string.
+func ErrorCode(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return string(pqErr.Code)
+	}
+	return ""
+}
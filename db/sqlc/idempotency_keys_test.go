@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+)
+
+func createRandomIdempotencyKey(t *testing.T, user User) IdempotencyKey {
+	arg := CreateIdempotencyKeyParams{
+		UserID:      user.ID,
+		Key:         helpers.RandomString(16),
+		RequestHash: helpers.RandomString(64),
+		ExpiresAt:   time.Now().Add(24 * time.Hour),
+	}
+
+	key, err := testStore.CreateIdempotencyKey(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.UserID, key.UserID)
+	require.Equal(t, arg.Key, key.Key)
+	require.Equal(t, arg.RequestHash, key.RequestHash)
+	require.False(t, key.StatusCode.Valid)
+	require.Empty(t, key.ResponseBody)
+	return key
+}
+
+func TestCreateIdempotencyKey(t *testing.T) {
+	user := createRandomUser(t)
+	key1 := createRandomIdempotencyKey(t, user)
+
+	// a live key cannot be claimed again
+	_, err := testStore.CreateIdempotencyKey(context.Background(), CreateIdempotencyKeyParams{
+		UserID:      user.ID,
+		Key:         key1.Key,
+		RequestHash: helpers.RandomString(64),
+		ExpiresAt:   time.Now().Add(24 * time.Hour),
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	// the same key is independent for another user
+	other := createRandomUser(t)
+	key2, err := testStore.CreateIdempotencyKey(context.Background(), CreateIdempotencyKeyParams{
+		UserID:      other.ID,
+		Key:         key1.Key,
+		RequestHash: key1.RequestHash,
+		ExpiresAt:   time.Now().Add(24 * time.Hour),
+	})
+	require.NoError(t, err)
+	require.Equal(t, other.ID, key2.UserID)
+}
+
+func TestCreateExpiredIdempotencyKey(t *testing.T) {
+	user := createRandomUser(t)
+	key1, err := testStore.CreateIdempotencyKey(context.Background(), CreateIdempotencyKeyParams{
+		UserID:      user.ID,
+		Key:         helpers.RandomString(16),
+		RequestHash: helpers.RandomString(64),
+		ExpiresAt:   time.Now().Add(-time.Minute),
+	})
+	require.NoError(t, err)
+
+	_, err = testStore.GetIdempotencyKey(context.Background(), GetIdempotencyKeyParams{
+		UserID: user.ID,
+		Key:    key1.Key,
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	// an expired key is taken over by the next request
+	key2, err := testStore.CreateIdempotencyKey(context.Background(), CreateIdempotencyKeyParams{
+		UserID:      user.ID,
+		Key:         key1.Key,
+		RequestHash: helpers.RandomString(64),
+		ExpiresAt:   time.Now().Add(24 * time.Hour),
+	})
+	require.NoError(t, err)
+	require.NotEqual(t, key1.RequestHash, key2.RequestHash)
+}
+
+func TestSaveIdempotencyResponse(t *testing.T) {
+	user := createRandomUser(t)
+	key1 := createRandomIdempotencyKey(t, user)
+
+	body := []byte(`{"data":{"id":"1"}}`)
+	err := testStore.SaveIdempotencyResponse(context.Background(), SaveIdempotencyResponseParams{
+		UserID:       user.ID,
+		Key:          key1.Key,
+		StatusCode:   sql.NullInt32{Int32: 201, Valid: true},
+		ResponseBody: body,
+	})
+	require.NoError(t, err)
+
+	key2, err := testStore.GetIdempotencyKey(context.Background(), GetIdempotencyKeyParams{
+		UserID: user.ID,
+		Key:    key1.Key,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int32(201), key2.StatusCode.Int32)
+	require.Equal(t, body, key2.ResponseBody)
+	require.Equal(t, key1.RequestHash, key2.RequestHash)
+}
+
+func TestDeleteIdempotencyKey(t *testing.T) {
+	user := createRandomUser(t)
+	key1 := createRandomIdempotencyKey(t, user)
+
+	err := testStore.DeleteIdempotencyKey(context.Background(), DeleteIdempotencyKeyParams{
+		UserID: user.ID,
+		Key:    key1.Key,
+	})
+	require.NoError(t, err)
+
+	_, err = testStore.GetIdempotencyKey(context.Background(), GetIdempotencyKeyParams{
+		UserID: user.ID,
+		Key:    key1.Key,
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
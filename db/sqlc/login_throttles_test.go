@@ -0,0 +1,164 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+)
+
+// countFailure reserves an attempt by counting it, unless the counter is locked.
+func countFailure(throttle LoginThrottle) (LoginThrottle, bool) {
+	if throttle.LockedUntil.Valid {
+		return throttle, false
+	}
+	throttle.Failures++
+	throttle.LastFailedAt = time.Now()
+	return throttle, true
+}
+
+func TestReserveLoginAttemptTx(t *testing.T) {
+	key := helpers.RandomEmail()
+	arg := ReserveLoginAttemptTxParams{
+		Scope:   LoginThrottleAccount,
+		Key:     key,
+		Reserve: countFailure,
+	}
+
+	result, err := testStore.ReserveLoginAttemptTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, result.Reserved)
+	require.Equal(t, int32(1), result.Throttle.Failures)
+
+	// refused attempts leave the counter alone
+	_, err = testStore.UpdateLoginThrottle(context.Background(), UpdateLoginThrottleParams{
+		Scope:        LoginThrottleAccount,
+		Key:          key,
+		Failures:     result.Throttle.Failures,
+		LastFailedAt: result.Throttle.LastFailedAt,
+		LockedUntil:  sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true},
+	})
+	require.NoError(t, err)
+	result, err = testStore.ReserveLoginAttemptTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.False(t, result.Reserved)
+	require.Equal(t, int32(1), result.Throttle.Failures)
+
+	deleted, err := testStore.DeleteLoginThrottle(context.Background(), DeleteLoginThrottleParams{
+		Scope: LoginThrottleAccount,
+		Key:   key,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+
+	_, err = testStore.GetLoginThrottle(context.Background(), GetLoginThrottleParams{
+		Scope: LoginThrottleAccount,
+		Key:   key,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestReserveLoginAttemptTxParallel(t *testing.T) {
+	key := helpers.RandomString(12)
+
+	// every attempt sees the ones reserved before it, including the first ones against a new key
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := testStore.ReserveLoginAttemptTx(context.Background(), ReserveLoginAttemptTxParams{
+				Scope:   LoginThrottleIP,
+				Key:     key,
+				Reserve: countFailure,
+			})
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	throttle, err := testStore.GetLoginThrottle(context.Background(), GetLoginThrottleParams{Scope: LoginThrottleIP, Key: key})
+	require.NoError(t, err)
+	require.Equal(t, int32(10), throttle.Failures)
+}
+
+func TestReleaseLoginAttempt(t *testing.T) {
+	key := helpers.RandomString(12)
+	for range 3 {
+		_, err := testStore.ReserveLoginAttemptTx(context.Background(), ReserveLoginAttemptTxParams{
+			Scope:   LoginThrottleIP,
+			Key:     key,
+			Reserve: countFailure,
+		})
+		require.NoError(t, err)
+	}
+	_, err := testStore.UpdateLoginThrottle(context.Background(), UpdateLoginThrottleParams{
+		Scope:         LoginThrottleIP,
+		Key:           key,
+		Failures:      3,
+		LastFailedAt:  time.Now(),
+		NextAttemptAt: sql.NullTime{Time: time.Now().Add(time.Minute), Valid: true},
+		LockedUntil:   sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true},
+	})
+	require.NoError(t, err)
+
+	// releasing the attempt that reached the limit lifts the lockout it caused
+	err = testStore.ReleaseLoginAttempt(context.Background(), ReleaseLoginAttemptParams{
+		MaxFailures: 3,
+		Scope:       LoginThrottleIP,
+		Key:         key,
+	})
+	require.NoError(t, err)
+	throttle, err := testStore.GetLoginThrottle(context.Background(), GetLoginThrottleParams{Scope: LoginThrottleIP, Key: key})
+	require.NoError(t, err)
+	require.Equal(t, int32(2), throttle.Failures)
+	require.False(t, throttle.LockedUntil.Valid)
+	require.True(t, throttle.NextAttemptAt.Valid)
+
+	// the delay goes with the last failure
+	for range 2 {
+		err = testStore.ReleaseLoginAttempt(context.Background(), ReleaseLoginAttemptParams{
+			MaxFailures: 3,
+			Scope:       LoginThrottleIP,
+			Key:         key,
+		})
+		require.NoError(t, err)
+	}
+	throttle, err = testStore.GetLoginThrottle(context.Background(), GetLoginThrottleParams{Scope: LoginThrottleIP, Key: key})
+	require.NoError(t, err)
+	require.Equal(t, int32(0), throttle.Failures)
+	require.False(t, throttle.NextAttemptAt.Valid)
+}
+
+func TestDeleteStaleLoginThrottles(t *testing.T) {
+	stale, locked := helpers.RandomString(12), helpers.RandomString(12)
+	for _, key := range []string{stale, locked} {
+		_, err := testStore.ReserveLoginAttemptTx(context.Background(), ReserveLoginAttemptTxParams{
+			Scope:   LoginThrottleIP,
+			Key:     key,
+			Reserve: countFailure,
+		})
+		require.NoError(t, err)
+	}
+	_, err := testStore.UpdateLoginThrottle(context.Background(), UpdateLoginThrottleParams{
+		Scope:        LoginThrottleIP,
+		Key:          locked,
+		Failures:     1,
+		LastFailedAt: time.Now(),
+		LockedUntil:  sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true},
+	})
+	require.NoError(t, err)
+
+	// locked throttles stay until the lockout ends
+	_, err = testStore.DeleteStaleLoginThrottles(context.Background(), time.Now().Add(time.Minute))
+	require.NoError(t, err)
+
+	_, err = testStore.GetLoginThrottle(context.Background(), GetLoginThrottleParams{Scope: LoginThrottleIP, Key: stale})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = testStore.GetLoginThrottle(context.Background(), GetLoginThrottleParams{Scope: LoginThrottleIP, Key: locked})
+	require.NoError(t, err)
+}
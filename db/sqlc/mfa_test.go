@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+)
+
+func TestEnableMfaTx(t *testing.T) {
+	user := createRandomUser(t)
+
+	// MFA can't be enabled without enrolling first
+	_, err := testStore.EnableMfaTx(context.Background(), EnableMfaTxParams{UserID: user.ID, Step: 100})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = testStore.SetUserMfaSecret(context.Background(), SetUserMfaSecretParams{
+		ID:        user.ID,
+		MfaSecret: sql.NullString{String: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", Valid: true},
+	})
+	require.NoError(t, err)
+
+	hashedCode := helpers.RandomString(64)
+	enabled, err := testStore.EnableMfaTx(context.Background(), EnableMfaTxParams{
+		UserID:              user.ID,
+		Step:                100,
+		HashedRecoveryCodes: []string{hashedCode, helpers.RandomString(64)},
+	})
+	require.NoError(t, err)
+	require.True(t, enabled.MfaEnabledAt.Valid)
+	require.Equal(t, int64(100), enabled.MfaLastUsedStep)
+
+	// the secret can't be replaced while MFA is on
+	_, err = testStore.SetUserMfaSecret(context.Background(), SetUserMfaSecretParams{
+		ID:        user.ID,
+		MfaSecret: sql.NullString{String: "JBSWY3DPEHPK3PXP", Valid: true},
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// steps only move forward, so a code can't be used twice
+	used, err := testStore.UseMfaStep(context.Background(), UseMfaStepParams{ID: user.ID, MfaLastUsedStep: 100})
+	require.NoError(t, err)
+	require.Zero(t, used)
+	used, err = testStore.UseMfaStep(context.Background(), UseMfaStepParams{ID: user.ID, MfaLastUsedStep: 101})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), used)
+
+	used, err = testStore.UseMfaRecoveryCode(context.Background(), UseMfaRecoveryCodeParams{UserID: user.ID, HashedCode: hashedCode})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), used)
+	used, err = testStore.UseMfaRecoveryCode(context.Background(), UseMfaRecoveryCodeParams{UserID: user.ID, HashedCode: hashedCode})
+	require.NoError(t, err)
+	require.Zero(t, used)
+
+	remaining, err := testStore.CountUnusedMfaRecoveryCodes(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), remaining)
+
+	disabled, err := testStore.DisableMfaTx(context.Background(), DisableMfaTxParams{UserID: user.ID})
+	require.NoError(t, err)
+	require.False(t, disabled.MfaEnabledAt.Valid)
+	require.False(t, disabled.MfaSecret.Valid)
+
+	remaining, err = testStore.CountUnusedMfaRecoveryCodes(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Zero(t, remaining)
+}
@@ -6,35 +6,309 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AccountToken struct {
+	HashedToken string       `json:"hashed_token"`
+	UserID      uuid.UUID    `json:"user_id"`
+	Purpose     string       `json:"purpose"`
+	ExpiresAt   time.Time    `json:"expires_at"`
+	UsedAt      sql.NullTime `json:"used_at"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+type ApiKey struct {
+	ID         uuid.UUID    `json:"id"`
+	UserID     uuid.UUID    `json:"user_id"`
+	Name       string       `json:"name"`
+	Prefix     string       `json:"prefix"`
+	KeyHash    string       `json:"key_hash"`
+	Scopes     []string     `json:"scopes"`
+	ExpiresAt  sql.NullTime `json:"expires_at"`
+	LastUsedAt sql.NullTime `json:"last_used_at"`
+	RevokedAt  sql.NullTime `json:"revoked_at"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+type DeniedToken struct {
+	Kind      string    `json:"kind"`
+	TokenID   string    `json:"token_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type IdempotencyKey struct {
+	UserID       uuid.UUID     `json:"user_id"`
+	Key          string        `json:"key"`
+	RequestHash  string        `json:"request_hash"`
+	StatusCode   sql.NullInt32 `json:"status_code"`
+	ResponseBody []byte        `json:"response_body"`
+	CreatedAt    time.Time     `json:"created_at"`
+	ExpiresAt    time.Time     `json:"expires_at"`
+}
+
+type LoginThrottle struct {
+	Scope         string       `json:"scope"`
+	Key           string       `json:"key"`
+	Failures      int32        `json:"failures"`
+	LastFailedAt  time.Time    `json:"last_failed_at"`
+	NextAttemptAt sql.NullTime `json:"next_attempt_at"`
+	LockedUntil   sql.NullTime `json:"locked_until"`
+}
+
+type MfaRecoveryCode struct {
+	ID         uuid.UUID    `json:"id"`
+	UserID     uuid.UUID    `json:"user_id"`
+	HashedCode string       `json:"hashed_code"`
+	UsedAt     sql.NullTime `json:"used_at"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+type Organization struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type OrganizationInvitation struct {
+	ID             uuid.UUID     `json:"id"`
+	OrganizationID uuid.UUID     `json:"organization_id"`
+	Email          string        `json:"email"`
+	Role           string        `json:"role"`
+	InvitedBy      uuid.NullUUID `json:"invited_by"`
+	CreatedAt      time.Time     `json:"created_at"`
+	ExpiresAt      time.Time     `json:"expires_at"`
+}
+
+type OrganizationMember struct {
+	OrganizationID uuid.UUID `json:"organization_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	Role           string    `json:"role"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
 type RefreshToken struct {
-	UserID      uuid.UUID `json:"user_id"`
-	HashedToken string    `json:"hashed_token"`
-	CreatedAt   time.Time `json:"created_at"`
-	ExpiresAt   time.Time `json:"expires_at"`
+	UserID      uuid.UUID    `json:"user_id"`
+	HashedToken string       `json:"hashed_token"`
+	CreatedAt   time.Time    `json:"created_at"`
+	ExpiresAt   time.Time    `json:"expires_at"`
+	SessionID   uuid.UUID    `json:"session_id"`
+	RotatedAt   sql.NullTime `json:"rotated_at"`
 }
 
 type Report struct {
-	UserID            uuid.UUID      `json:"user_id"`
-	ID                uuid.UUID      `json:"id"`
-	ReportType        string         `json:"report_type"`
-	OutputFilePath    sql.NullString `json:"output_file_path"`
-	DownloadUrl       sql.NullString `json:"download_url"`
-	DownloadExpiresAt sql.NullTime   `json:"download_expires_at"`
-	ErrorMessage      sql.NullString `json:"error_message"`
-	CreatedAt         time.Time      `json:"created_at"`
-	StartedAt         sql.NullTime   `json:"started_at"`
-	FailedAt          sql.NullTime   `json:"failed_at"`
-	CompletedAt       sql.NullTime   `json:"completed_at"`
+	UserID            uuid.UUID       `json:"user_id"`
+	ID                uuid.UUID       `json:"id"`
+	ReportType        string          `json:"report_type"`
+	OutputFilePath    sql.NullString  `json:"output_file_path"`
+	DownloadUrl       sql.NullString  `json:"download_url"`
+	DownloadExpiresAt sql.NullTime    `json:"download_expires_at"`
+	ErrorMessage      sql.NullString  `json:"error_message"`
+	CreatedAt         time.Time       `json:"created_at"`
+	StartedAt         sql.NullTime    `json:"started_at"`
+	FailedAt          sql.NullTime    `json:"failed_at"`
+	CompletedAt       sql.NullTime    `json:"completed_at"`
+	CancelledAt       sql.NullTime    `json:"cancelled_at"`
+	CallbackUrl       sql.NullString  `json:"callback_url"`
+	CallbackSecret    sql.NullString  `json:"callback_secret"`
+	RowsTotal         sql.NullInt32   `json:"rows_total"`
+	RowsWritten       int32           `json:"rows_written"`
+	BytesWritten      int64           `json:"bytes_written"`
+	Phase             sql.NullString  `json:"phase"`
+	Params            json.RawMessage `json:"params"`
+	ParamsHash        sql.NullString  `json:"params_hash"`
+	ArtifactID        uuid.NullUUID   `json:"artifact_id"`
+	TemplateID        uuid.NullUUID   `json:"template_id"`
+	TemplateVersion   sql.NullInt32   `json:"template_version"`
+	OrganizationID    uuid.NullUUID   `json:"organization_id"`
+	EnqueuedAt        time.Time       `json:"enqueued_at"`
+}
+
+type ReportArtifact struct {
+	ID             uuid.UUID     `json:"id"`
+	ParamsHash     string        `json:"params_hash"`
+	ObjectKey      string        `json:"object_key"`
+	SizeBytes      int64         `json:"size_bytes"`
+	RowCount       int32         `json:"row_count"`
+	RefCount       int32         `json:"ref_count"`
+	CreatedAt      time.Time     `json:"created_at"`
+	OrganizationID uuid.NullUUID `json:"organization_id"`
+}
+
+type ReportAttempt struct {
+	ID            uuid.UUID      `json:"id"`
+	UserID        uuid.UUID      `json:"user_id"`
+	ReportID      uuid.UUID      `json:"report_id"`
+	AttemptNumber int32          `json:"attempt_number"`
+	StartedAt     sql.NullTime   `json:"started_at"`
+	FailedAt      sql.NullTime   `json:"failed_at"`
+	ErrorMessage  sql.NullString `json:"error_message"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+type ReportDestination struct {
+	ID        uuid.UUID       `json:"id"`
+	UserID    uuid.UUID       `json:"user_id"`
+	Name      string          `json:"name"`
+	Kind      string          `json:"kind"`
+	Config    json.RawMessage `json:"config"`
+	Secret    sql.NullString  `json:"secret"`
+	PublicKey sql.NullString  `json:"public_key"`
+	Enabled   bool            `json:"enabled"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type ReportDestinationDelivery struct {
+	ID              uuid.UUID      `json:"id"`
+	UserID          uuid.UUID      `json:"user_id"`
+	ReportID        uuid.UUID      `json:"report_id"`
+	DestinationID   uuid.NullUUID  `json:"destination_id"`
+	DestinationName string         `json:"destination_name"`
+	Status          string         `json:"status"`
+	RemotePath      sql.NullString `json:"remote_path"`
+	AttemptCount    int32          `json:"attempt_count"`
+	LastError       sql.NullString `json:"last_error"`
+	NextAttemptAt   time.Time      `json:"next_attempt_at"`
+	DeliveredAt     sql.NullTime   `json:"delivered_at"`
+	CreatedAt       time.Time      `json:"created_at"`
+}
+
+type ReportEmailDelivery struct {
+	ID            uuid.UUID      `json:"id"`
+	UserID        uuid.UUID      `json:"user_id"`
+	ReportID      uuid.UUID      `json:"report_id"`
+	Recipient     string         `json:"recipient"`
+	Status        string         `json:"status"`
+	AttemptCount  int32          `json:"attempt_count"`
+	LastError     sql.NullString `json:"last_error"`
+	NextAttemptAt time.Time      `json:"next_attempt_at"`
+	SentAt        sql.NullTime   `json:"sent_at"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+type ReportSchedule struct {
+	ID             uuid.UUID       `json:"id"`
+	UserID         uuid.UUID       `json:"user_id"`
+	Name           string          `json:"name"`
+	CronExpression string          `json:"cron_expression"`
+	Timezone       string          `json:"timezone"`
+	Params         json.RawMessage `json:"params"`
+	Enabled        bool            `json:"enabled"`
+	LastRunAt      sql.NullTime    `json:"last_run_at"`
+	LastReportID   uuid.NullUUID   `json:"last_report_id"`
+	NextRunAt      time.Time       `json:"next_run_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	OrganizationID uuid.NullUUID   `json:"organization_id"`
+	LastError      sql.NullString  `json:"last_error"`
+}
+
+type ReportShare struct {
+	ID            uuid.UUID      `json:"id"`
+	UserID        uuid.UUID      `json:"user_id"`
+	ReportID      uuid.UUID      `json:"report_id"`
+	TokenHash     string         `json:"token_hash"`
+	PasswordHash  sql.NullString `json:"password_hash"`
+	ExpiresAt     time.Time      `json:"expires_at"`
+	MaxDownloads  sql.NullInt32  `json:"max_downloads"`
+	DownloadCount int32          `json:"download_count"`
+	RevokedAt     sql.NullTime   `json:"revoked_at"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+type ReportShareRedemption struct {
+	ID            uuid.UUID      `json:"id"`
+	ShareID       uuid.UUID      `json:"share_id"`
+	Succeeded     bool           `json:"succeeded"`
+	FailureReason sql.NullString `json:"failure_reason"`
+	IpAddress     string         `json:"ip_address"`
+	UserAgent     string         `json:"user_agent"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+type ReportTemplate struct {
+	ID             uuid.UUID       `json:"id"`
+	UserID         uuid.UUID       `json:"user_id"`
+	Name           string          `json:"name"`
+	Params         json.RawMessage `json:"params"`
+	Version        int32           `json:"version"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	OrganizationID uuid.NullUUID   `json:"organization_id"`
+}
+
+type SecurityEvent struct {
+	ID        uuid.UUID       `json:"id"`
+	UserID    uuid.NullUUID   `json:"user_id"`
+	EventType string          `json:"event_type"`
+	IpAddress string          `json:"ip_address"`
+	UserAgent string          `json:"user_agent"`
+	Details   json.RawMessage `json:"details"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type Session struct {
+	ID           uuid.UUID      `json:"id"`
+	UserID       uuid.UUID      `json:"user_id"`
+	RevokedAt    sql.NullTime   `json:"revoked_at"`
+	RevokeReason sql.NullString `json:"revoke_reason"`
+	CreatedAt    time.Time      `json:"created_at"`
+	DeviceName   string         `json:"device_name"`
+	UserAgent    string         `json:"user_agent"`
+	IpAddress    string         `json:"ip_address"`
+	LastUsedAt   time.Time      `json:"last_used_at"`
+	ExpiresAt    time.Time      `json:"expires_at"`
 }
 
 type User struct {
-	ID             uuid.UUID `json:"id"`
-	Email          string    `json:"email"`
-	HashedPassword string    `json:"hashed_password"`
-	CreatedAt      time.Time `json:"created_at"`
+	ID              uuid.UUID      `json:"id"`
+	Email           string         `json:"email"`
+	HashedPassword  string         `json:"hashed_password"`
+	CreatedAt       time.Time      `json:"created_at"`
+	Role            string         `json:"role"`
+	EmailVerifiedAt sql.NullTime   `json:"email_verified_at"`
+	MfaSecret       sql.NullString `json:"mfa_secret"`
+	MfaEnabledAt    sql.NullTime   `json:"mfa_enabled_at"`
+	MfaLastUsedStep int64          `json:"mfa_last_used_step"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	UserID         uuid.UUID       `json:"user_id"`
+	EndpointID     uuid.NullUUID   `json:"endpoint_id"`
+	ReportID       uuid.UUID       `json:"report_id"`
+	Event          string          `json:"event"`
+	Url            string          `json:"url"`
+	Secret         string          `json:"secret"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	AttemptCount   int32           `json:"attempt_count"`
+	LastStatusCode sql.NullInt32   `json:"last_status_code"`
+	LastError      sql.NullString  `json:"last_error"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	DeliveredAt    sql.NullTime    `json:"delivered_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+type WebhookDeliveryAttempt struct {
+	ID            int64          `json:"id"`
+	DeliveryID    uuid.UUID      `json:"delivery_id"`
+	AttemptNumber int32          `json:"attempt_number"`
+	StatusCode    sql.NullInt32  `json:"status_code"`
+	Error         sql.NullString `json:"error"`
+	DurationMs    int32          `json:"duration_ms"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Url       string    `json:"url"`
+	Secret    string    `json:"secret"`
+	CreatedAt time.Time `json:"created_at"`
 }
@@ -0,0 +1,264 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+)
+
+// createRandomOrganization is a helper function to create an organization owned by a user
+func createRandomOrganization(t *testing.T, owner User) Organization {
+	result, err := testStore.CreateOrganizationTx(context.Background(), CreateOrganizationTxParams{
+		Name:    helpers.RandomString(10),
+		OwnerID: owner.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, owner.ID, result.Owner.UserID)
+	require.Equal(t, OrganizationRoleOwner, result.Owner.Role)
+	return result.Organization
+}
+
+// addOrganizationMember is a helper function to add a user to an organization
+func joinOrganization(t *testing.T, organization Organization, user User, role string) {
+	_, err := testStore.AddOrganizationMember(context.Background(), AddOrganizationMemberParams{
+		OrganizationID: organization.ID,
+		UserID:         user.ID,
+		Role:           role,
+	})
+	require.NoError(t, err)
+}
+
+func TestCreateOrganizationTx(t *testing.T) {
+	owner := createRandomUser(t)
+	member := createRandomUser(t)
+	organization := createRandomOrganization(t, owner)
+	joinOrganization(t, organization, member, OrganizationRoleMember)
+
+	organizations, err := testStore.ListUserOrganizations(context.Background(), member.ID)
+	require.NoError(t, err)
+	require.Len(t, organizations, 1)
+	require.Equal(t, organization.ID, organizations[0].ID)
+	require.Equal(t, OrganizationRoleMember, organizations[0].Role)
+
+	members, err := testStore.ListOrganizationMembers(context.Background(), organization.ID)
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+	require.Equal(t, owner.Email, members[0].Email)
+
+	owners, err := testStore.CountOrganizationOwners(context.Background(), organization.ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, owners)
+}
+
+func TestAcceptOrganizationInvitationTx(t *testing.T) {
+	owner := createRandomUser(t)
+	invitee := createRandomUser(t)
+	organization := createRandomOrganization(t, owner)
+
+	invitation, err := testStore.CreateOrganizationInvitation(context.Background(), CreateOrganizationInvitationParams{
+		OrganizationID: organization.ID,
+		Email:          invitee.Email,
+		Role:           OrganizationRoleMember,
+		InvitedBy:      uuid.NullUUID{UUID: owner.ID, Valid: true},
+		ExpiresAt:      time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	// inviting the same email again updates the invitation
+	again, err := testStore.CreateOrganizationInvitation(context.Background(), CreateOrganizationInvitationParams{
+		OrganizationID: organization.ID,
+		Email:          invitee.Email,
+		Role:           OrganizationRoleAdmin,
+		InvitedBy:      uuid.NullUUID{UUID: owner.ID, Valid: true},
+		ExpiresAt:      time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.Equal(t, invitation.ID, again.ID)
+
+	invitations, err := testStore.ListUserOrganizationInvitations(context.Background(), invitee.Email)
+	require.NoError(t, err)
+	require.Len(t, invitations, 1)
+	require.Equal(t, organization.Name, invitations[0].OrganizationName)
+
+	// only the invited email can accept
+	_, err = testStore.AcceptOrganizationInvitationTx(context.Background(), AcceptOrganizationInvitationTxParams{
+		ID:     invitation.ID,
+		Email:  owner.Email,
+		UserID: owner.ID,
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	member, err := testStore.AcceptOrganizationInvitationTx(context.Background(), AcceptOrganizationInvitationTxParams{
+		ID:     invitation.ID,
+		Email:  invitee.Email,
+		UserID: invitee.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, OrganizationRoleAdmin, member.Role)
+
+	// an invitation works once
+	_, err = testStore.AcceptOrganizationInvitationTx(context.Background(), AcceptOrganizationInvitationTxParams{
+		ID:     invitation.ID,
+		Email:  invitee.Email,
+		UserID: invitee.ID,
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestUpdateOrganizationMemberRoleTxLastOwner(t *testing.T) {
+	owner := createRandomUser(t)
+	organization := createRandomOrganization(t, owner)
+
+	result, err := testStore.UpdateOrganizationMemberRoleTx(context.Background(), UpdateOrganizationMemberRoleParams{
+		OrganizationID: organization.ID,
+		UserID:         owner.ID,
+		Role:           OrganizationRoleMember,
+	})
+	require.NoError(t, err)
+	require.True(t, result.LastOwner)
+
+	removed, err := testStore.RemoveOrganizationMemberTx(context.Background(), RemoveOrganizationMemberParams{
+		OrganizationID: organization.ID,
+		UserID:         owner.ID,
+	})
+	require.NoError(t, err)
+	require.True(t, removed.LastOwner)
+	require.False(t, removed.Removed)
+}
+
+func TestUpdateOrganizationMemberRoleTxParallel(t *testing.T) {
+	first := createRandomUser(t)
+	second := createRandomUser(t)
+	organization := createRandomOrganization(t, first)
+	joinOrganization(t, organization, second, OrganizationRoleOwner)
+
+	// two owners demoting each other at once leave one of them an owner
+	results := make(chan UpdateOrganizationMemberRoleTxResult, 2)
+	errs := make(chan error, 2)
+	for _, user := range []User{first, second} {
+		go func() {
+			result, err := testStore.UpdateOrganizationMemberRoleTx(context.Background(), UpdateOrganizationMemberRoleParams{
+				OrganizationID: organization.ID,
+				UserID:         user.ID,
+				Role:           OrganizationRoleMember,
+			})
+			results <- result
+			errs <- err
+		}()
+	}
+
+	lastOwner := 0
+	for range 2 {
+		require.NoError(t, <-errs)
+		if (<-results).LastOwner {
+			lastOwner++
+		}
+	}
+	require.Equal(t, 1, lastOwner)
+
+	owners, err := testStore.CountOrganizationOwners(context.Background(), organization.ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, owners)
+}
+
+func TestGetReportOrganizationMember(t *testing.T) {
+	owner := createRandomUser(t)
+	member := createRandomUser(t)
+	outsider := createRandomUser(t)
+	organization := createRandomOrganization(t, owner)
+	joinOrganization(t, organization, member, OrganizationRoleMember)
+
+	report, err := testStore.CreateReport(context.Background(), CreateReportParams{
+		UserID:         owner.ID,
+		ReportType:     "monsters",
+		OrganizationID: uuid.NullUUID{UUID: organization.ID, Valid: true},
+	})
+	require.NoError(t, err)
+
+	got, err := testStore.GetReport(context.Background(), GetReportParams{UserID: member.ID, ID: report.ID})
+	require.NoError(t, err)
+	require.Equal(t, owner.ID, got.UserID)
+	require.Equal(t, organization.ID, got.OrganizationID.UUID)
+
+	_, err = testStore.GetReport(context.Background(), GetReportParams{UserID: outsider.ID, ID: report.ID})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// removed members lose access
+	removed, err := testStore.RemoveOrganizationMember(context.Background(), RemoveOrganizationMemberParams{
+		OrganizationID: organization.ID,
+		UserID:         member.ID,
+	})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, removed)
+	_, err = testStore.GetReport(context.Background(), GetReportParams{UserID: member.ID, ID: report.ID})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestListReportTemplatesOrganization(t *testing.T) {
+	owner := createRandomUser(t)
+	member := createRandomUser(t)
+	organization := createRandomOrganization(t, owner)
+	joinOrganization(t, organization, member, OrganizationRoleMember)
+
+	createRandomReportTemplate(t, owner)
+	shared, err := testStore.CreateReportTemplate(context.Background(), CreateReportTemplateParams{
+		UserID:         owner.ID,
+		Name:           helpers.RandomString(10),
+		Params:         []byte(`{"type":"monsters"}`),
+		OrganizationID: uuid.NullUUID{UUID: organization.ID, Valid: true},
+	})
+	require.NoError(t, err)
+	mine := createRandomReportTemplate(t, member)
+
+	templates, err := testStore.ListReportTemplates(context.Background(), member.ID)
+	require.NoError(t, err)
+	ids := []uuid.UUID{}
+	for _, template := range templates {
+		ids = append(ids, template.ID)
+	}
+	require.ElementsMatch(t, []uuid.UUID{shared.ID, mine.ID}, ids)
+}
+
+func TestReusableReportArtifactOrganization(t *testing.T) {
+	paramsHash := helpers.RandomString(64)
+	owner := createRandomUser(t)
+	organization := createRandomOrganization(t, owner)
+
+	report, err := testStore.CreateReport(context.Background(), CreateReportParams{
+		UserID:         owner.ID,
+		ReportType:     "monsters",
+		ParamsHash:     sql.NullString{String: paramsHash, Valid: true},
+		OrganizationID: uuid.NullUUID{UUID: organization.ID, Valid: true},
+	})
+	require.NoError(t, err)
+	_, err = testStore.CompleteReportTx(context.Background(), CompleteReportTxParams{
+		UserID:         report.UserID,
+		ID:             report.ID,
+		ParamsHash:     paramsHash,
+		ObjectKey:      "/orgs/" + organization.ID.String() + "/artifacts/" + paramsHash + "/" + report.ID.String() + ".csv.gz",
+		SizeBytes:      1024,
+		RowCount:       10,
+		OrganizationID: report.OrganizationID,
+	})
+	require.NoError(t, err)
+
+	// artifacts of an organization are not reused for personal reports
+	_, err = testStore.GetReusableReportArtifact(context.Background(), GetReusableReportArtifactParams{
+		ParamsHash:   paramsHash,
+		CreatedAfter: time.Now().Add(-time.Hour),
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	artifact, err := testStore.GetReusableReportArtifact(context.Background(), GetReusableReportArtifactParams{
+		ParamsHash:     paramsHash,
+		OrganizationID: report.OrganizationID,
+		CreatedAfter:   time.Now().Add(-time.Hour),
+	})
+	require.NoError(t, err)
+	require.Equal(t, organization.ID, artifact.OrganizationID.UUID)
+}
@@ -6,28 +6,155 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type Querier interface {
+	AcquireReportArtifact(ctx context.Context, id uuid.UUID) (ReportArtifact, error)
+	ActivateReportEmailDeliveries(ctx context.Context, reportID uuid.UUID) (int64, error)
+	AddOrganizationMember(ctx context.Context, arg AddOrganizationMemberParams) (OrganizationMember, error)
+	CancelReport(ctx context.Context, arg CancelReportParams) (Report, error)
+	ClaimDueReportDestinationDeliveries(ctx context.Context, arg ClaimDueReportDestinationDeliveriesParams) ([]ReportDestinationDelivery, error)
+	ClaimDueReportEmailDeliveries(ctx context.Context, arg ClaimDueReportEmailDeliveriesParams) ([]ReportEmailDelivery, error)
+	ClaimDueWebhookDeliveries(ctx context.Context, arg ClaimDueWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	ClaimStaleRequestedReports(ctx context.Context, arg ClaimStaleRequestedReportsParams) ([]Report, error)
+	CompleteReport(ctx context.Context, arg CompleteReportParams) (Report, error)
+	ConsumeReportShare(ctx context.Context, id uuid.UUID) (ReportShare, error)
+	CountActiveReports(ctx context.Context) ([]CountActiveReportsRow, error)
+	CountOrganizationOwners(ctx context.Context, organizationID uuid.UUID) (int64, error)
+	CountUnusedMfaRecoveryCodes(ctx context.Context, userID uuid.UUID) (int64, error)
+	CreateAccountToken(ctx context.Context, arg CreateAccountTokenParams) (AccountToken, error)
+	CreateApiKey(ctx context.Context, arg CreateApiKeyParams) (ApiKey, error)
+	CreateIdempotencyKey(ctx context.Context, arg CreateIdempotencyKeyParams) (IdempotencyKey, error)
+	CreateLoginThrottle(ctx context.Context, arg CreateLoginThrottleParams) error
+	CreateMfaRecoveryCode(ctx context.Context, arg CreateMfaRecoveryCodeParams) error
+	CreateOrganization(ctx context.Context, name string) (Organization, error)
+	CreateOrganizationInvitation(ctx context.Context, arg CreateOrganizationInvitationParams) (OrganizationInvitation, error)
 	CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error)
 	CreateReport(ctx context.Context, arg CreateReportParams) (Report, error)
+	CreateReportArtifact(ctx context.Context, arg CreateReportArtifactParams) (ReportArtifact, error)
+	CreateReportAttempt(ctx context.Context, arg CreateReportAttemptParams) (ReportAttempt, error)
+	CreateReportDestination(ctx context.Context, arg CreateReportDestinationParams) (ReportDestination, error)
+	CreateReportDestinationDelivery(ctx context.Context, arg CreateReportDestinationDeliveryParams) (ReportDestinationDelivery, error)
+	CreateReportEmailDelivery(ctx context.Context, arg CreateReportEmailDeliveryParams) (ReportEmailDelivery, error)
+	CreateReportSchedule(ctx context.Context, arg CreateReportScheduleParams) (ReportSchedule, error)
+	CreateReportShare(ctx context.Context, arg CreateReportShareParams) (ReportShare, error)
+	CreateReportShareRedemption(ctx context.Context, arg CreateReportShareRedemptionParams) (ReportShareRedemption, error)
+	CreateReportTemplate(ctx context.Context, arg CreateReportTemplateParams) (ReportTemplate, error)
+	CreateSecurityEvent(ctx context.Context, arg CreateSecurityEventParams) (SecurityEvent, error)
+	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) (WebhookDelivery, error)
+	CreateWebhookDeliveryAttempt(ctx context.Context, arg CreateWebhookDeliveryAttemptParams) (WebhookDeliveryAttempt, error)
+	CreateWebhookEndpoint(ctx context.Context, arg CreateWebhookEndpointParams) (WebhookEndpoint, error)
+	DeclineOrganizationInvitation(ctx context.Context, arg DeclineOrganizationInvitationParams) (int64, error)
 	DeleteAllUserRefreshTokens(ctx context.Context, userID uuid.UUID) error
+	DeleteExpiredDeniedTokens(ctx context.Context) (int64, error)
+	DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error)
 	DeleteExpiredRefreshTokens(ctx context.Context) error
+	DeleteIdempotencyKey(ctx context.Context, arg DeleteIdempotencyKeyParams) error
+	DeleteLoginThrottle(ctx context.Context, arg DeleteLoginThrottleParams) (int64, error)
+	DeleteMfaRecoveryCodes(ctx context.Context, userID uuid.UUID) error
+	DeleteOrganizationInvitation(ctx context.Context, arg DeleteOrganizationInvitationParams) (int64, error)
 	DeleteRefreshToken(ctx context.Context, hashedToken string) error
-	// UUID
 	DeleteReport(ctx context.Context, arg DeleteReportParams) error
+	DeleteReportArtifact(ctx context.Context, id uuid.UUID) error
+	DeleteReportDestination(ctx context.Context, arg DeleteReportDestinationParams) (int64, error)
+	// UUID
+	DeleteReportReturning(ctx context.Context, arg DeleteReportReturningParams) (Report, error)
+	DeleteReportSchedule(ctx context.Context, arg DeleteReportScheduleParams) (int64, error)
+	DeleteReportTemplate(ctx context.Context, arg DeleteReportTemplateParams) (int64, error)
+	DeleteStaleLoginThrottles(ctx context.Context, lastFailedAt time.Time) (int64, error)
 	DeleteUserRefreshToken(ctx context.Context, arg DeleteUserRefreshTokenParams) error
+	DeleteWebhookEndpoint(ctx context.Context, arg DeleteWebhookEndpointParams) (int64, error)
+	DenyToken(ctx context.Context, arg DenyTokenParams) error
+	DisableReportSchedule(ctx context.Context, arg DisableReportScheduleParams) (ReportSchedule, error)
+	DisableUserMfa(ctx context.Context, id uuid.UUID) (User, error)
+	EnableUserMfa(ctx context.Context, arg EnableUserMfaParams) (User, error)
 	FindUserByEmail(ctx context.Context, email string) (User, error)
 	FindUserById(ctx context.Context, id uuid.UUID) (User, error)
+	GetAccountTokenForUpdate(ctx context.Context, arg GetAccountTokenForUpdateParams) (AccountToken, error)
+	GetApiKeyByHash(ctx context.Context, keyHash string) (ApiKey, error)
+	GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKey, error)
+	GetLoginThrottle(ctx context.Context, arg GetLoginThrottleParams) (LoginThrottle, error)
+	GetLoginThrottleForUpdate(ctx context.Context, arg GetLoginThrottleForUpdateParams) (LoginThrottle, error)
+	GetOrganizationInvitation(ctx context.Context, arg GetOrganizationInvitationParams) (OrganizationInvitation, error)
+	GetOrganizationMember(ctx context.Context, arg GetOrganizationMemberParams) (OrganizationMember, error)
 	GetRefreshToken(ctx context.Context, hashedToken string) (RefreshToken, error)
+	GetRefreshTokenForUpdate(ctx context.Context, hashedToken string) (RefreshToken, error)
 	GetReport(ctx context.Context, arg GetReportParams) (Report, error)
+	GetReportDestination(ctx context.Context, arg GetReportDestinationParams) (ReportDestination, error)
+	GetReportSchedule(ctx context.Context, arg GetReportScheduleParams) (ReportSchedule, error)
+	GetReportShare(ctx context.Context, arg GetReportShareParams) (ReportShare, error)
+	GetReportShareByTokenHash(ctx context.Context, tokenHash string) (ReportShare, error)
+	GetReportTemplate(ctx context.Context, arg GetReportTemplateParams) (ReportTemplate, error)
+	GetReusableReportArtifact(ctx context.Context, arg GetReusableReportArtifactParams) (ReportArtifact, error)
+	GetSession(ctx context.Context, id uuid.UUID) (Session, error)
 	GetTokenByPrimaryKey(ctx context.Context, userID uuid.UUID) (RefreshToken, error)
+	GetWebhookDelivery(ctx context.Context, arg GetWebhookDeliveryParams) (WebhookDelivery, error)
+	InvalidateAccountTokens(ctx context.Context, arg InvalidateAccountTokensParams) error
+	ListActiveDeniedTokens(ctx context.Context) ([]DeniedToken, error)
+	ListActiveSessions(ctx context.Context, userID uuid.UUID) ([]Session, error)
+	ListAllReports(ctx context.Context, arg ListAllReportsParams) ([]Report, error)
+	ListApiKeys(ctx context.Context, userID uuid.UUID) ([]ApiKey, error)
+	ListDueReportSchedules(ctx context.Context, arg ListDueReportSchedulesParams) ([]ReportSchedule, error)
+	ListEnabledReportDestinations(ctx context.Context, userID uuid.UUID) ([]ReportDestination, error)
+	ListOrganizationInvitations(ctx context.Context, organizationID uuid.UUID) ([]OrganizationInvitation, error)
+	ListOrganizationMembers(ctx context.Context, organizationID uuid.UUID) ([]ListOrganizationMembersRow, error)
+	ListReportAttempts(ctx context.Context, arg ListReportAttemptsParams) ([]ReportAttempt, error)
+	ListReportDestinationDeliveries(ctx context.Context, arg ListReportDestinationDeliveriesParams) ([]ReportDestinationDelivery, error)
+	ListReportDestinations(ctx context.Context, userID uuid.UUID) ([]ReportDestination, error)
+	ListReportEmailDeliveries(ctx context.Context, arg ListReportEmailDeliveriesParams) ([]ReportEmailDelivery, error)
+	ListReportSchedules(ctx context.Context, userID uuid.UUID) ([]ReportSchedule, error)
+	ListReportShareRedemptions(ctx context.Context, shareID uuid.UUID) ([]ReportShareRedemption, error)
+	ListReportShares(ctx context.Context, arg ListReportSharesParams) ([]ReportShare, error)
+	ListReportTemplates(ctx context.Context, userID uuid.UUID) ([]ReportTemplate, error)
+	ListUserOrganizationInvitations(ctx context.Context, email string) ([]ListUserOrganizationInvitationsRow, error)
+	ListUserOrganizations(ctx context.Context, userID uuid.UUID) ([]ListUserOrganizationsRow, error)
+	ListUserSecurityEvents(ctx context.Context, arg ListUserSecurityEventsParams) ([]SecurityEvent, error)
+	ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error)
+	ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	ListWebhookDeliveryAttempts(ctx context.Context, deliveryID uuid.UUID) ([]WebhookDeliveryAttempt, error)
+	ListWebhookEndpoints(ctx context.Context, userID uuid.UUID) ([]WebhookEndpoint, error)
+	LockOrganizationOwners(ctx context.Context, organizationID uuid.UUID) ([]uuid.UUID, error)
+	MarkAccountTokenUsed(ctx context.Context, hashedToken string) (int64, error)
+	MarkRefreshTokenRotated(ctx context.Context, hashedToken string) (int64, error)
+	MarkReportScheduleRun(ctx context.Context, arg MarkReportScheduleRunParams) (ReportSchedule, error)
+	MarkUserEmailVerified(ctx context.Context, id uuid.UUID) (User, error)
+	PublishReportEvent(ctx context.Context, payload string) error
+	RedeliverWebhookDelivery(ctx context.Context, arg RedeliverWebhookDeliveryParams) (WebhookDelivery, error)
+	ReleaseLoginAttempt(ctx context.Context, arg ReleaseLoginAttemptParams) error
+	ReleaseReportArtifact(ctx context.Context, id uuid.UUID) (ReportArtifact, error)
+	RemoveOrganizationMember(ctx context.Context, arg RemoveOrganizationMemberParams) (int64, error)
+	ResetReport(ctx context.Context, arg ResetReportParams) (Report, error)
+	RevokeApiKey(ctx context.Context, arg RevokeApiKeyParams) (int64, error)
+	RevokeReportShare(ctx context.Context, arg RevokeReportShareParams) (ReportShare, error)
+	RevokeSession(ctx context.Context, arg RevokeSessionParams) (int64, error)
+	RevokeUserSessions(ctx context.Context, arg RevokeUserSessionsParams) ([]Session, error)
+	SaveIdempotencyResponse(ctx context.Context, arg SaveIdempotencyResponseParams) error
+	SetReportDestinationEnabled(ctx context.Context, arg SetReportDestinationEnabledParams) (ReportDestination, error)
+	SetUserMfaSecret(ctx context.Context, arg SetUserMfaSecretParams) (User, error)
+	StartReport(ctx context.Context, arg StartReportParams) (Report, error)
+	TakeOrganizationInvitation(ctx context.Context, arg TakeOrganizationInvitationParams) (OrganizationInvitation, error)
+	TouchApiKey(ctx context.Context, id uuid.UUID) error
+	TouchSession(ctx context.Context, arg TouchSessionParams) (Session, error)
+	TryAdvisoryXactLock(ctx context.Context, lockID int64) (bool, error)
+	UpdateLoginThrottle(ctx context.Context, arg UpdateLoginThrottleParams) (LoginThrottle, error)
+	UpdateOrganizationMemberRole(ctx context.Context, arg UpdateOrganizationMemberRoleParams) (OrganizationMember, error)
 	UpdateRefreshTokenExpiry(ctx context.Context, arg UpdateRefreshTokenExpiryParams) (RefreshToken, error)
-	// UUID
 	UpdateReport(ctx context.Context, arg UpdateReportParams) (Report, error)
+	UpdateReportDestinationDeliveryResult(ctx context.Context, arg UpdateReportDestinationDeliveryResultParams) (ReportDestinationDelivery, error)
+	UpdateReportEmailDeliveryResult(ctx context.Context, arg UpdateReportEmailDeliveryResultParams) (ReportEmailDelivery, error)
+	UpdateReportProgress(ctx context.Context, arg UpdateReportProgressParams) error
+	UpdateReportSchedule(ctx context.Context, arg UpdateReportScheduleParams) (ReportSchedule, error)
+	UpdateReportTemplate(ctx context.Context, arg UpdateReportTemplateParams) (ReportTemplate, error)
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error)
+	UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error)
+	UpdateWebhookDeliveryResult(ctx context.Context, arg UpdateWebhookDeliveryResultParams) (WebhookDelivery, error)
+	UseMfaRecoveryCode(ctx context.Context, arg UseMfaRecoveryCodeParams) (int64, error)
+	UseMfaStep(ctx context.Context, arg UseMfaStepParams) (int64, error)
 }
 
 var _ Querier = (*Queries)(nil)
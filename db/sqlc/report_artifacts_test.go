@@ -0,0 +1,183 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+)
+
+// createRequestedReport is a helper function to create a report that has not been built yet
+func createRequestedReport(t *testing.T, paramsHash string) Report {
+	user := createRandomUser(t)
+
+	report, err := testStore.CreateReport(context.Background(), CreateReportParams{
+		UserID:     user.ID,
+		ReportType: "monsters",
+		Params:     []byte(`{"type":"monsters"}`),
+		ParamsHash: sql.NullString{String: paramsHash, Valid: true},
+	})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"type":"monsters"}`, string(report.Params))
+	require.Equal(t, paramsHash, report.ParamsHash.String)
+	require.False(t, report.ArtifactID.Valid)
+	return report
+}
+
+func completeWithNewArtifact(t *testing.T, report Report) CompleteReportTxResult {
+	result, err := testStore.CompleteReportTx(context.Background(), CompleteReportTxParams{
+		UserID:     report.UserID,
+		ID:         report.ID,
+		ParamsHash: report.ParamsHash.String,
+		ObjectKey:  "/artifacts/" + report.ParamsHash.String + "/" + report.ID.String() + ".csv.gz",
+		SizeBytes:  1024,
+		RowCount:   10,
+	})
+	require.NoError(t, err)
+	return result
+}
+
+func TestCreateReportDefaultParams(t *testing.T) {
+	report := createRandomReport(t)
+	require.JSONEq(t, `{}`, string(report.Params))
+	require.False(t, report.ParamsHash.Valid)
+}
+
+func TestCompleteReportTx(t *testing.T) {
+	report := createRequestedReport(t, helpers.RandomString(64))
+
+	result := completeWithNewArtifact(t, report)
+	require.Equal(t, int32(1), result.Artifact.RefCount)
+	require.Equal(t, report.ParamsHash.String, result.Artifact.ParamsHash)
+	require.True(t, result.Report.CompletedAt.Valid)
+	require.Equal(t, result.Artifact.ID, result.Report.ArtifactID.UUID)
+	require.Equal(t, result.Artifact.ObjectKey, result.Report.OutputFilePath.String)
+}
+
+func TestCompleteReportTxCancelled(t *testing.T) {
+	paramsHash := helpers.RandomString(64)
+	report := createRequestedReport(t, paramsHash)
+	_, err := testStore.CancelReport(context.Background(), CancelReportParams{
+		UserID: report.UserID,
+		ID:     report.ID,
+	})
+	require.NoError(t, err)
+
+	_, err = testStore.CompleteReportTx(context.Background(), CompleteReportTxParams{
+		UserID:     report.UserID,
+		ID:         report.ID,
+		ParamsHash: paramsHash,
+		ObjectKey:  "/artifacts/" + paramsHash + "/cancelled.csv.gz",
+		SizeBytes:  1024,
+		RowCount:   10,
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	// the artifact is rolled back with the report
+	_, err = testStore.GetReusableReportArtifact(context.Background(), GetReusableReportArtifactParams{
+		ParamsHash:   paramsHash,
+		CreatedAfter: time.Now().Add(-time.Hour),
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestCompleteReportTxTwice(t *testing.T) {
+	report := createRequestedReport(t, helpers.RandomString(64))
+	first := completeWithNewArtifact(t, report)
+
+	// a second build of the same report can't replace the first one's artifact
+	_, err := testStore.CompleteReportTx(context.Background(), CompleteReportTxParams{
+		UserID:     report.UserID,
+		ID:         report.ID,
+		ParamsHash: report.ParamsHash.String,
+		ObjectKey:  "/artifacts/" + report.ParamsHash.String + "/second.csv.gz",
+		SizeBytes:  1024,
+		RowCount:   10,
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	stored, err := testStore.GetReport(context.Background(), GetReportParams{
+		UserID: report.UserID,
+		ID:     report.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, first.Artifact.ID, stored.ArtifactID.UUID)
+	require.Equal(t, first.Artifact.ObjectKey, stored.OutputFilePath.String)
+}
+
+func TestReuseReportArtifactTx(t *testing.T) {
+	paramsHash := helpers.RandomString(64)
+	first := completeWithNewArtifact(t, createRequestedReport(t, paramsHash))
+
+	artifact, err := testStore.GetReusableReportArtifact(context.Background(), GetReusableReportArtifactParams{
+		ParamsHash:   paramsHash,
+		CreatedAfter: time.Now().Add(-time.Hour),
+	})
+	require.NoError(t, err)
+	require.Equal(t, first.Artifact.ID, artifact.ID)
+
+	// artifacts older than the TTL are not reused
+	_, err = testStore.GetReusableReportArtifact(context.Background(), GetReusableReportArtifactParams{
+		ParamsHash:   paramsHash,
+		CreatedAfter: time.Now().Add(time.Minute),
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	second := createRequestedReport(t, paramsHash)
+	result, err := testStore.ReuseReportArtifactTx(context.Background(), ReuseReportArtifactTxParams{
+		UserID:     second.UserID,
+		ID:         second.ID,
+		ArtifactID: artifact.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int32(2), result.Artifact.RefCount)
+	require.True(t, result.Report.CompletedAt.Valid)
+	require.Equal(t, first.Report.OutputFilePath, result.Report.OutputFilePath)
+	require.Equal(t, artifact.ID, result.Report.ArtifactID.UUID)
+}
+
+func TestDeleteReportTx(t *testing.T) {
+	paramsHash := helpers.RandomString(64)
+	first := completeWithNewArtifact(t, createRequestedReport(t, paramsHash))
+
+	second := createRequestedReport(t, paramsHash)
+	_, err := testStore.ReuseReportArtifactTx(context.Background(), ReuseReportArtifactTxParams{
+		UserID:     second.UserID,
+		ID:         second.ID,
+		ArtifactID: first.Artifact.ID,
+	})
+	require.NoError(t, err)
+
+	// the artifact is still used by the second report
+	result, err := testStore.DeleteReportTx(context.Background(), DeleteReportTxParams{
+		UserID: first.Report.UserID,
+		ID:     first.Report.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, first.Report.ID, result.Report.ID)
+	require.Empty(t, result.OrphanedObjectKey)
+
+	// deleting the last report releases the object
+	result, err = testStore.DeleteReportTx(context.Background(), DeleteReportTxParams{
+		UserID: second.UserID,
+		ID:     second.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, first.Artifact.ObjectKey, result.OrphanedObjectKey)
+
+	// a released artifact is never reused
+	_, err = testStore.GetReusableReportArtifact(context.Background(), GetReusableReportArtifactParams{
+		ParamsHash:   paramsHash,
+		CreatedAfter: time.Now().Add(-time.Hour),
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	_, err = testStore.DeleteReportTx(context.Background(), DeleteReportTxParams{
+		UserID: second.UserID,
+		ID:     second.ID,
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
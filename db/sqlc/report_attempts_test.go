@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// createFailedReport is a helper function to create a report whose build failed
+func createFailedReport(t *testing.T, errorMessage string) Report {
+	report := createRandomReport(t)
+
+	report, err := testStore.UpdateReport(context.Background(), UpdateReportParams{
+		UserID:       report.UserID,
+		ID:           report.ID,
+		FailedAt:     sql.NullTime{Time: time.Now(), Valid: true},
+		ErrorMessage: sql.NullString{String: errorMessage, Valid: true},
+	})
+	require.NoError(t, err)
+	return report
+}
+
+func TestRetryReportTx(t *testing.T) {
+	report := createFailedReport(t, "failed to fetch monsters")
+
+	result, err := testStore.RetryReportTx(context.Background(), RetryReportTxParams{
+		UserID: report.UserID,
+		ID:     report.ID,
+	})
+	require.NoError(t, err)
+
+	// the failed run is kept in the attempt history
+	require.Equal(t, report.ID, result.Attempt.ReportID)
+	require.Equal(t, report.UserID, result.Attempt.UserID)
+	require.Equal(t, int32(1), result.Attempt.AttemptNumber)
+	require.Equal(t, "failed to fetch monsters", result.Attempt.ErrorMessage.String)
+	require.Equal(t, report.StartedAt.Time.Unix(), result.Attempt.StartedAt.Time.Unix())
+	require.Equal(t, report.FailedAt.Time.Unix(), result.Attempt.FailedAt.Time.Unix())
+
+	// the report itself is reset
+	require.Equal(t, report.ID, result.Report.ID)
+	require.False(t, result.Report.StartedAt.Valid)
+	require.False(t, result.Report.FailedAt.Valid)
+	require.False(t, result.Report.ErrorMessage.Valid)
+	require.False(t, result.Report.OutputFilePath.Valid)
+	require.False(t, result.Report.DownloadUrl.Valid)
+
+	// a report that has not failed cannot be retried
+	_, err = testStore.RetryReportTx(context.Background(), RetryReportTxParams{
+		UserID: report.UserID,
+		ID:     report.ID,
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestListReportAttempts(t *testing.T) {
+	report := createFailedReport(t, "first failure")
+
+	_, err := testStore.RetryReportTx(context.Background(), RetryReportTxParams{
+		UserID: report.UserID,
+		ID:     report.ID,
+	})
+	require.NoError(t, err)
+
+	_, err = testStore.UpdateReport(context.Background(), UpdateReportParams{
+		UserID:       report.UserID,
+		ID:           report.ID,
+		StartedAt:    sql.NullTime{Time: time.Now(), Valid: true},
+		FailedAt:     sql.NullTime{Time: time.Now(), Valid: true},
+		ErrorMessage: sql.NullString{String: "second failure", Valid: true},
+	})
+	require.NoError(t, err)
+
+	_, err = testStore.RetryReportTx(context.Background(), RetryReportTxParams{
+		UserID: report.UserID,
+		ID:     report.ID,
+	})
+	require.NoError(t, err)
+
+	attempts, err := testStore.ListReportAttempts(context.Background(), ListReportAttemptsParams{
+		UserID:   report.UserID,
+		ReportID: report.ID,
+	})
+	require.NoError(t, err)
+	require.Len(t, attempts, 2)
+	require.Equal(t, int32(1), attempts[0].AttemptNumber)
+	require.Equal(t, "first failure", attempts[0].ErrorMessage.String)
+	require.Equal(t, int32(2), attempts[1].AttemptNumber)
+	require.Equal(t, "second failure", attempts[1].ErrorMessage.String)
+}
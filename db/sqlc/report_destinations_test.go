@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+)
+
+// createRandomReportDestination is a helper function to register a WebDAV destination for a user
+func createRandomReportDestination(t *testing.T, user User, enabled bool) ReportDestination {
+	arg := CreateReportDestinationParams{
+		UserID:  user.ID,
+		Name:    helpers.RandomString(10),
+		Kind:    "webdav",
+		Config:  []byte(`{"url":"https://dav.example.com/drop"}`),
+		Secret:  sql.NullString{String: "secret", Valid: true},
+		Enabled: enabled,
+	}
+
+	destination, err := testStore.CreateReportDestination(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Name, destination.Name)
+	require.Equal(t, arg.Kind, destination.Kind)
+	require.JSONEq(t, string(arg.Config), string(destination.Config))
+	require.Equal(t, arg.Secret, destination.Secret)
+	require.Equal(t, enabled, destination.Enabled)
+	return destination
+}
+
+func TestListEnabledReportDestinations(t *testing.T) {
+	user := createRandomUser(t)
+	enabled := createRandomReportDestination(t, user, true)
+	disabled := createRandomReportDestination(t, user, false)
+
+	destinations, err := testStore.ListEnabledReportDestinations(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Len(t, destinations, 1)
+	require.Equal(t, enabled.ID, destinations[0].ID)
+
+	updated, err := testStore.SetReportDestinationEnabled(context.Background(), SetReportDestinationEnabledParams{
+		UserID:  user.ID,
+		ID:      disabled.ID,
+		Enabled: true,
+	})
+	require.NoError(t, err)
+	require.True(t, updated.Enabled)
+
+	destinations, err = testStore.ListEnabledReportDestinations(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Len(t, destinations, 2)
+}
+
+func TestReportDestinationDeliveryOutlivesDestination(t *testing.T) {
+	report := createRandomReport(t)
+	user := User{ID: report.UserID}
+	destination := createRandomReportDestination(t, user, true)
+
+	delivery, err := testStore.CreateReportDestinationDelivery(context.Background(), CreateReportDestinationDeliveryParams{
+		UserID:          report.UserID,
+		ReportID:        report.ID,
+		DestinationID:   uuid.NullUUID{UUID: destination.ID, Valid: true},
+		DestinationName: destination.Name,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "pending", delivery.Status)
+
+	deleted, err := testStore.DeleteReportDestination(context.Background(), DeleteReportDestinationParams{
+		UserID: user.ID,
+		ID:     destination.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+
+	deliveries, err := testStore.ListReportDestinationDeliveries(context.Background(), ListReportDestinationDeliveriesParams{
+		UserID:   report.UserID,
+		ReportID: report.ID,
+	})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.False(t, deliveries[0].DestinationID.Valid)
+	require.Equal(t, destination.Name, deliveries[0].DestinationName)
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateReportTxWithEmailDeliveries(t *testing.T) {
+	user := createRandomUser(t)
+
+	result, err := testStore.CreateReportTx(context.Background(), CreateReportTxParams{
+		Report: CreateReportParams{
+			UserID:     user.ID,
+			ReportType: "monsters",
+			Params:     []byte(`{"type":"monsters"}`),
+		},
+		DeliverTo: []string{"a@example.com", "b@example.com"},
+	})
+	require.NoError(t, err)
+	require.Len(t, result.EmailDeliveries, 2)
+	for _, delivery := range result.EmailDeliveries {
+		require.Equal(t, result.Report.ID, delivery.ReportID)
+		require.Equal(t, "waiting", delivery.Status)
+	}
+
+	// waiting deliveries are never claimed
+	claimed, err := testStore.ClaimDueReportEmailDeliveries(context.Background(), ClaimDueReportEmailDeliveriesParams{
+		LeaseUntil: time.Now().Add(time.Minute),
+		Limit:      100,
+	})
+	require.NoError(t, err)
+	for _, delivery := range claimed {
+		require.NotEqual(t, result.Report.ID, delivery.ReportID)
+	}
+
+	activated, err := testStore.ActivateReportEmailDeliveries(context.Background(), result.Report.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), activated)
+
+	deliveries, err := testStore.ListReportEmailDeliveries(context.Background(), ListReportEmailDeliveriesParams{
+		UserID:   user.ID,
+		ReportID: result.Report.ID,
+	})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	require.Equal(t, "a@example.com", deliveries[0].Recipient)
+	require.Equal(t, "pending", deliveries[0].Status)
+}
+
+func TestCreateReportTxDuplicateRecipient(t *testing.T) {
+	user := createRandomUser(t)
+
+	_, err := testStore.CreateReportTx(context.Background(), CreateReportTxParams{
+		Report: CreateReportParams{
+			UserID:     user.ID,
+			ReportType: "monsters",
+			Params:     []byte(`{"type":"monsters"}`),
+		},
+		DeliverTo: []string{"a@example.com", "a@example.com"},
+	})
+	require.Error(t, err)
+	require.Equal(t, UniqueViolation, ErrorCode(err))
+}
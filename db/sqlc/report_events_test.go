@@ -0,0 +1,34 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+	"github.com/trenchesdeveloper/csv-reporter/config"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+)
+
+func TestPublishReportEvent(t *testing.T) {
+	cfg, err := config.LoadConfig("../..")
+	require.NoError(t, err)
+
+	listener := pq.NewListener(cfg.DB_SOURCE_TEST, time.Second, time.Minute, nil)
+	defer listener.Close()
+	require.NoError(t, listener.Listen("report_events"))
+
+	payload := `{"type":"status","status":"` + helpers.RandomString(8) + `"}`
+	err = testStore.PublishReportEvent(context.Background(), payload)
+	require.NoError(t, err)
+
+	select {
+	case n := <-listener.Notify:
+		require.NotNil(t, n)
+		require.Equal(t, "report_events", n.Channel)
+		require.Equal(t, payload, n.Extra)
+	case <-time.After(5 * time.Second):
+		t.Fatal("report event was not delivered")
+	}
+}
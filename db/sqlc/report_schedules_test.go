@@ -0,0 +1,156 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+)
+
+// createRandomReportSchedule is a helper function to create a schedule that runs at nextRunAt
+func createRandomReportSchedule(t *testing.T, user User, nextRunAt time.Time) ReportSchedule {
+	arg := CreateReportScheduleParams{
+		UserID:         user.ID,
+		Name:           helpers.RandomString(10),
+		CronExpression: "0 7 * * *",
+		Timezone:       "Europe/Berlin",
+		Params:         []byte(`{"type":"monsters"}`),
+		Enabled:        true,
+		NextRunAt:      nextRunAt,
+	}
+
+	schedule, err := testStore.CreateReportSchedule(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.UserID, schedule.UserID)
+	require.Equal(t, arg.Name, schedule.Name)
+	require.Equal(t, arg.CronExpression, schedule.CronExpression)
+	require.Equal(t, arg.Timezone, schedule.Timezone)
+	require.JSONEq(t, string(arg.Params), string(schedule.Params))
+	require.True(t, schedule.Enabled)
+	require.False(t, schedule.LastRunAt.Valid)
+	require.WithinDuration(t, arg.NextRunAt, schedule.NextRunAt, time.Second)
+	return schedule
+}
+
+func TestUpdateReportSchedule(t *testing.T) {
+	user := createRandomUser(t)
+	schedule1 := createRandomReportSchedule(t, user, time.Now().Add(time.Hour))
+
+	arg := UpdateReportScheduleParams{
+		UserID:         user.ID,
+		ID:             schedule1.ID,
+		Name:           "weekly",
+		CronExpression: "@weekly",
+		Timezone:       "UTC",
+		Params:         []byte(`{"type":"weapons"}`),
+		Enabled:        false,
+		NextRunAt:      time.Now().Add(24 * time.Hour),
+	}
+	schedule2, err := testStore.UpdateReportSchedule(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, "weekly", schedule2.Name)
+	require.Equal(t, "@weekly", schedule2.CronExpression)
+	require.False(t, schedule2.Enabled)
+	require.True(t, schedule2.UpdatedAt.After(schedule1.UpdatedAt))
+
+	// schedules of other users are not visible
+	_, err = testStore.GetReportSchedule(context.Background(), GetReportScheduleParams{
+		UserID: createRandomUser(t).ID,
+		ID:     schedule1.ID,
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	schedules, err := testStore.ListReportSchedules(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Len(t, schedules, 1)
+
+	deleted, err := testStore.DeleteReportSchedule(context.Background(), DeleteReportScheduleParams{
+		UserID: user.ID,
+		ID:     schedule1.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+}
+
+func TestRunDueSchedulesTx(t *testing.T) {
+	user := createRandomUser(t)
+	due := createRandomReportSchedule(t, user, time.Now().Add(-time.Minute))
+	later := createRandomReportSchedule(t, user, time.Now().Add(time.Hour))
+
+	now := time.Now()
+	nextRunAt := now.Add(24 * time.Hour)
+	result, err := testStore.RunDueSchedulesTx(context.Background(), RunDueSchedulesTxParams{
+		LockID: 42,
+		Now:    now,
+		Limit:  1000,
+		Plan: func(schedule ReportSchedule) (CreateReportParams, time.Time, error) {
+			return CreateReportParams{
+				UserID:     schedule.UserID,
+				ReportType: "monsters",
+				Params:     schedule.Params,
+			}, nextRunAt, nil
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, result.Locked)
+
+	var report Report
+	for _, r := range result.Reports {
+		if r.UserID == user.ID {
+			report = r
+		}
+	}
+	require.NotZero(t, report.ID)
+
+	due, err = testStore.GetReportSchedule(context.Background(), GetReportScheduleParams{UserID: user.ID, ID: due.ID})
+	require.NoError(t, err)
+	require.True(t, due.LastRunAt.Valid)
+	require.Equal(t, report.ID, due.LastReportID.UUID)
+	require.WithinDuration(t, nextRunAt, due.NextRunAt, time.Second)
+
+	later, err = testStore.GetReportSchedule(context.Background(), GetReportScheduleParams{UserID: user.ID, ID: later.ID})
+	require.NoError(t, err)
+	require.False(t, later.LastRunAt.Valid)
+}
+
+func TestRunDueSchedulesTxPlanError(t *testing.T) {
+	user := createRandomUser(t)
+	due := createRandomReportSchedule(t, user, time.Now().Add(-time.Minute))
+
+	result, err := testStore.RunDueSchedulesTx(context.Background(), RunDueSchedulesTxParams{
+		LockID: 43,
+		Now:    time.Now(),
+		Limit:  1000,
+		Plan: func(schedule ReportSchedule) (CreateReportParams, time.Time, error) {
+			return CreateReportParams{}, time.Time{}, errors.New("invalid schedule")
+		},
+	})
+	require.NoError(t, err)
+	require.Error(t, result.Errors[due.ID])
+
+	// the schedule would fail on every run, so it is disabled with the error
+	due, err = testStore.GetReportSchedule(context.Background(), GetReportScheduleParams{UserID: user.ID, ID: due.ID})
+	require.NoError(t, err)
+	require.False(t, due.LastRunAt.Valid)
+	require.False(t, due.Enabled)
+	require.Equal(t, "invalid schedule", due.LastError.String)
+
+	// fixing the schedule clears the error
+	due, err = testStore.UpdateReportSchedule(context.Background(), UpdateReportScheduleParams{
+		UserID:         user.ID,
+		ID:             due.ID,
+		Name:           due.Name,
+		CronExpression: due.CronExpression,
+		Timezone:       due.Timezone,
+		Params:         due.Params,
+		Enabled:        true,
+		NextRunAt:      time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.True(t, due.Enabled)
+	require.False(t, due.LastError.Valid)
+}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+)
+
+// createRandomReportShare is a helper function to share a report
+func createRandomReportShare(t *testing.T, report Report, expiresAt time.Time, maxDownloads sql.NullInt32) ReportShare {
+	arg := CreateReportShareParams{
+		UserID:       report.UserID,
+		ReportID:     report.ID,
+		TokenHash:    helpers.RandomString(64),
+		ExpiresAt:    expiresAt,
+		MaxDownloads: maxDownloads,
+	}
+
+	share, err := testStore.CreateReportShare(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.ReportID, share.ReportID)
+	require.Equal(t, arg.TokenHash, share.TokenHash)
+	require.Equal(t, arg.MaxDownloads, share.MaxDownloads)
+	require.Zero(t, share.DownloadCount)
+	require.False(t, share.RevokedAt.Valid)
+
+	found, err := testStore.GetReportShareByTokenHash(context.Background(), arg.TokenHash)
+	require.NoError(t, err)
+	require.Equal(t, share.ID, found.ID)
+	return share
+}
+
+func TestConsumeReportShareLimit(t *testing.T) {
+	report := createRandomReport(t)
+	share := createRandomReportShare(t, report, time.Now().Add(time.Hour), sql.NullInt32{Int32: 2, Valid: true})
+
+	for i := int32(1); i <= 2; i++ {
+		consumed, err := testStore.ConsumeReportShare(context.Background(), share.ID)
+		require.NoError(t, err)
+		require.Equal(t, i, consumed.DownloadCount)
+	}
+
+	_, err := testStore.ConsumeReportShare(context.Background(), share.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestConsumeExpiredOrRevokedReportShare(t *testing.T) {
+	report := createRandomReport(t)
+
+	expired := createRandomReportShare(t, report, time.Now().Add(-time.Minute), sql.NullInt32{})
+	_, err := testStore.ConsumeReportShare(context.Background(), expired.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	share := createRandomReportShare(t, report, time.Now().Add(time.Hour), sql.NullInt32{})
+	revoked, err := testStore.RevokeReportShare(context.Background(), RevokeReportShareParams{
+		UserID:   report.UserID,
+		ReportID: report.ID,
+		ID:       share.ID,
+	})
+	require.NoError(t, err)
+	require.True(t, revoked.RevokedAt.Valid)
+
+	_, err = testStore.ConsumeReportShare(context.Background(), share.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	shares, err := testStore.ListReportShares(context.Background(), ListReportSharesParams{
+		UserID:   report.UserID,
+		ReportID: report.ID,
+	})
+	require.NoError(t, err)
+	require.Len(t, shares, 2)
+}
+
+func TestReportShareRedemptions(t *testing.T) {
+	report := createRandomReport(t)
+	share := createRandomReportShare(t, report, time.Now().Add(time.Hour), sql.NullInt32{})
+
+	_, err := testStore.CreateReportShareRedemption(context.Background(), CreateReportShareRedemptionParams{
+		ShareID:       share.ID,
+		Succeeded:     false,
+		FailureReason: sql.NullString{String: "invalid_password", Valid: true},
+		IpAddress:     "203.0.113.7",
+		UserAgent:     "curl/8.0",
+	})
+	require.NoError(t, err)
+
+	_, err = testStore.CreateReportShareRedemption(context.Background(), CreateReportShareRedemptionParams{
+		ShareID:   share.ID,
+		Succeeded: true,
+		IpAddress: "203.0.113.7",
+		UserAgent: "curl/8.0",
+	})
+	require.NoError(t, err)
+
+	redemptions, err := testStore.ListReportShareRedemptions(context.Background(), share.ID)
+	require.NoError(t, err)
+	require.Len(t, redemptions, 2)
+	require.True(t, redemptions[0].Succeeded)
+	require.Equal(t, "invalid_password", redemptions[1].FailureReason.String)
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+)
+
+// createRandomReportTemplate is a helper function to save a template for a user
+func createRandomReportTemplate(t *testing.T, user User) ReportTemplate {
+	arg := CreateReportTemplateParams{
+		UserID: user.ID,
+		Name:   helpers.RandomString(10),
+		Params: []byte(`{"type":"monsters","columns":["name","drops"]}`),
+	}
+
+	template, err := testStore.CreateReportTemplate(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.UserID, template.UserID)
+	require.Equal(t, arg.Name, template.Name)
+	require.JSONEq(t, string(arg.Params), string(template.Params))
+	require.Equal(t, int32(1), template.Version)
+	return template
+}
+
+func TestCreateReportTemplateUniqueName(t *testing.T) {
+	user := createRandomUser(t)
+	template := createRandomReportTemplate(t, user)
+
+	_, err := testStore.CreateReportTemplate(context.Background(), CreateReportTemplateParams{
+		UserID: user.ID,
+		Name:   template.Name,
+		Params: template.Params,
+	})
+	require.Error(t, err)
+	require.Equal(t, UniqueViolation, ErrorCode(err))
+}
+
+func TestUpdateReportTemplateVersion(t *testing.T) {
+	user := createRandomUser(t)
+	template1 := createRandomReportTemplate(t, user)
+
+	// renaming keeps the version
+	template2, err := testStore.UpdateReportTemplate(context.Background(), UpdateReportTemplateParams{
+		UserID: user.ID,
+		ID:     template1.ID,
+		Name:   "renamed " + template1.Name,
+		Params: []byte(`{"columns": ["name", "drops"], "type": "monsters"}`),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "renamed "+template1.Name, template2.Name)
+	require.Equal(t, int32(1), template2.Version)
+
+	// changing the params bumps it
+	template3, err := testStore.UpdateReportTemplate(context.Background(), UpdateReportTemplateParams{
+		UserID: user.ID,
+		ID:     template1.ID,
+		Name:   template2.Name,
+		Params: []byte(`{"type":"monsters","columns":["name"]}`),
+	})
+	require.NoError(t, err)
+	require.Equal(t, int32(2), template3.Version)
+}
+
+func TestReportFromTemplate(t *testing.T) {
+	user := createRandomUser(t)
+	template := createRandomReportTemplate(t, user)
+
+	report, err := testStore.CreateReport(context.Background(), CreateReportParams{
+		UserID:          user.ID,
+		ReportType:      "monsters",
+		Params:          template.Params,
+		TemplateID:      uuid.NullUUID{UUID: template.ID, Valid: true},
+		TemplateVersion: sql.NullInt32{Int32: template.Version, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, template.ID, report.TemplateID.UUID)
+	require.Equal(t, template.Version, report.TemplateVersion.Int32)
+
+	// deleting the template keeps the report and its version
+	deleted, err := testStore.DeleteReportTemplate(context.Background(), DeleteReportTemplateParams{
+		UserID: user.ID,
+		ID:     template.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+
+	report, err = testStore.GetReport(context.Background(), GetReportParams{
+		UserID: user.ID,
+		ID:     report.ID,
+	})
+	require.NoError(t, err)
+	require.False(t, report.TemplateID.Valid)
+	require.Equal(t, template.Version, report.TemplateVersion.Int32)
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
 )
 
 func createRandomReport(t *testing.T) Report {
@@ -176,3 +177,180 @@ func TestNonExistentReport(t *testing.T) {
 	require.EqualError(t, err, sql.ErrNoRows.Error())
 	require.Empty(t, report)
 }
+
+func TestCancelReport(t *testing.T) {
+	report1 := createRandomReport(t)
+
+	report2, err := testStore.CancelReport(context.Background(), CancelReportParams{
+		UserID: report1.UserID,
+		ID:     report1.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, report1.ID, report2.ID)
+	require.True(t, report2.CancelledAt.Valid)
+	require.WithinDuration(t, time.Now(), report2.CancelledAt.Time, time.Second*5)
+
+	// a cancelled report cannot be cancelled again
+	_, err = testStore.CancelReport(context.Background(), CancelReportParams{
+		UserID: report1.UserID,
+		ID:     report1.ID,
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestCancelCompletedReport(t *testing.T) {
+	report1 := createRandomReport(t)
+
+	_, err := testStore.UpdateReport(context.Background(), UpdateReportParams{
+		UserID:      report1.UserID,
+		ID:          report1.ID,
+		CompletedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+	require.NoError(t, err)
+
+	_, err = testStore.CancelReport(context.Background(), CancelReportParams{
+		UserID: report1.UserID,
+		ID:     report1.ID,
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestCompleteReport(t *testing.T) {
+	report1 := createRandomReport(t)
+
+	report2, err := testStore.CompleteReport(context.Background(), CompleteReportParams{
+		UserID:         report1.UserID,
+		ID:             report1.ID,
+		OutputFilePath: sql.NullString{String: "/path/to/completed.csv.gz", Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "/path/to/completed.csv.gz", report2.OutputFilePath.String)
+	require.True(t, report2.CompletedAt.Valid)
+
+	// a cancelled report is never marked as completed
+	report3 := createRandomReport(t)
+	_, err = testStore.CancelReport(context.Background(), CancelReportParams{
+		UserID: report3.UserID,
+		ID:     report3.ID,
+	})
+	require.NoError(t, err)
+
+	_, err = testStore.CompleteReport(context.Background(), CompleteReportParams{
+		UserID:         report3.UserID,
+		ID:             report3.ID,
+		OutputFilePath: sql.NullString{String: "/path/to/completed.csv.gz", Valid: true},
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestStartReport(t *testing.T) {
+	report1 := createRandomReport(t)
+
+	report2, err := testStore.StartReport(context.Background(), StartReportParams{
+		UserID: report1.UserID,
+		ID:     report1.ID,
+	})
+	require.NoError(t, err)
+	require.True(t, report2.StartedAt.Valid)
+
+	// only one worker gets to build a report
+	_, err = testStore.StartReport(context.Background(), StartReportParams{
+		UserID: report1.UserID,
+		ID:     report1.ID,
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	// a cancelled report is never started
+	report3 := createRandomReport(t)
+	_, err = testStore.CancelReport(context.Background(), CancelReportParams{
+		UserID: report3.UserID,
+		ID:     report3.ID,
+	})
+	require.NoError(t, err)
+
+	_, err = testStore.StartReport(context.Background(), StartReportParams{
+		UserID: report3.UserID,
+		ID:     report3.ID,
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestUpdateReportProgress(t *testing.T) {
+	report1 := createRandomReport(t)
+	require.Zero(t, report1.RowsWritten)
+	require.False(t, report1.Phase.Valid)
+
+	err := testStore.UpdateReportProgress(context.Background(), UpdateReportProgressParams{
+		UserID:       report1.UserID,
+		ID:           report1.ID,
+		Phase:        sql.NullString{String: "encoding", Valid: true},
+		RowsTotal:    sql.NullInt32{Int32: 100, Valid: true},
+		RowsWritten:  40,
+		BytesWritten: 2048,
+	})
+	require.NoError(t, err)
+
+	report2, err := testStore.GetReport(context.Background(), GetReportParams{
+		UserID: report1.UserID,
+		ID:     report1.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "encoding", report2.Phase.String)
+	require.Equal(t, int32(100), report2.RowsTotal.Int32)
+	require.Equal(t, int32(40), report2.RowsWritten)
+	require.Equal(t, int64(2048), report2.BytesWritten)
+
+	// progress of a finished report is left alone
+	_, err = testStore.CompleteReport(context.Background(), CompleteReportParams{
+		UserID:         report1.UserID,
+		ID:             report1.ID,
+		OutputFilePath: sql.NullString{String: "/path/to/completed.csv.gz", Valid: true},
+	})
+	require.NoError(t, err)
+
+	err = testStore.UpdateReportProgress(context.Background(), UpdateReportProgressParams{
+		UserID:       report1.UserID,
+		ID:           report1.ID,
+		Phase:        sql.NullString{String: "fetching", Valid: true},
+		RowsWritten:  0,
+		BytesWritten: 0,
+	})
+	require.NoError(t, err)
+
+	report3, err := testStore.GetReport(context.Background(), GetReportParams{
+		UserID: report1.UserID,
+		ID:     report1.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "encoding", report3.Phase.String)
+	require.Equal(t, int32(40), report3.RowsWritten)
+}
+
+func TestClaimStaleRequestedReports(t *testing.T) {
+	requested := createRequestedReport(t, helpers.RandomString(64))
+	started := createRandomReport(t)
+
+	claimed := func(enqueuedBefore time.Time) map[uuid.UUID]Report {
+		reports, err := testStore.ClaimStaleRequestedReports(context.Background(), ClaimStaleRequestedReportsParams{
+			EnqueuedBefore: enqueuedBefore,
+			// older reports of earlier test runs come first
+			Limit: 100000,
+		})
+		require.NoError(t, err)
+		byID := make(map[uuid.UUID]Report, len(reports))
+		for _, report := range reports {
+			byID[report.ID] = report
+		}
+		return byID
+	}
+
+	// only reports that haven't started are enqueued again
+	reports := claimed(time.Now().Add(time.Minute))
+	require.Contains(t, reports, requested.ID)
+	require.NotContains(t, reports, started.ID)
+	require.True(t, reports[requested.ID].EnqueuedAt.After(requested.EnqueuedAt))
+
+	// claiming moves enqueued_at forward, so the report isn't stale anymore
+	reports = claimed(time.Now().Add(-time.Minute))
+	require.NotContains(t, reports, requested.ID)
+}
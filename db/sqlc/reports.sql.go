@@ -8,10 +8,236 @@ package db
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const cancelReport = `-- name: CancelReport :one
+UPDATE reports
+SET cancelled_at = NOW()
+WHERE
+    user_id = $1
+  AND id = $2
+  AND completed_at IS NULL
+  AND failed_at IS NULL
+  AND cancelled_at IS NULL
+RETURNING user_id, id, report_type, output_file_path, download_url, download_expires_at, error_message, created_at, started_at, failed_at, completed_at, cancelled_at, callback_url, callback_secret, rows_total, rows_written, bytes_written, phase, params, params_hash, artifact_id, template_id, template_version, organization_id, enqueued_at
+`
+
+type CancelReportParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	ID     uuid.UUID `json:"id"`
+}
+
+func (q *Queries) CancelReport(ctx context.Context, arg CancelReportParams) (Report, error) {
+	row := q.db.QueryRowContext(ctx, cancelReport, arg.UserID, arg.ID)
+	var i Report
+	err := row.Scan(
+		&i.UserID,
+		&i.ID,
+		&i.ReportType,
+		&i.OutputFilePath,
+		&i.DownloadUrl,
+		&i.DownloadExpiresAt,
+		&i.ErrorMessage,
+		&i.CreatedAt,
+		&i.StartedAt,
+		&i.FailedAt,
+		&i.CompletedAt,
+		&i.CancelledAt,
+		&i.CallbackUrl,
+		&i.CallbackSecret,
+		&i.RowsTotal,
+		&i.RowsWritten,
+		&i.BytesWritten,
+		&i.Phase,
+		&i.Params,
+		&i.ParamsHash,
+		&i.ArtifactID,
+		&i.TemplateID,
+		&i.TemplateVersion,
+		&i.OrganizationID,
+		&i.EnqueuedAt,
+	)
+	return i, err
+}
+
+const claimStaleRequestedReports = `-- name: ClaimStaleRequestedReports :many
+UPDATE reports
+SET enqueued_at = NOW()
+WHERE id IN (
+    SELECT id
+    FROM reports
+    WHERE started_at IS NULL
+      AND cancelled_at IS NULL
+      AND enqueued_at < $1
+    ORDER BY enqueued_at
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING user_id, id, report_type, output_file_path, download_url, download_expires_at, error_message, created_at, started_at, failed_at, completed_at, cancelled_at, callback_url, callback_secret, rows_total, rows_written, bytes_written, phase, params, params_hash, artifact_id, template_id, template_version, organization_id, enqueued_at
+`
+
+type ClaimStaleRequestedReportsParams struct {
+	EnqueuedBefore time.Time `json:"enqueued_before"`
+	Limit          int32     `json:"limit"`
+}
+
+func (q *Queries) ClaimStaleRequestedReports(ctx context.Context, arg ClaimStaleRequestedReportsParams) ([]Report, error) {
+	rows, err := q.db.QueryContext(ctx, claimStaleRequestedReports, arg.EnqueuedBefore, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Report{}
+	for rows.Next() {
+		var i Report
+		if err := rows.Scan(
+			&i.UserID,
+			&i.ID,
+			&i.ReportType,
+			&i.OutputFilePath,
+			&i.DownloadUrl,
+			&i.DownloadExpiresAt,
+			&i.ErrorMessage,
+			&i.CreatedAt,
+			&i.StartedAt,
+			&i.FailedAt,
+			&i.CompletedAt,
+			&i.CancelledAt,
+			&i.CallbackUrl,
+			&i.CallbackSecret,
+			&i.RowsTotal,
+			&i.RowsWritten,
+			&i.BytesWritten,
+			&i.Phase,
+			&i.Params,
+			&i.ParamsHash,
+			&i.ArtifactID,
+			&i.TemplateID,
+			&i.TemplateVersion,
+			&i.OrganizationID,
+			&i.EnqueuedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const completeReport = `-- name: CompleteReport :one
+UPDATE reports
+SET
+    output_file_path = $1,
+    artifact_id = $2,
+    completed_at = NOW()
+WHERE
+    user_id = $3
+  AND id = $4
+  AND completed_at IS NULL
+  AND cancelled_at IS NULL
+RETURNING user_id, id, report_type, output_file_path, download_url, download_expires_at, error_message, created_at, started_at, failed_at, completed_at, cancelled_at, callback_url, callback_secret, rows_total, rows_written, bytes_written, phase, params, params_hash, artifact_id, template_id, template_version, organization_id, enqueued_at
+`
+
+type CompleteReportParams struct {
+	OutputFilePath sql.NullString `json:"output_file_path"`
+	ArtifactID     uuid.NullUUID  `json:"artifact_id"`
+	UserID         uuid.UUID      `json:"user_id"`
+	ID             uuid.UUID      `json:"id"`
+}
+
+func (q *Queries) CompleteReport(ctx context.Context, arg CompleteReportParams) (Report, error) {
+	row := q.db.QueryRowContext(ctx, completeReport,
+		arg.OutputFilePath,
+		arg.ArtifactID,
+		arg.UserID,
+		arg.ID,
+	)
+	var i Report
+	err := row.Scan(
+		&i.UserID,
+		&i.ID,
+		&i.ReportType,
+		&i.OutputFilePath,
+		&i.DownloadUrl,
+		&i.DownloadExpiresAt,
+		&i.ErrorMessage,
+		&i.CreatedAt,
+		&i.StartedAt,
+		&i.FailedAt,
+		&i.CompletedAt,
+		&i.CancelledAt,
+		&i.CallbackUrl,
+		&i.CallbackSecret,
+		&i.RowsTotal,
+		&i.RowsWritten,
+		&i.BytesWritten,
+		&i.Phase,
+		&i.Params,
+		&i.ParamsHash,
+		&i.ArtifactID,
+		&i.TemplateID,
+		&i.TemplateVersion,
+		&i.OrganizationID,
+		&i.EnqueuedAt,
+	)
+	return i, err
+}
+
+const countActiveReports = `-- name: CountActiveReports :many
+SELECT CASE WHEN started_at IS NULL THEN 'requested' ELSE 'processing' END::text AS status,
+       COUNT(*)                                                                AS count,
+       MIN(COALESCE(started_at, created_at))::timestamptz                      AS oldest_at
+FROM reports
+WHERE completed_at IS NULL
+  AND failed_at IS NULL
+  AND cancelled_at IS NULL
+GROUP BY 1
+ORDER BY 1 DESC
+`
+
+type CountActiveReportsRow struct {
+	Status   string    `json:"status"`
+	Count    int64     `json:"count"`
+	OldestAt time.Time `json:"oldest_at"`
+}
+
+func (q *Queries) CountActiveReports(ctx context.Context) ([]CountActiveReportsRow, error) {
+	rows, err := q.db.QueryContext(ctx, countActiveReports)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []CountActiveReportsRow{}
+	for rows.Next() {
+		var i CountActiveReportsRow
+		if err := rows.Scan(
+			&i.Status,
+			&i.Count,
+			&i.OldestAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createReport = `-- name: CreateReport :one
 INSERT INTO reports (
     user_id,
@@ -22,31 +248,52 @@ INSERT INTO reports (
     error_message,
     started_at,
     failed_at,
-    completed_at
+    completed_at,
+    callback_url,
+    callback_secret,
+    params,
+    params_hash,
+    template_id,
+    template_version,
+    organization_id
 ) VALUES (
-             $1,  -- user_id
-             $2,  -- report_type
-             $3,  -- output_file_path
-             $4,  -- download_url
-             $5,  -- download_expires_at
-             $6,  -- error_message
-             $7,  -- started_at
-             $8,  -- failed_at
-             $9   -- completed_at
+             $1,
+             $2,
+             $3,
+             $4,
+             $5,
+             $6,
+             $7,
+             $8,
+             $9,
+             $10,
+             $11,
+             COALESCE($12::jsonb, '{}'),
+             $13,
+             $14,
+             $15,
+             $16
          )
-RETURNING user_id, id, report_type, output_file_path, download_url, download_expires_at, error_message, created_at, started_at, failed_at, completed_at
+RETURNING user_id, id, report_type, output_file_path, download_url, download_expires_at, error_message, created_at, started_at, failed_at, completed_at, cancelled_at, callback_url, callback_secret, rows_total, rows_written, bytes_written, phase, params, params_hash, artifact_id, template_id, template_version, organization_id, enqueued_at
 `
 
 type CreateReportParams struct {
-	UserID            uuid.UUID      `json:"user_id"`
-	ReportType        string         `json:"report_type"`
-	OutputFilePath    sql.NullString `json:"output_file_path"`
-	DownloadUrl       sql.NullString `json:"download_url"`
-	DownloadExpiresAt sql.NullTime   `json:"download_expires_at"`
-	ErrorMessage      sql.NullString `json:"error_message"`
-	StartedAt         sql.NullTime   `json:"started_at"`
-	FailedAt          sql.NullTime   `json:"failed_at"`
-	CompletedAt       sql.NullTime   `json:"completed_at"`
+	UserID            uuid.UUID       `json:"user_id"`
+	ReportType        string          `json:"report_type"`
+	OutputFilePath    sql.NullString  `json:"output_file_path"`
+	DownloadUrl       sql.NullString  `json:"download_url"`
+	DownloadExpiresAt sql.NullTime    `json:"download_expires_at"`
+	ErrorMessage      sql.NullString  `json:"error_message"`
+	StartedAt         sql.NullTime    `json:"started_at"`
+	FailedAt          sql.NullTime    `json:"failed_at"`
+	CompletedAt       sql.NullTime    `json:"completed_at"`
+	CallbackUrl       sql.NullString  `json:"callback_url"`
+	CallbackSecret    sql.NullString  `json:"callback_secret"`
+	Params            json.RawMessage `json:"params"`
+	ParamsHash        sql.NullString  `json:"params_hash"`
+	TemplateID        uuid.NullUUID   `json:"template_id"`
+	TemplateVersion   sql.NullInt32   `json:"template_version"`
+	OrganizationID    uuid.NullUUID   `json:"organization_id"`
 }
 
 func (q *Queries) CreateReport(ctx context.Context, arg CreateReportParams) (Report, error) {
@@ -60,6 +307,13 @@ func (q *Queries) CreateReport(ctx context.Context, arg CreateReportParams) (Rep
 		arg.StartedAt,
 		arg.FailedAt,
 		arg.CompletedAt,
+		arg.CallbackUrl,
+		arg.CallbackSecret,
+		arg.Params,
+		arg.ParamsHash,
+		arg.TemplateID,
+		arg.TemplateVersion,
+		arg.OrganizationID,
 	)
 	var i Report
 	err := row.Scan(
@@ -74,12 +328,25 @@ func (q *Queries) CreateReport(ctx context.Context, arg CreateReportParams) (Rep
 		&i.StartedAt,
 		&i.FailedAt,
 		&i.CompletedAt,
+		&i.CancelledAt,
+		&i.CallbackUrl,
+		&i.CallbackSecret,
+		&i.RowsTotal,
+		&i.RowsWritten,
+		&i.BytesWritten,
+		&i.Phase,
+		&i.Params,
+		&i.ParamsHash,
+		&i.ArtifactID,
+		&i.TemplateID,
+		&i.TemplateVersion,
+		&i.OrganizationID,
+		&i.EnqueuedAt,
 	)
 	return i, err
 }
 
 const deleteReport = `-- name: DeleteReport :exec
-
 DELETE FROM reports
 WHERE
     user_id = $1  -- UUID
@@ -91,12 +358,59 @@ type DeleteReportParams struct {
 	ID     uuid.UUID `json:"id"`
 }
 
-// UUID
 func (q *Queries) DeleteReport(ctx context.Context, arg DeleteReportParams) error {
 	_, err := q.db.ExecContext(ctx, deleteReport, arg.UserID, arg.ID)
 	return err
 }
 
+const deleteReportReturning = `-- name: DeleteReportReturning :one
+
+DELETE FROM reports
+WHERE
+    user_id = $1
+  AND id = $2
+RETURNING user_id, id, report_type, output_file_path, download_url, download_expires_at, error_message, created_at, started_at, failed_at, completed_at, cancelled_at, callback_url, callback_secret, rows_total, rows_written, bytes_written, phase, params, params_hash, artifact_id, template_id, template_version, organization_id, enqueued_at
+`
+
+type DeleteReportReturningParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	ID     uuid.UUID `json:"id"`
+}
+
+// UUID
+func (q *Queries) DeleteReportReturning(ctx context.Context, arg DeleteReportReturningParams) (Report, error) {
+	row := q.db.QueryRowContext(ctx, deleteReportReturning, arg.UserID, arg.ID)
+	var i Report
+	err := row.Scan(
+		&i.UserID,
+		&i.ID,
+		&i.ReportType,
+		&i.OutputFilePath,
+		&i.DownloadUrl,
+		&i.DownloadExpiresAt,
+		&i.ErrorMessage,
+		&i.CreatedAt,
+		&i.StartedAt,
+		&i.FailedAt,
+		&i.CompletedAt,
+		&i.CancelledAt,
+		&i.CallbackUrl,
+		&i.CallbackSecret,
+		&i.RowsTotal,
+		&i.RowsWritten,
+		&i.BytesWritten,
+		&i.Phase,
+		&i.Params,
+		&i.ParamsHash,
+		&i.ArtifactID,
+		&i.TemplateID,
+		&i.TemplateVersion,
+		&i.OrganizationID,
+		&i.EnqueuedAt,
+	)
+	return i, err
+}
+
 const getReport = `-- name: GetReport :one
 SELECT
     user_id,
@@ -109,11 +423,26 @@ SELECT
     created_at,
     started_at,
     failed_at,
-    completed_at
+    completed_at,
+    cancelled_at,
+    callback_url,
+    callback_secret,
+    rows_total,
+    rows_written,
+    bytes_written,
+    phase,
+    params,
+    params_hash,
+    artifact_id,
+    template_id,
+    template_version,
+    organization_id,
+    enqueued_at
 FROM reports
 WHERE
-    user_id = $1  -- UUID
-  AND id      = $2
+    id = $2 -- UUID
+  AND (user_id = $1 OR
+       organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = $1))
 `
 
 type GetReportParams struct {
@@ -136,12 +465,213 @@ func (q *Queries) GetReport(ctx context.Context, arg GetReportParams) (Report, e
 		&i.StartedAt,
 		&i.FailedAt,
 		&i.CompletedAt,
+		&i.CancelledAt,
+		&i.CallbackUrl,
+		&i.CallbackSecret,
+		&i.RowsTotal,
+		&i.RowsWritten,
+		&i.BytesWritten,
+		&i.Phase,
+		&i.Params,
+		&i.ParamsHash,
+		&i.ArtifactID,
+		&i.TemplateID,
+		&i.TemplateVersion,
+		&i.OrganizationID,
+		&i.EnqueuedAt,
 	)
 	return i, err
 }
 
-const updateReport = `-- name: UpdateReport :one
+const listAllReports = `-- name: ListAllReports :many
+SELECT user_id, id, report_type, output_file_path, download_url, download_expires_at, error_message, created_at, started_at, failed_at, completed_at, cancelled_at, callback_url, callback_secret, rows_total, rows_written, bytes_written, phase, params, params_hash, artifact_id, template_id, template_version, organization_id, enqueued_at
+FROM reports
+WHERE ($1::uuid IS NULL OR user_id = $1)
+  AND ($2::text IS NULL OR
+       CASE
+           WHEN cancelled_at IS NOT NULL THEN 'cancelled'
+           WHEN started_at IS NULL THEN 'requested'
+           WHEN completed_at IS NOT NULL THEN 'completed'
+           WHEN failed_at IS NOT NULL THEN 'failed'
+           ELSE 'processing'
+           END = $2)
+ORDER BY created_at DESC
+LIMIT $3
+OFFSET $4
+`
+
+type ListAllReportsParams struct {
+	UserID uuid.NullUUID  `json:"user_id"`
+	Status sql.NullString `json:"status"`
+	Limit  int32          `json:"limit"`
+	Offset int32          `json:"offset"`
+}
+
+func (q *Queries) ListAllReports(ctx context.Context, arg ListAllReportsParams) ([]Report, error) {
+	rows, err := q.db.QueryContext(ctx, listAllReports,
+		arg.UserID,
+		arg.Status,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Report{}
+	for rows.Next() {
+		var i Report
+		if err := rows.Scan(
+			&i.UserID,
+			&i.ID,
+			&i.ReportType,
+			&i.OutputFilePath,
+			&i.DownloadUrl,
+			&i.DownloadExpiresAt,
+			&i.ErrorMessage,
+			&i.CreatedAt,
+			&i.StartedAt,
+			&i.FailedAt,
+			&i.CompletedAt,
+			&i.CancelledAt,
+			&i.CallbackUrl,
+			&i.CallbackSecret,
+			&i.RowsTotal,
+			&i.RowsWritten,
+			&i.BytesWritten,
+			&i.Phase,
+			&i.Params,
+			&i.ParamsHash,
+			&i.ArtifactID,
+			&i.TemplateID,
+			&i.TemplateVersion,
+			&i.OrganizationID,
+			&i.EnqueuedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
 
+const resetReport = `-- name: ResetReport :one
+UPDATE reports
+SET
+    output_file_path = NULL,
+    download_url = NULL,
+    download_expires_at = NULL,
+    error_message = NULL,
+    started_at = NULL,
+    failed_at = NULL,
+    completed_at = NULL,
+    cancelled_at = NULL,
+    rows_total = NULL,
+    rows_written = 0,
+    bytes_written = 0,
+    phase = NULL,
+    artifact_id = NULL,
+    enqueued_at = NOW()
+WHERE
+    user_id = $1
+  AND id = $2
+RETURNING user_id, id, report_type, output_file_path, download_url, download_expires_at, error_message, created_at, started_at, failed_at, completed_at, cancelled_at, callback_url, callback_secret, rows_total, rows_written, bytes_written, phase, params, params_hash, artifact_id, template_id, template_version, organization_id, enqueued_at
+`
+
+type ResetReportParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	ID     uuid.UUID `json:"id"`
+}
+
+func (q *Queries) ResetReport(ctx context.Context, arg ResetReportParams) (Report, error) {
+	row := q.db.QueryRowContext(ctx, resetReport, arg.UserID, arg.ID)
+	var i Report
+	err := row.Scan(
+		&i.UserID,
+		&i.ID,
+		&i.ReportType,
+		&i.OutputFilePath,
+		&i.DownloadUrl,
+		&i.DownloadExpiresAt,
+		&i.ErrorMessage,
+		&i.CreatedAt,
+		&i.StartedAt,
+		&i.FailedAt,
+		&i.CompletedAt,
+		&i.CancelledAt,
+		&i.CallbackUrl,
+		&i.CallbackSecret,
+		&i.RowsTotal,
+		&i.RowsWritten,
+		&i.BytesWritten,
+		&i.Phase,
+		&i.Params,
+		&i.ParamsHash,
+		&i.ArtifactID,
+		&i.TemplateID,
+		&i.TemplateVersion,
+		&i.OrganizationID,
+		&i.EnqueuedAt,
+	)
+	return i, err
+}
+
+const startReport = `-- name: StartReport :one
+UPDATE reports
+SET started_at = NOW()
+WHERE
+    user_id = $1
+  AND id = $2
+  AND started_at IS NULL
+  AND cancelled_at IS NULL
+RETURNING user_id, id, report_type, output_file_path, download_url, download_expires_at, error_message, created_at, started_at, failed_at, completed_at, cancelled_at, callback_url, callback_secret, rows_total, rows_written, bytes_written, phase, params, params_hash, artifact_id, template_id, template_version, organization_id, enqueued_at
+`
+
+type StartReportParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	ID     uuid.UUID `json:"id"`
+}
+
+func (q *Queries) StartReport(ctx context.Context, arg StartReportParams) (Report, error) {
+	row := q.db.QueryRowContext(ctx, startReport, arg.UserID, arg.ID)
+	var i Report
+	err := row.Scan(
+		&i.UserID,
+		&i.ID,
+		&i.ReportType,
+		&i.OutputFilePath,
+		&i.DownloadUrl,
+		&i.DownloadExpiresAt,
+		&i.ErrorMessage,
+		&i.CreatedAt,
+		&i.StartedAt,
+		&i.FailedAt,
+		&i.CompletedAt,
+		&i.CancelledAt,
+		&i.CallbackUrl,
+		&i.CallbackSecret,
+		&i.RowsTotal,
+		&i.RowsWritten,
+		&i.BytesWritten,
+		&i.Phase,
+		&i.Params,
+		&i.ParamsHash,
+		&i.ArtifactID,
+		&i.TemplateID,
+		&i.TemplateVersion,
+		&i.OrganizationID,
+		&i.EnqueuedAt,
+	)
+	return i, err
+}
+
+const updateReport = `-- name: UpdateReport :one
 UPDATE reports
 SET
     output_file_path = COALESCE($1, output_file_path),
@@ -165,7 +695,21 @@ RETURNING
     created_at,
     started_at,
     failed_at,
-    completed_at
+    completed_at,
+    cancelled_at,
+    callback_url,
+    callback_secret,
+    rows_total,
+    rows_written,
+    bytes_written,
+    phase,
+    params,
+    params_hash,
+    artifact_id,
+    template_id,
+    template_version,
+    organization_id,
+    enqueued_at
 `
 
 type UpdateReportParams struct {
@@ -180,7 +724,6 @@ type UpdateReportParams struct {
 	ID                uuid.UUID      `json:"id"`
 }
 
-// UUID
 func (q *Queries) UpdateReport(ctx context.Context, arg UpdateReportParams) (Report, error) {
 	row := q.db.QueryRowContext(ctx, updateReport,
 		arg.OutputFilePath,
@@ -206,6 +749,56 @@ func (q *Queries) UpdateReport(ctx context.Context, arg UpdateReportParams) (Rep
 		&i.StartedAt,
 		&i.FailedAt,
 		&i.CompletedAt,
+		&i.CancelledAt,
+		&i.CallbackUrl,
+		&i.CallbackSecret,
+		&i.RowsTotal,
+		&i.RowsWritten,
+		&i.BytesWritten,
+		&i.Phase,
+		&i.Params,
+		&i.ParamsHash,
+		&i.ArtifactID,
+		&i.TemplateID,
+		&i.TemplateVersion,
+		&i.OrganizationID,
+		&i.EnqueuedAt,
 	)
 	return i, err
 }
+
+const updateReportProgress = `-- name: UpdateReportProgress :exec
+UPDATE reports
+SET
+    phase = $1,
+    rows_total = $2,
+    rows_written = $3,
+    bytes_written = $4
+WHERE
+    user_id = $5
+  AND id = $6
+  AND completed_at IS NULL
+  AND failed_at IS NULL
+  AND cancelled_at IS NULL
+`
+
+type UpdateReportProgressParams struct {
+	Phase        sql.NullString `json:"phase"`
+	RowsTotal    sql.NullInt32  `json:"rows_total"`
+	RowsWritten  int32          `json:"rows_written"`
+	BytesWritten int64          `json:"bytes_written"`
+	UserID       uuid.UUID      `json:"user_id"`
+	ID           uuid.UUID      `json:"id"`
+}
+
+func (q *Queries) UpdateReportProgress(ctx context.Context, arg UpdateReportProgressParams) error {
+	_, err := q.db.ExecContext(ctx, updateReportProgress,
+		arg.Phase,
+		arg.RowsTotal,
+		arg.RowsWritten,
+		arg.BytesWritten,
+		arg.UserID,
+		arg.ID,
+	)
+	return err
+}
@@ -1,9 +1,38 @@
 package db
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type Store interface {
 	Querier
+	CreateReportTx(ctx context.Context, arg CreateReportTxParams) (CreateReportTxResult, error)
+	RetryReportTx(ctx context.Context, arg RetryReportTxParams) (RetryReportTxResult, error)
+	CompleteReportTx(ctx context.Context, arg CompleteReportTxParams) (CompleteReportTxResult, error)
+	ReuseReportArtifactTx(ctx context.Context, arg ReuseReportArtifactTxParams) (CompleteReportTxResult, error)
+	DeleteReportTx(ctx context.Context, arg DeleteReportTxParams) (DeleteReportTxResult, error)
+	RunDueSchedulesTx(ctx context.Context, arg RunDueSchedulesTxParams) (RunDueSchedulesTxResult, error)
+	CreateSessionTx(ctx context.Context, arg CreateSessionTxParams) (CreateSessionTxResult, error)
+	RotateRefreshTokenTx(ctx context.Context, arg RotateRefreshTokenTxParams) (RotateRefreshTokenTxResult, error)
+	RevokeSessionsTx(ctx context.Context, arg RevokeSessionsTxParams) (RevokeSessionsTxResult, error)
+	ChangeUserRoleTx(ctx context.Context, arg ChangeUserRoleTxParams) (ChangeUserRoleTxResult, error)
+	CreateOrganizationTx(ctx context.Context, arg CreateOrganizationTxParams) (CreateOrganizationTxResult, error)
+	AcceptOrganizationInvitationTx(ctx context.Context, arg AcceptOrganizationInvitationTxParams) (OrganizationMember, error)
+	UpdateOrganizationMemberRoleTx(ctx context.Context, arg UpdateOrganizationMemberRoleParams) (UpdateOrganizationMemberRoleTxResult, error)
+	RemoveOrganizationMemberTx(ctx context.Context, arg RemoveOrganizationMemberParams) (RemoveOrganizationMemberTxResult, error)
+	IssueAccountTokenTx(ctx context.Context, arg CreateAccountTokenParams) (AccountToken, error)
+	VerifyEmailTx(ctx context.Context, hashedToken string) (User, error)
+	ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (ResetPasswordTxResult, error)
+	EnableMfaTx(ctx context.Context, arg EnableMfaTxParams) (User, error)
+	DisableMfaTx(ctx context.Context, arg DisableMfaTxParams) (User, error)
+	ReplaceMfaRecoveryCodesTx(ctx context.Context, arg ReplaceMfaRecoveryCodesTxParams) error
+	ReserveLoginAttemptTx(ctx context.Context, arg ReserveLoginAttemptTxParams) (ReserveLoginAttemptTxResult, error)
 }
 
 type SQLStore struct {
@@ -17,3 +46,1093 @@ func NewStore(db *sql.DB) Store {
 		Queries:  New(db),
 	}
 }
+
+// execTx executes a function within a database transaction
+func (store *SQLStore) execTx(ctx context.Context, fn func(*Queries) error) error {
+	tx, err := store.connPool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	q := New(tx)
+	err = fn(q)
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("tx err: %v, rb err: %v", err, rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+type CreateReportTxParams struct {
+	Report CreateReportParams `json:"report"`
+	// DeliverTo lists the email addresses the finished report is sent to.
+	DeliverTo []string `json:"deliver_to"`
+}
+
+type CreateReportTxResult struct {
+	Report          Report                `json:"report"`
+	EmailDeliveries []ReportEmailDelivery `json:"email_deliveries"`
+}
+
+// CreateReportTx creates a report together with its email deliveries, which wait for the build.
+func (store *SQLStore) CreateReportTx(ctx context.Context, arg CreateReportTxParams) (CreateReportTxResult, error) {
+	var result CreateReportTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.Report, err = q.CreateReport(ctx, arg.Report)
+		if err != nil {
+			return err
+		}
+
+		for _, recipient := range arg.DeliverTo {
+			delivery, err := q.CreateReportEmailDelivery(ctx, CreateReportEmailDeliveryParams{
+				UserID:    result.Report.UserID,
+				ReportID:  result.Report.ID,
+				Recipient: recipient,
+			})
+			if err != nil {
+				return err
+			}
+			result.EmailDeliveries = append(result.EmailDeliveries, delivery)
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+type RetryReportTxParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	ID     uuid.UUID `json:"id"`
+}
+
+type RetryReportTxResult struct {
+	Report  Report        `json:"report"`
+	Attempt ReportAttempt `json:"attempt"`
+}
+
+// RetryReportTx records the failed run of a report in its attempt history and
+// resets the report so it can be built again.
+// It returns sql.ErrNoRows when the report has not failed.
+func (store *SQLStore) RetryReportTx(ctx context.Context, arg RetryReportTxParams) (RetryReportTxResult, error) {
+	var result RetryReportTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.Attempt, err = q.CreateReportAttempt(ctx, CreateReportAttemptParams{
+			UserID: arg.UserID,
+			ID:     arg.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Report, err = q.ResetReport(ctx, ResetReportParams{
+			UserID: arg.UserID,
+			ID:     arg.ID,
+		})
+		return err
+	})
+
+	return result, err
+}
+
+type CompleteReportTxParams struct {
+	UserID     uuid.UUID `json:"user_id"`
+	ID         uuid.UUID `json:"id"`
+	ParamsHash string    `json:"params_hash"`
+	ObjectKey  string    `json:"object_key"`
+	SizeBytes  int64     `json:"size_bytes"`
+	RowCount   int32     `json:"row_count"`
+	// OrganizationID scopes the artifact, so only reports of the same organization reuse it.
+	OrganizationID uuid.NullUUID `json:"organization_id"`
+}
+
+type CompleteReportTxResult struct {
+	Report   Report         `json:"report"`
+	Artifact ReportArtifact `json:"artifact"`
+}
+
+// CompleteReportTx records an uploaded object as an artifact and completes the report with it.
+// It returns sql.ErrNoRows when the report was cancelled or has already been completed.
+func (store *SQLStore) CompleteReportTx(ctx context.Context, arg CompleteReportTxParams) (CompleteReportTxResult, error) {
+	var result CompleteReportTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.Artifact, err = q.CreateReportArtifact(ctx, CreateReportArtifactParams{
+			ParamsHash:     arg.ParamsHash,
+			ObjectKey:      arg.ObjectKey,
+			SizeBytes:      arg.SizeBytes,
+			RowCount:       arg.RowCount,
+			OrganizationID: arg.OrganizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Report, err = q.CompleteReport(ctx, CompleteReportParams{
+			UserID:         arg.UserID,
+			ID:             arg.ID,
+			OutputFilePath: sql.NullString{String: result.Artifact.ObjectKey, Valid: true},
+			ArtifactID:     uuid.NullUUID{UUID: result.Artifact.ID, Valid: true},
+		})
+		return err
+	})
+
+	return result, err
+}
+
+type ReuseReportArtifactTxParams struct {
+	UserID     uuid.UUID `json:"user_id"`
+	ID         uuid.UUID `json:"id"`
+	ArtifactID uuid.UUID `json:"artifact_id"`
+}
+
+// ReuseReportArtifactTx completes the report with an artifact built for an earlier report.
+// It returns sql.ErrNoRows when the artifact is being deleted, or the report was cancelled or has
+// already been completed.
+func (store *SQLStore) ReuseReportArtifactTx(ctx context.Context, arg ReuseReportArtifactTxParams) (CompleteReportTxResult, error) {
+	var result CompleteReportTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.Artifact, err = q.AcquireReportArtifact(ctx, arg.ArtifactID)
+		if err != nil {
+			return err
+		}
+
+		result.Report, err = q.CompleteReport(ctx, CompleteReportParams{
+			UserID:         arg.UserID,
+			ID:             arg.ID,
+			OutputFilePath: sql.NullString{String: result.Artifact.ObjectKey, Valid: true},
+			ArtifactID:     uuid.NullUUID{UUID: result.Artifact.ID, Valid: true},
+		})
+		return err
+	})
+
+	return result, err
+}
+
+type DeleteReportTxParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	ID     uuid.UUID `json:"id"`
+}
+
+type DeleteReportTxResult struct {
+	Report Report `json:"report"`
+	// OrphanedObjectKey is the stored object no report refers to anymore, if any.
+	OrphanedObjectKey string `json:"orphaned_object_key"`
+}
+
+// DeleteReportTx deletes a report and releases its artifact. The artifact is deleted once
+// no report refers to it; removing its object is left to the caller after the commit.
+func (store *SQLStore) DeleteReportTx(ctx context.Context, arg DeleteReportTxParams) (DeleteReportTxResult, error) {
+	var result DeleteReportTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.Report, err = q.DeleteReportReturning(ctx, DeleteReportReturningParams{
+			UserID: arg.UserID,
+			ID:     arg.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		if !result.Report.ArtifactID.Valid {
+			// reports built before artifacts existed own their object
+			result.OrphanedObjectKey = result.Report.OutputFilePath.String
+			return nil
+		}
+
+		artifact, err := q.ReleaseReportArtifact(ctx, result.Report.ArtifactID.UUID)
+		if err != nil {
+			return err
+		}
+		if artifact.RefCount > 0 {
+			return nil
+		}
+
+		if err := q.DeleteReportArtifact(ctx, artifact.ID); err != nil {
+			return err
+		}
+		result.OrphanedObjectKey = artifact.ObjectKey
+		return nil
+	})
+
+	return result, err
+}
+
+type RunDueSchedulesTxParams struct {
+	// LockID is the advisory lock that keeps concurrent schedulers from running the same schedules.
+	LockID int64     `json:"lock_id"`
+	Now    time.Time `json:"now"`
+	Limit  int32     `json:"limit"`
+	// Plan returns the report to create for a due schedule and the time the schedule runs next.
+	Plan func(schedule ReportSchedule) (CreateReportParams, time.Time, error) `json:"-"`
+}
+
+type RunDueSchedulesTxResult struct {
+	// Locked is false when another scheduler holds the lock; nothing was run then.
+	Locked  bool     `json:"locked"`
+	Reports []Report `json:"reports"`
+	// Errors holds the schedules that could not be planned. They are disabled with the error as their
+	// last_error, since they would fail the same way on every run.
+	Errors map[uuid.UUID]error `json:"-"`
+}
+
+// RunDueSchedulesTx creates a report for every due schedule and moves the schedules to their next run.
+func (store *SQLStore) RunDueSchedulesTx(ctx context.Context, arg RunDueSchedulesTxParams) (RunDueSchedulesTxResult, error) {
+	var result RunDueSchedulesTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		// the lock is released when the transaction ends
+		result.Locked, err = q.TryAdvisoryXactLock(ctx, arg.LockID)
+		if err != nil || !result.Locked {
+			return err
+		}
+
+		schedules, err := q.ListDueReportSchedules(ctx, ListDueReportSchedulesParams{
+			Now:   arg.Now,
+			Limit: arg.Limit,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, schedule := range schedules {
+			reportArg, nextRunAt, err := arg.Plan(schedule)
+			if err != nil {
+				if result.Errors == nil {
+					result.Errors = make(map[uuid.UUID]error)
+				}
+				result.Errors[schedule.ID] = err
+				_, err = q.DisableReportSchedule(ctx, DisableReportScheduleParams{
+					ID:        schedule.ID,
+					LastError: sql.NullString{String: err.Error(), Valid: true},
+				})
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			report, err := q.CreateReport(ctx, reportArg)
+			if err != nil {
+				return err
+			}
+
+			_, err = q.MarkReportScheduleRun(ctx, MarkReportScheduleRunParams{
+				ID:           schedule.ID,
+				LastRunAt:    sql.NullTime{Time: arg.Now, Valid: true},
+				LastReportID: uuid.NullUUID{UUID: report.ID, Valid: true},
+				NextRunAt:    nextRunAt,
+			})
+			if err != nil {
+				return err
+			}
+
+			result.Reports = append(result.Reports, report)
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+// SecurityEventRefreshTokenReuse is recorded when a refresh token is presented after it was rotated.
+const SecurityEventRefreshTokenReuse = "refresh_token_reuse"
+
+// SecurityEventRoleChanged is recorded when an admin changes the role of a user.
+const SecurityEventRoleChanged = "role_changed"
+
+// SecurityEventPasswordReset is recorded when a user resets a forgotten password.
+const SecurityEventPasswordReset = "password_reset"
+
+// Security events of multi-factor authentication. A used recovery code is recorded because it
+// usually means the user lost their authenticator.
+const (
+	SecurityEventMfaEnabled          = "mfa_enabled"
+	SecurityEventMfaDisabled         = "mfa_disabled"
+	SecurityEventMfaRecoveryCodeUsed = "mfa_recovery_code_used"
+)
+
+// Purposes of account tokens, which are emailed to the user and can be used once.
+const (
+	AccountTokenEmailVerification = "email_verification"
+	AccountTokenPasswordReset     = "password_reset"
+)
+
+// Sign-in lockouts, recorded when too many sign-ins fail and when an admin lifts one.
+// Share link lockouts are recorded for the owner of the link.
+const (
+	SecurityEventLoginLocked   = "login_locked"
+	SecurityEventLoginUnlocked = "login_unlocked"
+	SecurityEventShareLocked   = "share_locked"
+)
+
+// Scopes of login throttles: failed sign-ins are counted per account, keyed by the lowercased
+// email, and per client IP. Wrong share link passwords are counted per share link, keyed by its
+// ID, and per client IP.
+const (
+	LoginThrottleAccount = "account"
+	LoginThrottleIP      = "ip"
+	LoginThrottleShare   = "share"
+	LoginThrottleShareIP = "share_ip"
+)
+
+// Roles of users. Auditors can read everything admins can, but change nothing.
+const (
+	UserRoleUser    = "user"
+	UserRoleAdmin   = "admin"
+	UserRoleAuditor = "auditor"
+)
+
+// userRoleRank orders the user roles by what they grant.
+var userRoleRank = map[string]int{
+	UserRoleUser:    0,
+	UserRoleAuditor: 1,
+	UserRoleAdmin:   2,
+}
+
+// Roles of organization members. Every member can see the organization's reports, templates and
+// schedules; owners and admins can also change ones other members created and manage members.
+const (
+	OrganizationRoleOwner  = "owner"
+	OrganizationRoleAdmin  = "admin"
+	OrganizationRoleMember = "member"
+)
+
+// Revoke reasons of sessions ended by their user, or because the role or password of their user changed.
+const (
+	SessionRevokedByUser = "user_revoked"
+	SessionLoggedOut     = "logout"
+	SessionRoleChanged   = "role_changed"
+	SessionPasswordReset = "password_reset"
+)
+
+// Kinds of denied access tokens: a single token by its jti, or every token of a session by its sid.
+const (
+	DeniedTokenKindToken   = "jti"
+	DeniedTokenKindSession = "sid"
+)
+
+type CreateSessionTxParams struct {
+	Session     CreateSessionParams `json:"session"`
+	HashedToken string              `json:"hashed_token"`
+}
+
+type CreateSessionTxResult struct {
+	Session      Session      `json:"session"`
+	RefreshToken RefreshToken `json:"refresh_token"`
+}
+
+// CreateSessionTx starts a session with the first refresh token of its chain.
+func (store *SQLStore) CreateSessionTx(ctx context.Context, arg CreateSessionTxParams) (CreateSessionTxResult, error) {
+	var result CreateSessionTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.Session, err = q.CreateSession(ctx, arg.Session)
+		if err != nil {
+			return err
+		}
+
+		result.RefreshToken, err = q.CreateRefreshToken(ctx, CreateRefreshTokenParams{
+			HashedToken: arg.HashedToken,
+			UserID:      result.Session.UserID,
+			ExpiresAt:   result.Session.ExpiresAt,
+			SessionID:   result.Session.ID,
+		})
+		return err
+	})
+
+	return result, err
+}
+
+type RotateRefreshTokenTxParams struct {
+	UserID    uuid.UUID `json:"user_id"`
+	SessionID uuid.UUID `json:"session_id"`
+	// HashedToken is the presented token, NewHashedToken the one replacing it.
+	HashedToken    string    `json:"hashed_token"`
+	NewHashedToken string    `json:"new_hashed_token"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	IpAddress      string    `json:"ip_address"`
+	UserAgent      string    `json:"user_agent"`
+	// DenyUntil is when the access tokens of a session revoked for reuse have all expired.
+	DenyUntil time.Time `json:"deny_until"`
+}
+
+type RotateRefreshTokenTxResult struct {
+	Token   RefreshToken `json:"token"`
+	Session Session      `json:"session"`
+	// Reused is set when the presented token had already been rotated. The whole session
+	// is revoked and a security event recorded instead of issuing a new token.
+	Reused bool `json:"reused"`
+}
+
+// RotateRefreshTokenTx exchanges a refresh token for the next one of its session and marks the session used.
+// It returns sql.ErrNoRows when the token is unknown, expired, belongs to another user or session,
+// or its session was revoked.
+func (store *SQLStore) RotateRefreshTokenTx(ctx context.Context, arg RotateRefreshTokenTxParams) (RotateRefreshTokenTxResult, error) {
+	var result RotateRefreshTokenTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		current, err := q.GetRefreshTokenForUpdate(ctx, arg.HashedToken)
+		if err != nil {
+			return err
+		}
+		if current.UserID != arg.UserID || current.SessionID != arg.SessionID {
+			return sql.ErrNoRows
+		}
+
+		session, err := q.GetSession(ctx, current.SessionID)
+		if err != nil {
+			return err
+		}
+		if session.RevokedAt.Valid {
+			return sql.ErrNoRows
+		}
+
+		if current.RotatedAt.Valid {
+			result.Reused = true
+			return revokeReusedSession(ctx, q, current, arg)
+		}
+		if !current.ExpiresAt.After(time.Now()) {
+			return sql.ErrNoRows
+		}
+
+		if _, err := q.MarkRefreshTokenRotated(ctx, current.HashedToken); err != nil {
+			return err
+		}
+
+		result.Token, err = q.CreateRefreshToken(ctx, CreateRefreshTokenParams{
+			HashedToken: arg.NewHashedToken,
+			UserID:      current.UserID,
+			ExpiresAt:   arg.ExpiresAt,
+			SessionID:   current.SessionID,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Session, err = q.TouchSession(ctx, TouchSessionParams{
+			ID:        session.ID,
+			IpAddress: arg.IpAddress,
+			UserAgent: arg.UserAgent,
+			ExpiresAt: arg.ExpiresAt,
+		})
+		return err
+	})
+
+	return result, err
+}
+
+// revokeReusedSession revokes the session of a rotated token that was presented again and records why.
+func revokeReusedSession(ctx context.Context, q *Queries, token RefreshToken, arg RotateRefreshTokenTxParams) error {
+	if _, err := q.RevokeSession(ctx, RevokeSessionParams{
+		UserID:       token.UserID,
+		ID:           token.SessionID,
+		RevokeReason: sql.NullString{String: SecurityEventRefreshTokenReuse, Valid: true},
+	}); err != nil {
+		return err
+	}
+
+	if err := q.DenyToken(ctx, DenyTokenParams{
+		Kind:      DeniedTokenKindSession,
+		TokenID:   token.SessionID.String(),
+		UserID:    token.UserID,
+		ExpiresAt: arg.DenyUntil,
+	}); err != nil {
+		return err
+	}
+
+	details, err := json.Marshal(map[string]any{
+		"session_id": token.SessionID,
+		"issued_at":  token.CreatedAt,
+		"rotated_at": token.RotatedAt.Time,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = q.CreateSecurityEvent(ctx, CreateSecurityEventParams{
+		UserID:    uuid.NullUUID{UUID: token.UserID, Valid: true},
+		EventType: SecurityEventRefreshTokenReuse,
+		IpAddress: arg.IpAddress,
+		UserAgent: arg.UserAgent,
+		Details:   details,
+	})
+	return err
+}
+
+type RevokeSessionsTxParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	// SessionID revokes a single session, AllSessions every session of the user.
+	SessionID   uuid.NullUUID `json:"session_id"`
+	AllSessions bool          `json:"all_sessions"`
+	Reason      string        `json:"reason"`
+	// DenyUntil is when the access tokens of the revoked sessions have all expired.
+	DenyUntil time.Time `json:"deny_until"`
+	// TokenID optionally denies one more access token until TokenExpiresAt.
+	TokenID        string    `json:"token_id"`
+	TokenExpiresAt time.Time `json:"token_expires_at"`
+}
+
+type RevokeSessionsTxResult struct {
+	SessionIDs []uuid.UUID `json:"session_ids"`
+}
+
+// RevokeSessionsTx revokes sessions and denies the access tokens they issued, so the refresh
+// and access tokens of the sessions stop working together.
+func (store *SQLStore) RevokeSessionsTx(ctx context.Context, arg RevokeSessionsTxParams) (RevokeSessionsTxResult, error) {
+	var result RevokeSessionsTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		result.SessionIDs, err = revokeSessions(ctx, q, arg)
+		return err
+	})
+
+	return result, err
+}
+
+// revokeSessions revokes the sessions named by arg and denies their access tokens.
+func revokeSessions(ctx context.Context, q *Queries, arg RevokeSessionsTxParams) ([]uuid.UUID, error) {
+	var sessionIDs []uuid.UUID
+	reason := sql.NullString{String: arg.Reason, Valid: true}
+	if arg.SessionID.Valid {
+		revoked, err := q.RevokeSession(ctx, RevokeSessionParams{
+			UserID:       arg.UserID,
+			ID:           arg.SessionID.UUID,
+			RevokeReason: reason,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if revoked > 0 {
+			sessionIDs = append(sessionIDs, arg.SessionID.UUID)
+		}
+	} else if arg.AllSessions {
+		sessions, err := q.RevokeUserSessions(ctx, RevokeUserSessionsParams{
+			UserID:       arg.UserID,
+			RevokeReason: reason,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, session := range sessions {
+			sessionIDs = append(sessionIDs, session.ID)
+		}
+	}
+
+	for _, sessionID := range sessionIDs {
+		if err := q.DenyToken(ctx, DenyTokenParams{
+			Kind:      DeniedTokenKindSession,
+			TokenID:   sessionID.String(),
+			UserID:    arg.UserID,
+			ExpiresAt: arg.DenyUntil,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if arg.TokenID == "" {
+		return sessionIDs, nil
+	}
+	return sessionIDs, q.DenyToken(ctx, DenyTokenParams{
+		Kind:      DeniedTokenKindToken,
+		TokenID:   arg.TokenID,
+		UserID:    arg.UserID,
+		ExpiresAt: arg.TokenExpiresAt,
+	})
+}
+
+type ChangeUserRoleTxParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	Role   string    `json:"role"`
+	// ChangedBy is the admin making the change.
+	ChangedBy uuid.UUID `json:"changed_by"`
+	IpAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	// DenyUntil is when the access tokens of the user's sessions have all expired.
+	DenyUntil time.Time `json:"deny_until"`
+}
+
+type ChangeUserRoleTxResult struct {
+	User User `json:"user"`
+	// SessionIDs are the revoked sessions, only set when the role was lowered.
+	SessionIDs []uuid.UUID `json:"session_ids"`
+}
+
+// ChangeUserRoleTx changes the role of a user and records who changed it. When the role is lowered,
+// it also revokes the user's sessions, so no token keeps granting the old role. A raised role reaches
+// the tokens with the next refresh. It returns sql.ErrNoRows for unknown users.
+func (store *SQLStore) ChangeUserRoleTx(ctx context.Context, arg ChangeUserRoleTxParams) (ChangeUserRoleTxResult, error) {
+	var result ChangeUserRoleTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		previous, err := q.FindUserById(ctx, arg.UserID)
+		if err != nil {
+			return err
+		}
+
+		result.User, err = q.UpdateUserRole(ctx, UpdateUserRoleParams{
+			ID:   arg.UserID,
+			Role: arg.Role,
+		})
+		if err != nil {
+			return err
+		}
+
+		details, err := json.Marshal(map[string]any{
+			"from":       previous.Role,
+			"to":         arg.Role,
+			"changed_by": arg.ChangedBy,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := q.CreateSecurityEvent(ctx, CreateSecurityEventParams{
+			UserID:    uuid.NullUUID{UUID: arg.UserID, Valid: true},
+			EventType: SecurityEventRoleChanged,
+			IpAddress: arg.IpAddress,
+			UserAgent: arg.UserAgent,
+			Details:   details,
+		}); err != nil {
+			return err
+		}
+
+		if userRoleRank[arg.Role] >= userRoleRank[previous.Role] {
+			return nil
+		}
+		result.SessionIDs, err = revokeSessions(ctx, q, RevokeSessionsTxParams{
+			UserID:      arg.UserID,
+			AllSessions: true,
+			Reason:      SessionRoleChanged,
+			DenyUntil:   arg.DenyUntil,
+		})
+		return err
+	})
+
+	return result, err
+}
+
+type CreateOrganizationTxParams struct {
+	Name    string    `json:"name"`
+	OwnerID uuid.UUID `json:"owner_id"`
+}
+
+type CreateOrganizationTxResult struct {
+	Organization Organization       `json:"organization"`
+	Owner        OrganizationMember `json:"owner"`
+}
+
+// CreateOrganizationTx creates an organization with its creator as the first owner.
+func (store *SQLStore) CreateOrganizationTx(ctx context.Context, arg CreateOrganizationTxParams) (CreateOrganizationTxResult, error) {
+	var result CreateOrganizationTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.Organization, err = q.CreateOrganization(ctx, arg.Name)
+		if err != nil {
+			return err
+		}
+
+		result.Owner, err = q.AddOrganizationMember(ctx, AddOrganizationMemberParams{
+			OrganizationID: result.Organization.ID,
+			UserID:         arg.OwnerID,
+			Role:           OrganizationRoleOwner,
+		})
+		return err
+	})
+
+	return result, err
+}
+
+type UpdateOrganizationMemberRoleTxResult struct {
+	Member OrganizationMember `json:"member"`
+	// LastOwner is set when the role wasn't changed because the member is the only owner.
+	LastOwner bool `json:"last_owner"`
+}
+
+// UpdateOrganizationMemberRoleTx changes the role of a member unless that leaves the organization
+// without an owner. The owners are locked while it checks, so two owners demoting each other at
+// the same time can't both succeed. It returns sql.ErrNoRows when there is no such member.
+func (store *SQLStore) UpdateOrganizationMemberRoleTx(ctx context.Context, arg UpdateOrganizationMemberRoleParams) (UpdateOrganizationMemberRoleTxResult, error) {
+	var result UpdateOrganizationMemberRoleTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		if arg.Role != OrganizationRoleOwner {
+			lastOwner, err := isLastOwner(ctx, q, arg.OrganizationID, arg.UserID)
+			if err != nil || lastOwner {
+				result.LastOwner = lastOwner
+				return err
+			}
+		}
+
+		var err error
+		result.Member, err = q.UpdateOrganizationMemberRole(ctx, arg)
+		return err
+	})
+
+	return result, err
+}
+
+type RemoveOrganizationMemberTxResult struct {
+	Removed bool `json:"removed"`
+	// LastOwner is set when the member wasn't removed because they are the only owner.
+	LastOwner bool `json:"last_owner"`
+}
+
+// RemoveOrganizationMemberTx removes a member unless that leaves the organization without an
+// owner, locking the owners like UpdateOrganizationMemberRoleTx.
+func (store *SQLStore) RemoveOrganizationMemberTx(ctx context.Context, arg RemoveOrganizationMemberParams) (RemoveOrganizationMemberTxResult, error) {
+	var result RemoveOrganizationMemberTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		lastOwner, err := isLastOwner(ctx, q, arg.OrganizationID, arg.UserID)
+		if err != nil || lastOwner {
+			result.LastOwner = lastOwner
+			return err
+		}
+
+		removed, err := q.RemoveOrganizationMember(ctx, arg)
+		result.Removed = removed > 0
+		return err
+	})
+
+	return result, err
+}
+
+// isLastOwner locks the owners of the organization and reports whether userId is the only one.
+func isLastOwner(ctx context.Context, q *Queries, organizationId, userId uuid.UUID) (bool, error) {
+	owners, err := q.LockOrganizationOwners(ctx, organizationId)
+	if err != nil {
+		return false, err
+	}
+	return len(owners) == 1 && owners[0] == userId, nil
+}
+
+type AcceptOrganizationInvitationTxParams struct {
+	ID     uuid.UUID `json:"id"`
+	Email  string    `json:"email"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+// AcceptOrganizationInvitationTx uses up an invitation sent to email and adds the user to its
+// organization with the invited role. It returns sql.ErrNoRows when there is no such invitation
+// or it has expired.
+func (store *SQLStore) AcceptOrganizationInvitationTx(ctx context.Context, arg AcceptOrganizationInvitationTxParams) (OrganizationMember, error) {
+	var member OrganizationMember
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		invitation, err := q.TakeOrganizationInvitation(ctx, TakeOrganizationInvitationParams{
+			ID:    arg.ID,
+			Email: arg.Email,
+		})
+		if err != nil {
+			return err
+		}
+
+		member, err = q.AddOrganizationMember(ctx, AddOrganizationMemberParams{
+			OrganizationID: invitation.OrganizationID,
+			UserID:         arg.UserID,
+			Role:           invitation.Role,
+		})
+		return err
+	})
+
+	return member, err
+}
+
+// IssueAccountTokenTx creates an account token and invalidates the unused tokens of the same
+// purpose, so only the most recently emailed link works.
+func (store *SQLStore) IssueAccountTokenTx(ctx context.Context, arg CreateAccountTokenParams) (AccountToken, error) {
+	var token AccountToken
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		if err := q.InvalidateAccountTokens(ctx, InvalidateAccountTokensParams{
+			UserID:  arg.UserID,
+			Purpose: arg.Purpose,
+		}); err != nil {
+			return err
+		}
+
+		var err error
+		token, err = q.CreateAccountToken(ctx, arg)
+		return err
+	})
+
+	return token, err
+}
+
+// useAccountToken marks an account token used. It returns sql.ErrNoRows when the token is
+// unknown, has another purpose, expired or was already used.
+func useAccountToken(ctx context.Context, q *Queries, hashedToken, purpose string) (AccountToken, error) {
+	token, err := q.GetAccountTokenForUpdate(ctx, GetAccountTokenForUpdateParams{
+		HashedToken: hashedToken,
+		Purpose:     purpose,
+	})
+	if err != nil {
+		return AccountToken{}, err
+	}
+	if token.UsedAt.Valid || !token.ExpiresAt.After(time.Now()) {
+		return AccountToken{}, sql.ErrNoRows
+	}
+
+	if _, err := q.MarkAccountTokenUsed(ctx, hashedToken); err != nil {
+		return AccountToken{}, err
+	}
+	return token, nil
+}
+
+// VerifyEmailTx uses an email verification token and marks the email of its user verified.
+// It returns sql.ErrNoRows when the token can't be used.
+func (store *SQLStore) VerifyEmailTx(ctx context.Context, hashedToken string) (User, error) {
+	var user User
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		token, err := useAccountToken(ctx, q, hashedToken, AccountTokenEmailVerification)
+		if err != nil {
+			return err
+		}
+
+		user, err = q.MarkUserEmailVerified(ctx, token.UserID)
+		return err
+	})
+
+	return user, err
+}
+
+type ResetPasswordTxParams struct {
+	HashedToken    string `json:"hashed_token"`
+	HashedPassword string `json:"hashed_password"`
+	IpAddress      string `json:"ip_address"`
+	UserAgent      string `json:"user_agent"`
+	// DenyUntil is when the access tokens of the user's sessions have all expired.
+	DenyUntil time.Time `json:"deny_until"`
+}
+
+type ResetPasswordTxResult struct {
+	User       User        `json:"user"`
+	SessionIDs []uuid.UUID `json:"session_ids"`
+}
+
+// ResetPasswordTx uses a password reset token to set a new password and revokes every session
+// of the user, so whoever knew the old password is signed out. Receiving the reset email also
+// proves the user owns the address, so it is marked verified.
+// It returns sql.ErrNoRows when the token can't be used.
+func (store *SQLStore) ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (ResetPasswordTxResult, error) {
+	var result ResetPasswordTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		token, err := useAccountToken(ctx, q, arg.HashedToken, AccountTokenPasswordReset)
+		if err != nil {
+			return err
+		}
+
+		if _, err := q.UpdateUserPassword(ctx, UpdateUserPasswordParams{
+			ID:             token.UserID,
+			HashedPassword: arg.HashedPassword,
+		}); err != nil {
+			return err
+		}
+		result.User, err = q.MarkUserEmailVerified(ctx, token.UserID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := q.CreateSecurityEvent(ctx, CreateSecurityEventParams{
+			UserID:    uuid.NullUUID{UUID: token.UserID, Valid: true},
+			EventType: SecurityEventPasswordReset,
+			IpAddress: arg.IpAddress,
+			UserAgent: arg.UserAgent,
+			Details:   json.RawMessage(`{}`),
+		}); err != nil {
+			return err
+		}
+
+		result.SessionIDs, err = revokeSessions(ctx, q, RevokeSessionsTxParams{
+			UserID:      token.UserID,
+			AllSessions: true,
+			Reason:      SessionPasswordReset,
+			DenyUntil:   arg.DenyUntil,
+		})
+		return err
+	})
+
+	return result, err
+}
+
+type EnableMfaTxParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	// Step is the time step of the code that confirmed the enrollment, so it can't sign in again.
+	Step                int64    `json:"step"`
+	HashedRecoveryCodes []string `json:"hashed_recovery_codes"`
+	IpAddress           string   `json:"ip_address"`
+	UserAgent           string   `json:"user_agent"`
+}
+
+// EnableMfaTx turns on MFA with the secret the user enrolled, replaces their recovery codes and
+// records the change. It returns sql.ErrNoRows when the user has no pending enrollment.
+func (store *SQLStore) EnableMfaTx(ctx context.Context, arg EnableMfaTxParams) (User, error) {
+	var user User
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		user, err = q.EnableUserMfa(ctx, EnableUserMfaParams{
+			ID:              arg.UserID,
+			MfaLastUsedStep: arg.Step,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := replaceMfaRecoveryCodes(ctx, q, arg.UserID, arg.HashedRecoveryCodes); err != nil {
+			return err
+		}
+
+		_, err = q.CreateSecurityEvent(ctx, CreateSecurityEventParams{
+			UserID:    uuid.NullUUID{UUID: arg.UserID, Valid: true},
+			EventType: SecurityEventMfaEnabled,
+			IpAddress: arg.IpAddress,
+			UserAgent: arg.UserAgent,
+			Details:   json.RawMessage(`{}`),
+		})
+		return err
+	})
+
+	return user, err
+}
+
+type DisableMfaTxParams struct {
+	UserID    uuid.UUID `json:"user_id"`
+	IpAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+}
+
+// DisableMfaTx turns off MFA, forgetting the secret and the recovery codes, and records the change.
+func (store *SQLStore) DisableMfaTx(ctx context.Context, arg DisableMfaTxParams) (User, error) {
+	var user User
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		user, err = q.DisableUserMfa(ctx, arg.UserID)
+		if err != nil {
+			return err
+		}
+
+		if err := q.DeleteMfaRecoveryCodes(ctx, arg.UserID); err != nil {
+			return err
+		}
+
+		_, err = q.CreateSecurityEvent(ctx, CreateSecurityEventParams{
+			UserID:    uuid.NullUUID{UUID: arg.UserID, Valid: true},
+			EventType: SecurityEventMfaDisabled,
+			IpAddress: arg.IpAddress,
+			UserAgent: arg.UserAgent,
+			Details:   json.RawMessage(`{}`),
+		})
+		return err
+	})
+
+	return user, err
+}
+
+type ReplaceMfaRecoveryCodesTxParams struct {
+	UserID              uuid.UUID `json:"user_id"`
+	HashedRecoveryCodes []string  `json:"hashed_recovery_codes"`
+}
+
+// ReplaceMfaRecoveryCodesTx swaps every recovery code of the user, used or not, for new ones.
+func (store *SQLStore) ReplaceMfaRecoveryCodesTx(ctx context.Context, arg ReplaceMfaRecoveryCodesTxParams) error {
+	return store.execTx(ctx, func(q *Queries) error {
+		return replaceMfaRecoveryCodes(ctx, q, arg.UserID, arg.HashedRecoveryCodes)
+	})
+}
+
+func replaceMfaRecoveryCodes(ctx context.Context, q *Queries, userID uuid.UUID, hashedCodes []string) error {
+	if err := q.DeleteMfaRecoveryCodes(ctx, userID); err != nil {
+		return err
+	}
+	for _, hashedCode := range hashedCodes {
+		if err := q.CreateMfaRecoveryCode(ctx, CreateMfaRecoveryCodeParams{
+			UserID:     userID,
+			HashedCode: hashedCode,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type ReserveLoginAttemptTxParams struct {
+	Scope string `json:"scope"`
+	Key   string `json:"key"`
+	// Reserve decides from the counter, locked until the transaction ends, whether the attempt can be
+	// made. It returns the counter with the attempt counted.
+	Reserve func(throttle LoginThrottle) (LoginThrottle, bool) `json:"-"`
+}
+
+type ReserveLoginAttemptTxResult struct {
+	// Reserved is false when the attempt was refused; Throttle is the unchanged counter then.
+	Reserved bool          `json:"reserved"`
+	Throttle LoginThrottle `json:"throttle"`
+}
+
+// ReserveLoginAttemptTx counts an attempt before it is made. The counter is locked while Reserve
+// decides, so parallel attempts see each other's reservations.
+func (store *SQLStore) ReserveLoginAttemptTx(ctx context.Context, arg ReserveLoginAttemptTxParams) (ReserveLoginAttemptTxResult, error) {
+	var result ReserveLoginAttemptTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		// the row is created first, so even the first attempts against a key wait on its lock
+		err := q.CreateLoginThrottle(ctx, CreateLoginThrottleParams{
+			Scope: arg.Scope,
+			Key:   arg.Key,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Throttle, err = q.GetLoginThrottleForUpdate(ctx, GetLoginThrottleForUpdateParams{
+			Scope: arg.Scope,
+			Key:   arg.Key,
+		})
+		if err != nil {
+			return err
+		}
+
+		reserved, ok := arg.Reserve(result.Throttle)
+		if !ok {
+			return nil
+		}
+		result.Reserved = true
+		result.Throttle, err = q.UpdateLoginThrottle(ctx, UpdateLoginThrottleParams{
+			Scope:         arg.Scope,
+			Key:           arg.Key,
+			Failures:      reserved.Failures,
+			LastFailedAt:  reserved.LastFailedAt,
+			NextAttemptAt: reserved.NextAttemptAt,
+			LockedUntil:   reserved.LockedUntil,
+		})
+		return err
+	})
+
+	return result, err
+}
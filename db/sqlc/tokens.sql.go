@@ -15,25 +15,34 @@ import (
 const createRefreshToken = `-- name: CreateRefreshToken :one
 INSERT INTO refresh_tokens (hashed_token,
                             user_id,
-                            expires_at)
-VALUES ($1, $2, $3)
-RETURNING user_id, hashed_token, created_at, expires_at
+                            expires_at,
+                            session_id)
+VALUES ($1, $2, $3, $4)
+RETURNING user_id, hashed_token, created_at, expires_at, session_id, rotated_at
 `
 
 type CreateRefreshTokenParams struct {
 	HashedToken string    `json:"hashed_token"`
 	UserID      uuid.UUID `json:"user_id"`
 	ExpiresAt   time.Time `json:"expires_at"`
+	SessionID   uuid.UUID `json:"session_id"`
 }
 
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, createRefreshToken, arg.HashedToken, arg.UserID, arg.ExpiresAt)
+	row := q.db.QueryRowContext(ctx, createRefreshToken,
+		arg.HashedToken,
+		arg.UserID,
+		arg.ExpiresAt,
+		arg.SessionID,
+	)
 	var i RefreshToken
 	err := row.Scan(
 		&i.UserID,
 		&i.HashedToken,
 		&i.CreatedAt,
 		&i.ExpiresAt,
+		&i.SessionID,
+		&i.RotatedAt,
 	)
 	return i, err
 }
@@ -87,7 +96,7 @@ func (q *Queries) DeleteUserRefreshToken(ctx context.Context, arg DeleteUserRefr
 }
 
 const getRefreshToken = `-- name: GetRefreshToken :one
-SELECT user_id, hashed_token, created_at, expires_at
+SELECT user_id, hashed_token, created_at, expires_at, session_id, rotated_at
 FROM refresh_tokens
 WHERE hashed_token = $1
   AND expires_at > NOW()
@@ -102,12 +111,35 @@ func (q *Queries) GetRefreshToken(ctx context.Context, hashedToken string) (Refr
 		&i.HashedToken,
 		&i.CreatedAt,
 		&i.ExpiresAt,
+		&i.SessionID,
+		&i.RotatedAt,
+	)
+	return i, err
+}
+
+const getRefreshTokenForUpdate = `-- name: GetRefreshTokenForUpdate :one
+SELECT user_id, hashed_token, created_at, expires_at, session_id, rotated_at
+FROM refresh_tokens
+WHERE hashed_token = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetRefreshTokenForUpdate(ctx context.Context, hashedToken string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getRefreshTokenForUpdate, hashedToken)
+	var i RefreshToken
+	err := row.Scan(
+		&i.UserID,
+		&i.HashedToken,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.SessionID,
+		&i.RotatedAt,
 	)
 	return i, err
 }
 
 const getTokenByPrimaryKey = `-- name: GetTokenByPrimaryKey :one
-SELECT user_id, hashed_token, created_at, expires_at
+SELECT user_id, hashed_token, created_at, expires_at, session_id, rotated_at
 FROM refresh_tokens
 WHERE user_id = $1
 `
@@ -120,15 +152,32 @@ func (q *Queries) GetTokenByPrimaryKey(ctx context.Context, userID uuid.UUID) (R
 		&i.HashedToken,
 		&i.CreatedAt,
 		&i.ExpiresAt,
+		&i.SessionID,
+		&i.RotatedAt,
 	)
 	return i, err
 }
 
+const markRefreshTokenRotated = `-- name: MarkRefreshTokenRotated :execrows
+UPDATE refresh_tokens
+SET rotated_at = NOW()
+WHERE hashed_token = $1
+  AND rotated_at IS NULL
+`
+
+func (q *Queries) MarkRefreshTokenRotated(ctx context.Context, hashedToken string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markRefreshTokenRotated, hashedToken)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const updateRefreshTokenExpiry = `-- name: UpdateRefreshTokenExpiry :one
 UPDATE refresh_tokens
 SET expires_at = $2
 WHERE hashed_token = $1
-RETURNING user_id, hashed_token, created_at, expires_at
+RETURNING user_id, hashed_token, created_at, expires_at, session_id, rotated_at
 `
 
 type UpdateRefreshTokenExpiryParams struct {
@@ -144,6 +193,8 @@ func (q *Queries) UpdateRefreshTokenExpiry(ctx context.Context, arg UpdateRefres
 		&i.HashedToken,
 		&i.CreatedAt,
 		&i.ExpiresAt,
+		&i.SessionID,
+		&i.RotatedAt,
 	)
 	return i, err
 }
@@ -2,9 +2,11 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 	"github.com/trenchesdeveloper/csv-reporter/helpers"
 )
@@ -25,6 +27,25 @@ func createRandomUser(t *testing.T) User {
 	return user
 }
 
+// createRandomSession is a helper function to start a session for a user
+func createRandomSession(t *testing.T, user User) Session {
+	arg := CreateSessionParams{
+		ID:         uuid.New(),
+		UserID:     user.ID,
+		DeviceName: helpers.RandomString(8),
+		UserAgent:  "csv-reporter-tests",
+		IpAddress:  "203.0.113.7",
+		ExpiresAt:  time.Now().Add(24 * time.Hour),
+	}
+
+	session, err := testStore.CreateSession(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.ID, session.ID)
+	require.Equal(t, arg.DeviceName, session.DeviceName)
+	require.False(t, session.RevokedAt.Valid)
+	return session
+}
+
 // createRandomRefreshToken is a helper function to create a random refresh token
 func createRandomRefreshToken(t *testing.T, user User) RefreshToken {
 	// Generate random token and hash it
@@ -37,6 +58,7 @@ func createRandomRefreshToken(t *testing.T, user User) RefreshToken {
 		HashedToken: hashedToken,
 		UserID:      user.ID,
 		ExpiresAt:   expiryTime,
+		SessionID:   createRandomSession(t, user).ID,
 	}
 
 	token, err := testStore.CreateRefreshToken(context.Background(), arg)
@@ -55,6 +77,7 @@ func TestCreateRefreshToken(t *testing.T) {
 		HashedToken: hashedToken,
 		UserID:      user.ID,
 		ExpiresAt:   expiryTime,
+		SessionID:   createRandomSession(t, user).ID,
 	}
 
 	token, err := testStore.CreateRefreshToken(context.Background(), arg)
@@ -64,8 +87,10 @@ func TestCreateRefreshToken(t *testing.T) {
 	// Verify the token properties
 	require.Equal(t, arg.HashedToken, token.HashedToken)
 	require.Equal(t, arg.UserID, token.UserID)
+	require.Equal(t, arg.SessionID, token.SessionID)
 	require.WithinDuration(t, arg.ExpiresAt, token.ExpiresAt, time.Second)
 	require.NotZero(t, token.CreatedAt)
+	require.False(t, token.RotatedAt.Valid)
 }
 
 func TestGetRefreshToken(t *testing.T) {
@@ -170,6 +195,7 @@ func TestDeleteExpiredRefreshTokens(t *testing.T) {
 		HashedToken: hashedToken,
 		UserID:      user.ID,
 		ExpiresAt:   expiredTime,
+		SessionID:   createRandomSession(t, user).ID,
 	}
 
 	expiredToken, err := testStore.CreateRefreshToken(context.Background(), expiredArg)
@@ -191,3 +217,167 @@ func TestDeleteExpiredRefreshTokens(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, validToken.HashedToken, validCheck.HashedToken)
 }
+
+// createRandomSessionTx is a helper function to sign in a user with a refresh token expiring at expiresAt
+func createRandomSessionTx(t *testing.T, user User, expiresAt time.Time) CreateSessionTxResult {
+	result, err := testStore.CreateSessionTx(context.Background(), CreateSessionTxParams{
+		Session: CreateSessionParams{
+			ID:         uuid.New(),
+			UserID:     user.ID,
+			DeviceName: "laptop",
+			ExpiresAt:  expiresAt,
+		},
+		HashedToken: helpers.RandomString(32),
+	})
+	require.NoError(t, err)
+	require.Equal(t, result.Session.ID, result.RefreshToken.SessionID)
+	return result
+}
+
+func TestRotateRefreshTokenTx(t *testing.T) {
+	user := createRandomUser(t)
+	first := createRandomSessionTx(t, user, time.Now().Add(time.Hour))
+
+	arg := RotateRefreshTokenTxParams{
+		UserID:         user.ID,
+		SessionID:      first.Session.ID,
+		HashedToken:    first.RefreshToken.HashedToken,
+		NewHashedToken: helpers.RandomString(32),
+		ExpiresAt:      time.Now().Add(2 * time.Hour),
+		IpAddress:      "198.51.100.4",
+		UserAgent:      "curl/8.0",
+	}
+	result, err := testStore.RotateRefreshTokenTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.False(t, result.Reused)
+	require.Equal(t, arg.NewHashedToken, result.Token.HashedToken)
+	require.Equal(t, first.Session.ID, result.Token.SessionID)
+	require.Equal(t, arg.IpAddress, result.Session.IpAddress)
+	require.Equal(t, arg.UserAgent, result.Session.UserAgent)
+	require.WithinDuration(t, arg.ExpiresAt, result.Session.ExpiresAt, time.Second)
+	require.True(t, result.Session.LastUsedAt.After(first.Session.LastUsedAt))
+
+	// another user's token is rejected without touching the session
+	otherArg := arg
+	otherArg.UserID = createRandomUser(t).ID
+	otherArg.HashedToken = result.Token.HashedToken
+	otherArg.NewHashedToken = helpers.RandomString(32)
+	_, err = testStore.RotateRefreshTokenTx(context.Background(), otherArg)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// presenting the rotated token again revokes the whole session
+	arg.NewHashedToken = helpers.RandomString(32)
+	result, err = testStore.RotateRefreshTokenTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, result.Reused)
+
+	session, err := testStore.GetSession(context.Background(), first.Session.ID)
+	require.NoError(t, err)
+	require.True(t, session.RevokedAt.Valid)
+	require.Equal(t, SecurityEventRefreshTokenReuse, session.RevokeReason.String)
+
+	events, err := testStore.ListUserSecurityEvents(context.Background(), ListUserSecurityEventsParams{
+		UserID: uuid.NullUUID{UUID: user.ID, Valid: true},
+		Limit:  10,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, SecurityEventRefreshTokenReuse, events[0].EventType)
+	require.Equal(t, arg.IpAddress, events[0].IpAddress)
+	require.Equal(t, arg.UserAgent, events[0].UserAgent)
+
+	// so the newest token of the session no longer works either
+	arg.HashedToken = otherArg.HashedToken
+	arg.NewHashedToken = helpers.RandomString(32)
+	_, err = testStore.RotateRefreshTokenTx(context.Background(), arg)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestRotateExpiredRefreshToken(t *testing.T) {
+	user := createRandomUser(t)
+	expired := createRandomSessionTx(t, user, time.Now().Add(-time.Minute))
+
+	_, err := testStore.RotateRefreshTokenTx(context.Background(), RotateRefreshTokenTxParams{
+		UserID:         user.ID,
+		SessionID:      expired.Session.ID,
+		HashedToken:    expired.RefreshToken.HashedToken,
+		NewHashedToken: helpers.RandomString(32),
+		ExpiresAt:      time.Now().Add(time.Hour),
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestListActiveSessions(t *testing.T) {
+	user := createRandomUser(t)
+	laptop := createRandomSession(t, user)
+	phone := createRandomSession(t, user)
+	createRandomSessionTx(t, user, time.Now().Add(-time.Minute))
+
+	revoked, err := testStore.RevokeSession(context.Background(), RevokeSessionParams{
+		UserID:       user.ID,
+		ID:           phone.ID,
+		RevokeReason: sql.NullString{String: SessionRevokedByUser, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), revoked)
+
+	// revoking twice or as another user finds nothing
+	revoked, err = testStore.RevokeSession(context.Background(), RevokeSessionParams{UserID: user.ID, ID: phone.ID})
+	require.NoError(t, err)
+	require.Zero(t, revoked)
+	revoked, err = testStore.RevokeSession(context.Background(), RevokeSessionParams{UserID: createRandomUser(t).ID, ID: laptop.ID})
+	require.NoError(t, err)
+	require.Zero(t, revoked)
+
+	sessions, err := testStore.ListActiveSessions(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	require.Equal(t, laptop.ID, sessions[0].ID)
+}
+
+func TestRevokeSessionsTx(t *testing.T) {
+	user := createRandomUser(t)
+	current := createRandomSession(t, user)
+	other := createRandomSession(t, user)
+	denyUntil := time.Now().Add(15 * time.Minute)
+
+	// logging out of one session denies it and the presented token
+	tokenID := uuid.NewString()
+	result, err := testStore.RevokeSessionsTx(context.Background(), RevokeSessionsTxParams{
+		UserID:         user.ID,
+		SessionID:      uuid.NullUUID{UUID: current.ID, Valid: true},
+		Reason:         SessionLoggedOut,
+		DenyUntil:      denyUntil,
+		TokenID:        tokenID,
+		TokenExpiresAt: denyUntil,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []uuid.UUID{current.ID}, result.SessionIDs)
+
+	denied, err := testStore.ListActiveDeniedTokens(context.Background())
+	require.NoError(t, err)
+	require.Contains(t, deniedTokenIDs(denied), DeniedTokenKindSession+":"+current.ID.String())
+	require.Contains(t, deniedTokenIDs(denied), DeniedTokenKindToken+":"+tokenID)
+
+	// logging out everywhere revokes the sessions left
+	result, err = testStore.RevokeSessionsTx(context.Background(), RevokeSessionsTxParams{
+		UserID:      user.ID,
+		AllSessions: true,
+		Reason:      SessionLoggedOut,
+		DenyUntil:   denyUntil,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []uuid.UUID{other.ID}, result.SessionIDs)
+
+	sessions, err := testStore.ListActiveSessions(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Empty(t, sessions)
+}
+
+func deniedTokenIDs(denied []DeniedToken) []string {
+	ids := make([]string, 0, len(denied))
+	for _, token := range denied {
+		ids = append(ids, token.Kind+":"+token.TokenID)
+	}
+	return ids
+}
@@ -2,7 +2,11 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"testing"
+	"time"
+
+	"github.com/google/uuid"
 
 	"github.com/trenchesdeveloper/csv-reporter/helpers"
 
@@ -25,5 +29,133 @@ func TestCreateRandomUser(t *testing.T) {
 	require.Equal(t, arg.HashedPassword, user.HashedPassword)
 	require.Equal(t, arg.Email, user.Email)
 	require.NotZero(t, user.CreatedAt)
+	require.Equal(t, UserRoleUser, user.Role)
+
+}
+
+func TestChangeUserRoleTx(t *testing.T) {
+	admin := createRandomUser(t)
+	user := createRandomUser(t)
+	session := createRandomSession(t, user)
+
+	changeRole := func(role string) ChangeUserRoleTxResult {
+		result, err := testStore.ChangeUserRoleTx(context.Background(), ChangeUserRoleTxParams{
+			UserID:    user.ID,
+			Role:      role,
+			ChangedBy: admin.ID,
+			IpAddress: "203.0.113.7",
+			UserAgent: "csv-reporter-tests",
+			DenyUntil: time.Now().Add(time.Minute),
+		})
+		require.NoError(t, err)
+		require.Equal(t, role, result.User.Role)
+		return result
+	}
+
+	// a raised role keeps the user signed in
+	result := changeRole(UserRoleAdmin)
+	require.Empty(t, result.SessionIDs)
+	got, err := testStore.GetSession(context.Background(), session.ID)
+	require.NoError(t, err)
+	require.False(t, got.RevokedAt.Valid)
+
+	// the old sessions can't be refreshed into tokens with the old role
+	result = changeRole(UserRoleAuditor)
+	require.Equal(t, []uuid.UUID{session.ID}, result.SessionIDs)
+	got, err = testStore.GetSession(context.Background(), session.ID)
+	require.NoError(t, err)
+	require.True(t, got.RevokedAt.Valid)
+	require.Equal(t, SessionRoleChanged, got.RevokeReason.String)
+
+	events, err := testStore.ListUserSecurityEvents(context.Background(), ListUserSecurityEventsParams{
+		UserID: uuid.NullUUID{UUID: user.ID, Valid: true},
+		Limit:  10,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, SecurityEventRoleChanged, events[0].EventType)
+
+	_, err = testStore.ChangeUserRoleTx(context.Background(), ChangeUserRoleTxParams{
+		UserID:    uuid.New(),
+		Role:      UserRoleAdmin,
+		ChangedBy: admin.ID,
+		DenyUntil: time.Now().Add(time.Minute),
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func issueAccountToken(t *testing.T, user User, purpose string, expiresAt time.Time) string {
+	hashedToken := helpers.RandomString(64)
+	_, err := testStore.IssueAccountTokenTx(context.Background(), CreateAccountTokenParams{
+		HashedToken: hashedToken,
+		UserID:      user.ID,
+		Purpose:     purpose,
+		ExpiresAt:   expiresAt,
+	})
+	require.NoError(t, err)
+	return hashedToken
+}
+
+func TestVerifyEmailTx(t *testing.T) {
+	user := createRandomUser(t)
+	require.False(t, user.EmailVerifiedAt.Valid)
+
+	// a new token replaces the earlier one
+	replaced := issueAccountToken(t, user, AccountTokenEmailVerification, time.Now().Add(time.Hour))
+	hashedToken := issueAccountToken(t, user, AccountTokenEmailVerification, time.Now().Add(time.Hour))
+	_, err := testStore.VerifyEmailTx(context.Background(), replaced)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	verified, err := testStore.VerifyEmailTx(context.Background(), hashedToken)
+	require.NoError(t, err)
+	require.Equal(t, user.ID, verified.ID)
+	require.True(t, verified.EmailVerifiedAt.Valid)
+
+	// tokens are single-use
+	_, err = testStore.VerifyEmailTx(context.Background(), hashedToken)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	expired := issueAccountToken(t, user, AccountTokenEmailVerification, time.Now().Add(-time.Minute))
+	_, err = testStore.VerifyEmailTx(context.Background(), expired)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// a password reset token can't verify an email
+	reset := issueAccountToken(t, user, AccountTokenPasswordReset, time.Now().Add(time.Hour))
+	_, err = testStore.VerifyEmailTx(context.Background(), reset)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestResetPasswordTx(t *testing.T) {
+	user := createRandomUser(t)
+	session := createRandomSession(t, user)
+	hashedToken := issueAccountToken(t, user, AccountTokenPasswordReset, time.Now().Add(time.Hour))
+
+	arg := ResetPasswordTxParams{
+		HashedToken:    hashedToken,
+		HashedPassword: helpers.RandomString(32),
+		IpAddress:      "203.0.113.7",
+		UserAgent:      "csv-reporter-tests",
+		DenyUntil:      time.Now().Add(time.Minute),
+	}
+	result, err := testStore.ResetPasswordTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.HashedPassword, result.User.HashedPassword)
+	require.True(t, result.User.EmailVerifiedAt.Valid)
+	require.Equal(t, []uuid.UUID{session.ID}, result.SessionIDs)
+
+	got, err := testStore.GetSession(context.Background(), session.ID)
+	require.NoError(t, err)
+	require.True(t, got.RevokedAt.Valid)
+	require.Equal(t, SessionPasswordReset, got.RevokeReason.String)
+
+	events, err := testStore.ListUserSecurityEvents(context.Background(), ListUserSecurityEventsParams{
+		UserID: uuid.NullUUID{UUID: user.ID, Valid: true},
+		Limit:  10,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, SecurityEventPasswordReset, events[0].EventType)
 
+	_, err = testStore.ResetPasswordTx(context.Background(), arg)
+	require.ErrorIs(t, err, sql.ErrNoRows)
 }
@@ -12,7 +12,7 @@ import (
 )
 
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (email, hashed_password) VALUES ($1, $2) RETURNING id, email, hashed_password, created_at
+INSERT INTO users (email, hashed_password) VALUES ($1, $2) RETURNING id, email, hashed_password, created_at, role, email_verified_at, mfa_secret, mfa_enabled_at, mfa_last_used_step
 `
 
 type CreateUserParams struct {
@@ -28,12 +28,17 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.CreatedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.MfaSecret,
+		&i.MfaEnabledAt,
+		&i.MfaLastUsedStep,
 	)
 	return i, err
 }
 
 const findUserByEmail = `-- name: FindUserByEmail :one
-SELECT id, email, hashed_password, created_at FROM users WHERE email = $1
+SELECT id, email, hashed_password, created_at, role, email_verified_at, mfa_secret, mfa_enabled_at, mfa_last_used_step FROM users WHERE email = $1
 `
 
 func (q *Queries) FindUserByEmail(ctx context.Context, email string) (User, error) {
@@ -44,12 +49,17 @@ func (q *Queries) FindUserByEmail(ctx context.Context, email string) (User, erro
 		&i.Email,
 		&i.HashedPassword,
 		&i.CreatedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.MfaSecret,
+		&i.MfaEnabledAt,
+		&i.MfaLastUsedStep,
 	)
 	return i, err
 }
 
 const findUserById = `-- name: FindUserById :one
-SELECT id, email, hashed_password, created_at FROM users WHERE id = $1
+SELECT id, email, hashed_password, created_at, role, email_verified_at, mfa_secret, mfa_enabled_at, mfa_last_used_step FROM users WHERE id = $1
 `
 
 func (q *Queries) FindUserById(ctx context.Context, id uuid.UUID) (User, error) {
@@ -60,6 +70,139 @@ func (q *Queries) FindUserById(ctx context.Context, id uuid.UUID) (User, error)
 		&i.Email,
 		&i.HashedPassword,
 		&i.CreatedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.MfaSecret,
+		&i.MfaEnabledAt,
+		&i.MfaLastUsedStep,
+	)
+	return i, err
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT id, email, hashed_password, created_at, role, email_verified_at, mfa_secret, mfa_enabled_at, mfa_last_used_step
+FROM users
+ORDER BY created_at DESC
+LIMIT $1
+OFFSET $2
+`
+
+type ListUsersParams struct {
+	Limit  int32 `json:"limit"`
+	Offset int32 `json:"offset"`
+}
+
+func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsers, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []User{}
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.HashedPassword,
+			&i.CreatedAt,
+			&i.Role,
+			&i.EmailVerifiedAt,
+			&i.MfaSecret,
+			&i.MfaEnabledAt,
+			&i.MfaLastUsedStep,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markUserEmailVerified = `-- name: MarkUserEmailVerified :one
+UPDATE users
+SET email_verified_at = COALESCE(email_verified_at, NOW())
+WHERE id = $1
+RETURNING id, email, hashed_password, created_at, role, email_verified_at, mfa_secret, mfa_enabled_at, mfa_last_used_step
+`
+
+func (q *Queries) MarkUserEmailVerified(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, markUserEmailVerified, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.CreatedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.MfaSecret,
+		&i.MfaEnabledAt,
+		&i.MfaLastUsedStep,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password = $2
+WHERE id = $1
+RETURNING id, email, hashed_password, created_at, role, email_verified_at, mfa_secret, mfa_enabled_at, mfa_last_used_step
+`
+
+type UpdateUserPasswordParams struct {
+	ID             uuid.UUID `json:"id"`
+	HashedPassword string    `json:"hashed_password"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserPassword, arg.ID, arg.HashedPassword)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.CreatedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.MfaSecret,
+		&i.MfaEnabledAt,
+		&i.MfaLastUsedStep,
+	)
+	return i, err
+}
+
+const updateUserRole = `-- name: UpdateUserRole :one
+UPDATE users
+SET role = $2
+WHERE id = $1
+RETURNING id, email, hashed_password, created_at, role, email_verified_at, mfa_secret, mfa_enabled_at, mfa_last_used_step
+`
+
+type UpdateUserRoleParams struct {
+	ID   uuid.UUID `json:"id"`
+	Role string    `json:"role"`
+}
+
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserRole, arg.ID, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.CreatedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.MfaSecret,
+		&i.MfaEnabledAt,
+		&i.MfaLastUsedStep,
 	)
 	return i, err
 }
@@ -0,0 +1,192 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+)
+
+// createRandomWebhookEndpoint is a helper function to register a webhook endpoint for a user
+func createRandomWebhookEndpoint(t *testing.T, user User) WebhookEndpoint {
+	arg := CreateWebhookEndpointParams{
+		UserID: user.ID,
+		Url:    "https://example.com/hooks/" + helpers.RandomString(8),
+		Secret: helpers.RandomString(32),
+	}
+
+	endpoint, err := testStore.CreateWebhookEndpoint(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.UserID, endpoint.UserID)
+	require.Equal(t, arg.Url, endpoint.Url)
+	require.Equal(t, arg.Secret, endpoint.Secret)
+	require.NotZero(t, endpoint.CreatedAt)
+	return endpoint
+}
+
+// createRandomWebhookDelivery is a helper function to queue a delivery for a report
+func createRandomWebhookDelivery(t *testing.T, report Report, endpoint WebhookEndpoint) WebhookDelivery {
+	arg := CreateWebhookDeliveryParams{
+		UserID:     report.UserID,
+		EndpointID: uuid.NullUUID{UUID: endpoint.ID, Valid: true},
+		ReportID:   report.ID,
+		Event:      "report.completed",
+		Url:        endpoint.Url,
+		Secret:     endpoint.Secret,
+		Payload:    json.RawMessage(`{"type": "report.completed"}`),
+	}
+
+	delivery, err := testStore.CreateWebhookDelivery(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, "pending", delivery.Status)
+	require.Equal(t, int32(0), delivery.AttemptCount)
+	require.JSONEq(t, string(arg.Payload), string(delivery.Payload))
+	return delivery
+}
+
+func TestWebhookEndpoints(t *testing.T) {
+	user := createRandomUser(t)
+	endpoint1 := createRandomWebhookEndpoint(t, user)
+	endpoint2 := createRandomWebhookEndpoint(t, user)
+
+	endpoints, err := testStore.ListWebhookEndpoints(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Len(t, endpoints, 2)
+	require.Equal(t, endpoint1.ID, endpoints[0].ID)
+	require.Equal(t, endpoint2.ID, endpoints[1].ID)
+
+	// another user cannot delete the endpoint
+	otherUser := createRandomUser(t)
+	deleted, err := testStore.DeleteWebhookEndpoint(context.Background(), DeleteWebhookEndpointParams{
+		UserID: otherUser.ID,
+		ID:     endpoint1.ID,
+	})
+	require.NoError(t, err)
+	require.Zero(t, deleted)
+
+	deleted, err = testStore.DeleteWebhookEndpoint(context.Background(), DeleteWebhookEndpointParams{
+		UserID: user.ID,
+		ID:     endpoint1.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+
+	endpoints, err = testStore.ListWebhookEndpoints(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Len(t, endpoints, 1)
+}
+
+func TestWebhookDeliveryLifecycle(t *testing.T) {
+	report := createRandomReport(t)
+	user, err := testStore.FindUserById(context.Background(), report.UserID)
+	require.NoError(t, err)
+	endpoint := createRandomWebhookEndpoint(t, user)
+	delivery := createRandomWebhookDelivery(t, report, endpoint)
+
+	// a failed attempt keeps the delivery pending until the next attempt
+	attempt, err := testStore.CreateWebhookDeliveryAttempt(context.Background(), CreateWebhookDeliveryAttemptParams{
+		DeliveryID: delivery.ID,
+		StatusCode: sql.NullInt32{Int32: 500, Valid: true},
+		Error:      sql.NullString{String: "unexpected status code: 500", Valid: true},
+		DurationMs: 120,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int32(1), attempt.AttemptNumber)
+
+	nextAttempt := time.Now().Add(time.Minute)
+	delivery, err = testStore.UpdateWebhookDeliveryResult(context.Background(), UpdateWebhookDeliveryResultParams{
+		ID:             delivery.ID,
+		Status:         "pending",
+		LastStatusCode: sql.NullInt32{Int32: 500, Valid: true},
+		LastError:      sql.NullString{String: "unexpected status code: 500", Valid: true},
+		NextAttemptAt:  nextAttempt,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int32(1), delivery.AttemptCount)
+	require.WithinDuration(t, nextAttempt, delivery.NextAttemptAt, time.Second)
+
+	// a pending delivery cannot be redelivered
+	_, err = testStore.RedeliverWebhookDelivery(context.Background(), RedeliverWebhookDeliveryParams{
+		UserID: report.UserID,
+		ID:     delivery.ID,
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	_, err = testStore.CreateWebhookDeliveryAttempt(context.Background(), CreateWebhookDeliveryAttemptParams{
+		DeliveryID: delivery.ID,
+		StatusCode: sql.NullInt32{Int32: 204, Valid: true},
+		DurationMs: 80,
+	})
+	require.NoError(t, err)
+
+	delivery, err = testStore.UpdateWebhookDeliveryResult(context.Background(), UpdateWebhookDeliveryResultParams{
+		ID:             delivery.ID,
+		Status:         "delivered",
+		LastStatusCode: sql.NullInt32{Int32: 204, Valid: true},
+		NextAttemptAt:  time.Now(),
+		DeliveredAt:    sql.NullTime{Time: time.Now(), Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "delivered", delivery.Status)
+	require.True(t, delivery.DeliveredAt.Valid)
+
+	attempts, err := testStore.ListWebhookDeliveryAttempts(context.Background(), delivery.ID)
+	require.NoError(t, err)
+	require.Len(t, attempts, 2)
+	require.Equal(t, int32(2), attempts[1].AttemptNumber)
+
+	// a finished delivery can be sent again
+	delivery, err = testStore.RedeliverWebhookDelivery(context.Background(), RedeliverWebhookDeliveryParams{
+		UserID: report.UserID,
+		ID:     delivery.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "pending", delivery.Status)
+	require.Equal(t, int32(0), delivery.AttemptCount)
+
+	deliveries, err := testStore.ListWebhookDeliveries(context.Background(), ListWebhookDeliveriesParams{
+		UserID: report.UserID,
+		Limit:  10,
+		Offset: 0,
+	})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, delivery.ID, deliveries[0].ID)
+}
+
+func TestClaimDueWebhookDeliveries(t *testing.T) {
+	report := createRandomReport(t)
+	user, err := testStore.FindUserById(context.Background(), report.UserID)
+	require.NoError(t, err)
+	endpoint := createRandomWebhookEndpoint(t, user)
+	delivery := createRandomWebhookDelivery(t, report, endpoint)
+
+	claimed, err := testStore.ClaimDueWebhookDeliveries(context.Background(), ClaimDueWebhookDeliveriesParams{
+		LeaseUntil: time.Now().Add(time.Minute),
+		Limit:      100,
+	})
+	require.NoError(t, err)
+
+	var found bool
+	for _, c := range claimed {
+		if c.ID == delivery.ID {
+			found = true
+		}
+	}
+	require.True(t, found)
+
+	// a leased delivery is not claimed again
+	claimed, err = testStore.ClaimDueWebhookDeliveries(context.Background(), ClaimDueWebhookDeliveriesParams{
+		LeaseUntil: time.Now().Add(time.Minute),
+		Limit:      100,
+	})
+	require.NoError(t, err)
+	for _, c := range claimed {
+		require.NotEqual(t, delivery.ID, c.ID)
+	}
+}
@@ -0,0 +1,248 @@
+package destinations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/uuid"
+	"github.com/trenchesdeveloper/csv-reporter/config"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"github.com/trenchesdeveloper/csv-reporter/reports"
+	"go.uber.org/zap"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+
+	maxAttempts    = 5
+	retryBaseDelay = time.Minute
+	maxRetryDelay  = time.Hour
+	claimBatchSize = 10
+	uploadTimeout  = 10 * time.Minute
+	// claimLease covers a whole batch of uploads that all time out, so no delivery is claimed
+	// and uploaded again by another worker while this one is still working through the batch
+	claimLease = claimBatchSize*uploadTimeout + time.Minute
+)
+
+// errDestinationRemoved fails deliveries whose destination was deleted after they were queued.
+var errDestinationRemoved = errors.New("destination was removed")
+
+// Deliverer pushes a copy of every completed report to the enabled destinations of its owner.
+type Deliverer struct {
+	store    db.Store
+	s3Client *s3.Client
+	config   *config.AppConfig
+	logger   *zap.SugaredLogger
+	wake     chan struct{}
+}
+
+func NewDeliverer(store db.Store, s3Client *s3.Client, config *config.AppConfig, logger *zap.SugaredLogger) *Deliverer {
+	return &Deliverer{
+		store:    store,
+		s3Client: s3Client,
+		config:   config,
+		logger:   logger,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// ReportFinished queues a delivery to every enabled destination of the owner of a completed report.
+func (d *Deliverer) ReportFinished(ctx context.Context, report db.Report) error {
+	if !report.CompletedAt.Valid {
+		return nil
+	}
+
+	destinations, err := d.store.ListEnabledReportDestinations(ctx, report.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to list report destinations: %w", err)
+	}
+
+	for _, destination := range destinations {
+		_, err := d.store.CreateReportDestinationDelivery(ctx, db.CreateReportDestinationDeliveryParams{
+			UserID:          report.UserID,
+			ReportID:        report.ID,
+			DestinationID:   uuid.NullUUID{UUID: destination.ID, Valid: true},
+			DestinationName: destination.Name,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create destination delivery: %w", err)
+		}
+	}
+
+	if len(destinations) > 0 {
+		d.Wake()
+	}
+	return nil
+}
+
+// Wake makes the deliverer look for due deliveries without waiting for the next poll.
+func (d *Deliverer) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start delivers due reports until the context is cancelled.
+func (d *Deliverer) Start(ctx context.Context) {
+	interval := d.config.DESTINATION_POLL_INTERVAL
+	if interval <= 0 {
+		interval = time.Second * 10
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	d.logger.Info("destination deliverer started")
+	for {
+		d.deliverDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			d.logger.Info("destination deliverer stopping due to context cancellation")
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+func (d *Deliverer) deliverDue(ctx context.Context) {
+	for {
+		// claimed deliveries are leased, so other workers skip them while we upload
+		deliveries, err := d.store.ClaimDueReportDestinationDeliveries(ctx, db.ClaimDueReportDestinationDeliveriesParams{
+			LeaseUntil: time.Now().Add(claimLease),
+			Limit:      claimBatchSize,
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				d.logger.Errorw("failed to claim destination deliveries", "error", err)
+			}
+			return
+		}
+
+		for _, delivery := range deliveries {
+			d.deliver(ctx, delivery)
+		}
+
+		if len(deliveries) < claimBatchSize {
+			return
+		}
+	}
+}
+
+func (d *Deliverer) deliver(ctx context.Context, delivery db.ReportDestinationDelivery) {
+	uploadCtx, cancel := context.WithTimeout(ctx, uploadTimeout)
+	remotePath, uploadErr := d.upload(uploadCtx, delivery)
+	cancel()
+	attempt := int(delivery.AttemptCount) + 1
+
+	d.logger.Infow("destination delivery attempt",
+		"delivery_id", delivery.ID,
+		"report_id", delivery.ReportID,
+		"destination", delivery.DestinationName,
+		"remote_path", remotePath,
+		"attempt", attempt,
+		"error", uploadErr,
+	)
+
+	now := time.Now()
+	result := db.UpdateReportDestinationDeliveryResultParams{
+		ID:            delivery.ID,
+		Status:        StatusDelivered,
+		RemotePath:    sql.NullString{String: remotePath, Valid: remotePath != ""},
+		NextAttemptAt: now,
+		DeliveredAt:   sql.NullTime{Time: now, Valid: true},
+	}
+	if uploadErr != nil {
+		result.DeliveredAt = sql.NullTime{Valid: false}
+		result.LastError = sql.NullString{String: uploadErr.Error(), Valid: true}
+		if attempt >= maxAttempts || errors.Is(uploadErr, errDestinationRemoved) {
+			result.Status = StatusFailed
+		} else {
+			result.Status = StatusPending
+			result.NextAttemptAt = now.Add(retryDelay(attempt))
+		}
+	}
+
+	if _, err := d.store.UpdateReportDestinationDeliveryResult(ctx, result); err != nil {
+		d.logger.Errorw("failed to update destination delivery", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+// upload copies the report file to the destination and returns the path it was written to.
+func (d *Deliverer) upload(ctx context.Context, delivery db.ReportDestinationDelivery) (string, error) {
+	if !delivery.DestinationID.Valid {
+		return "", errDestinationRemoved
+	}
+	destination, err := d.store.GetReportDestination(ctx, db.GetReportDestinationParams{
+		UserID: delivery.UserID,
+		ID:     delivery.DestinationID.UUID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", errDestinationRemoved
+		}
+		return "", fmt.Errorf("failed to get destination: %w", err)
+	}
+
+	report, err := d.store.GetReport(ctx, db.GetReportParams{
+		UserID: delivery.UserID,
+		ID:     delivery.ReportID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get report: %w", err)
+	}
+	params, err := reports.ParamsFromReport(report)
+	if err != nil {
+		return "", err
+	}
+
+	uploader, pathTemplate, err := New(destination)
+	if err != nil {
+		return "", err
+	}
+	remotePath, err := RenderPath(pathTemplate, NewPathData(
+		report.ID,
+		params.Type,
+		params.Game,
+		params.Format,
+		reports.DownloadFilename(report, params),
+		report.CompletedAt.Time,
+	))
+	if err != nil {
+		return "", err
+	}
+
+	object, err := d.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(d.config.S3_BUCKET),
+		Key:    aws.String(report.OutputFilePath.String),
+	})
+	if err != nil {
+		return remotePath, fmt.Errorf("failed to get report file: %w", err)
+	}
+	defer object.Body.Close()
+
+	if err := uploader.Upload(ctx, remotePath, object.Body, aws.ToInt64(object.ContentLength)); err != nil {
+		return remotePath, err
+	}
+	return remotePath, nil
+}
+
+// retryDelay returns the delay before the next attempt, doubling from retryBaseDelay up to maxRetryDelay.
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= maxRetryDelay {
+			return maxRetryDelay
+		}
+	}
+	return delay
+}
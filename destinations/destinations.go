@@ -0,0 +1,132 @@
+package destinations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+)
+
+const (
+	KindSFTP   = "sftp"
+	KindWebDAV = "webdav"
+)
+
+// DefaultPathTemplate puts every report in the root of the destination under its download name.
+const DefaultPathTemplate = "{{.Filename}}"
+
+// SFTPConfig is the stored configuration of an SFTP destination. The worker authenticates with the
+// key pair generated for the destination; the server's host key must match HostKey.
+type SFTPConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port,omitempty"`
+	Username string `json:"username"`
+	// HostKey is the server's public key in authorized_keys format.
+	HostKey      string `json:"host_key"`
+	PathTemplate string `json:"path_template,omitempty"`
+}
+
+// WebDAVConfig is the stored configuration of a WebDAV destination. The password is stored as the
+// destination secret.
+type WebDAVConfig struct {
+	URL          string `json:"url"`
+	Username     string `json:"username,omitempty"`
+	PathTemplate string `json:"path_template,omitempty"`
+}
+
+// PathData is what destination path templates are rendered with.
+type PathData struct {
+	ReportID   uuid.UUID
+	ReportType string
+	Game       string
+	Format     string
+	Date       string
+	Filename   string
+}
+
+// Uploader writes a file to a destination.
+type Uploader interface {
+	Upload(ctx context.Context, remotePath string, body io.Reader, size int64) error
+}
+
+// New returns the uploader of a destination and its path template.
+func New(destination db.ReportDestination) (Uploader, string, error) {
+	switch destination.Kind {
+	case KindSFTP:
+		var config SFTPConfig
+		if err := json.Unmarshal(destination.Config, &config); err != nil {
+			return nil, "", fmt.Errorf("failed to decode SFTP destination: %w", err)
+		}
+		uploader, err := NewSFTPUploader(config, []byte(destination.Secret.String))
+		if err != nil {
+			return nil, "", err
+		}
+		return uploader, config.PathTemplate, nil
+	case KindWebDAV:
+		var config WebDAVConfig
+		if err := json.Unmarshal(destination.Config, &config); err != nil {
+			return nil, "", fmt.Errorf("failed to decode WebDAV destination: %w", err)
+		}
+		return NewWebDAVUploader(config, destination.Secret.String, nil), config.PathTemplate, nil
+	default:
+		return nil, "", fmt.Errorf("unknown destination kind %q", destination.Kind)
+	}
+}
+
+// ParsePathTemplate validates a path template, falling back to DefaultPathTemplate when it is empty.
+func ParsePathTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = DefaultPathTemplate
+	}
+	tmpl, err := template.New("path").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// RenderPath renders a path template. The result is relative to the destination root and
+// can't escape it.
+func RenderPath(text string, data PathData) (string, error) {
+	tmpl, err := ParsePathTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render path template: %w", err)
+	}
+
+	rendered := strings.TrimSpace(buf.String())
+	for _, segment := range strings.Split(rendered, "/") {
+		if segment == ".." {
+			return "", errors.New("path template must not leave the destination directory")
+		}
+	}
+	cleaned := strings.TrimPrefix(path.Clean("/"+rendered), "/")
+	if cleaned == "" || strings.HasSuffix(rendered, "/") {
+		return "", errors.New("path template must name a file")
+	}
+	return cleaned, nil
+}
+
+// NewPathData describes a report for path templates.
+func NewPathData(reportID uuid.UUID, reportType, game, format, filename string, completedAt time.Time) PathData {
+	return PathData{
+		ReportID:   reportID,
+		ReportType: reportType,
+		Game:       game,
+		Format:     format,
+		Date:       completedAt.UTC().Format(time.DateOnly),
+		Filename:   filename,
+	}
+}
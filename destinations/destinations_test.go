@@ -0,0 +1,116 @@
+package destinations
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+)
+
+func TestRenderPath(t *testing.T) {
+	data := NewPathData(
+		uuid.MustParse("1a2b3c4d-0000-0000-0000-000000000000"),
+		"monsters", "totk", "csv",
+		"monsters-totk-2026-10-19-1a2b3c4d.csv.gz",
+		time.Date(2026, 10, 19, 23, 0, 0, 0, time.UTC),
+	)
+
+	testCases := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{name: "default", template: "", want: "monsters-totk-2026-10-19-1a2b3c4d.csv.gz"},
+		{name: "nested", template: "/exports/{{.Game}}/{{.Date}}/{{.ReportType}}.{{.Format}}.gz", want: "exports/totk/2026-10-19/monsters.csv.gz"},
+		{name: "report id", template: "{{.ReportID}}.gz", want: "1a2b3c4d-0000-0000-0000-000000000000.gz"},
+		{name: "escapes root", template: "../{{.Filename}}", wantErr: true},
+		{name: "directory", template: "exports/", wantErr: true},
+		{name: "unknown field", template: "{{.Owner}}", wantErr: true},
+		{name: "invalid", template: "{{.Filename", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := RenderPath(tc.template, data)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestWebDAVUpload(t *testing.T) {
+	var mu sync.Mutex
+	collections := map[string]bool{}
+	files := map[string][]byte{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "partner" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case "MKCOL":
+			if collections[r.URL.Path] {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			collections[r.URL.Path] = true
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			files[r.URL.Path] = body
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+
+	uploader := NewWebDAVUploader(WebDAVConfig{
+		URL:      server.URL + "/dav/drop",
+		Username: "partner",
+	}, "secret", server.Client())
+
+	content := []byte("report")
+	for i := 0; i < 2; i++ {
+		err := uploader.Upload(context.Background(), "totk/2026-10-19/monsters.csv.gz", bytes.NewReader(content), int64(len(content)))
+		require.NoError(t, err)
+	}
+
+	require.True(t, collections["/dav/drop/totk/"])
+	require.True(t, collections["/dav/drop/totk/2026-10-19/"])
+	require.Equal(t, content, files["/dav/drop/totk/2026-10-19/monsters.csv.gz"])
+
+	wrongPassword := NewWebDAVUploader(WebDAVConfig{URL: server.URL, Username: "partner"}, "wrong", server.Client())
+	err := wrongPassword.Upload(context.Background(), "monsters.csv.gz", bytes.NewReader(content), int64(len(content)))
+	require.ErrorContains(t, err, "unexpected status code 401")
+}
+
+func TestWebDAVUploadRefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	uploader := NewWebDAVUploader(WebDAVConfig{URL: server.URL}, "", nil)
+	err := uploader.Upload(context.Background(), "monsters.csv.gz", bytes.NewReader(nil), 0)
+	require.ErrorIs(t, err, helpers.ErrForbiddenAddress)
+}
@@ -0,0 +1,149 @@
+package destinations
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+	"golang.org/x/crypto/ssh"
+)
+
+// SFTPUploader uploads files over SFTP with public key authentication.
+type SFTPUploader struct {
+	config  SFTPConfig
+	signer  ssh.Signer
+	hostKey ssh.PublicKey
+	// dialer refuses internal addresses, since SFTP hosts are supplied by users
+	dialer *net.Dialer
+}
+
+func NewSFTPUploader(config SFTPConfig, privateKey []byte) (*SFTPUploader, error) {
+	signer, err := ssh.ParsePrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SFTP private key: %w", err)
+	}
+	hostKey, err := ParseHostKey(config.HostKey)
+	if err != nil {
+		return nil, err
+	}
+	return &SFTPUploader{
+		config:  config,
+		signer:  signer,
+		hostKey: hostKey,
+		dialer:  helpers.NewGuardedDialer(time.Second * 10),
+	}, nil
+}
+
+// Upload writes the file next to its final path and renames it once complete, so partners
+// polling the drop box never pick up a partial file.
+func (u *SFTPUploader) Upload(ctx context.Context, remotePath string, body io.Reader, size int64) error {
+	port := u.config.Port
+	if port == 0 {
+		port = 22
+	}
+
+	addr := net.JoinHostPort(u.config.Host, strconv.Itoa(port))
+	conn, err := u.dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SFTP server: %w", err)
+	}
+	// closing the connection unblocks any transfer in flight once the context is done
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, &ssh.ClientConfig{
+		User:            u.config.Username,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(u.signer)},
+		HostKeyCallback: ssh.FixedHostKey(u.hostKey),
+		Timeout:         time.Second * 10,
+	})
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SSH session: %w", err)
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+	defer client.Close()
+
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		return fmt.Errorf("failed to start SFTP session: %w", err)
+	}
+	defer sftpClient.Close()
+
+	if dir := path.Dir(remotePath); dir != "." {
+		if err := sftpClient.MkdirAll(dir); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+
+	partial := remotePath + ".part"
+	file, err := sftpClient.Create(partial)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", partial, err)
+	}
+	written, err := file.ReadFrom(body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && written != size {
+		err = fmt.Errorf("wrote %d of %d bytes", written, size)
+	}
+	if err != nil {
+		sftpClient.Remove(partial)
+		return fmt.Errorf("failed to upload %s: %w", remotePath, err)
+	}
+
+	// overwrite earlier deliveries of the same path
+	if err := sftpClient.PosixRename(partial, remotePath); err != nil {
+		sftpClient.Remove(remotePath)
+		if err := sftpClient.Rename(partial, remotePath); err != nil {
+			sftpClient.Remove(partial)
+			return fmt.Errorf("failed to move %s into place: %w", remotePath, err)
+		}
+	}
+	return nil
+}
+
+// ParseHostKey parses a host key in authorized_keys format.
+func ParseHostKey(text string) (ssh.PublicKey, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, errors.New("SFTP host key is required")
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(text))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SFTP host key: %w", err)
+	}
+	return key, nil
+}
+
+// GenerateKeyPair returns a new ed25519 private key as PEM and its public key in authorized_keys format.
+func GenerateKeyPair(comment string) (privateKey string, publicKey string, err error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	block, err := ssh.MarshalPrivateKey(private, comment)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode key: %w", err)
+	}
+	sshPublic, err := ssh.NewPublicKey(public)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode key: %w", err)
+	}
+	authorized := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPublic)))
+	if comment != "" {
+		authorized += " " + comment
+	}
+	return string(pem.EncodeToMemory(block)), authorized, nil
+}
@@ -0,0 +1,190 @@
+package destinations
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/require"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+	"golang.org/x/crypto/ssh"
+)
+
+// startSFTPServer serves dir over SFTP on a local port to clients holding authorizedKey.
+// It returns the port and the server's host key in authorized_keys format.
+func startSFTPServer(t *testing.T, dir string, authorizedKey string) (int, string) {
+	_, hostPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	hostSigner, err := ssh.NewSignerFromKey(hostPrivate)
+	require.NoError(t, err)
+
+	allowed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(authorizedKey))
+	require.NoError(t, err)
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == "partner" && bytes.Equal(key.Marshal(), allowed.Marshal()) {
+				return nil, nil
+			}
+			return nil, os.ErrPermission
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSFTP(conn, config, dir)
+		}
+	}()
+
+	hostKey := string(ssh.MarshalAuthorizedKey(hostSigner.PublicKey()))
+	return listener.Addr().(*net.TCPAddr).Port, hostKey
+}
+
+func serveSFTP(conn net.Conn, config *ssh.ServerConfig, dir string) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if !ok {
+					continue
+				}
+				server, err := sftp.NewServer(channel, sftp.WithServerWorkingDirectory(dir))
+				if err != nil {
+					channel.Close()
+					return
+				}
+				server.Serve()
+				channel.Close()
+				return
+			}
+		}()
+	}
+}
+
+func TestSFTPUpload(t *testing.T) {
+	privateKey, publicKey, err := GenerateKeyPair("test")
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	port, hostKey := startSFTPServer(t, dir, publicKey)
+
+	uploader, err := NewSFTPUploader(SFTPConfig{
+		Host:     "127.0.0.1",
+		Port:     port,
+		Username: "partner",
+		HostKey:  hostKey,
+	}, []byte(privateKey))
+	require.NoError(t, err)
+	// the test server listens on loopback, which the default dialer refuses
+	uploader.dialer = &net.Dialer{Timeout: 10 * time.Second}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	content := []byte("name,drops\nBokoblin,Bokoblin Horn\n")
+	require.NoError(t, uploader.Upload(ctx, "drop/2026-10-19/monsters.csv.gz", bytes.NewReader(content), int64(len(content))))
+
+	uploaded, err := os.ReadFile(filepath.Join(dir, "drop", "2026-10-19", "monsters.csv.gz"))
+	require.NoError(t, err)
+	require.Equal(t, content, uploaded)
+	_, err = os.Stat(filepath.Join(dir, "drop", "2026-10-19", "monsters.csv.gz.part"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	// a second delivery replaces the file
+	content = []byte("name,drops\n")
+	require.NoError(t, uploader.Upload(ctx, "drop/2026-10-19/monsters.csv.gz", bytes.NewReader(content), int64(len(content))))
+	uploaded, err = os.ReadFile(filepath.Join(dir, "drop", "2026-10-19", "monsters.csv.gz"))
+	require.NoError(t, err)
+	require.Equal(t, content, uploaded)
+}
+
+func TestSFTPUploadRejectsUnknownHostKey(t *testing.T) {
+	privateKey, publicKey, err := GenerateKeyPair("test")
+	require.NoError(t, err)
+	port, _ := startSFTPServer(t, t.TempDir(), publicKey)
+
+	// the key of another server
+	_, otherHostKey, err := GenerateKeyPair("")
+	require.NoError(t, err)
+
+	uploader, err := NewSFTPUploader(SFTPConfig{
+		Host:     "127.0.0.1",
+		Port:     port,
+		Username: "partner",
+		HostKey:  otherHostKey,
+	}, []byte(privateKey))
+	require.NoError(t, err)
+	uploader.dialer = &net.Dialer{Timeout: 10 * time.Second}
+
+	err = uploader.Upload(context.Background(), "monsters.csv.gz", bytes.NewReader(nil), 0)
+	require.ErrorContains(t, err, "host key mismatch")
+}
+
+func TestSFTPUploadRejectsUnauthorizedKey(t *testing.T) {
+	_, publicKey, err := GenerateKeyPair("test")
+	require.NoError(t, err)
+	port, hostKey := startSFTPServer(t, t.TempDir(), publicKey)
+
+	otherPrivateKey, _, err := GenerateKeyPair("")
+	require.NoError(t, err)
+
+	uploader, err := NewSFTPUploader(SFTPConfig{
+		Host:     "127.0.0.1",
+		Port:     port,
+		Username: "partner",
+		HostKey:  hostKey,
+	}, []byte(otherPrivateKey))
+	require.NoError(t, err)
+	uploader.dialer = &net.Dialer{Timeout: 10 * time.Second}
+
+	err = uploader.Upload(context.Background(), "monsters.csv.gz", bytes.NewReader(nil), 0)
+	require.ErrorContains(t, err, "unable to authenticate")
+}
+
+func TestSFTPUploadRefusesInternalAddresses(t *testing.T) {
+	privateKey, publicKey, err := GenerateKeyPair("test")
+	require.NoError(t, err)
+	port, hostKey := startSFTPServer(t, t.TempDir(), publicKey)
+
+	uploader, err := NewSFTPUploader(SFTPConfig{
+		Host:     "127.0.0.1",
+		Port:     port,
+		Username: "partner",
+		HostKey:  hostKey,
+	}, []byte(privateKey))
+	require.NoError(t, err)
+
+	err = uploader.Upload(context.Background(), "monsters.csv.gz", bytes.NewReader(nil), 0)
+	require.ErrorIs(t, err, helpers.ErrForbiddenAddress)
+}
@@ -0,0 +1,97 @@
+package destinations
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+)
+
+type HttpClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// WebDAVUploader uploads files with HTTP PUT, creating missing collections with MKCOL.
+type WebDAVUploader struct {
+	config     WebDAVConfig
+	password   string
+	httpClient HttpClient
+}
+
+// NewWebDAVUploader returns an uploader for a destination. Without httpClient it uses
+// helpers.NewGuardedHTTPClient, since WebDAV URLs are supplied by users; the upload context
+// bounds each request.
+func NewWebDAVUploader(config WebDAVConfig, password string, httpClient HttpClient) *WebDAVUploader {
+	if httpClient == nil {
+		httpClient = helpers.NewGuardedHTTPClient(0)
+	}
+	return &WebDAVUploader{config: config, password: password, httpClient: httpClient}
+}
+
+func (u *WebDAVUploader) Upload(ctx context.Context, remotePath string, body io.Reader, size int64) error {
+	segments := strings.Split(remotePath, "/")
+	for i := 1; i < len(segments); i++ {
+		status, err := u.do(ctx, "MKCOL", strings.Join(segments[:i], "/")+"/", nil, 0)
+		if err != nil {
+			return err
+		}
+		// 405 means the collection exists already
+		if status != http.StatusCreated && status != http.StatusMethodNotAllowed {
+			return fmt.Errorf("failed to create collection %s: unexpected status code %d", strings.Join(segments[:i], "/"), status)
+		}
+	}
+
+	status, err := u.do(ctx, http.MethodPut, remotePath, body, size)
+	if err != nil {
+		return err
+	}
+	if status < 200 || status >= 300 {
+		return fmt.Errorf("failed to upload %s: unexpected status code %d", remotePath, status)
+	}
+	return nil
+}
+
+func (u *WebDAVUploader) do(ctx context.Context, method, remotePath string, body io.Reader, size int64) (int, error) {
+	target, err := u.resolve(remotePath)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.ContentLength = size
+		req.Header.Set("Content-Type", "application/gzip")
+	}
+	if u.config.Username != "" {
+		req.SetBasicAuth(u.config.Username, u.password)
+	}
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+	// drain a bounded part of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	return resp.StatusCode, nil
+}
+
+// resolve joins a path relative to the destination root onto the configured URL.
+func (u *WebDAVUploader) resolve(remotePath string) (string, error) {
+	base, err := url.Parse(u.config.URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid WebDAV URL: %w", err)
+	}
+	resolved := base.JoinPath(strings.Split(strings.TrimSuffix(remotePath, "/"), "/")...)
+	// collections are addressed with a trailing slash, which JoinPath drops
+	if strings.HasSuffix(remotePath, "/") {
+		resolved.Path += "/"
+	}
+	return resolved.String(), nil
+}
@@ -8,14 +8,43 @@ import (
 	"time"
 )
 
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 7 * 24 * time.Hour
+	// MFATokenTTL is how long a user has to enter their code after the password was accepted
+	MFATokenTTL = 5 * time.Minute
+)
+
 type JwtManager struct {
 	config *config.AppConfig
+	keys   *KeySet
 }
 
+// NewJwtManager signs and verifies tokens with the HS256 JWT_SECRET, which is meant for local development
 func NewJwtManager(config *config.AppConfig) *JwtManager {
+	return NewJwtManagerWithKeys(config, NewHMACKeySet(config.JWT_SECRET))
+}
+
+func NewJwtManagerWithKeys(config *config.AppConfig, keys *KeySet) *JwtManager {
 	return &JwtManager{
 		config: config,
+		keys:   keys,
+	}
+}
+
+// LoadJwtManager uses the key set at JWT_KEYSET_PATH when one is configured and falls back to HS256
+func LoadJwtManager(config *config.AppConfig) (*JwtManager, error) {
+	if config.JWT_KEYSET_PATH == "" {
+		return NewJwtManager(config), nil
 	}
+	keys, err := LoadKeySet(config.JWT_KEYSET_PATH)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := keys.SigningKey(time.Now()); err != nil {
+		return nil, err
+	}
+	return NewJwtManagerWithKeys(config, keys), nil
 }
 
 type TokenPairs struct {
@@ -25,38 +54,58 @@ type TokenPairs struct {
 
 type CustomClaims struct {
 	TokenType string `json:"token_type"`
+	// SessionID ties both tokens of a pair to the session that issued them
+	SessionID string `json:"sid,omitempty"`
+	// Role is the role of the user when the token was issued, for services that verify tokens with the JWKS
+	Role string `json:"role,omitempty"`
 	jwt.RegisteredClaims
 }
 
 func (j JwtManager) GenerateTokenPairs(userID uuid.UUID) (*TokenPairs, error) {
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, CustomClaims{
+	return j.GenerateSessionTokenPairs(userID, uuid.Nil, "")
+}
+
+// GenerateSessionTokenPairs issues tokens that carry the session ID as the sid claim and the role of the user
+func (j JwtManager) GenerateSessionTokenPairs(userID uuid.UUID, sessionID uuid.UUID, role string) (*TokenPairs, error) {
+	sid := ""
+	if sessionID != uuid.Nil {
+		sid = sessionID.String()
+	}
+
+	now := time.Now()
+	key, err := j.keys.SigningKey(now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pick signing key: %w", err)
+	}
+
+	accessToken, err := j.sign(key, CustomClaims{
 		TokenType: "access",
+		SessionID: sid,
+		Role:      role,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			Issuer:    j.config.AppName,
 			Subject:   userID.String(),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * 15)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
 		},
 	})
-
-	key := []byte(j.config.JWT_SECRET)
-	accessToken, err := jwtToken.SignedString(key)
-
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign access token: %w", err)
 	}
 
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, CustomClaims{
+	refreshTokenString, err := j.sign(key, CustomClaims{
 		TokenType: "refresh",
+		SessionID: sid,
+		Role:      role,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			Issuer:    j.config.AppName,
 			Subject:   userID.String(),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24 * 7)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(RefreshTokenTTL)),
 		},
 	})
-	key = []byte(j.config.JWT_SECRET)
-	refreshTokenString, err := refreshToken.SignedString(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign refresh token: %w", err)
 	}
@@ -65,12 +114,53 @@ func (j JwtManager) GenerateTokenPairs(userID uuid.UUID) (*TokenPairs, error) {
 		RefreshToken: refreshTokenString,
 	}, nil
 }
+
+// GenerateMFAToken issues the challenge token of a sign-in that still needs a second factor.
+// It carries no session and isn't an access token, so it only works for completing the sign-in.
+func (j JwtManager) GenerateMFAToken(userID uuid.UUID) (string, time.Time, error) {
+	now := time.Now()
+	key, err := j.keys.SigningKey(now)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to pick signing key: %w", err)
+	}
+
+	expiresAt := now.Add(MFATokenTTL)
+	token, err := j.sign(key, CustomClaims{
+		TokenType: "mfa",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    j.config.AppName,
+			Subject:   userID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign MFA token: %w", err)
+	}
+	return token, expiresAt, nil
+}
+
+func (j JwtManager) sign(key SigningKey, claims CustomClaims) (string, error) {
+	token := jwt.NewWithClaims(key.Method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	return token.SignedString(key.private)
+}
+
 func (j JwtManager) ValidateToken(tokenString string) (*jwt.Token, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := j.keys.VerificationKey(kid, time.Now())
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key: %q", kid)
+		}
+		// the key decides the algorithm, never the token
+		if token.Method.Alg() != key.Method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(j.config.JWT_SECRET), nil
+		return key.public, nil
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
@@ -78,6 +168,11 @@ func (j JwtManager) ValidateToken(tokenString string) (*jwt.Token, error) {
 	return token, nil
 }
 
+// JWKS returns the public keys that verify tokens, empty when signing with HS256
+func (j JwtManager) JWKS() JSONWebKeySet {
+	return j.keys.JWKS(time.Now())
+}
+
 func (j JwtManager) IsAccessToken(token *jwt.Token) bool {
 	claims, ok := token.Claims.(*CustomClaims)
 	if !ok {
@@ -85,3 +180,30 @@ func (j JwtManager) IsAccessToken(token *jwt.Token) bool {
 	}
 	return claims.TokenType == "access"
 }
+
+func (j JwtManager) IsMFAToken(token *jwt.Token) bool {
+	claims, ok := token.Claims.(*CustomClaims)
+	if !ok {
+		return false
+	}
+	return claims.TokenType == "mfa"
+}
+
+// SessionID returns the session a validated token belongs to
+func (j JwtManager) SessionID(token *jwt.Token) (uuid.UUID, bool) {
+	claims, ok := token.Claims.(*CustomClaims)
+	if !ok || claims.SessionID == "" {
+		return uuid.Nil, false
+	}
+	sessionID, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return sessionID, true
+}
+
+// Claims returns the claims of a validated token
+func (j JwtManager) Claims(token *jwt.Token) (*CustomClaims, bool) {
+	claims, ok := token.Claims.(*CustomClaims)
+	return claims, ok
+}
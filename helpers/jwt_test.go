@@ -126,3 +126,49 @@ func TestValidateTokenInvalid(t *testing.T) {
 	require.Error(t, err)
 	assert.Nil(t, accessToken)
 }
+
+func TestGenerateSessionTokenPairs(t *testing.T) {
+	jwtManager := NewJwtManager(&config.AppConfig{AppName: "csv_reporter", JWT_SECRET: "secretToSecretMySecret"})
+
+	sessionID := uuid.New()
+	tokenPairs, err := jwtManager.GenerateSessionTokenPairs(uuid.New(), sessionID, "admin")
+	require.NoError(t, err)
+
+	for _, tokenString := range []string{tokenPairs.AccessToken, tokenPairs.RefreshToken} {
+		token, err := jwtManager.ValidateToken(tokenString)
+		require.NoError(t, err)
+		got, ok := jwtManager.SessionID(token)
+		require.True(t, ok)
+		assert.Equal(t, sessionID, got)
+		assert.NotEmpty(t, token.Claims.(*CustomClaims).ID)
+		assert.Equal(t, "admin", token.Claims.(*CustomClaims).Role)
+	}
+
+	// tokens without a session have no sid claim
+	tokenPairs, err = jwtManager.GenerateTokenPairs(uuid.New())
+	require.NoError(t, err)
+	token, err := jwtManager.ValidateToken(tokenPairs.AccessToken)
+	require.NoError(t, err)
+	_, ok := jwtManager.SessionID(token)
+	assert.False(t, ok)
+}
+
+func TestGenerateMFAToken(t *testing.T) {
+	jwtManager := NewJwtManager(&config.AppConfig{AppName: "csv_reporter", JWT_SECRET: "secretToSecretMySecret"})
+
+	userID := uuid.New()
+	tokenString, expiresAt, err := jwtManager.GenerateMFAToken(userID)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(MFATokenTTL), expiresAt, time.Second)
+
+	token, err := jwtManager.ValidateToken(tokenString)
+	require.NoError(t, err)
+	assert.True(t, jwtManager.IsMFAToken(token))
+	// a challenge token can't be used as an access token or refreshed
+	assert.False(t, jwtManager.IsAccessToken(token))
+	_, ok := jwtManager.SessionID(token)
+	assert.False(t, ok)
+	subject, err := token.Claims.GetSubject()
+	require.NoError(t, err)
+	assert.Equal(t, userID.String(), subject)
+}
@@ -0,0 +1,246 @@
+package helpers
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minRSABits is the smallest RSA modulus accepted for RS256 keys
+const minRSABits = 2048
+
+// SigningKey is one entry of a KeySet. Asymmetric keys without a private key only verify tokens.
+type SigningKey struct {
+	ID          string
+	Method      jwt.SigningMethod
+	SignFrom    time.Time
+	VerifyUntil time.Time // zero means the key never retires
+
+	private any
+	public  any
+}
+
+// KeySet holds the keys used to sign and verify tokens. The schedule lets a new key be
+// published before it starts signing and an old key keep verifying until its tokens expire.
+type KeySet struct {
+	keys []SigningKey
+}
+
+// NewHMACKeySet returns a key set that signs and verifies with a single HS256 secret.
+// Tokens carry no kid so ones issued before key sets existed stay valid.
+func NewHMACKeySet(secret string) *KeySet {
+	return &KeySet{keys: []SigningKey{{
+		Method:  jwt.SigningMethodHS256,
+		private: []byte(secret),
+		public:  []byte(secret),
+	}}}
+}
+
+// keySetFile is the JSON layout read by LoadKeySet. Key paths are relative to the file.
+type keySetFile struct {
+	Keys []struct {
+		ID          string    `json:"kid"`
+		Algorithm   string    `json:"alg"`
+		PrivateKey  string    `json:"private_key"`
+		PublicKey   string    `json:"public_key"`
+		SignFrom    time.Time `json:"sign_from"`
+		VerifyUntil time.Time `json:"verify_until"`
+	} `json:"keys"`
+}
+
+// LoadKeySet reads an RS256/EdDSA key set from the JSON file at path
+func LoadKeySet(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading key set: %w", err)
+	}
+
+	var file keySetFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error decoding key set: %w", err)
+	}
+	if len(file.Keys) == 0 {
+		return nil, errors.New("key set has no keys")
+	}
+
+	dir := filepath.Dir(path)
+	keys := make([]SigningKey, 0, len(file.Keys))
+	seen := make(map[string]bool, len(file.Keys))
+	for _, entry := range file.Keys {
+		if entry.ID == "" {
+			return nil, errors.New("key set entry is missing a kid")
+		}
+		if seen[entry.ID] {
+			return nil, fmt.Errorf("key %q is listed twice", entry.ID)
+		}
+		seen[entry.ID] = true
+
+		key := SigningKey{ID: entry.ID, SignFrom: entry.SignFrom, VerifyUntil: entry.VerifyUntil}
+		switch {
+		case entry.PrivateKey != "":
+			key.private, err = readPEMPrivateKey(filepath.Join(dir, entry.PrivateKey))
+			if err == nil {
+				key.public = key.private.(crypto.Signer).Public()
+			}
+		case entry.PublicKey != "":
+			key.public, err = readPEMPublicKey(filepath.Join(dir, entry.PublicKey))
+		default:
+			err = errors.New("needs a private_key or public_key")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", entry.ID, err)
+		}
+
+		if key.Method, err = asymmetricMethod(entry.Algorithm, key.public); err != nil {
+			return nil, fmt.Errorf("key %q: %w", entry.ID, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return &KeySet{keys: keys}, nil
+}
+
+// asymmetricMethod checks that the public key suits the named algorithm
+func asymmetricMethod(alg string, public any) (jwt.SigningMethod, error) {
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		rsaKey, ok := public.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("RS256 needs an RSA key")
+		}
+		if rsaKey.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA keys must be at least %d bits", minRSABits)
+		}
+		return jwt.SigningMethodRS256, nil
+	case jwt.SigningMethodEdDSA.Alg():
+		if _, ok := public.(ed25519.PublicKey); !ok {
+			return nil, errors.New("EdDSA needs an Ed25519 key")
+		}
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q, use RS256 or EdDSA", alg)
+	}
+}
+
+func readPEMPrivateKey(path string) (any, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	return x509.ParsePKCS8PrivateKey(block.Bytes)
+}
+
+func readPEMPublicKey(path string) (any, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not PEM encoded", path)
+	}
+	return block, nil
+}
+
+// verifies reports whether the key is still accepted at now
+func (k SigningKey) verifies(now time.Time) bool {
+	return k.VerifyUntil.IsZero() || now.Before(k.VerifyUntil)
+}
+
+// SigningKey returns the key that signs new tokens at now: the most recently scheduled key
+// that has a private key and has not retired.
+func (ks *KeySet) SigningKey(now time.Time) (SigningKey, error) {
+	var current *SigningKey
+	for i, key := range ks.keys {
+		if key.private == nil || key.SignFrom.After(now) || !key.verifies(now) {
+			continue
+		}
+		if current == nil || key.SignFrom.After(current.SignFrom) {
+			current = &ks.keys[i]
+		}
+	}
+	if current == nil {
+		return SigningKey{}, errors.New("no signing key is active")
+	}
+	return *current, nil
+}
+
+// VerificationKey returns the unretired key with the given kid
+func (ks *KeySet) VerificationKey(kid string, now time.Time) (SigningKey, bool) {
+	for _, key := range ks.keys {
+		if key.ID == kid && key.verifies(now) {
+			return key, true
+		}
+	}
+	return SigningKey{}, false
+}
+
+// JSONWebKey is the public half of a signing key in JWK form
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS returns the public keys that verify tokens at now, including ones scheduled to sign later
+// so verifiers can cache them before they are used. HMAC secrets are never published.
+func (ks *KeySet) JWKS(now time.Time) JSONWebKeySet {
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, key := range ks.keys {
+		if !key.verifies(now) {
+			continue
+		}
+		jwk := JSONWebKey{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
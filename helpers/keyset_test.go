@@ -0,0 +1,170 @@
+package helpers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/trenchesdeveloper/csv-reporter/config"
+)
+
+func writePrivateKey(t *testing.T, dir, name string, key any) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0o600))
+}
+
+func writeKeySet(t *testing.T, dir, body string) string {
+	path := filepath.Join(dir, "keyset.json")
+	require.NoError(t, os.WriteFile(path, []byte(body), 0o600))
+	return path
+}
+
+func TestLoadKeySetRotation(t *testing.T) {
+	dir := t.TempDir()
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, nextKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	writePrivateKey(t, dir, "old.pem", edKey)
+	writePrivateKey(t, dir, "current.pem", rsaKey)
+	writePrivateKey(t, dir, "next.pem", nextKey)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	path := writeKeySet(t, dir, fmt.Sprintf(`{"keys":[
+		{"kid":"old","alg":"EdDSA","private_key":"old.pem","sign_from":%q,"verify_until":%q},
+		{"kid":"current","alg":"RS256","private_key":"current.pem","sign_from":%q},
+		{"kid":"next","alg":"EdDSA","private_key":"next.pem","sign_from":%q}
+	]}`,
+		now.Add(-30*24*time.Hour).Format(time.RFC3339), now.Add(time.Hour).Format(time.RFC3339),
+		now.Add(-time.Hour).Format(time.RFC3339),
+		now.Add(time.Hour).Format(time.RFC3339)))
+
+	keys, err := LoadKeySet(path)
+	require.NoError(t, err)
+
+	// the newest key that has started signing wins
+	key, err := keys.SigningKey(now)
+	require.NoError(t, err)
+	require.Equal(t, "current", key.ID)
+	key, err = keys.SigningKey(now.Add(2 * time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, "next", key.ID)
+
+	// the old key keeps verifying until it retires
+	_, ok := keys.VerificationKey("old", now)
+	require.True(t, ok)
+	_, ok = keys.VerificationKey("old", now.Add(2*time.Hour))
+	require.False(t, ok)
+
+	jwks := keys.JWKS(now)
+	require.Len(t, jwks.Keys, 3)
+	require.Equal(t, JSONWebKey{Kty: "RSA", Kid: "current", Use: "sig", Alg: "RS256", N: jwks.Keys[0].N, E: "AQAB"}, jwks.Keys[0])
+	require.Equal(t, "OKP", jwks.Keys[1].Kty)
+	require.Equal(t, "Ed25519", jwks.Keys[1].Crv)
+	require.Len(t, keys.JWKS(now.Add(2*time.Hour)).Keys, 2)
+}
+
+func TestLoadKeySetInvalid(t *testing.T) {
+	dir := t.TempDir()
+	weakKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	writePrivateKey(t, dir, "weak.pem", weakKey)
+	writePrivateKey(t, dir, "ed.pem", edKey)
+
+	testCases := []struct {
+		name string
+		body string
+	}{
+		{"no keys", `{"keys":[]}`},
+		{"missing kid", `{"keys":[{"alg":"EdDSA","private_key":"ed.pem"}]}`},
+		{"duplicate kid", `{"keys":[{"kid":"a","alg":"EdDSA","private_key":"ed.pem"},{"kid":"a","alg":"EdDSA","private_key":"ed.pem"}]}`},
+		{"algorithm mismatch", `{"keys":[{"kid":"a","alg":"RS256","private_key":"ed.pem"}]}`},
+		{"weak rsa key", `{"keys":[{"kid":"a","alg":"RS256","private_key":"weak.pem"}]}`},
+		{"hmac", `{"keys":[{"kid":"a","alg":"HS256","private_key":"ed.pem"}]}`},
+		{"missing file", `{"keys":[{"kid":"a","alg":"EdDSA","private_key":"nope.pem"}]}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadKeySet(writeKeySet(t, dir, tc.body))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestJwtManagerKeyRotation(t *testing.T) {
+	conf := &config.AppConfig{AppName: "csv_reporter", JWT_SECRET: "secretToSecretMySecret"}
+	_, oldKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, newKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	now := time.Now()
+	old := SigningKey{ID: "old", Method: jwt.SigningMethodEdDSA, SignFrom: now.Add(-time.Hour), private: oldKey, public: oldKey.Public()}
+	next := SigningKey{ID: "new", Method: jwt.SigningMethodEdDSA, SignFrom: now.Add(-time.Minute), private: newKey, public: newKey.Public()}
+
+	before := NewJwtManagerWithKeys(conf, &KeySet{keys: []SigningKey{old}})
+	oldTokens, err := before.GenerateTokenPairs(uuid.New())
+	require.NoError(t, err)
+
+	after := NewJwtManagerWithKeys(conf, &KeySet{keys: []SigningKey{old, next}})
+	newTokens, err := after.GenerateTokenPairs(uuid.New())
+	require.NoError(t, err)
+
+	token, err := after.ValidateToken(newTokens.AccessToken)
+	require.NoError(t, err)
+	require.Equal(t, "new", token.Header["kid"])
+	require.Equal(t, "EdDSA", token.Header["alg"])
+
+	// tokens signed before the rotation stay valid
+	token, err = after.ValidateToken(oldTokens.RefreshToken)
+	require.NoError(t, err)
+	require.Equal(t, "old", token.Header["kid"])
+
+	// until the old key retires
+	old.VerifyUntil = now.Add(-time.Second)
+	retired := NewJwtManagerWithKeys(conf, &KeySet{keys: []SigningKey{old, next}})
+	_, err = retired.ValidateToken(oldTokens.AccessToken)
+	require.Error(t, err)
+	require.Len(t, retired.JWKS().Keys, 1)
+}
+
+func TestJwtManagerRejectsHMACWithKeySet(t *testing.T) {
+	conf := &config.AppConfig{AppName: "csv_reporter", JWT_SECRET: "secretToSecretMySecret"}
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signing := SigningKey{ID: "current", Method: jwt.SigningMethodEdDSA, private: key, public: key.Public()}
+
+	hmacTokens, err := NewJwtManager(conf).GenerateTokenPairs(uuid.New())
+	require.NoError(t, err)
+
+	manager := NewJwtManagerWithKeys(conf, &KeySet{keys: []SigningKey{signing}})
+	_, err = manager.ValidateToken(hmacTokens.AccessToken)
+	require.Error(t, err)
+
+	// an HS256 token claiming the asymmetric kid must not verify either
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, CustomClaims{TokenType: "access"})
+	forged.Header["kid"] = "current"
+	forgedString, err := forged.SignedString([]byte(key.Public().(ed25519.PublicKey)))
+	require.NoError(t, err)
+	_, err = manager.ValidateToken(forgedString)
+	require.Error(t, err)
+
+	require.Empty(t, NewJwtManager(conf).JWKS().Keys)
+}
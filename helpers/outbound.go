@@ -0,0 +1,122 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned for outbound connections to loopback, private, link-local and
+// other non-public addresses, such as the cloud metadata service.
+var ErrForbiddenAddress = errors.New("address is not publicly routable")
+
+// reservedPrefixes are the non-public ranges netip.Addr has no predicate for
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT, also some metadata services
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved and broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, embeds IPv4 addresses
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+}
+
+// IsPublicAddr reports whether addr may be reached by outbound requests made on behalf of users
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() ||
+		addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewGuardedDialer returns a dialer that refuses to connect to non-public addresses. The check runs
+// on the resolved address of every connection, so a hostname that resolves to an internal address,
+// or starts to after it was validated, is refused as well.
+func NewGuardedDialer(timeout time.Duration) *net.Dialer {
+	return &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("invalid address %q: %w", address, err)
+			}
+			if !IsPublicAddr(addrPort.Addr()) {
+				return fmt.Errorf("refusing to connect to %s: %w", addrPort.Addr(), ErrForbiddenAddress)
+			}
+			return nil
+		},
+	}
+}
+
+// NewGuardedHTTPClient returns a client for requests to user supplied URLs. It connects through
+// NewGuardedDialer, ignores proxy settings and doesn't follow redirects, so a public URL can't
+// bounce a request to an internal one.
+func NewGuardedHTTPClient(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = NewGuardedDialer(time.Second * 10).DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// ValidateOutboundURL rejects URLs that outbound requests must not be made to: anything but http
+// and https, plain http when requireHTTPS is set, and hosts that are obviously internal. Hostnames
+// are only resolved when connecting, where NewGuardedDialer checks them.
+func ValidateOutboundURL(rawURL string, requireHTTPS bool) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	switch parsed.Scheme {
+	case "https":
+	case "http":
+		if requireHTTPS {
+			return errors.New("URL must use https")
+		}
+	default:
+		return errors.New("URL must use http or https")
+	}
+	return ValidateOutboundHost(parsed.Hostname())
+}
+
+// ValidateOutboundHost rejects hosts that are empty, localhost or a non-public IP address
+func ValidateOutboundHost(host string) error {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" {
+		return errors.New("host is required")
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrForbiddenAddress
+	}
+	if addr, err := netip.ParseAddr(strings.Trim(host, "[]")); err == nil && !IsPublicAddr(addr) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package helpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	testCases := []struct {
+		addr   string
+		public bool
+	}{
+		{"93.184.215.14", true},
+		{"2606:2800:21f:cb07:6820:80da:af6b:8b2c", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.100.100.200", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"fd00:ec2::254", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"64:ff9b::a9fe:a9fe", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.addr, func(t *testing.T) {
+			require.Equal(t, tc.public, IsPublicAddr(netip.MustParseAddr(tc.addr)))
+		})
+	}
+}
+
+func TestValidateOutboundURL(t *testing.T) {
+	testCases := []struct {
+		url          string
+		requireHTTPS bool
+		wantErr      bool
+	}{
+		{"https://hooks.example.com/reports", true, false},
+		{"http://hooks.example.com/reports", false, false},
+		{"http://hooks.example.com/reports", true, true},
+		{"ftp://hooks.example.com/reports", false, true},
+		{"https://localhost/hook", false, true},
+		{"https://api.localhost./hook", false, true},
+		{"https://127.0.0.1/hook", false, true},
+		{"https://[::1]:8443/hook", false, true},
+		{"http://169.254.169.254/latest/meta-data/", false, true},
+		{"https:///hook", false, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			err := ValidateOutboundURL(tc.url, tc.requireHTTPS)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestGuardedHTTPClientRefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// the test server listens on loopback, which the guarded client must not reach
+	_, err := NewGuardedHTTPClient(time.Second).Get(server.URL)
+	require.ErrorIs(t, err, ErrForbiddenAddress)
+
+	resp, err := server.Client().Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+}
+
+func TestGuardedHTTPClientDoesNotFollowRedirects(t *testing.T) {
+	client := NewGuardedHTTPClient(time.Second)
+	req := httptest.NewRequest(http.MethodGet, "https://hooks.example.com/", nil)
+	err := client.CheckRedirect(req, []*http.Request{req})
+	require.ErrorIs(t, err, http.ErrUseLastResponse)
+}
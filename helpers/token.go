@@ -0,0 +1,16 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// GenerateSecureToken returns a URL-safe random token built from n random bytes
+func GenerateSecureToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
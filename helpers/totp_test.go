@@ -0,0 +1,62 @@
+package helpers
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the base32 encoding of the RFC 6238 SHA-1 test key "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// the RFC 6238 test vectors, truncated to six digits
+	testCases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tc := range testCases {
+		code, err := TOTPCode(rfcSecret, TOTPStep(time.Unix(tc.unix, 0)))
+		require.NoError(t, err)
+		require.Equal(t, tc.code, code)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	require.NoError(t, err)
+	now := time.Now()
+
+	code, err := TOTPCode(secret, TOTPStep(now)-1)
+	require.NoError(t, err)
+	step, ok := ValidateTOTP(secret, code, now)
+	require.True(t, ok)
+	require.Equal(t, TOTPStep(now)-1, step)
+
+	// codes older than the allowed drift are refused
+	code, err = TOTPCode(secret, TOTPStep(now)-3)
+	require.NoError(t, err)
+	_, ok = ValidateTOTP(secret, code, now)
+	require.False(t, ok)
+
+	_, ok = ValidateTOTP(secret, "12345", now)
+	require.False(t, ok)
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(TOTPURI("CSV Reporter", "user@example.com", rfcSecret))
+	require.NoError(t, err)
+	require.Equal(t, "otpauth", uri.Scheme)
+	require.Equal(t, "totp", uri.Host)
+	require.Equal(t, "/CSV Reporter:user@example.com", uri.Path)
+	require.Equal(t, rfcSecret, uri.Query().Get("secret"))
+	require.Equal(t, "CSV Reporter", uri.Query().Get("issuer"))
+}
@@ -0,0 +1,77 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	verifyEmailTemplate   = "templates/verify_email.tmpl"
+	passwordResetTemplate = "templates/password_reset.tmpl"
+)
+
+// AccountEmail is the data the email verification and password reset templates are rendered with.
+type AccountEmail struct {
+	Email string
+	// URL opens the page that submits the token to the API.
+	URL       string
+	ExpiresAt time.Time
+}
+
+// AccountMailer sends the emails that verify addresses and reset passwords.
+type AccountMailer struct {
+	sender        Sender
+	baseURL       string
+	verifyEmail   *Templates
+	passwordReset *Templates
+}
+
+// NewAccountMailer sends account emails through sender, linking to pages under baseURL.
+func NewAccountMailer(sender Sender, baseURL string) (*AccountMailer, error) {
+	verifyEmail, err := loadEmbeddedTemplates(verifyEmailTemplate)
+	if err != nil {
+		return nil, err
+	}
+	passwordReset, err := loadEmbeddedTemplates(passwordResetTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &AccountMailer{
+		sender:        sender,
+		baseURL:       baseURL,
+		verifyEmail:   verifyEmail,
+		passwordReset: passwordReset,
+	}, nil
+}
+
+// SendVerification emails a link to <baseURL>/verify-email?token=...
+func (m *AccountMailer) SendVerification(ctx context.Context, to, token string, expiresAt time.Time) error {
+	return m.send(ctx, m.verifyEmail, "/verify-email", to, token, expiresAt)
+}
+
+// SendPasswordReset emails a link to <baseURL>/reset-password?token=...
+func (m *AccountMailer) SendPasswordReset(ctx context.Context, to, token string, expiresAt time.Time) error {
+	return m.send(ctx, m.passwordReset, "/reset-password", to, token, expiresAt)
+}
+
+func (m *AccountMailer) send(ctx context.Context, templates *Templates, path, to, token string, expiresAt time.Time) error {
+	msg, err := templates.Render([]string{to}, AccountEmail{
+		Email:     to,
+		URL:       m.baseURL + path + "?" + url.Values{"token": {token}}.Encode(),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+	return m.sender.Send(ctx, msg)
+}
+
+func loadEmbeddedTemplates(name string) (*Templates, error) {
+	source, err := templateFS.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read email template: %w", err)
+	}
+	return ParseTemplates(string(source))
+}
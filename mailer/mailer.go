@@ -0,0 +1,218 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trenchesdeveloper/csv-reporter/config"
+)
+
+// Message is a multipart email with a plain text and an HTML body.
+type Message struct {
+	To          []string
+	Subject     string
+	TextBody    string
+	HTMLBody    string
+	Attachments []Attachment
+}
+
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Sender sends email messages.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTPMailer sends messages through an SMTP server, upgrading to TLS when the server offers it.
+type SMTPMailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     *mail.Address
+}
+
+func NewSMTPMailer(config *config.AppConfig) (*SMTPMailer, error) {
+	if config.SMTP_HOST == "" {
+		return nil, errors.New("SMTP_HOST is not set")
+	}
+	from, err := mail.ParseAddress(config.SMTP_FROM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP_FROM address: %w", err)
+	}
+	return &SMTPMailer{
+		host:     config.SMTP_HOST,
+		port:     config.SMTP_PORT,
+		username: config.SMTP_USERNAME,
+		password: config.SMTP_PASSWORD,
+		from:     from,
+	}, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	data, err := buildMessage(m.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	dialer := net.Dialer{Timeout: time.Second * 10}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(m.host, strconv.Itoa(m.port)))
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if m.username != "" {
+		// PlainAuth refuses to send credentials over an unencrypted connection to a remote host
+		if err := client.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+
+	if err := client.Mail(m.from.Address); err != nil {
+		return fmt.Errorf("failed to set sender: %w", err)
+	}
+	for _, to := range msg.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("failed to add recipient %s: %w", to, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to start message: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return client.Quit()
+}
+
+// buildMessage encodes the message as multipart/mixed, with the bodies as multipart/alternative
+// followed by the attachments.
+func buildMessage(from *mail.Address, msg Message, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	mixed := multipart.NewWriter(&buf)
+
+	domain := "localhost"
+	if _, host, ok := strings.Cut(from.Address, "@"); ok {
+		domain = host
+	}
+
+	headers := [][2]string{
+		{"From", from.String()},
+		{"To", strings.Join(msg.To, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", msg.Subject)},
+		{"Date", now.Format(time.RFC1123Z)},
+		{"Message-ID", fmt.Sprintf("<%s@%s>", uuid.New(), domain)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/mixed; boundary=" + mixed.Boundary()},
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&buf, "%s: %s\r\n", header[0], header[1])
+	}
+	buf.WriteString("\r\n")
+
+	alternativeBoundary := multipart.NewWriter(io.Discard).Boundary()
+	body, err := mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"multipart/alternative; boundary=" + alternativeBoundary},
+	})
+	if err != nil {
+		return nil, err
+	}
+	alternative := multipart.NewWriter(body)
+	if err := alternative.SetBoundary(alternativeBoundary); err != nil {
+		return nil, err
+	}
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", msg.TextBody},
+		{"text/html; charset=utf-8", msg.HTMLBody},
+	} {
+		w, err := alternative.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := alternative.Close(); err != nil {
+		return nil, err
+	}
+
+	for _, attachment := range msg.Attachments {
+		w, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(attachment.ContentType, map[string]string{"name": attachment.Filename})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBase64(w, attachment.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeBase64 writes data as base64 in lines of 76 characters, as RFC 2045 requires.
+func writeBase64(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		if _, err := fmt.Fprintf(w, "%s\r\n", encoded[:76]); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err := fmt.Fprintf(w, "%s\r\n", encoded)
+	return err
+}
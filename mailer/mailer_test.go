@@ -0,0 +1,237 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/trenchesdeveloper/csv-reporter/config"
+)
+
+func testReportEmail() ReportEmail {
+	return ReportEmail{
+		ReportID:    uuid.New(),
+		ReportType:  "monsters",
+		Game:        "totk",
+		Format:      "csv",
+		Rows:        42,
+		CompletedAt: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC),
+		Filename:    "monsters-totk-2026-10-19-1a2b3c4d.csv.gz",
+	}
+}
+
+func TestRenderReportEmail(t *testing.T) {
+	templates, err := LoadTemplates("")
+	require.NoError(t, err)
+
+	attached := testReportEmail()
+	attached.Attached = true
+	msg, err := templates.Render([]string{"link@example.com"}, attached)
+	require.NoError(t, err)
+	require.Equal(t, "Your monsters report is ready", msg.Subject)
+	require.Contains(t, msg.TextBody, "attached as monsters-totk-2026-10-19-1a2b3c4d.csv.gz")
+	require.NotContains(t, msg.TextBody, "Download it here")
+
+	linked := testReportEmail()
+	linked.DownloadURL = "https://example.com/report?a=1&b=2"
+	linked.LinkExpiresAt = linked.CompletedAt.Add(72 * time.Hour)
+	msg, err = templates.Render([]string{"link@example.com"}, linked)
+	require.NoError(t, err)
+	require.Contains(t, msg.TextBody, "Download it here: https://example.com/report?a=1&b=2")
+	require.Contains(t, msg.HTMLBody, `href="https://example.com/report?a=1&amp;b=2"`)
+}
+
+func TestParseTemplatesRequiresBlocks(t *testing.T) {
+	_, err := ParseTemplates(`{{define "subject"}}Hi{{end}}`)
+	require.ErrorContains(t, err, `"plainBody"`)
+}
+
+func TestBuildMessage(t *testing.T) {
+	from := &mail.Address{Name: "CSV Reporter", Address: "reports@example.com"}
+	data, err := buildMessage(from, Message{
+		To:       []string{"a@example.com", "b@example.com"},
+		Subject:  "Your report is ready ✓",
+		TextBody: "plain body",
+		HTMLBody: "<p>html body</p>",
+		Attachments: []Attachment{{
+			Filename:    "report.csv.gz",
+			ContentType: "application/gzip",
+			Data:        []byte(strings.Repeat("x", 200)),
+		}},
+	}, time.Now())
+	require.NoError(t, err)
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(data)))
+	require.NoError(t, err)
+	require.Equal(t, "a@example.com, b@example.com", msg.Header.Get("To"))
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.NoError(t, err)
+	require.Equal(t, "Your report is ready ✓", subject)
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/mixed", mediaType)
+
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+
+	body, err := parts.NextPart()
+	require.NoError(t, err)
+	mediaType, params, err = mime.ParseMediaType(body.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/alternative", mediaType)
+	alternatives := multipart.NewReader(body, params["boundary"])
+	for _, want := range []string{"plain body", "<p>html body</p>"} {
+		part, err := alternatives.NextPart()
+		require.NoError(t, err)
+		content, err := io.ReadAll(part)
+		require.NoError(t, err)
+		require.Equal(t, want, string(content))
+	}
+
+	attachment, err := parts.NextPart()
+	require.NoError(t, err)
+	require.Equal(t, "report.csv.gz", attachment.FileName())
+	require.Equal(t, "base64", attachment.Header.Get("Content-Transfer-Encoding"))
+	content, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, attachment))
+	require.NoError(t, err)
+	require.Equal(t, strings.Repeat("x", 200), string(content))
+
+	_, err = parts.NextPart()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+// fakeSMTPServer accepts a single session and returns the recipients and data it received.
+func fakeSMTPServer(t *testing.T) (string, <-chan []string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var lines []string
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				reply("250 localhost")
+			case strings.HasPrefix(line, "MAIL FROM:"), strings.HasPrefix(line, "RCPT TO:"):
+				lines = append(lines, line)
+				reply("250 OK")
+			case line == "DATA":
+				reply("354 go ahead")
+				for {
+					data, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if data == ".\r\n" {
+						break
+					}
+					lines = append(lines, strings.TrimRight(data, "\r\n"))
+				}
+				reply("250 OK")
+			case line == "QUIT":
+				reply("221 bye")
+				received <- lines
+				return
+			default:
+				reply("500 unknown command")
+			}
+		}
+	}()
+
+	return listener.Addr().String(), received
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	addr, received := fakeSMTPServer(t)
+	host, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	sender, err := NewSMTPMailer(&config.AppConfig{
+		SMTP_HOST: host,
+		SMTP_PORT: portNumber,
+		SMTP_FROM: "CSV Reporter <reports@example.com>",
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = sender.Send(ctx, Message{
+		To:       []string{"stakeholder@example.com"},
+		Subject:  "Report",
+		TextBody: "plain body",
+		HTMLBody: "<p>html body</p>",
+	})
+	require.NoError(t, err)
+
+	lines := <-received
+	require.Equal(t, "MAIL FROM:<reports@example.com>", lines[0])
+	require.Equal(t, "RCPT TO:<stakeholder@example.com>", lines[1])
+	require.Contains(t, lines, "To: stakeholder@example.com")
+	require.Contains(t, lines, "Subject: Report")
+}
+
+func TestRetryDelay(t *testing.T) {
+	require.Equal(t, time.Minute, retryDelay(1))
+	require.Equal(t, 4*time.Minute, retryDelay(3))
+	require.Equal(t, maxRetryDelay, retryDelay(20))
+}
+
+// recordingSender keeps the messages it is asked to send.
+type recordingSender struct {
+	messages []Message
+}
+
+func (s *recordingSender) Send(ctx context.Context, msg Message) error {
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+func TestAccountMailer(t *testing.T) {
+	sender := &recordingSender{}
+	accountMailer, err := NewAccountMailer(sender, "https://reports.example.com")
+	require.NoError(t, err)
+	expiresAt := time.Date(2026, 10, 19, 13, 0, 0, 0, time.UTC)
+
+	require.NoError(t, accountMailer.SendVerification(context.Background(), "new@example.com", "abc+/=", expiresAt))
+	require.NoError(t, accountMailer.SendPasswordReset(context.Background(), "new@example.com", "def", expiresAt))
+	require.Len(t, sender.messages, 2)
+
+	verification := sender.messages[0]
+	require.Equal(t, []string{"new@example.com"}, verification.To)
+	require.Equal(t, "Verify your email address", verification.Subject)
+	require.Contains(t, verification.TextBody, "https://reports.example.com/verify-email?token=abc%2B%2F%3D")
+	require.Contains(t, verification.TextBody, "2026-10-19 13:00 UTC")
+
+	reset := sender.messages[1]
+	require.Equal(t, "Reset your password", reset.Subject)
+	require.Contains(t, reset.TextBody, "https://reports.example.com/reset-password?token=def")
+	require.Contains(t, reset.HTMLBody, `href="https://reports.example.com/reset-password?token=def"`)
+}
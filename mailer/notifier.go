@@ -0,0 +1,268 @@
+package mailer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/trenchesdeveloper/csv-reporter/config"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"github.com/trenchesdeveloper/csv-reporter/reports"
+	"go.uber.org/zap"
+)
+
+const (
+	StatusWaiting = "waiting"
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+
+	maxAttempts      = 5
+	retryBaseDelay   = time.Minute
+	maxRetryDelay    = time.Hour
+	claimBatchSize   = 10
+	sendTimeout      = time.Minute
+	attachmentFormat = "application/gzip"
+	// claimLease covers a whole batch of sends that all time out, so no delivery is claimed
+	// and emailed again by another worker while this one is still working through the batch
+	claimLease = claimBatchSize*sendTimeout + time.Minute
+)
+
+// Notifier emails completed reports to the recipients given at creation, attaching the file
+// when it is small enough and linking to it otherwise.
+type Notifier struct {
+	store         db.Store
+	sender        Sender
+	templates     *Templates
+	s3Client      *s3.Client
+	presignClient *s3.PresignClient
+	config        *config.AppConfig
+	logger        *zap.SugaredLogger
+	wake          chan struct{}
+}
+
+func NewNotifier(store db.Store, sender Sender, templates *Templates, s3Client *s3.Client, config *config.AppConfig, logger *zap.SugaredLogger) *Notifier {
+	return &Notifier{
+		store:         store,
+		sender:        sender,
+		templates:     templates,
+		s3Client:      s3Client,
+		presignClient: s3.NewPresignClient(s3Client),
+		config:        config,
+		logger:        logger,
+		wake:          make(chan struct{}, 1),
+	}
+}
+
+// ReportFinished releases the email deliveries of a completed report. Deliveries of failed
+// reports keep waiting, so they are sent if a retry completes.
+func (n *Notifier) ReportFinished(ctx context.Context, report db.Report) error {
+	if !report.CompletedAt.Valid {
+		return nil
+	}
+
+	activated, err := n.store.ActivateReportEmailDeliveries(ctx, report.ID)
+	if err != nil {
+		return fmt.Errorf("failed to activate email deliveries: %w", err)
+	}
+	if activated > 0 {
+		n.Wake()
+	}
+	return nil
+}
+
+// Wake makes the notifier look for due deliveries without waiting for the next poll.
+func (n *Notifier) Wake() {
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start sends due report emails until the context is cancelled.
+func (n *Notifier) Start(ctx context.Context) {
+	interval := n.config.EMAIL_POLL_INTERVAL
+	if interval <= 0 {
+		interval = time.Second * 10
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	n.logger.Info("email notifier started")
+	for {
+		n.sendDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			n.logger.Info("email notifier stopping due to context cancellation")
+			return
+		case <-ticker.C:
+		case <-n.wake:
+		}
+	}
+}
+
+func (n *Notifier) sendDue(ctx context.Context) {
+	for {
+		// claimed deliveries are leased, so other workers skip them while we send
+		deliveries, err := n.store.ClaimDueReportEmailDeliveries(ctx, db.ClaimDueReportEmailDeliveriesParams{
+			LeaseUntil: time.Now().Add(claimLease),
+			Limit:      claimBatchSize,
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				n.logger.Errorw("failed to claim email deliveries", "error", err)
+			}
+			return
+		}
+
+		for _, delivery := range deliveries {
+			n.deliver(ctx, delivery)
+		}
+
+		if len(deliveries) < claimBatchSize {
+			return
+		}
+	}
+}
+
+func (n *Notifier) deliver(ctx context.Context, delivery db.ReportEmailDelivery) {
+	sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+	sendErr := n.send(sendCtx, delivery)
+	cancel()
+	attempt := int(delivery.AttemptCount) + 1
+
+	n.logger.Infow("report email attempt",
+		"delivery_id", delivery.ID,
+		"report_id", delivery.ReportID,
+		"attempt", attempt,
+		"error", sendErr,
+	)
+
+	now := time.Now()
+	result := db.UpdateReportEmailDeliveryResultParams{
+		ID:            delivery.ID,
+		Status:        StatusSent,
+		NextAttemptAt: now,
+		SentAt:        sql.NullTime{Time: now, Valid: true},
+	}
+	if sendErr != nil {
+		result.SentAt = sql.NullTime{Valid: false}
+		result.LastError = sql.NullString{String: sendErr.Error(), Valid: true}
+		if attempt >= maxAttempts {
+			result.Status = StatusFailed
+		} else {
+			result.Status = StatusPending
+			result.NextAttemptAt = now.Add(retryDelay(attempt))
+		}
+	}
+
+	if _, err := n.store.UpdateReportEmailDeliveryResult(ctx, result); err != nil {
+		n.logger.Errorw("failed to update email delivery", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+func (n *Notifier) send(ctx context.Context, delivery db.ReportEmailDelivery) error {
+	report, err := n.store.GetReport(ctx, db.GetReportParams{
+		UserID: delivery.UserID,
+		ID:     delivery.ReportID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get report: %w", err)
+	}
+	params, err := reports.ParamsFromReport(report)
+	if err != nil {
+		return err
+	}
+
+	data := ReportEmail{
+		ReportID:    report.ID,
+		ReportType:  params.Type,
+		Game:        params.Game,
+		Format:      params.Format,
+		Rows:        report.RowsWritten,
+		CompletedAt: report.CompletedAt.Time,
+		Filename:    reports.DownloadFilename(report, params),
+	}
+
+	key := report.OutputFilePath.String
+	head, err := n.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(n.config.S3_BUCKET),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get report file: %w", err)
+	}
+
+	var attachments []Attachment
+	if size := aws.ToInt64(head.ContentLength); size <= n.config.EMAIL_ATTACHMENT_MAX_BYTES {
+		file, err := n.download(ctx, key)
+		if err != nil {
+			return err
+		}
+		attachments = append(attachments, Attachment{
+			Filename:    data.Filename,
+			ContentType: attachmentFormat,
+			Data:        file,
+		})
+		data.Attached = true
+	} else {
+		ttl := min(n.config.EMAIL_LINK_TTL, reports.MaxPresignTTL)
+		signed, err := n.presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(n.config.S3_BUCKET),
+			Key:    aws.String(key),
+		}, func(options *s3.PresignOptions) {
+			options.Expires = ttl
+		})
+		if err != nil {
+			return fmt.Errorf("failed to presign download link: %w", err)
+		}
+		data.DownloadURL = signed.URL
+		data.LinkExpiresAt = time.Now().Add(ttl)
+	}
+
+	msg, err := n.templates.Render([]string{delivery.Recipient}, data)
+	if err != nil {
+		return err
+	}
+	msg.Attachments = attachments
+
+	return n.sender.Send(ctx, msg)
+}
+
+func (n *Notifier) download(ctx context.Context, key string) ([]byte, error) {
+	object, err := n.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(n.config.S3_BUCKET),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get report file: %w", err)
+	}
+	defer object.Body.Close()
+
+	// the object could have been replaced since it was sized, so never read more than the limit
+	file, err := io.ReadAll(io.LimitReader(object.Body, n.config.EMAIL_ATTACHMENT_MAX_BYTES+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report file: %w", err)
+	}
+	if int64(len(file)) > n.config.EMAIL_ATTACHMENT_MAX_BYTES {
+		return nil, fmt.Errorf("report file exceeds the attachment limit")
+	}
+	return file, nil
+}
+
+// retryDelay returns the delay before the next attempt, doubling from retryBaseDelay up to maxRetryDelay.
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= maxRetryDelay {
+			return maxRetryDelay
+		}
+	}
+	return delay
+}
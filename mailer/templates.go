@@ -0,0 +1,98 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+const defaultTemplate = "templates/report_completed.tmpl"
+
+// ReportEmail is the data the report email templates are rendered with.
+type ReportEmail struct {
+	ReportID    uuid.UUID
+	ReportType  string
+	Game        string
+	Format      string
+	Rows        int32
+	CompletedAt time.Time
+	Filename    string
+	// Attached is set when the file is attached; otherwise DownloadURL links to it.
+	Attached      bool
+	DownloadURL   string
+	LinkExpiresAt time.Time
+}
+
+// Templates renders emails. A template file defines "subject", "plainBody" and "htmlBody";
+// the HTML body is escaped as HTML.
+type Templates struct {
+	text *template.Template
+	html *htmltemplate.Template
+}
+
+// LoadTemplates parses the template file at path, or the built-in template when path is empty.
+func LoadTemplates(path string) (*Templates, error) {
+	var source []byte
+	var err error
+	if path == "" {
+		source, err = templateFS.ReadFile(defaultTemplate)
+	} else {
+		source, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read email template: %w", err)
+	}
+	return ParseTemplates(string(source))
+}
+
+// ParseTemplates parses email templates from source.
+func ParseTemplates(source string) (*Templates, error) {
+	text, err := template.New("email").Option("missingkey=error").Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse email template: %w", err)
+	}
+	html, err := htmltemplate.New("email").Option("missingkey=error").Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse email template: %w", err)
+	}
+	for _, name := range []string{"subject", "plainBody", "htmlBody"} {
+		if text.Lookup(name) == nil {
+			return nil, fmt.Errorf("email template does not define %q", name)
+		}
+	}
+	return &Templates{text: text, html: html}, nil
+}
+
+// Render renders the email to the given recipients, with a ReportEmail or AccountEmail as data.
+func (t *Templates) Render(to []string, data any) (Message, error) {
+	msg := Message{To: to}
+
+	var buf bytes.Buffer
+	if err := t.text.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render email subject: %w", err)
+	}
+	msg.Subject = buf.String()
+
+	buf.Reset()
+	if err := t.text.ExecuteTemplate(&buf, "plainBody", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render email body: %w", err)
+	}
+	msg.TextBody = buf.String()
+
+	buf.Reset()
+	if err := t.html.ExecuteTemplate(&buf, "htmlBody", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render email body: %w", err)
+	}
+	msg.HTMLBody = buf.String()
+
+	return msg, nil
+}
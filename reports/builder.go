@@ -5,7 +5,7 @@ import (
 	"compress/gzip"
 	"context"
 	"database/sql"
-	"encoding/csv"
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -13,17 +13,24 @@ import (
 	"github.com/trenchesdeveloper/csv-reporter/config"
 	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
 	"go.uber.org/zap"
-	"strconv"
-	"strings"
 	"time"
 )
 
+// ErrReportCancelled is the cause given to a build context when its report is cancelled.
+var ErrReportCancelled = errors.New("report cancelled")
+
+// ReportListener is notified once a report build has completed or failed.
+type ReportListener interface {
+	ReportFinished(ctx context.Context, report db.Report) error
+}
+
 type ReportBuilder struct {
 	store     db.Store
 	lozClient *LozClient
 	s3Client  *s3.Client
 	config    *config.AppConfig
 	logger    *zap.SugaredLogger
+	listeners []ReportListener
 }
 
 func NewReportBuilder(store db.Store, lozClient *LozClient, s3Client *s3.Client, config *config.AppConfig, logger *zap.SugaredLogger) *ReportBuilder {
@@ -36,6 +43,11 @@ func NewReportBuilder(store db.Store, lozClient *LozClient, s3Client *s3.Client,
 	}
 }
 
+// AddListener registers a listener that is notified about finished builds.
+func (rb *ReportBuilder) AddListener(listener ReportListener) {
+	rb.listeners = append(rb.listeners, listener)
+}
+
 func (rb *ReportBuilder) BuildReport(ctx context.Context, userId uuid.UUID, reportId uuid.UUID) (report db.Report, err error) {
 	// Fetch the report from the database
 	report, err = rb.store.GetReport(ctx, db.GetReportParams{
@@ -45,120 +57,266 @@ func (rb *ReportBuilder) BuildReport(ctx context.Context, userId uuid.UUID, repo
 	if err != nil {
 		return db.Report{}, fmt.Errorf("failed to get report %s: %w", reportId, err)
 	}
-	// Check if the report is already built
-	if report.StartedAt.Valid {
+	// Check if the report is already built or was cancelled before it started
+	if report.StartedAt.Valid || report.CancelledAt.Valid {
 		return report, nil
 	}
+	// claim the build, so a duplicate message for the same report can't build it twice
+	startedReport, err := rb.store.StartReport(ctx, db.StartReportParams{
+		ID:     report.ID,
+		UserID: report.UserID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			rb.logger.Infow("report already started or cancelled", "report_id", reportId)
+			return report, nil
+		}
+		return db.Report{}, fmt.Errorf("failed to start report %s: %w", reportId, err)
+	}
+	report = startedReport
+
+	// the build context is cancelled as soon as the report is cancelled through the API
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go rb.watchForCancellation(ctx, report, cancel)
 
+	var key string
+	progress := rb.newProgress(report)
 	defer func() {
-		if err != nil {
-			// If an error occurs, update the report with the error message
-			_, updateErr := rb.store.UpdateReport(ctx, db.UpdateReportParams{
-				ID:           report.ID,
-				UserID:       report.UserID,
-				FailedAt:     sql.NullTime{Time: time.Now(), Valid: true},
-				ErrorMessage: sql.NullString{String: err.Error(), Valid: true},
+		if err == nil {
+			return
+		}
+
+		// the build context may already be done, so clean up with a fresh one
+		cleanupCtx, cleanupCancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second*5)
+		defer cleanupCancel()
+
+		if errors.Is(err, ErrReportCancelled) || errors.Is(context.Cause(ctx), ErrReportCancelled) {
+			rb.logger.Infow("report build cancelled", "report_id", reportId)
+			if key != "" {
+				rb.removeObject(cleanupCtx, key)
+			}
+			report, err = rb.store.GetReport(cleanupCtx, db.GetReportParams{
+				ID:     reportId,
+				UserID: userId,
 			})
-			if updateErr != nil {
-				err = fmt.Errorf("failed to update report with error: %w", updateErr)
+			if err != nil {
+				err = fmt.Errorf("failed to get cancelled report %s: %w", reportId, err)
+				return
 			}
+			rb.publishStatus(cleanupCtx, report, "cancelled")
+			return
+		}
+
+		// If an error occurs, update the report with the error message
+		failedReport, updateErr := rb.store.UpdateReport(cleanupCtx, db.UpdateReportParams{
+			ID:           report.ID,
+			UserID:       report.UserID,
+			FailedAt:     sql.NullTime{Time: time.Now(), Valid: true},
+			ErrorMessage: sql.NullString{String: err.Error(), Valid: true},
+		})
+		if updateErr != nil {
+			err = fmt.Errorf("failed to update report with error: %w", updateErr)
+			return
 		}
+		report = failedReport
+		rb.publishStatus(cleanupCtx, failedReport, "failed")
+		rb.notifyListeners(cleanupCtx, failedReport)
 	}()
 
-	now := time.Now()
-	// Update the report
-	updatedReport, err := rb.store.UpdateReport(ctx, db.UpdateReportParams{
-		ID:                report.ID,
-		UserID:            report.UserID,
-		StartedAt:         sql.NullTime{Time: now, Valid: true},
-		CompletedAt:       sql.NullTime{Valid: false},
-		FailedAt:          sql.NullTime{Valid: false},
-		ErrorMessage:      sql.NullString{Valid: false},
-		DownloadUrl:       sql.NullString{Valid: false},
-		DownloadExpiresAt: sql.NullTime{Valid: false},
-		OutputFilePath:    sql.NullString{Valid: false},
-	})
+	rb.publishStatus(ctx, startedReport, "processing")
 
+	params, err := ParamsFromReport(report)
 	if err != nil {
-		return db.Report{}, fmt.Errorf("failed to update report %s: %w", reportId, err)
-
+		return db.Report{}, err
+	}
+	paramsHash, err := params.Hash()
+	if err != nil {
+		return db.Report{}, err
 	}
 
-	resp, err := rb.lozClient.GetMonsters()
+	// an identical report built recently is reused instead of rebuilt
+	if reused, ok := rb.reuseArtifact(ctx, report, paramsHash); ok {
+		rb.logger.Infow("reused report artifact", "report_id", reportId, "artifact_id", reused.ArtifactID.UUID)
+		rb.publishStatus(ctx, reused, "completed")
+		rb.notifyListeners(ctx, reused)
+		return reused, nil
+	}
 
+	progress.enter(ctx, PhaseFetching)
+	entries, err := rb.lozClient.FetchEntries(ctx, params)
 	if err != nil {
-		return db.Report{}, fmt.Errorf("failed to fetch monsters: %w", err)
+		return db.Report{}, fmt.Errorf("failed to fetch %s: %w", params.Type, err)
 	}
-	if len(resp.Data) == 0 {
-		return db.Report{}, fmt.Errorf("no monsters found")
+	if len(entries) == 0 {
+		return db.Report{}, fmt.Errorf("no %s found", params.Type)
 	}
+	rows := Rows(entries, params, 0)
+	progress.fetched(ctx, int64(len(entries)), int64(len(rows)))
 
+	progress.enter(ctx, PhaseEncoding)
 	var buffer bytes.Buffer
 	qzipWriter := gzip.NewWriter(&buffer)
-	csvWriter := csv.NewWriter(qzipWriter)
-	header := []string{
-		"name",
-		"id",
-		"category",
-		"description",
-		"image",
-		"common_locations",
-		"drops",
-		"dlc",
-	}
-	if err := csvWriter.Write(header); err != nil {
-		return db.Report{}, fmt.Errorf("failed to write CSV header: %w", err)
-	}
-	for _, monster := range resp.Data {
-		if err := csvWriter.Write([]string{
-			monster.Name,
-			fmt.Sprintf("%d", monster.Id),
-			monster.Category,
-			monster.Description,
-			monster.Image,
-			strings.Join(monster.Drops, ", "),
-			strings.Join(monster.CommonLocations, ", "),
-			strconv.FormatBool(monster.Dlc),
-		}); err != nil {
-			return db.Report{}, fmt.Errorf("failed to write CSV row: %w", err)
+	rowWriter, err := NewRowWriter(qzipWriter, params)
+	if err != nil {
+		return db.Report{}, err
+	}
+	if err := rowWriter.WriteHeader(params.Columns); err != nil {
+		return db.Report{}, fmt.Errorf("failed to write header: %w", err)
+	}
+	for _, row := range rows {
+		if err := rowWriter.WriteRow(row); err != nil {
+			return db.Report{}, fmt.Errorf("failed to write row: %w", err)
 		}
+		progress.wrote(ctx, 1, int64(buffer.Len()))
 	}
-	csvWriter.Flush()
-	if err := csvWriter.Error(); err != nil {
-		return db.Report{}, fmt.Errorf("failed to flush CSV writer: %w", err)
+	if err := rowWriter.Close(); err != nil {
+		return db.Report{}, fmt.Errorf("failed to flush rows: %w", err)
 	}
 
 	if err := qzipWriter.Close(); err != nil {
 		return db.Report{}, fmt.Errorf("failed to close gzip writer: %w", err)
 	}
+	progress.wrote(ctx, 0, int64(buffer.Len()))
 
-	// prepare the file for S3 upload
-	key := "/users/" + userId.String() + "/reports/" + reportId.String() + ".csv.gz"
+	key = ObjectKey(report, paramsHash, params)
 
 	// Upload the file to S3
+	progress.enter(ctx, PhaseUploading)
 	_, err = rb.s3Client.PutObject(ctx, &s3.PutObjectInput{
 		Bucket: aws.String(rb.config.S3_BUCKET),
 		Key:    aws.String(key),
-		Body:   bytes.NewReader(buffer.Bytes()),
+		Body: &progressReader{
+			reader:   bytes.NewReader(buffer.Bytes()),
+			ctx:      ctx,
+			size:     int64(buffer.Len()),
+			progress: progress,
+		},
 	})
 	if err != nil {
 		return db.Report{}, fmt.Errorf("failed to upload report to S3: %w", err)
 	}
+	progress.uploaded(ctx, int64(buffer.Len()))
+	progress.flush(ctx)
 
-	// Update the report with the download URL and expiration
-	now = time.Now()
-	updatedReport, err = rb.store.UpdateReport(ctx, db.UpdateReportParams{
-		ID:             report.ID,
+	// Mark the report as completed unless it was cancelled in the meantime
+	result, err := rb.store.CompleteReportTx(ctx, db.CompleteReportTxParams{
 		UserID:         report.UserID,
-		OutputFilePath: sql.NullString{String: key, Valid: true},
-		CompletedAt:    sql.NullTime{Time: now, Valid: true},
+		ID:             report.ID,
+		ParamsHash:     paramsHash,
+		ObjectKey:      key,
+		SizeBytes:      int64(buffer.Len()),
+		RowCount:       int32(len(rows)),
+		OrganizationID: report.OrganizationID,
 	})
 
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return db.Report{}, ErrReportCancelled
+		}
 		return db.Report{}, fmt.Errorf("failed to update report %s: %w", reportId, err)
 	}
+	updatedReport := result.Report
 
 	rb.logger.Info("successfully uploaded report to S3")
+	rb.publishStatus(ctx, updatedReport, "completed")
+	rb.notifyListeners(ctx, updatedReport)
 
 	return updatedReport, nil
 }
+
+func (rb *ReportBuilder) notifyListeners(ctx context.Context, report db.Report) {
+	for _, listener := range rb.listeners {
+		if err := listener.ReportFinished(ctx, report); err != nil {
+			rb.logger.Errorw("report listener failed", "report_id", report.ID, "error", err)
+		}
+	}
+}
+
+// watchForCancellation polls the report until the build finishes and cancels
+// the build context once the report has been cancelled.
+func (rb *ReportBuilder) watchForCancellation(ctx context.Context, report db.Report, cancel context.CancelCauseFunc) {
+	interval := rb.config.REPORT_CANCEL_POLL_INTERVAL
+	if interval <= 0 {
+		interval = time.Second * 2
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current, err := rb.store.GetReport(ctx, db.GetReportParams{
+				ID:     report.ID,
+				UserID: report.UserID,
+			})
+			if err != nil {
+				if ctx.Err() == nil {
+					rb.logger.Warnw("failed to check report for cancellation", "report_id", report.ID, "error", err)
+				}
+				continue
+			}
+			if current.CancelledAt.Valid {
+				cancel(ErrReportCancelled)
+				return
+			}
+		}
+	}
+}
+
+// ObjectKey returns the S3 key a report is uploaded to. Artifacts are shared by every report with
+// the same params, within the organization of the report or among personal reports.
+func ObjectKey(report db.Report, paramsHash string, params Params) string {
+	key := "/artifacts/" + paramsHash + "/" + report.ID.String() + params.Extension()
+	if report.OrganizationID.Valid {
+		key = "/orgs/" + report.OrganizationID.UUID.String() + key
+	}
+	return key
+}
+
+// reuseArtifact completes the report with a recent artifact of identical params, if there is one.
+func (rb *ReportBuilder) reuseArtifact(ctx context.Context, report db.Report, paramsHash string) (db.Report, bool) {
+	ttl := rb.config.REPORT_CACHE_TTL
+	if ttl <= 0 {
+		return db.Report{}, false
+	}
+
+	artifact, err := rb.store.GetReusableReportArtifact(ctx, db.GetReusableReportArtifactParams{
+		ParamsHash:     paramsHash,
+		OrganizationID: report.OrganizationID,
+		CreatedAfter:   time.Now().Add(-ttl),
+	})
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			rb.logger.Warnw("failed to look up report artifact", "report_id", report.ID, "error", err)
+		}
+		return db.Report{}, false
+	}
+
+	// the artifact may have been released in the meantime; building it again is always safe
+	result, err := rb.store.ReuseReportArtifactTx(ctx, db.ReuseReportArtifactTxParams{
+		UserID:     report.UserID,
+		ID:         report.ID,
+		ArtifactID: artifact.ID,
+	})
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			rb.logger.Warnw("failed to reuse report artifact", "report_id", report.ID, "error", err)
+		}
+		return db.Report{}, false
+	}
+	return result.Report, true
+}
+
+// removeObject deletes an uploaded object, e.g. when the build was cancelled after the upload.
+func (rb *ReportBuilder) removeObject(ctx context.Context, key string) {
+	_, err := rb.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(rb.config.S3_BUCKET),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		rb.logger.Errorw("failed to remove report object", "key", key, "error", err)
+	}
+}
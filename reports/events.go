@@ -0,0 +1,67 @@
+package reports
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+)
+
+// ReportEventsChannel is the Postgres NOTIFY channel report events are published on.
+const ReportEventsChannel = "report_events"
+
+const (
+	EventStatus   = "status"
+	EventProgress = "progress"
+)
+
+// ReportEvent describes a status transition or progress update of a report build.
+type ReportEvent struct {
+	Type          string    `json:"type"`
+	ReportID      uuid.UUID `json:"report_id"`
+	UserID        uuid.UUID `json:"user_id"`
+	Status        string    `json:"status,omitempty"`
+	RowsFetched   int64     `json:"rows_fetched,omitempty"`
+	RowsWritten   int64     `json:"rows_written"`
+	BytesUploaded int64     `json:"bytes_uploaded"`
+	Phase         string    `json:"phase,omitempty"`
+	RowsTotal     int64     `json:"rows_total"`
+	BytesWritten  int64     `json:"bytes_written"`
+	ErrorMessage  string    `json:"error_message,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// PublishEvent sends the event to every API instance listening on ReportEventsChannel.
+func PublishEvent(ctx context.Context, store db.Store, event ReportEvent) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal report event: %w", err)
+	}
+	if err := store.PublishReportEvent(ctx, string(payload)); err != nil {
+		return fmt.Errorf("failed to publish report event: %w", err)
+	}
+	return nil
+}
+
+// publish sends a report event; failures are logged because events are best effort.
+func (rb *ReportBuilder) publish(ctx context.Context, event ReportEvent) {
+	if err := PublishEvent(ctx, rb.store, event); err != nil {
+		rb.logger.Warnw("failed to publish report event", "report_id", event.ReportID, "error", err)
+	}
+}
+
+func (rb *ReportBuilder) publishStatus(ctx context.Context, report db.Report, status string) {
+	rb.publish(ctx, ReportEvent{
+		Type:         EventStatus,
+		ReportID:     report.ID,
+		UserID:       report.UserID,
+		Status:       status,
+		ErrorMessage: report.ErrorMessage.String,
+	})
+}
@@ -0,0 +1,158 @@
+package reports
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Row holds the values of a single report row, in the order of Params.Columns.
+type Row []string
+
+// FetchEntries loads the compendium entries a report is built from.
+func (c *LozClient) FetchEntries(ctx context.Context, params Params) ([]Entry, error) {
+	reportType := reportTypes[params.Type]
+	resp, err := c.GetEntries(ctx, reportType.category, params.Game)
+	if err != nil {
+		return nil, err
+	}
+	if reportType.kind == nil {
+		return resp.Data, nil
+	}
+	return slices.DeleteFunc(resp.Data, func(entry Entry) bool {
+		return !reportType.kind(entry)
+	}), nil
+}
+
+// Rows filters the entries and turns the matching ones into rows. A limit above zero stops after that many rows.
+func Rows(entries []Entry, params Params, limit int) []Row {
+	rows := make([]Row, 0, len(entries))
+	for _, entry := range entries {
+		if limit > 0 && len(rows) == limit {
+			break
+		}
+		if !params.Filters.match(entry) {
+			continue
+		}
+		row := make(Row, len(params.Columns))
+		for i, column := range params.Columns {
+			row[i] = columnValue(entry, column)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func (f Filters) match(entry Entry) bool {
+	if f.Name != "" && !strings.Contains(strings.ToLower(entry.Name), f.Name) {
+		return false
+	}
+	if f.Location != "" && !containsFold(entry.CommonLocations, f.Location) {
+		return false
+	}
+	if f.Drop != "" && !containsFold(entry.Drops, f.Drop) {
+		return false
+	}
+	if f.Dlc != nil && entry.Dlc != *f.Dlc {
+		return false
+	}
+	return true
+}
+
+func containsFold(values []string, value string) bool {
+	return slices.ContainsFunc(values, func(v string) bool {
+		return strings.EqualFold(v, value)
+	})
+}
+
+func columnValue(entry Entry, column string) string {
+	switch column {
+	case "name":
+		return entry.Name
+	case "id":
+		return strconv.Itoa(entry.Id)
+	case "category":
+		return entry.Category
+	case "description":
+		return entry.Description
+	case "image":
+		return entry.Image
+	case "common_locations":
+		return strings.Join(entry.CommonLocations, ", ")
+	case "drops":
+		return strings.Join(entry.Drops, ", ")
+	case "dlc":
+		return strconv.FormatBool(entry.Dlc)
+	default:
+		return ""
+	}
+}
+
+// RowWriter encodes report rows in one of the report formats.
+type RowWriter interface {
+	WriteHeader(columns []string) error
+	WriteRow(row Row) error
+	// Close flushes buffered rows; it does not close the underlying writer.
+	Close() error
+}
+
+// NewRowWriter returns the writer for the format of the params.
+func NewRowWriter(w io.Writer, params Params) (RowWriter, error) {
+	switch params.Format {
+	case FormatCSV:
+		return &csvRowWriter{writer: csv.NewWriter(w)}, nil
+	case FormatNDJSON:
+		return &ndjsonRowWriter{encoder: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", params.Format)
+	}
+}
+
+type csvRowWriter struct {
+	writer *csv.Writer
+}
+
+func (c *csvRowWriter) WriteHeader(columns []string) error {
+	return c.writer.Write(columns)
+}
+
+func (c *csvRowWriter) WriteRow(row Row) error {
+	return c.writer.Write(row)
+}
+
+func (c *csvRowWriter) Close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+type ndjsonRowWriter struct {
+	encoder *json.Encoder
+	columns []string
+}
+
+func (n *ndjsonRowWriter) WriteHeader(columns []string) error {
+	n.columns = columns
+	return nil
+}
+
+func (n *ndjsonRowWriter) WriteRow(row Row) error {
+	return n.encoder.Encode(RowObject(n.columns, row))
+}
+
+func (n *ndjsonRowWriter) Close() error {
+	return nil
+}
+
+// RowObject maps the values of a row to their column names.
+func RowObject(columns []string, row Row) map[string]string {
+	object := make(map[string]string, len(columns))
+	for i, column := range columns {
+		object[column] = row[i]
+	}
+	return object
+}
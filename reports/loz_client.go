@@ -1,6 +1,7 @@
 package reports
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -25,7 +26,8 @@ func NewClient(httpClient HttpClient) *LozClient {
 	}
 }
 
-type Monster struct {
+// Entry is a compendium entry. Monsters, equipment and the other categories share its shape.
+type Entry struct {
 	Name            string   `json:"name"`
 	Description     string   `json:"description"`
 	Image           string   `json:"image"`
@@ -34,21 +36,30 @@ type Monster struct {
 	CommonLocations []string `json:"common_locations"`
 	Drops           []string `json:"drops"`
 	Dlc             bool     `json:"dlc"`
+	// Properties are only set for equipment.
+	Properties EntryProperties `json:"properties"`
 }
 
-type MonstersResponse struct {
-	Data []Monster `json:"data"`
+// EntryProperties are the stats of an equipment entry.
+type EntryProperties struct {
+	Attack  int `json:"attack"`
+	Defense int `json:"defense"`
 }
 
-func (c *LozClient) GetMonsters() (*MonstersResponse, error) {
-	url := c.baseURL + "/category/monsters"
-	req, err := http.NewRequest("GET", url, nil)
+type EntriesResponse struct {
+	Data []Entry `json:"data"`
+}
+
+// GetEntries fetches every entry of a compendium category for a game.
+func (c *LozClient) GetEntries(ctx context.Context, category string, game string) (*EntriesResponse, error) {
+	url := c.baseURL + "/category/" + category
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 	reqUrl := req.URL
 	queryParams := req.URL.Query()
-	queryParams.Set("game", "totk")
+	queryParams.Set("game", game)
 	reqUrl.RawQuery = queryParams.Encode()
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -59,9 +70,9 @@ func (c *LozClient) GetMonsters() (*MonstersResponse, error) {
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
-	var monstersResponse MonstersResponse
-	if err := json.NewDecoder(resp.Body).Decode(&monstersResponse); err != nil {
+	var entriesResponse EntriesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&entriesResponse); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
-	return &monstersResponse, nil
+	return &entriesResponse, nil
 }
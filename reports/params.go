@@ -0,0 +1,190 @@
+package reports
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+const (
+	GameTOTK = "totk"
+	GameBOTW = "botw"
+)
+
+// Columns lists every column a report can contain, in their default order.
+var Columns = []string{
+	"name",
+	"id",
+	"category",
+	"description",
+	"image",
+	"common_locations",
+	"drops",
+	"dlc",
+}
+
+// reportType is where the entries of a report type come from in the compendium.
+type reportType struct {
+	category string
+	// kind keeps the entries of the category that belong to the report type; nil keeps them all.
+	kind func(Entry) bool
+}
+
+// reportTypes maps report types to compendium categories. The compendium lists weapons, bows and
+// shields together as equipment and has no armor, so armor reports cover the shields.
+var reportTypes = map[string]reportType{
+	"monsters": {category: "monsters"},
+	"weapons": {category: "equipment", kind: func(entry Entry) bool {
+		return entry.Properties.Attack > 0
+	}},
+	"armor": {category: "equipment", kind: func(entry Entry) bool {
+		return entry.Properties.Attack == 0 && entry.Properties.Defense > 0
+	}},
+}
+
+// Params describes what a report contains and how it is encoded.
+type Params struct {
+	Type    string   `json:"type"`
+	Game    string   `json:"game,omitempty"`
+	Columns []string `json:"columns,omitempty"`
+	Filters Filters  `json:"filters"`
+	Format  string   `json:"format,omitempty"`
+}
+
+// Filters narrow down the entries of a report. Empty filters match every entry.
+type Filters struct {
+	// Name matches entries whose name contains the value.
+	Name string `json:"name,omitempty"`
+	// Location matches entries found at the location.
+	Location string `json:"location,omitempty"`
+	// Drop matches entries that drop the item.
+	Drop string `json:"drop,omitempty"`
+	// Dlc matches DLC or base game entries only.
+	Dlc *bool `json:"dlc,omitempty"`
+}
+
+// Normalize fills in defaults and validates the params, so equal reports get equal params.
+func (p Params) Normalize() (Params, error) {
+	if p.Type == "" {
+		return Params{}, errors.New("report type is required")
+	}
+	if _, ok := reportTypes[p.Type]; !ok {
+		return Params{}, fmt.Errorf("unknown report type %q", p.Type)
+	}
+
+	switch p.Game {
+	case "":
+		p.Game = GameTOTK
+	case GameTOTK, GameBOTW:
+	default:
+		return Params{}, fmt.Errorf("unknown game %q", p.Game)
+	}
+
+	switch p.Format {
+	case "":
+		p.Format = FormatCSV
+	case FormatCSV, FormatNDJSON:
+	default:
+		return Params{}, fmt.Errorf("unknown format %q", p.Format)
+	}
+
+	if len(p.Columns) == 0 {
+		p.Columns = slices.Clone(Columns)
+	}
+	seen := make(map[string]bool, len(p.Columns))
+	for _, column := range p.Columns {
+		if !slices.Contains(Columns, column) {
+			return Params{}, fmt.Errorf("unknown column %q", column)
+		}
+		if seen[column] {
+			return Params{}, fmt.Errorf("duplicate column %q", column)
+		}
+		seen[column] = true
+	}
+
+	p.Filters.Name = strings.ToLower(strings.TrimSpace(p.Filters.Name))
+	p.Filters.Location = strings.ToLower(strings.TrimSpace(p.Filters.Location))
+	p.Filters.Drop = strings.ToLower(strings.TrimSpace(p.Filters.Drop))
+
+	return p, nil
+}
+
+// GeneratorVersion is part of every params hash. Bump it whenever the same params produce different
+// rows, so artifacts built by an older generator are no longer reused.
+const GeneratorVersion = 1
+
+// Hash returns the canonical hash of normalized params. Reports with the same hash have the same content.
+func (p Params) Hash() (string, error) {
+	data, err := json.Marshal(struct {
+		Version int    `json:"version"`
+		Params  Params `json:"params"`
+	}{GeneratorVersion, p})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal report params: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ContentType is the content type of the encoded report before compression.
+func (p Params) ContentType() string {
+	if p.Format == FormatNDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/csv"
+}
+
+// Extension is the file extension of the compressed report.
+func (p Params) Extension() string {
+	return "." + p.Format + ".gz"
+}
+
+// MaxPresignTTL is the longest lifetime S3 accepts for a presigned URL.
+const MaxPresignTTL = 7 * 24 * time.Hour
+
+// DownloadFilename names the file of a report after its params, e.g. monsters-totk-2026-10-19-1a2b3c4d.csv.gz.
+func DownloadFilename(report db.Report, params Params) string {
+	return fmt.Sprintf("%s-%s-%s-%s%s",
+		params.Type,
+		params.Game,
+		report.CompletedAt.Time.UTC().Format(time.DateOnly),
+		report.ID.String()[:8],
+		params.Extension(),
+	)
+}
+
+// ParseParams decodes and normalizes stored params.
+func ParseParams(data []byte) (Params, error) {
+	var params Params
+	if err := json.Unmarshal(data, &params); err != nil {
+		return Params{}, fmt.Errorf("failed to decode report params: %w", err)
+	}
+	return params.Normalize()
+}
+
+// ParamsFromReport returns the normalized params of a report. Reports created before params
+// were stored only have a type.
+func ParamsFromReport(report db.Report) (Params, error) {
+	var params Params
+	if len(report.Params) > 0 {
+		if err := json.Unmarshal(report.Params, &params); err != nil {
+			return Params{}, fmt.Errorf("failed to decode report params: %w", err)
+		}
+	}
+	if params.Type == "" {
+		params.Type = report.ReportType
+	}
+	return params.Normalize()
+}
@@ -0,0 +1,152 @@
+package reports
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+)
+
+func TestNormalizeDefaults(t *testing.T) {
+	params, err := Params{Type: "monsters"}.Normalize()
+	require.NoError(t, err)
+	require.Equal(t, GameTOTK, params.Game)
+	require.Equal(t, FormatCSV, params.Format)
+	require.Equal(t, Columns, params.Columns)
+	require.Equal(t, ".csv.gz", params.Extension())
+}
+
+func TestNormalizeRejectsInvalidParams(t *testing.T) {
+	for name, params := range map[string]Params{
+		"missing":   {},
+		"type":      {Type: "recipes"},
+		"game":      {Type: "monsters", Game: "oot"},
+		"format":    {Type: "monsters", Format: "xlsx"},
+		"column":    {Type: "monsters", Columns: []string{"name", "hearts"}},
+		"duplicate": {Type: "monsters", Columns: []string{"name", "name"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := params.Normalize()
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestHashIsCanonical(t *testing.T) {
+	a, err := Params{Type: "monsters"}.Normalize()
+	require.NoError(t, err)
+	b, err := Params{Type: "monsters", Game: GameTOTK, Format: FormatCSV, Columns: Columns, Filters: Filters{Name: "  "}}.Normalize()
+	require.NoError(t, err)
+
+	hashA, err := a.Hash()
+	require.NoError(t, err)
+	hashB, err := b.Hash()
+	require.NoError(t, err)
+	require.Equal(t, hashA, hashB)
+
+	// filters are compared case-insensitively
+	c, err := Params{Type: "monsters", Filters: Filters{Location: "Hyrule Field"}}.Normalize()
+	require.NoError(t, err)
+	d, err := Params{Type: "monsters", Filters: Filters{Location: "hyrule field "}}.Normalize()
+	require.NoError(t, err)
+	hashC, err := c.Hash()
+	require.NoError(t, err)
+	hashD, err := d.Hash()
+	require.NoError(t, err)
+	require.Equal(t, hashC, hashD)
+	require.NotEqual(t, hashA, hashC)
+
+	// the column order changes the output
+	e, err := Params{Type: "monsters", Columns: []string{"id", "name"}}.Normalize()
+	require.NoError(t, err)
+	f, err := Params{Type: "monsters", Columns: []string{"name", "id"}}.Normalize()
+	require.NoError(t, err)
+	hashE, err := e.Hash()
+	require.NoError(t, err)
+	hashF, err := f.Hash()
+	require.NoError(t, err)
+	require.NotEqual(t, hashE, hashF)
+}
+
+func TestRows(t *testing.T) {
+	entries := []Entry{
+		{Name: "Bokoblin", Id: 1, CommonLocations: []string{"Hyrule Field"}, Drops: []string{"Bokoblin Horn"}},
+		{Name: "Blue Bokoblin", Id: 2, CommonLocations: []string{"Hebra Mountains"}, Drops: []string{"Bokoblin Fang"}},
+		{Name: "Lynel", Id: 3, CommonLocations: []string{"Hyrule Field"}, Dlc: true},
+	}
+
+	params, err := Params{Type: "monsters", Columns: []string{"id", "name", "drops"}}.Normalize()
+	require.NoError(t, err)
+	require.Equal(t, []Row{
+		{"1", "Bokoblin", "Bokoblin Horn"},
+		{"2", "Blue Bokoblin", "Bokoblin Fang"},
+		{"3", "Lynel", ""},
+	}, Rows(entries, params, 0))
+	require.Len(t, Rows(entries, params, 2), 2)
+
+	dlc := false
+	params.Filters = Filters{Name: "bokoblin", Location: "hyrule field", Dlc: &dlc}
+	params, err = params.Normalize()
+	require.NoError(t, err)
+	require.Equal(t, []Row{{"1", "Bokoblin", "Bokoblin Horn"}}, Rows(entries, params, 0))
+}
+
+type equipmentClient struct{}
+
+func (equipmentClient) Do(req *http.Request) (*http.Response, error) {
+	body := `{"data":[
+		{"name":"Master Sword","id":1,"category":"equipment","properties":{"attack":30,"defense":0}},
+		{"name":"Traveler's Bow","id":2,"category":"equipment","properties":{"attack":5,"defense":0}},
+		{"name":"Hylian Shield","id":3,"category":"equipment","properties":{"attack":0,"defense":90}}
+	]}`
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func TestFetchEntriesFiltersEquipmentByKind(t *testing.T) {
+	client := NewClient(equipmentClient{})
+	names := func(reportType string) []string {
+		params, err := Params{Type: reportType}.Normalize()
+		require.NoError(t, err)
+		entries, err := client.FetchEntries(context.Background(), params)
+		require.NoError(t, err)
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name)
+		}
+		return names
+	}
+
+	require.Equal(t, []string{"Master Sword", "Traveler's Bow"}, names("weapons"))
+	require.Equal(t, []string{"Hylian Shield"}, names("armor"))
+}
+
+func TestDownloadFilename(t *testing.T) {
+	id := uuid.MustParse("1a2b3c4d-0000-0000-0000-000000000000")
+	report := db.Report{
+		ID:          id,
+		CompletedAt: sql.NullTime{Time: time.Date(2026, 10, 19, 23, 0, 0, 0, time.UTC), Valid: true},
+	}
+	params, err := Params{Type: "monsters", Format: FormatNDJSON}.Normalize()
+	require.NoError(t, err)
+
+	require.Equal(t, "monsters-totk-2026-10-19-1a2b3c4d.ndjson.gz", DownloadFilename(report, params))
+}
+
+func TestObjectKey(t *testing.T) {
+	report := db.Report{ID: uuid.MustParse("1a2b3c4d-0000-0000-0000-000000000000")}
+	params, err := Params{Type: "monsters"}.Normalize()
+	require.NoError(t, err)
+
+	require.Equal(t, "/artifacts/hash/1a2b3c4d-0000-0000-0000-000000000000.csv.gz", ObjectKey(report, "hash", params))
+
+	report.OrganizationID = uuid.NullUUID{UUID: uuid.MustParse("0f0f0f0f-0000-0000-0000-000000000000"), Valid: true}
+	require.Equal(t, "/orgs/0f0f0f0f-0000-0000-0000-000000000000/artifacts/hash/1a2b3c4d-0000-0000-0000-000000000000.csv.gz",
+		ObjectKey(report, "hash", params))
+}
@@ -0,0 +1,154 @@
+package reports
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+)
+
+const (
+	PhaseFetching  = "fetching"
+	PhaseEncoding  = "encoding"
+	PhaseUploading = "uploading"
+)
+
+// progressInterval limits how often progress events are published for one build.
+const progressInterval = time.Millisecond * 500
+
+// progress tracks the counters of a single build. Events are published at most once per
+// progressInterval and the counters are saved at most once per REPORT_PROGRESS_INTERVAL,
+// except on phase changes which are always published and saved.
+type progress struct {
+	rb     *ReportBuilder
+	report db.Report
+
+	mu            sync.Mutex
+	phase         string
+	rowsFetched   int64
+	rowsTotal     int64
+	rowsWritten   int64
+	bytesWritten  int64
+	bytesUploaded int64
+	lastPublished time.Time
+	lastSaved     time.Time
+}
+
+func (rb *ReportBuilder) newProgress(report db.Report) *progress {
+	return &progress{rb: rb, report: report}
+}
+
+func (p *progress) enter(ctx context.Context, phase string) {
+	p.mu.Lock()
+	p.phase = phase
+	p.mu.Unlock()
+	p.update(ctx, true)
+}
+
+// fetched records the entries read from the API and the rows left of them once the filters applied.
+func (p *progress) fetched(ctx context.Context, entries int64, rows int64) {
+	p.mu.Lock()
+	p.rowsFetched = entries
+	p.rowsTotal = rows
+	p.mu.Unlock()
+	p.update(ctx, true)
+}
+
+func (p *progress) wrote(ctx context.Context, rows int64, bytesWritten int64) {
+	p.mu.Lock()
+	p.rowsWritten += rows
+	p.bytesWritten = bytesWritten
+	p.mu.Unlock()
+	p.update(ctx, false)
+}
+
+func (p *progress) uploaded(ctx context.Context, bytes int64) {
+	p.mu.Lock()
+	p.bytesUploaded = bytes
+	p.mu.Unlock()
+	p.update(ctx, false)
+}
+
+// flush publishes and saves the current counters regardless of the throttle.
+func (p *progress) flush(ctx context.Context) {
+	p.update(ctx, true)
+}
+
+func (p *progress) update(ctx context.Context, force bool) {
+	saveInterval := p.rb.config.REPORT_PROGRESS_INTERVAL
+	if saveInterval <= 0 {
+		saveInterval = time.Second * 2
+	}
+
+	p.mu.Lock()
+	now := time.Now()
+	publish := force || now.Sub(p.lastPublished) >= progressInterval
+	save := force || now.Sub(p.lastSaved) >= saveInterval
+	if publish {
+		p.lastPublished = now
+	}
+	if save {
+		p.lastSaved = now
+	}
+	event := ReportEvent{
+		Type:          EventProgress,
+		ReportID:      p.report.ID,
+		UserID:        p.report.UserID,
+		Phase:         p.phase,
+		RowsTotal:     p.rowsTotal,
+		RowsFetched:   p.rowsFetched,
+		RowsWritten:   p.rowsWritten,
+		BytesWritten:  p.bytesWritten,
+		BytesUploaded: p.bytesUploaded,
+	}
+	p.mu.Unlock()
+
+	if save {
+		p.save(ctx, event)
+	}
+	if publish {
+		p.rb.publish(ctx, event)
+	}
+}
+
+// save stores the counters on the report; failures are logged because progress is informational.
+func (p *progress) save(ctx context.Context, event ReportEvent) {
+	err := p.rb.store.UpdateReportProgress(ctx, db.UpdateReportProgressParams{
+		UserID:       p.report.UserID,
+		ID:           p.report.ID,
+		Phase:        sql.NullString{String: event.Phase, Valid: event.Phase != ""},
+		RowsTotal:    sql.NullInt32{Int32: int32(event.RowsTotal), Valid: event.RowsTotal > 0},
+		RowsWritten:  int32(event.RowsWritten),
+		BytesWritten: event.BytesWritten,
+	})
+	if err != nil && ctx.Err() == nil {
+		p.rb.logger.Warnw("failed to save report progress", "report_id", p.report.ID, "error", err)
+	}
+}
+
+// progressReader reports how far the upload has read through its body. The reader is not embedded,
+// so its WriteTo can't be used to copy the body without going through Read.
+type progressReader struct {
+	reader   *bytes.Reader
+	ctx      context.Context
+	size     int64
+	progress *progress
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.reader.Read(b)
+	r.progress.uploaded(r.ctx, r.size-int64(r.reader.Len()))
+	return n, err
+}
+
+// Seek lets the SDK measure and rewind the body, e.g. to retry the upload.
+func (r *progressReader) Seek(offset int64, whence int) (int64, error) {
+	return r.reader.Seek(offset, whence)
+}
+
+func (r *progressReader) Len() int {
+	return r.reader.Len()
+}
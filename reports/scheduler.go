@@ -0,0 +1,184 @@
+package reports
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/robfig/cron/v3"
+	"github.com/trenchesdeveloper/csv-reporter/config"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"go.uber.org/zap"
+)
+
+// schedulerLockID is the Postgres advisory lock held by the replica that runs due schedules.
+const schedulerLockID int64 = 0x7363686564756c65
+
+// schedulerBatchSize limits how many schedules are run in a single transaction.
+const schedulerBatchSize = 100
+
+// requeueBatchSize limits how many stale requested reports are enqueued again per poll.
+const requeueBatchSize = 100
+
+// cronParser accepts standard five field expressions and descriptors such as @daily.
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// NextRun returns the first time after the given time that matches the cron expression in the timezone.
+func NextRun(expression string, timezone string, after time.Time) (time.Time, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+	}
+	schedule, err := cronParser.Parse(expression)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid cron expression %q: %w", expression, err)
+	}
+	next := schedule.Next(after.In(location))
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("cron expression %q never runs", expression)
+	}
+	return next.UTC(), nil
+}
+
+// Scheduler creates and enqueues the reports of due schedules.
+type Scheduler struct {
+	store     db.Store
+	sqsClient *sqs.Client
+	config    *config.AppConfig
+	logger    *zap.SugaredLogger
+}
+
+func NewScheduler(store db.Store, sqsClient *sqs.Client, config *config.AppConfig, logger *zap.SugaredLogger) *Scheduler {
+	return &Scheduler{
+		store:     store,
+		sqsClient: sqsClient,
+		config:    config,
+		logger:    logger,
+	}
+}
+
+// Start runs due schedules until the context is done.
+func (s *Scheduler) Start(ctx context.Context) {
+	interval := s.config.SCHEDULER_POLL_INTERVAL
+	if interval <= 0 {
+		interval = time.Second * 30
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.runDue(ctx)
+		s.requeueStale(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) runDue(ctx context.Context) {
+	now := time.Now()
+	result, err := s.store.RunDueSchedulesTx(ctx, db.RunDueSchedulesTxParams{
+		LockID: schedulerLockID,
+		Now:    now,
+		Limit:  schedulerBatchSize,
+		Plan: func(schedule db.ReportSchedule) (db.CreateReportParams, time.Time, error) {
+			return planScheduledReport(schedule, now)
+		},
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Errorw("failed to run due schedules", "error", err)
+		}
+		return
+	}
+	if !result.Locked {
+		return
+	}
+
+	for scheduleID, err := range result.Errors {
+		s.logger.Errorw("failed to run schedule", "schedule_id", scheduleID, "error", err)
+	}
+
+	// the reports are committed, so a failed enqueue leaves them requested until requeueStale picks them up
+	for _, report := range result.Reports {
+		err := EnqueueReport(ctx, s.sqsClient, s.config.SQS_QUEUE, SQSMessage{
+			UserID:   report.UserID,
+			ReportID: report.ID,
+		})
+		if err != nil {
+			s.logger.Errorw("failed to enqueue scheduled report", "report_id", report.ID, "error", err)
+			continue
+		}
+		s.logger.Infow("enqueued scheduled report", "report_id", report.ID)
+	}
+}
+
+// requeueStale enqueues reports again that are still requested long after they were last enqueued,
+// such as reports whose enqueue failed after they were created. Claiming them moves their
+// enqueued_at forward, so each one is enqueued at most once per REPORT_REQUEUE_AFTER. A report that
+// was only slow to be picked up gets a second message; the builder claims a report before it builds
+// it, so only one of them builds it.
+func (s *Scheduler) requeueStale(ctx context.Context) {
+	after := s.config.REPORT_REQUEUE_AFTER
+	if after <= 0 {
+		after = time.Minute * 15
+	}
+	reports, err := s.store.ClaimStaleRequestedReports(ctx, db.ClaimStaleRequestedReportsParams{
+		EnqueuedBefore: time.Now().Add(-after),
+		Limit:          requeueBatchSize,
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Errorw("failed to claim stale requested reports", "error", err)
+		}
+		return
+	}
+
+	for _, report := range reports {
+		err := EnqueueReport(ctx, s.sqsClient, s.config.SQS_QUEUE, SQSMessage{
+			UserID:   report.UserID,
+			ReportID: report.ID,
+		})
+		if err != nil {
+			s.logger.Errorw("failed to enqueue stale report", "report_id", report.ID, "error", err)
+			continue
+		}
+		s.logger.Warnw("enqueued stale report again", "report_id", report.ID, "created_at", report.CreatedAt)
+	}
+}
+
+// planScheduledReport returns the report a due schedule creates and the time it runs next.
+func planScheduledReport(schedule db.ReportSchedule, now time.Time) (db.CreateReportParams, time.Time, error) {
+	params, err := ParseParams(schedule.Params)
+	if err != nil {
+		return db.CreateReportParams{}, time.Time{}, err
+	}
+	paramsHash, err := params.Hash()
+	if err != nil {
+		return db.CreateReportParams{}, time.Time{}, err
+	}
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return db.CreateReportParams{}, time.Time{}, fmt.Errorf("failed to marshal report params: %w", err)
+	}
+
+	// missed runs are not caught up, the schedule continues from now
+	nextRunAt, err := NextRun(schedule.CronExpression, schedule.Timezone, now)
+	if err != nil {
+		return db.CreateReportParams{}, time.Time{}, err
+	}
+
+	return db.CreateReportParams{
+		UserID:         schedule.UserID,
+		ReportType:     params.Type,
+		Params:         paramsJSON,
+		ParamsHash:     sql.NullString{String: paramsHash, Valid: true},
+		OrganizationID: schedule.OrganizationID,
+	}, nextRunAt, nil
+}
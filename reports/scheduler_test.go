@@ -0,0 +1,64 @@
+package reports
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+)
+
+func TestNextRun(t *testing.T) {
+	after := time.Date(2026, 3, 10, 12, 30, 0, 0, time.UTC)
+
+	next, err := NextRun("0 7 * * *", "UTC", after)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2026, 3, 11, 7, 0, 0, 0, time.UTC), next)
+
+	// the expression is evaluated in the schedule's timezone
+	next, err = NextRun("0 7 * * *", "America/New_York", after)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2026, 3, 11, 11, 0, 0, 0, time.UTC), next)
+
+	next, err = NextRun("@hourly", "UTC", after)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2026, 3, 10, 13, 0, 0, 0, time.UTC), next)
+}
+
+func TestNextRunRejectsInvalidSchedules(t *testing.T) {
+	_, err := NextRun("every morning", "UTC", time.Now())
+	require.Error(t, err)
+
+	_, err = NextRun("0 7 * * *", "Mars/Olympus_Mons", time.Now())
+	require.Error(t, err)
+}
+
+func TestPlanScheduledReport(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 30, 0, 0, time.UTC)
+	schedule := db.ReportSchedule{
+		ID:             uuid.New(),
+		UserID:         uuid.New(),
+		CronExpression: "0 7 * * *",
+		Timezone:       "UTC",
+		Params:         []byte(`{"type":"monsters","format":"ndjson"}`),
+		OrganizationID: uuid.NullUUID{UUID: uuid.New(), Valid: true},
+	}
+
+	report, nextRunAt, err := planScheduledReport(schedule, now)
+	require.NoError(t, err)
+	require.Equal(t, schedule.UserID, report.UserID)
+	require.Equal(t, schedule.OrganizationID, report.OrganizationID)
+	require.Equal(t, "monsters", report.ReportType)
+	require.True(t, report.ParamsHash.Valid)
+	require.Equal(t, time.Date(2026, 3, 11, 7, 0, 0, 0, time.UTC), nextRunAt)
+
+	params, err := ParseParams(report.Params)
+	require.NoError(t, err)
+	require.Equal(t, FormatNDJSON, params.Format)
+	require.Equal(t, Columns, params.Columns)
+
+	schedule.Params = []byte(`{"type":"recipes"}`)
+	_, _, err = planScheduledReport(schedule, now)
+	require.Error(t, err)
+}
@@ -1,9 +1,82 @@
 package reports
 
-import "github.com/google/uuid"
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/google/uuid"
+)
 
 type SQSMessage struct {
 	ReportID uuid.UUID `json:"report_id"`
 	UserID   uuid.UUID `json:"user_id"`
-}
\ No newline at end of file
+}
+
+// EnqueueReport sends the message that asks a worker to build a report.
+func EnqueueReport(ctx context.Context, sqsClient *sqs.Client, queueName string, message SQSMessage) error {
+	queueUrl, err := sqsClient.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{
+		QueueName: aws.String(queueName),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get queue URL: %w", err)
+	}
+
+	bytes, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	_, err = sqsClient.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    queueUrl.QueueUrl,
+		MessageBody: aws.String(string(bytes)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
+
+// QueueDepth holds the approximate message counts SQS reports for a queue.
+type QueueDepth struct {
+	Visible  int64 `json:"visible"`
+	InFlight int64 `json:"in_flight"`
+	Delayed  int64 `json:"delayed"`
+}
+
+// GetQueueDepth reads the approximate number of waiting, in-flight and delayed messages.
+func GetQueueDepth(ctx context.Context, sqsClient *sqs.Client, queueName string) (QueueDepth, error) {
+	queueUrl, err := sqsClient.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{
+		QueueName: aws.String(queueName),
+	})
+	if err != nil {
+		return QueueDepth{}, fmt.Errorf("failed to get queue URL: %w", err)
+	}
+
+	attributes, err := sqsClient.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl: queueUrl.QueueUrl,
+		AttributeNames: []types.QueueAttributeName{
+			types.QueueAttributeNameApproximateNumberOfMessages,
+			types.QueueAttributeNameApproximateNumberOfMessagesNotVisible,
+			types.QueueAttributeNameApproximateNumberOfMessagesDelayed,
+		},
+	})
+	if err != nil {
+		return QueueDepth{}, fmt.Errorf("failed to get queue attributes: %w", err)
+	}
+
+	count := func(name types.QueueAttributeName) int64 {
+		value, _ := strconv.ParseInt(attributes.Attributes[string(name)], 10, 64)
+		return value
+	}
+	return QueueDepth{
+		Visible:  count(types.QueueAttributeNameApproximateNumberOfMessages),
+		InFlight: count(types.QueueAttributeNameApproximateNumberOfMessagesNotVisible),
+		Delayed:  count(types.QueueAttributeNameApproximateNumberOfMessagesDelayed),
+	}, nil
+}
@@ -0,0 +1,310 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trenchesdeveloper/csv-reporter/config"
+	db "github.com/trenchesdeveloper/csv-reporter/db/sqlc"
+	"github.com/trenchesdeveloper/csv-reporter/helpers"
+	"go.uber.org/zap"
+)
+
+const (
+	EventReportCompleted = "report.completed"
+	EventReportFailed    = "report.failed"
+
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+
+	maxBackoff      = time.Hour
+	claimBatchSize  = 10
+	deliveryTimeout = 10 * time.Second
+	// claimLease covers a whole batch of deliveries that all time out, so no delivery is
+	// claimed and sent again by another worker while this one is still working through the batch
+	claimLease = claimBatchSize*deliveryTimeout + time.Minute
+)
+
+type HttpClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// Event is the JSON body POSTed to webhook endpoints
+type Event struct {
+	ID        uuid.UUID  `json:"id"`
+	Type      string     `json:"type"`
+	CreatedAt time.Time  `json:"created_at"`
+	Data      ReportData `json:"data"`
+}
+
+type ReportData struct {
+	ID           uuid.UUID `json:"id"`
+	ReportType   string    `json:"report_type"`
+	ErrorMessage string    `json:"error_message,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	CompletedAt  time.Time `json:"completed_at,omitempty"`
+	FailedAt     time.Time `json:"failed_at,omitempty"`
+}
+
+// Dispatcher records webhook deliveries for finished reports and delivers them,
+// retrying failed deliveries with exponential backoff. Its HTTP client should come from
+// helpers.NewGuardedHTTPClient, since webhook URLs are supplied by users.
+type Dispatcher struct {
+	store      db.Store
+	httpClient HttpClient
+	config     *config.AppConfig
+	logger     *zap.SugaredLogger
+	wake       chan struct{}
+}
+
+func NewDispatcher(store db.Store, httpClient HttpClient, config *config.AppConfig, logger *zap.SugaredLogger) *Dispatcher {
+	return &Dispatcher{
+		store:      store,
+		httpClient: httpClient,
+		config:     config,
+		logger:     logger,
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// ReportFinished queues a completed or failed event for every webhook endpoint
+// of the report owner and for the report's callback URL.
+func (d *Dispatcher) ReportFinished(ctx context.Context, report db.Report) error {
+	var eventType string
+	switch {
+	case report.CompletedAt.Valid:
+		eventType = EventReportCompleted
+	case report.FailedAt.Valid:
+		eventType = EventReportFailed
+	default:
+		return nil
+	}
+
+	payload, err := json.Marshal(Event{
+		ID:        uuid.New(),
+		Type:      eventType,
+		CreatedAt: time.Now(),
+		Data: ReportData{
+			ID:           report.ID,
+			ReportType:   report.ReportType,
+			ErrorMessage: report.ErrorMessage.String,
+			CreatedAt:    report.CreatedAt,
+			CompletedAt:  report.CompletedAt.Time,
+			FailedAt:     report.FailedAt.Time,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook event: %w", err)
+	}
+
+	endpoints, err := d.store.ListWebhookEndpoints(ctx, report.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to list webhook endpoints: %w", err)
+	}
+
+	params := make([]db.CreateWebhookDeliveryParams, 0, len(endpoints)+1)
+	for _, endpoint := range endpoints {
+		params = append(params, db.CreateWebhookDeliveryParams{
+			UserID:     report.UserID,
+			EndpointID: uuid.NullUUID{UUID: endpoint.ID, Valid: true},
+			ReportID:   report.ID,
+			Event:      eventType,
+			Url:        endpoint.Url,
+			Secret:     endpoint.Secret,
+			Payload:    payload,
+		})
+	}
+	if report.CallbackUrl.Valid {
+		params = append(params, db.CreateWebhookDeliveryParams{
+			UserID:   report.UserID,
+			ReportID: report.ID,
+			Event:    eventType,
+			Url:      report.CallbackUrl.String,
+			Secret:   report.CallbackSecret.String,
+			Payload:  payload,
+		})
+	}
+
+	for _, arg := range params {
+		if _, err := d.store.CreateWebhookDelivery(ctx, arg); err != nil {
+			return fmt.Errorf("failed to create webhook delivery: %w", err)
+		}
+	}
+
+	if len(params) > 0 {
+		d.Wake()
+	}
+	return nil
+}
+
+// Wake makes the dispatcher look for due deliveries without waiting for the next poll.
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start delivers due webhooks until the context is cancelled.
+func (d *Dispatcher) Start(ctx context.Context) {
+	interval := d.config.WEBHOOK_POLL_INTERVAL
+	if interval <= 0 {
+		interval = time.Second * 5
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	d.logger.Info("webhook dispatcher started")
+	for {
+		d.dispatchDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			d.logger.Info("webhook dispatcher stopping due to context cancellation")
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+func (d *Dispatcher) dispatchDue(ctx context.Context) {
+	for {
+		// claimed deliveries are leased, so other workers skip them while we deliver
+		deliveries, err := d.store.ClaimDueWebhookDeliveries(ctx, db.ClaimDueWebhookDeliveriesParams{
+			LeaseUntil: time.Now().Add(claimLease),
+			Limit:      claimBatchSize,
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				d.logger.Errorw("failed to claim webhook deliveries", "error", err)
+			}
+			return
+		}
+
+		for _, delivery := range deliveries {
+			d.deliver(ctx, delivery)
+		}
+
+		if len(deliveries) < claimBatchSize {
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery db.WebhookDelivery) {
+	start := time.Now()
+	sendCtx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	statusCode, sendErr := d.send(sendCtx, delivery)
+	cancel()
+	duration := time.Since(start)
+	attempt := int(delivery.AttemptCount) + 1
+
+	d.logger.Infow("webhook delivery attempt",
+		"delivery_id", delivery.ID,
+		"report_id", delivery.ReportID,
+		"event", delivery.Event,
+		"attempt", attempt,
+		"status_code", statusCode,
+		"duration", duration,
+		"error", sendErr,
+	)
+
+	lastStatusCode := sql.NullInt32{Int32: int32(statusCode), Valid: statusCode != 0}
+	lastError := sql.NullString{Valid: false}
+	if sendErr != nil {
+		lastError = sql.NullString{String: sendErr.Error(), Valid: true}
+	}
+
+	_, err := d.store.CreateWebhookDeliveryAttempt(ctx, db.CreateWebhookDeliveryAttemptParams{
+		DeliveryID: delivery.ID,
+		StatusCode: lastStatusCode,
+		Error:      lastError,
+		DurationMs: int32(duration.Milliseconds()),
+	})
+	if err != nil {
+		d.logger.Errorw("failed to record webhook delivery attempt", "delivery_id", delivery.ID, "error", err)
+	}
+
+	now := time.Now()
+	result := db.UpdateWebhookDeliveryResultParams{
+		ID:             delivery.ID,
+		Status:         StatusDelivered,
+		LastStatusCode: lastStatusCode,
+		LastError:      lastError,
+		NextAttemptAt:  now,
+		DeliveredAt:    sql.NullTime{Time: now, Valid: true},
+	}
+	if sendErr != nil {
+		result.DeliveredAt = sql.NullTime{Valid: false}
+		if attempt >= d.maxAttempts() {
+			result.Status = StatusFailed
+		} else {
+			result.Status = StatusPending
+			result.NextAttemptAt = now.Add(backoff(d.config.WEBHOOK_RETRY_BASE_DELAY, attempt))
+		}
+	}
+
+	if _, err := d.store.UpdateWebhookDeliveryResult(ctx, result); err != nil {
+		d.logger.Errorw("failed to update webhook delivery", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery db.WebhookDelivery) (int, error) {
+	// the API validates URLs when they are registered; deliveries queued before that are checked here
+	if err := helpers.ValidateOutboundURL(delivery.Url, d.config.ENVIRONMENT != "development"); err != nil {
+		return 0, fmt.Errorf("invalid webhook URL: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.ID.String())
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, time.Now(), delivery.Payload))
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+	// drain a bounded part of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) maxAttempts() int {
+	if d.config.WEBHOOK_MAX_ATTEMPTS <= 0 {
+		return 8
+	}
+	return d.config.WEBHOOK_MAX_ATTEMPTS
+}
+
+// backoff returns the delay before the next attempt, doubling from base up to maxBackoff
+func backoff(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		base = time.Second * 30
+	}
+	delay := base
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
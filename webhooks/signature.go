@@ -0,0 +1,69 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Sign returns the signature header value for a payload, in the form
+// "t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<payload>">".
+func Sign(secret string, timestamp time.Time, payload []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + ts + ",v1=" + computeSignature(secret, ts, payload)
+}
+
+// Verify checks a signature header produced by Sign and rejects signatures
+// older than tolerance, which protects receivers against replayed deliveries.
+func Verify(secret string, header string, payload []byte, tolerance time.Duration) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sig = value
+		}
+	}
+	if ts == "" || sig == "" {
+		return ErrInvalidSignature
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if tolerance > 0 && time.Since(time.Unix(unix, 0)) > tolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+
+	if !hmac.Equal([]byte(sig), []byte(computeSignature(secret, ts, payload))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func computeSignature(secret string, ts string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
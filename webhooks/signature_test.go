@@ -0,0 +1,47 @@
+package webhooks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	payload := []byte(`{"type":"report.completed"}`)
+	header := Sign("whsec_secret", time.Now(), payload)
+	assert.Contains(t, header, "t=")
+	assert.Contains(t, header, ",v1=")
+
+	require.NoError(t, Verify("whsec_secret", header, payload, time.Minute*5))
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	payload := []byte(`{"type":"report.completed"}`)
+	header := Sign("whsec_secret", time.Now(), payload)
+
+	// wrong secret
+	require.ErrorIs(t, Verify("whsec_other", header, payload, time.Minute*5), ErrInvalidSignature)
+	// modified payload
+	require.ErrorIs(t, Verify("whsec_secret", header, []byte(`{"type":"report.failed"}`), time.Minute*5), ErrInvalidSignature)
+	// malformed header
+	require.ErrorIs(t, Verify("whsec_secret", "garbage", payload, time.Minute*5), ErrInvalidSignature)
+}
+
+func TestVerifyRejectsOldSignatures(t *testing.T) {
+	payload := []byte(`{"type":"report.completed"}`)
+	header := Sign("whsec_secret", time.Now().Add(-time.Hour), payload)
+
+	require.ErrorIs(t, Verify("whsec_secret", header, payload, time.Minute*5), ErrInvalidSignature)
+	// a zero tolerance disables the timestamp check
+	require.NoError(t, Verify("whsec_secret", header, payload, 0))
+}
+
+func TestBackoff(t *testing.T) {
+	base := time.Second * 30
+	assert.Equal(t, base, backoff(base, 1))
+	assert.Equal(t, base*2, backoff(base, 2))
+	assert.Equal(t, base*8, backoff(base, 4))
+	assert.Equal(t, maxBackoff, backoff(base, 20))
+}